@@ -0,0 +1,230 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// AnalysisVersion describes one stored version of a call's analysis
+type AnalysisVersion struct {
+	Version       int       `json:"version"`
+	PromptVariant *string   `json:"promptVariant"`
+	ProcessedAt   string    `json:"processedAt,omitempty"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// AnswerDiff compares a question's answers in two versions; a nil answer wasn't given in that version
+type AnswerDiff struct {
+	QuestionID string  `json:"questionId"`
+	From       *string `json:"from"`
+	To         *string `json:"to"`
+	Changed    bool    `json:"changed" doc:"Whether the answers differ, ignoring case, surrounding space and a trailing period"`
+}
+
+// AnalysisDiff is the GET /analysis/{id}/diff response
+type AnalysisDiff struct {
+	CallLogsID           string          `json:"call_logsId"`
+	From                 AnalysisVersion `json:"from"`
+	To                   AnalysisVersion `json:"to"`
+	Answers              []AnswerDiff    `json:"answers"`
+	ChangedAnswers       int             `json:"changedAnswers"`
+	TranscriptSimilarity float64         `json:"transcriptSimilarity" doc:"1 minus the word-level edit distance between the transcriptions over the longer one's word count"`
+}
+
+// storedAnalysis is the part of a stored callAnalysis the diff compares
+type storedAnalysis struct {
+	Transcription string            `json:"transcription"`
+	Answers       map[string]string `json:"answers"`
+	ProcessedAt   string            `json:"processed_at"`
+}
+
+// handleAnalysisDiff compares two stored versions of a call's analysis. to defaults to the latest
+// version and from to the one before it; versions may be given as "2" or "v2".
+//
+//	GET /analysis/{id}/diff?from=v1&to=v2
+func handleAnalysisDiff(schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	callLogsID := request.PathParameters["id"]
+
+	from, err := parseAnalysisVersion(request.QueryStringParameters["from"])
+	if err != nil {
+		return errorResponse(400, "from %v", err)
+	}
+	to, err := parseAnalysisVersion(request.QueryStringParameters["to"])
+	if err != nil {
+		return errorResponse(400, "to %v", err)
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	if to == 0 {
+		query := fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s WHERE "call_logsId" = $1`, schema.Table("call_analysis_versions"))
+		if err := db.QueryRow(query, callLogsID).Scan(&to); err != nil {
+			log.Printf("❌ Analysis version query error: %v", err)
+			return errorResponse(500, "Error fetching analysis versions")
+		}
+		if to == 0 {
+			return errorResponse(404, "No analysis versions found for call_logsId: %s", callLogsID)
+		}
+	}
+	if from == 0 {
+		from = to - 1
+	}
+	if from < 1 {
+		return errorResponse(404, "Call %s has only one analysis version", callLogsID)
+	}
+
+	fromVersion, fromAnalysis, err := loadAnalysisVersion(db, schema, callLogsID, from)
+	if err == nil {
+		var toVersion AnalysisVersion
+		var toAnalysis storedAnalysis
+		toVersion, toAnalysis, err = loadAnalysisVersion(db, schema, callLogsID, to)
+		if err == nil {
+			return jsonResponse(200, diffAnalyses(callLogsID, fromVersion, fromAnalysis, toVersion, toAnalysis))
+		}
+	}
+	if err == sql.ErrNoRows {
+		return errorResponse(404, "Analysis version not found for call_logsId: %s", callLogsID)
+	}
+	log.Printf("❌ Analysis version query error: %v", err)
+	return errorResponse(500, "Error fetching analysis versions")
+}
+
+// parseAnalysisVersion parses a version parameter ("2" or "v2"); 0 means it wasn't given
+func parseAnalysisVersion(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(raw), "v"))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("must be a version number such as v1")
+	}
+	return version, nil
+}
+
+// loadAnalysisVersion reads one stored version of a call's analysis
+func loadAnalysisVersion(db *sql.DB, schema SchemaConfig, callLogsID string, version int) (AnalysisVersion, storedAnalysis, error) {
+	query := fmt.Sprintf(`
+		SELECT version, "promptVariant", "createdAt", analysis
+		FROM %s
+		WHERE "call_logsId" = $1 AND version = $2
+	`, schema.Table("call_analysis_versions"))
+
+	var info AnalysisVersion
+	var analysisJSON []byte
+	if err := db.QueryRow(query, callLogsID, version).Scan(&info.Version, &info.PromptVariant, &info.CreatedAt, &analysisJSON); err != nil {
+		return info, storedAnalysis{}, err
+	}
+
+	var analysis storedAnalysis
+	if err := json.Unmarshal(analysisJSON, &analysis); err != nil {
+		return info, analysis, fmt.Errorf("error parsing analysis version %d: %v", version, err)
+	}
+	info.ProcessedAt = analysis.ProcessedAt
+	return info, analysis, nil
+}
+
+// diffAnalyses compares the answers and transcriptions of two analysis versions
+func diffAnalyses(callLogsID string, fromVersion AnalysisVersion, from storedAnalysis, toVersion AnalysisVersion, to storedAnalysis) AnalysisDiff {
+	diff := AnalysisDiff{
+		CallLogsID:           callLogsID,
+		From:                 fromVersion,
+		To:                   toVersion,
+		Answers:              []AnswerDiff{},
+		TranscriptSimilarity: transcriptSimilarity(from.Transcription, to.Transcription),
+	}
+
+	questionIDs := map[string]bool{}
+	for questionID := range from.Answers {
+		questionIDs[questionID] = true
+	}
+	for questionID := range to.Answers {
+		questionIDs[questionID] = true
+	}
+
+	for questionID := range questionIDs {
+		answer := AnswerDiff{QuestionID: questionID}
+		if value, ok := from.Answers[questionID]; ok {
+			answer.From = &value
+		}
+		if value, ok := to.Answers[questionID]; ok {
+			answer.To = &value
+		}
+		answer.Changed = answer.From == nil || answer.To == nil ||
+			normalizeComparedAnswer(*answer.From) != normalizeComparedAnswer(*answer.To)
+		if answer.Changed {
+			diff.ChangedAnswers++
+		}
+		diff.Answers = append(diff.Answers, answer)
+	}
+
+	// Changed answers first, then by question ID
+	sort.Slice(diff.Answers, func(i, j int) bool {
+		if diff.Answers[i].Changed != diff.Answers[j].Changed {
+			return diff.Answers[i].Changed
+		}
+		return diff.Answers[i].QuestionID < diff.Answers[j].QuestionID
+	})
+	return diff
+}
+
+// transcriptSimilarity scores two transcriptions from 0 to 1 by their word-level edit distance,
+// ignoring case and punctuation
+func transcriptSimilarity(a, b string) float64 {
+	wordsA, wordsB := similarityWords(a), similarityWords(b)
+	longest := len(wordsA)
+	if len(wordsB) > longest {
+		longest = len(wordsB)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	previous := make([]int, len(wordsB)+1)
+	current := make([]int, len(wordsB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(wordsA); i++ {
+		current[0] = i
+		for j := 1; j <= len(wordsB); j++ {
+			substitution := previous[j-1]
+			if wordsA[i-1] != wordsB[j-1] {
+				substitution++
+			}
+			current[j] = minInt(substitution, minInt(previous[j]+1, current[j-1]+1))
+		}
+		previous, current = current, previous
+	}
+
+	return roundTo(1-float64(previous[len(wordsB)])/float64(longest), 4)
+}
+
+// similarityWords splits a transcription into lowercase words; marks are kept so Devanagari vowel
+// signs stay part of their words
+func similarityWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+	})
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
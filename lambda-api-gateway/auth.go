@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// clientSecretsTTL is how long client credentials are cached across warm invocations
+	clientSecretsTTL = 5 * time.Minute
+	// signatureMaxSkew is the allowed difference between X-Timestamp and the current time
+	signatureMaxSkew = 5 * time.Minute
+)
+
+// ClientCredentials represents a single API client's credentials in the Secrets Manager secret.
+// A client may use a static API key, HMAC request signing, or both.
+type ClientCredentials struct {
+	APIKey     string `json:"api_key,omitempty"`
+	HMACSecret string `json:"hmac_secret,omitempty"`
+}
+
+// errAuthUnavailable marks failures to load client credentials, as opposed to invalid credentials
+var errAuthUnavailable = errors.New("authentication unavailable")
+
+var (
+	clientSecretsMu       sync.Mutex
+	clientSecrets         map[string]ClientCredentials
+	clientSecretsLoadedAt time.Time
+)
+
+// authenticateRequest validates the request's credentials and returns the client ID.
+//
+// Every request must carry X-Client-Id plus either:
+//   - X-Api-Key: the client's API key, or
+//   - X-Timestamp (unix seconds) and X-Signature: hex(HMAC-SHA256(hmac_secret, timestamp + "\n" + method + "\n" + path + "\n" + query + "\n" + body))
+//
+// query is the canonical query string (see canonicalQueryString), so signed query parameters can't
+// be altered or added.
+//
+// Client credentials are read from the Secrets Manager secret named by API_CLIENTS_SECRET_ID,
+// a JSON object keyed by client ID.
+func authenticateRequest(request events.APIGatewayProxyRequest) (string, error) {
+	clientID := headerValue(request.Headers, "X-Client-Id")
+	if clientID == "" {
+		return "", fmt.Errorf("missing X-Client-Id header")
+	}
+
+	clients, err := loadClientSecrets()
+	if err != nil {
+		return "", err
+	}
+
+	creds, ok := clients[clientID]
+	if !ok {
+		return "", fmt.Errorf("unknown client")
+	}
+
+	if apiKey := headerValue(request.Headers, "X-Api-Key"); apiKey != "" {
+		if creds.APIKey == "" || !hmac.Equal([]byte(apiKey), []byte(creds.APIKey)) {
+			return "", fmt.Errorf("invalid API key")
+		}
+		return clientID, nil
+	}
+
+	signature := headerValue(request.Headers, "X-Signature")
+	timestamp := headerValue(request.Headers, "X-Timestamp")
+	if signature == "" || timestamp == "" {
+		return "", fmt.Errorf("missing X-Api-Key or X-Signature/X-Timestamp headers")
+	}
+	if creds.HMACSecret == "" {
+		return "", fmt.Errorf("client is not configured for signed requests")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid X-Timestamp")
+	}
+	if skew := time.Since(time.Unix(unix, 0)); math.Abs(skew.Seconds()) > signatureMaxSkew.Seconds() {
+		return "", fmt.Errorf("request timestamp outside the allowed window")
+	}
+
+	mac := hmac.New(sha256.New, []byte(creds.HMACSecret))
+	mac.Write([]byte(timestamp + "\n" + request.HTTPMethod + "\n" + request.Path + "\n" + canonicalQueryString(request) + "\n" + request.Body))
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		return "", fmt.Errorf("invalid signature")
+	}
+
+	return clientID, nil
+}
+
+// canonicalQueryString returns the request's query parameters as signed: name=value pairs with both
+// percent-encoded, sorted as strings and joined with "&"; "" without parameters
+func canonicalQueryString(request events.APIGatewayProxyRequest) string {
+	params := request.MultiValueQueryStringParameters
+	if len(params) == 0 {
+		params = make(map[string][]string, len(request.QueryStringParameters))
+		for name, value := range request.QueryStringParameters {
+			params[name] = []string{value}
+		}
+	}
+
+	var pairs []string
+	for name, values := range params {
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// authDisabled reports whether authentication is switched off (AUTH_DISABLED=true, for local testing only)
+func authDisabled() bool {
+	return os.Getenv("AUTH_DISABLED") == "true"
+}
+
+// loadClientSecrets returns the per-client credentials, refreshing them from Secrets Manager after clientSecretsTTL
+func loadClientSecrets() (map[string]ClientCredentials, error) {
+	clientSecretsMu.Lock()
+	defer clientSecretsMu.Unlock()
+
+	if clientSecrets != nil && time.Since(clientSecretsLoadedAt) < clientSecretsTTL {
+		return clientSecrets, nil
+	}
+
+	secretID := os.Getenv("API_CLIENTS_SECRET_ID")
+	if secretID == "" {
+		return nil, fmt.Errorf("%w: API_CLIENTS_SECRET_ID is not configured", errAuthUnavailable)
+	}
+
+	secretString, err := getSecretString(secretID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error loading client credentials: %v", errAuthUnavailable, err)
+	}
+
+	var clients map[string]ClientCredentials
+	if err := json.Unmarshal([]byte(secretString), &clients); err != nil {
+		return nil, fmt.Errorf("%w: error parsing client credentials: %v", errAuthUnavailable, err)
+	}
+
+	clientSecrets = clients
+	clientSecretsLoadedAt = time.Now()
+	return clientSecrets, nil
+}
+
+// redactHeaders returns a copy of the headers that is safe to log
+func redactHeaders(headers map[string]string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for key, value := range headers {
+		switch strings.ToLower(key) {
+		case "x-api-key", "x-signature", "authorization":
+			value = "[REDACTED]"
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// headerValue returns a header value regardless of the case API Gateway delivered it in
+func headerValue(headers map[string]string, name string) string {
+	if value, ok := headers[name]; ok {
+		return value
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWS requests are signed with Signature Version 4 using the credentials the Lambda runtime
+// exposes in the environment (AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN).
+
+const awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+
+// awsCredentials represents AWS credentials used to sign requests
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// loadAWSCredentials reads AWS credentials from the environment
+func loadAWSCredentials() (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("AWS credentials are not configured")
+	}
+	return creds, nil
+}
+
+// awsRegion returns the AWS region the function runs in
+func awsRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// signAWSRequest adds SigV4 authentication headers to the request. body must be the exact request payload.
+func signAWSRequest(req *http.Request, body []byte, service, region string, creds awsCredentials, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	// Sign every header we set plus the host
+	headerNames := []string{"host"}
+	for name := range req.Header {
+		headerNames = append(headerNames, strings.ToLower(name))
+	}
+	sort.Strings(headerNames)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		value := req.Host
+		if name != "host" {
+			value = strings.Join(req.Header.Values(name), ",")
+		} else if value == "" {
+			value = req.URL.Host
+		}
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, strings.TrimSpace(value))
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalURI(req.URL),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := awsCredentialScope(now, region, service)
+	signature := awsSignature(creds, now, region, service, amzDate, scope, canonicalRequest)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// presignAWSURL returns a SigV4 query-string signed URL valid for the given duration.
+// payloadHash is "UNSIGNED-PAYLOAD" for S3 or the hash of the empty payload for other services.
+func presignAWSURL(method, rawURL, service, region string, creds awsCredentials, expires time.Duration, payloadHash string, now time.Time) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %v", err)
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := awsCredentialScope(now, region, service)
+
+	query := u.Query()
+	query.Set("X-Amz-Algorithm", awsSigningAlgorithm)
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", fmt.Sprintf("%d", int(expires.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if creds.SessionToken != "" {
+		query.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		awsCanonicalURI(u),
+		awsCanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		payloadHash,
+	}, "\n")
+
+	signature := awsSignature(creds, now, region, service, amzDate, scope, canonicalRequest)
+	query.Set("X-Amz-Signature", signature)
+
+	u.RawQuery = awsCanonicalQuery(query)
+	return u.String(), nil
+}
+
+// awsCredentialScope returns the "date/region/service/aws4_request" scope
+func awsCredentialScope(now time.Time, region, service string) string {
+	return fmt.Sprintf("%s/%s/%s/aws4_request", now.UTC().Format("20060102"), region, service)
+}
+
+// awsSignature derives the signing key and signs the canonical request
+func awsSignature(creds awsCredentials, now time.Time, region, service, amzDate, scope, canonicalRequest string) string {
+	stringToSign := strings.Join([]string{
+		awsSigningAlgorithm,
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), now.UTC().Format("20060102"))
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// sha256Hex returns the hex-encoded SHA-256 digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsCanonicalURI returns the URI-encoded path, keeping slashes
+func awsCanonicalURI(u *url.URL) string {
+	path := u.Path
+	if path == "" {
+		return "/"
+	}
+	return awsURIEncode(path, false)
+}
+
+// awsCanonicalQuery returns the query string sorted by key with RFC 3986 encoding
+func awsCanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string(nil), query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(key, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes everything except RFC 3986 unreserved characters (and '/' unless encodeSlash)
+func awsURIEncode(value string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// doAWSRequest signs and sends a request to an AWS service, returning the response body.
+// Non-2xx responses are returned as errors including the service's error body.
+func doAWSRequest(method, rawURL, service string, headers map[string]string, body []byte) ([]byte, error) {
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return nil, err
+	}
+
+	region := awsRegion()
+	if region == "" {
+		return nil, fmt.Errorf("AWS_REGION is not configured")
+	}
+
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating %s request: %v", service, err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	signAWSRequest(req, body, service, region, creds, time.Now())
+
+	resp, err := httpClient(30 * time.Second).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making %s request: %v", service, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s response: %v", service, err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%s API error: status %d, body: %s", service, resp.StatusCode, string(respBody))
+	}
+
+	return respBody, nil
+}
+
+// callAWSJSON calls an AWS JSON-protocol API (Secrets Manager, DynamoDB, ...) and decodes the response into out
+func callAWSJSON(service, endpointPrefix, target, jsonVersion string, payload, out interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error marshaling %s request: %v", service, err)
+	}
+
+	endpoint := fmt.Sprintf("https://%s.%s.amazonaws.com/", endpointPrefix, awsRegion())
+	headers := map[string]string{
+		"Content-Type": "application/x-amz-json-" + jsonVersion,
+		"X-Amz-Target": target,
+	}
+
+	respBody, err := doAWSRequest("POST", endpoint, service, headers, body)
+	if err != nil {
+		return err
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return fmt.Errorf("error decoding %s response: %v", service, err)
+		}
+	}
+	return nil
+}
+
+// getSecretString fetches a secret's string value from Secrets Manager
+func getSecretString(secretID string) (string, error) {
+	var out struct {
+		SecretString string `json:"SecretString"`
+	}
+	payload := map[string]string{"SecretId": secretID}
+	if err := callAWSJSON("secretsmanager", "secretsmanager", "secretsmanager.GetSecretValue", "1.1", payload, &out); err != nil {
+		return "", err
+	}
+	return out.SecretString, nil
+}
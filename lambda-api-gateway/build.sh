@@ -7,7 +7,9 @@ rm -f main bootstrap deployment.zip
 
 echo "🔨 Compiling Go binary..."
 # Build for Linux (AWS Lambda environment) - AWS Lambda Go runtime expects 'bootstrap'
-GOOS=linux GOARCH=amd64 go build -o bootstrap main.go
+VERSION=$(git describe --tags --always --dirty 2>/dev/null || echo dev)
+BUILD_TIME=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+GOOS=linux GOARCH=amd64 go build -ldflags "-X main.version=$VERSION -X main.buildTime=$BUILD_TIME" -o bootstrap .
 
 # Check if build was successful
 if [ $? -eq 0 ]; then
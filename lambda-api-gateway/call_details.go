@@ -0,0 +1,27 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// loadCallDetailsPrompt renders the call details section of the pipeline's prompt for a call of the
+// campaign. sql.ErrNoRows means the call isn't the campaign's.
+func loadCallDetailsPrompt(db *sql.DB, schema SchemaConfig, campaignID, callLogsID string) (string, error) {
+	c := func(name string) string { return schema.Column("call_logs", name) }
+	query := fmt.Sprintf(`
+		SELECT COALESCE(%s, 0), COALESCE(%s, ''), COALESCE(%s, ''), COALESCE(%s, '')
+		FROM %s
+		WHERE %s::text = $1 AND %s::text = $2
+	`, c("duration"), c("agent_name"), c("campaign_name"), c("caller_id_number"), schema.Table("call_logs"), c("id"), c("campaignId"))
+
+	var duration int
+	var agentName, campaignName, callerNumber string
+	if err := db.QueryRow(query, callLogsID, campaignID).Scan(&duration, &agentName, &campaignName, &callerNumber); err != nil {
+		return "", err
+	}
+	if !callDetailsInPrompt() {
+		return "", nil
+	}
+	return renderCallDetailsPrompt(duration, agentName, campaignName, callerNumber), nil
+}
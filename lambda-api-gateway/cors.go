@@ -0,0 +1,174 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	defaultCORSAllowedMethods = "GET, POST, PUT, OPTIONS"
+	defaultCORSAllowedHeaders = "Content-Type, X-Client-Id, X-Api-Key, X-Timestamp, X-Signature"
+	defaultCORSMaxAge         = 600
+)
+
+// CORSConfig controls which browser origins may call the API
+type CORSConfig struct {
+	// AllowedOrigins are exact origins (e.g. "https://app.example.com"); "*" allows any origin
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// LoadCORSConfig reads the CORS configuration from the environment:
+//
+//	CORS_ALLOWED_ORIGINS     comma-separated origins; empty (the default) disables CORS
+//	CORS_ALLOWED_METHODS     comma-separated methods (default "GET, POST, PUT, OPTIONS")
+//	CORS_ALLOWED_HEADERS     comma-separated request headers (default the auth headers and Content-Type)
+//	CORS_ALLOW_CREDENTIALS   "true" to let browsers send cookies and auth headers
+//	CORS_MAX_AGE             seconds browsers may cache a preflight (default 600)
+//
+// Credentials are never allowed together with the "*" origin.
+func LoadCORSConfig() CORSConfig {
+	config := CORSConfig{
+		AllowedOrigins:   splitList(os.Getenv("CORS_ALLOWED_ORIGINS")),
+		AllowedMethods:   splitList(defaultCORSAllowedMethods),
+		AllowedHeaders:   splitList(defaultCORSAllowedHeaders),
+		AllowCredentials: os.Getenv("CORS_ALLOW_CREDENTIALS") == "true",
+		MaxAge:           defaultCORSMaxAge,
+	}
+
+	if methods := splitList(os.Getenv("CORS_ALLOWED_METHODS")); len(methods) > 0 {
+		config.AllowedMethods = methods
+	}
+	if headers := splitList(os.Getenv("CORS_ALLOWED_HEADERS")); len(headers) > 0 {
+		config.AllowedHeaders = headers
+	}
+	if maxAge, err := strconv.Atoi(os.Getenv("CORS_MAX_AGE")); err == nil && maxAge >= 0 {
+		config.MaxAge = maxAge
+	}
+
+	if config.AllowCredentials && config.allowsAnyOrigin() {
+		log.Printf("⚠️ CORS_ALLOW_CREDENTIALS ignored: not allowed with the \"*\" origin")
+		config.AllowCredentials = false
+	}
+
+	return config
+}
+
+// allowsAnyOrigin reports whether the "*" origin is configured
+func (c CORSConfig) allowsAnyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsOrigin reports whether a request's Origin header is allowed
+func (c CORSConfig) allowsOrigin(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsMethod reports whether a method is in the allowed list
+func (c CORSConfig) allowsMethod(method string) bool {
+	for _, allowed := range c.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every header in a preflight's Access-Control-Request-Headers is allowed
+func (c CORSConfig) allowsHeaders(requested string) bool {
+	for _, header := range splitList(requested) {
+		allowed := false
+		for _, candidate := range c.AllowedHeaders {
+			if strings.EqualFold(candidate, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// isPreflight reports whether a request is a CORS preflight
+func isPreflight(request events.APIGatewayProxyRequest) bool {
+	return request.HTTPMethod == "OPTIONS" && headerValue(request.Headers, "Access-Control-Request-Method") != ""
+}
+
+// handlePreflight answers a CORS preflight. Disallowed origins, methods or headers get a 403
+// without CORS headers, so the browser blocks the actual request.
+func handlePreflight(config CORSConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	origin := headerValue(request.Headers, "Origin")
+	method := headerValue(request.Headers, "Access-Control-Request-Method")
+	requestedHeaders := headerValue(request.Headers, "Access-Control-Request-Headers")
+
+	if !config.allowsOrigin(origin) || !config.allowsMethod(method) || !config.allowsHeaders(requestedHeaders) {
+		log.Printf("🚫 CORS preflight rejected: origin=%q method=%q headers=%q", origin, method, requestedHeaders)
+		response := errorResponse(403, "CORS preflight rejected")
+		response.Headers["Vary"] = "Origin"
+		return response
+	}
+
+	response := events.APIGatewayProxyResponse{StatusCode: 204, Headers: map[string]string{}}
+	applyCORSHeaders(config, origin, &response)
+	response.Headers["Access-Control-Allow-Methods"] = strings.Join(config.AllowedMethods, ", ")
+	response.Headers["Access-Control-Allow-Headers"] = strings.Join(config.AllowedHeaders, ", ")
+	response.Headers["Access-Control-Max-Age"] = strconv.Itoa(config.MaxAge)
+	return response
+}
+
+// applyCORSHeaders adds the CORS response headers when the request's origin is allowed.
+// The allowed origin is echoed back rather than "*" so responses can carry credentials.
+func applyCORSHeaders(config CORSConfig, origin string, response *events.APIGatewayProxyResponse) {
+	if response.Headers == nil {
+		response.Headers = map[string]string{}
+	}
+	if len(config.AllowedOrigins) == 0 {
+		return
+	}
+	response.Headers["Vary"] = "Origin"
+	if !config.allowsOrigin(origin) {
+		return
+	}
+
+	if config.allowsAnyOrigin() && !config.AllowCredentials {
+		response.Headers["Access-Control-Allow-Origin"] = "*"
+	} else {
+		response.Headers["Access-Control-Allow-Origin"] = origin
+	}
+	if config.AllowCredentials {
+		response.Headers["Access-Control-Allow-Credentials"] = "true"
+	}
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
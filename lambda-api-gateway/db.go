@@ -0,0 +1,79 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+)
+
+// pooledConnMaxLifetime is long enough for the pooled connection to span warm invocations. RDS IAM
+// tokens are minted per connection, so it doesn't need to track their 15-minute expiry.
+const pooledConnMaxLifetime = 5 * time.Minute
+
+// pooledDB is the connection kept open across warm invocations for the checks every request makes
+var (
+	pooledDBMu sync.Mutex
+	pooledDB   *sql.DB
+)
+
+// pooledDatabase returns the connection pool shared across warm invocations, opening it on first
+// use, so per-request checks such as rate limiting don't connect every time. Callers must not
+// close it. A failed open isn't kept and is retried by the next caller.
+func pooledDatabase() (*sql.DB, error) {
+	pooledDBMu.Lock()
+	defer pooledDBMu.Unlock()
+
+	if pooledDB != nil {
+		return pooledDB, nil
+	}
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxLifetime(pooledConnMaxLifetime)
+	pooledDB = db
+	return pooledDB, nil
+}
+
+// openDatabase opens and verifies a PostgreSQL connection using DB_CONNECTION_STRING
+func openDatabase() (*sql.DB, error) {
+	// Load environment variables; if .env doesn't exist, continue with the process environment
+	_ = godotenv.Load()
+
+	dbConnectionString := os.Getenv("DB_CONNECTION_STRING")
+	if dbConnectionString == "" {
+		return nil, fmt.Errorf("DB_CONNECTION_STRING is not configured")
+	}
+
+	var db *sql.DB
+	if dbIAMAuthEnabled() {
+		connector, err := newRDSIAMConnector(dbConnectionString)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(connector)
+	} else {
+		var err error
+		db, err = sql.Open("postgres", dbConnectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database connection: %v", err)
+		}
+	}
+
+	// Set connection timeouts
+	db.SetConnMaxLifetime(30 * time.Second)
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %v", err)
+	}
+
+	return db, nil
+}
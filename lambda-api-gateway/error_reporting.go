@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// errorReportTimeout bounds each error report; the invocation waits for it, since Lambda freezes
+// the instance once the handler returns
+const errorReportTimeout = 3 * time.Second
+
+// Levels of reported events
+const (
+	reportLevelFatal = "fatal"
+	reportLevelError = "error"
+)
+
+// errorReporter sends events to Sentry and Rollbar, whichever are configured
+type errorReporter struct {
+	// sentryEndpoint and sentryAuth are the store endpoint and X-Sentry-Auth header from SENTRY_DSN
+	sentryEndpoint string
+	sentryAuth     string
+	rollbarToken   string
+	environment    string
+	// release is the build's VCS revision, when it was built with one
+	release string
+}
+
+// errorReporting is set up by initErrorReporting at cold start; nil when nothing is configured
+var errorReporting *errorReporter
+
+// initErrorReporting reads SENTRY_DSN, ROLLBAR_ACCESS_TOKEN and ERROR_REPORTING_ENVIRONMENT
+// (default "production"). An invalid DSN is logged and Sentry left out.
+func initErrorReporting() {
+	reporter := &errorReporter{
+		rollbarToken: os.Getenv("ROLLBAR_ACCESS_TOKEN"),
+		environment:  os.Getenv("ERROR_REPORTING_ENVIRONMENT"),
+	}
+	if reporter.environment == "" {
+		reporter.environment = "production"
+	}
+	if dsn := os.Getenv("SENTRY_DSN"); dsn != "" {
+		endpoint, auth, err := parseSentryDSN(dsn)
+		if err != nil {
+			log.Printf("Sentry reporting disabled: %v", err)
+		}
+		reporter.sentryEndpoint, reporter.sentryAuth = endpoint, auth
+	}
+	if reporter.sentryEndpoint == "" && reporter.rollbarToken == "" {
+		return
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				reporter.release = setting.Value
+			}
+		}
+	}
+	errorReporting = reporter
+}
+
+// parseSentryDSN returns the store endpoint and auth header of a DSN like https://<key>@<host>/<project>
+func parseSentryDSN(dsn string) (endpoint, auth string, err error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil || parsed.User == nil || parsed.Host == "" {
+		return "", "", fmt.Errorf("invalid SENTRY_DSN")
+	}
+	project := path.Base(parsed.Path)
+	if project == "" || project == "/" || project == "." {
+		return "", "", fmt.Errorf("SENTRY_DSN has no project ID")
+	}
+	endpoint = fmt.Sprintf("%s://%s%s/api/%s/store/", parsed.Scheme, parsed.Host, strings.TrimSuffix(path.Dir(parsed.Path), "/"), project)
+	auth = fmt.Sprintf("Sentry sentry_version=7, sentry_client=smartflo-api/1.0, sentry_key=%s", parsed.User.Username())
+	return endpoint, auth, nil
+}
+
+// reportEvent sends an event to the configured services. Events are grouped by errorType and the
+// tags in fingerprint rather than by message, which carries IDs. Empty tags are left out; failures
+// are logged.
+func reportEvent(level, errorType, message, stack string, tags map[string]string, fingerprint []string) {
+	reporter := errorReporting
+	if reporter == nil {
+		return
+	}
+	for name, value := range tags {
+		if value == "" {
+			delete(tags, name)
+		}
+	}
+	if len(fingerprint) > 0 {
+		fingerprint = append([]string{errorType}, fingerprint...)
+	}
+
+	if reporter.sentryEndpoint != "" {
+		if err := reporter.reportToSentry(level, errorType, message, stack, tags, fingerprint); err != nil {
+			log.Printf("Error reporting to Sentry: %v", err)
+		}
+	}
+	if reporter.rollbarToken != "" {
+		if err := reporter.reportToRollbar(level, message, stack, tags, fingerprint); err != nil {
+			log.Printf("Error reporting to Rollbar: %v", err)
+		}
+	}
+}
+
+// reportToSentry files the event through Sentry's store endpoint
+func (r *errorReporter) reportToSentry(level, errorType, message, stack string, tags map[string]string, fingerprint []string) error {
+	eventID := make([]byte, 16)
+	if _, err := rand.Read(eventID); err != nil {
+		return err
+	}
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(eventID),
+		"timestamp":   time.Now().UTC().Format(time.RFC3339),
+		"platform":    "go",
+		"level":       level,
+		"logger":      "lambda-api-gateway",
+		"server_name": os.Getenv("AWS_LAMBDA_FUNCTION_NAME"),
+		"environment": r.environment,
+		"exception":   map[string]interface{}{"values": []map[string]string{{"type": errorType, "value": message}}},
+		"tags":        tags,
+	}
+	if r.release != "" {
+		event["release"] = r.release
+	}
+	if stack != "" {
+		event["extra"] = map[string]string{"stack": stack}
+	}
+	if len(fingerprint) > 0 {
+		event["fingerprint"] = fingerprint
+	}
+	return postErrorReport(r.sentryEndpoint, map[string]string{"X-Sentry-Auth": r.sentryAuth}, event)
+}
+
+// reportToRollbar files the event through Rollbar's item API, with Rollbar's "critical" for fatal
+func (r *errorReporter) reportToRollbar(level, message, stack string, tags map[string]string, fingerprint []string) error {
+	if level == reportLevelFatal {
+		level = "critical"
+	}
+	if stack != "" {
+		message += "\n\n" + stack
+	}
+	data := map[string]interface{}{
+		"environment": r.environment,
+		"level":       level,
+		"platform":    "go",
+		"language":    "go",
+		"framework":   "aws-lambda",
+		"timestamp":   time.Now().Unix(),
+		"server":      map[string]string{"host": os.Getenv("AWS_LAMBDA_FUNCTION_NAME")},
+		"body":        map[string]interface{}{"message": map[string]string{"body": message}},
+		"custom":      tags,
+	}
+	if r.release != "" {
+		data["code_version"] = r.release
+	}
+	if len(fingerprint) > 0 {
+		data["fingerprint"] = strings.Join(fingerprint, "|")
+	}
+	return postErrorReport("https://api.rollbar.com/api/1/item/", map[string]string{"X-Rollbar-Access-Token": r.rollbarToken}, map[string]interface{}{"data": data})
+}
+
+// postErrorReport POSTs a JSON report within errorReportTimeout
+func postErrorReport(endpoint string, headers map[string]string, report interface{}) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := httpClient(errorReportTimeout).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// recoveredPanic logs a recovered panic with its stack trace, reports it as a fatal event and
+// returns the 500 response to send instead
+func recoveredPanic(r interface{}, tags map[string]string) events.APIGatewayProxyResponse {
+	message := fmt.Sprintf("panic: %v", r)
+	stack := string(debug.Stack())
+	log.Printf("❌ PANIC RECOVERED: %s\n%s", message, stack)
+	reportEvent(reportLevelFatal, "panic", message, stack, tags, nil)
+	return errorResponse(500, "Internal error")
+}
+
+// reportServerError reports a 5xx response, grouped by route and status; the message is the
+// response's error, since the cause was logged where it happened
+func reportServerError(response events.APIGatewayProxyResponse, tags map[string]string) {
+	var problem struct {
+		Detail string `json:"detail"`
+	}
+	json.Unmarshal([]byte(response.Body), &problem)
+	if problem.Detail == "" {
+		problem.Detail = http.StatusText(response.StatusCode)
+	}
+	reportEvent(reportLevelError, fmt.Sprintf("http_%d", response.StatusCode), problem.Detail, "", tags,
+		[]string{tags["method"], tags["route"]})
+}
+
+// withWebSocketRecover turns a panic in the WebSocket handler into a 500, like withRecover
+func withWebSocketRecover(next func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error)) func(context.Context, events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return func(ctx context.Context, request events.APIGatewayWebsocketProxyRequest) (response events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				response, err = recoveredPanic(r, map[string]string{
+					"route":         request.RequestContext.RouteKey,
+					"connection_id": request.RequestContext.ConnectionID,
+					"request_id":    request.RequestContext.RequestID,
+				}), nil
+			}
+		}()
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultExperimentDays is the comparison window when since isn't given
+const defaultExperimentDays = 7
+
+// VariantStats summarizes one prompt variant's calls and cost
+type VariantStats struct {
+	Variant         string  `json:"variant"`
+	Model           string  `json:"model"`
+	Calls           int     `json:"calls"`
+	ShadowCalls     int     `json:"shadowCalls"`
+	AvgPromptTokens float64 `json:"avgPromptTokens"`
+	AvgOutputTokens float64 `json:"avgOutputTokens"`
+	AvgCostUsd      float64 `json:"avgCostUsd"`
+	TotalCostUsd    float64 `json:"totalCostUsd"`
+}
+
+// VariantAgreement compares the answers of two variants on the calls processed with both
+type VariantAgreement struct {
+	VariantA      string             `json:"variantA"`
+	VariantB      string             `json:"variantB"`
+	Calls         int                `json:"calls"`
+	Answers       int                `json:"answers"`
+	Agreed        int                `json:"agreed"`
+	AgreementRate float64            `json:"agreementRate"`
+	ByQuestion    map[string]float64 `json:"byQuestion"`
+}
+
+// ExperimentComparison is the GET /experiments/compare response
+type ExperimentComparison struct {
+	CampaignID string             `json:"campaignId"`
+	Since      string             `json:"since"`
+	Until      string             `json:"until"`
+	Variants   []VariantStats     `json:"variants"`
+	Agreement  []VariantAgreement `json:"agreement"`
+}
+
+// handleCompareExperiments compares answer agreement and cost across prompt variants
+//
+//	GET /experiments/compare?campaignId=<id>&since=2025-09-01&until=2025-09-30
+func handleCompareExperiments(schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	params := request.QueryStringParameters
+	campaignID := params["campaignId"]
+
+	since := params["since"]
+	if since == "" {
+		since = time.Now().UTC().AddDate(0, 0, -defaultExperimentDays).Format("2006-01-02")
+	}
+	if _, err := time.Parse("2006-01-02", since); err != nil {
+		return errorResponse(400, "since must be a YYYY-MM-DD date")
+	}
+	until := params["until"]
+	if until != "" {
+		if _, err := time.Parse("2006-01-02", until); err != nil {
+			return errorResponse(400, "until must be a YYYY-MM-DD date")
+		}
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(`
+		SELECT "call_logsId", variant, model, shadow, answers, "promptTokens", "outputTokens", "costUsd"
+		FROM %s
+		WHERE ($1 = '' OR "campaignId"::text = $1)
+		  AND "createdAt" >= $2::date
+		  AND ($3 = '' OR "createdAt" < NULLIF($3, '')::date + 1)
+	`, schema.Table("prompt_variant_results"))
+
+	rows, err := db.Query(query, campaignID, since, until)
+	if err != nil {
+		log.Printf("❌ Experiment query error: %v", err)
+		return errorResponse(500, "Error comparing experiments")
+	}
+	defer rows.Close()
+
+	stats := make(map[string]*VariantStats)
+	answersByCall := make(map[string]map[string]map[string]string) // call -> variant -> answers
+	for rows.Next() {
+		var callLogsID, variant, model string
+		var shadow bool
+		var answersJSON []byte
+		var promptTokens, outputTokens int
+		var cost float64
+		if err := rows.Scan(&callLogsID, &variant, &model, &shadow, &answersJSON, &promptTokens, &outputTokens, &cost); err != nil {
+			log.Printf("❌ Experiment scan error: %v", err)
+			return errorResponse(500, "Error comparing experiments")
+		}
+
+		s := stats[variant]
+		if s == nil {
+			s = &VariantStats{Variant: variant, Model: model}
+			stats[variant] = s
+		}
+		s.Calls++
+		if shadow {
+			s.ShadowCalls++
+		}
+		s.AvgPromptTokens += float64(promptTokens)
+		s.AvgOutputTokens += float64(outputTokens)
+		s.TotalCostUsd += cost
+
+		var answers map[string]string
+		if err := json.Unmarshal(answersJSON, &answers); err != nil {
+			log.Printf("⚠️ Skipping unreadable answers for %s (%s): %v", callLogsID, variant, err)
+			continue
+		}
+		if answersByCall[callLogsID] == nil {
+			answersByCall[callLogsID] = make(map[string]map[string]string)
+		}
+		answersByCall[callLogsID][variant] = answers
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Experiment rows error: %v", err)
+		return errorResponse(500, "Error comparing experiments")
+	}
+
+	variants := make([]string, 0, len(stats))
+	variantStats := make([]VariantStats, 0, len(stats))
+	for name, s := range stats {
+		n := float64(s.Calls)
+		s.AvgPromptTokens = roundTo(s.AvgPromptTokens/n, 1)
+		s.AvgOutputTokens = roundTo(s.AvgOutputTokens/n, 1)
+		s.AvgCostUsd = roundTo(s.TotalCostUsd/n, 6)
+		s.TotalCostUsd = roundTo(s.TotalCostUsd, 6)
+		variants = append(variants, name)
+	}
+	sort.Strings(variants)
+	for _, name := range variants {
+		variantStats = append(variantStats, *stats[name])
+	}
+
+	agreements := []VariantAgreement{}
+	for i := 0; i < len(variants); i++ {
+		for j := i + 1; j < len(variants); j++ {
+			if agreement := compareVariantAnswers(variants[i], variants[j], answersByCall); agreement.Calls > 0 {
+				agreements = append(agreements, agreement)
+			}
+		}
+	}
+
+	return jsonResponse(200, ExperimentComparison{
+		CampaignID: campaignID,
+		Since:      since,
+		Until:      until,
+		Variants:   variantStats,
+		Agreement:  agreements,
+	})
+}
+
+// compareVariantAnswers counts how often two variants gave the same answer to the same question of the
+// same call. Only questions both variants answered are compared; answers match case-insensitively.
+func compareVariantAnswers(a, b string, answersByCall map[string]map[string]map[string]string) VariantAgreement {
+	agreement := VariantAgreement{VariantA: a, VariantB: b, ByQuestion: map[string]float64{}}
+	questionTotals := make(map[string]int)
+	questionAgreed := make(map[string]int)
+
+	for _, byVariant := range answersByCall {
+		answersA, okA := byVariant[a]
+		answersB, okB := byVariant[b]
+		if !okA || !okB {
+			continue
+		}
+		agreement.Calls++
+
+		for questionID, answerA := range answersA {
+			answerB, ok := answersB[questionID]
+			if !ok {
+				continue
+			}
+			agreement.Answers++
+			questionTotals[questionID]++
+			if normalizeComparedAnswer(answerA) == normalizeComparedAnswer(answerB) {
+				agreement.Agreed++
+				questionAgreed[questionID]++
+			}
+		}
+	}
+
+	if agreement.Answers > 0 {
+		agreement.AgreementRate = roundTo(float64(agreement.Agreed)/float64(agreement.Answers), 4)
+	}
+	for questionID, total := range questionTotals {
+		agreement.ByQuestion[questionID] = roundTo(float64(questionAgreed[questionID])/float64(total), 4)
+	}
+	return agreement
+}
+
+// normalizeComparedAnswer lowercases and trims an answer for agreement comparisons
+func normalizeComparedAnswer(answer string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(answer)), ".")
+}
+
+// roundTo rounds a value to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow10(places)
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// version and buildTime are set at build time via -ldflags (see build.sh)
+var (
+	version   = "dev"
+	buildTime = ""
+)
+
+// HealthCheck represents the result of checking a single dependency
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// HealthResponse represents the GET /health response body
+type HealthResponse struct {
+	Status    string                 `json:"status"`
+	Checks    map[string]HealthCheck `json:"checks"`
+	Version   string                 `json:"version"`
+	Commit    string                 `json:"commit,omitempty"`
+	BuildTime string                 `json:"build_time,omitempty"`
+	GoVersion string                 `json:"go_version"`
+	CheckedAt string                 `json:"checked_at"`
+}
+
+// LivenessResponse represents the GET /health/live response body
+type LivenessResponse struct {
+	Status    string `json:"status"`
+	Version   string `json:"version"`
+	CheckedAt string `json:"checked_at"`
+}
+
+// handleLiveness reports that the function is up without checking any dependency, for uptime
+// monitors that can't hold API credentials
+func handleLiveness() events.APIGatewayProxyResponse {
+	return jsonResponse(200, LivenessResponse{
+		Status:    "ok",
+		Version:   version,
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// handleHealth verifies database connectivity and the Gemini API key and reports build info.
+// Returns 200 when every check passes and 503 otherwise so uptime monitors can alert on it.
+func handleHealth() events.APIGatewayProxyResponse {
+	checks := map[string]HealthCheck{
+		"database": runHealthCheck(checkDatabase),
+		"gemini":   runHealthCheck(checkGemini),
+	}
+
+	response := HealthResponse{
+		Status:    "ok",
+		Checks:    checks,
+		Version:   version,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		CheckedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// Commit comes from the VCS info the Go toolchain embeds in the binary
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				response.Commit = setting.Value
+			}
+		}
+	}
+
+	statusCode := 200
+	for _, check := range checks {
+		if check.Status != "ok" {
+			response.Status = "degraded"
+			statusCode = 503
+		}
+	}
+
+	return jsonResponse(statusCode, response)
+}
+
+// runHealthCheck times a dependency check
+func runHealthCheck(check func() error) HealthCheck {
+	start := time.Now()
+	err := check()
+	result := HealthCheck{Status: "ok", LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// checkDatabase pings the database on the pooled connection, so probes don't connect every time
+func checkDatabase() error {
+	db, err := pooledDatabase()
+	if err != nil {
+		return err
+	}
+	if err := db.Ping(); err != nil {
+		return fmt.Errorf("failed to ping database: %v", err)
+	}
+	return nil
+}
+
+// checkGemini validates the Gemini API key with a cheap models.list call
+func checkGemini() error {
+	geminiAPIKey := os.Getenv("GEMINI_API_KEY")
+	if geminiAPIKey == "" {
+		return fmt.Errorf("GEMINI_API_KEY is not configured")
+	}
+
+	req, err := http.NewRequest("GET", "https://generativelanguage.googleapis.com/v1beta/models", nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", err)
+	}
+
+	// The key goes in a header: a failed request's error quotes the URL, and errors are returned to clients
+	req.Header.Set("x-goog-api-key", geminiAPIKey)
+	q := req.URL.Query()
+	q.Add("pageSize", "1")
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := httpClient(5 * time.Second).Do(req)
+	if err != nil {
+		return fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gemini API error: status %d, body: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// sharedTransport is used by every outbound request so connections, TLS sessions and HTTP/2 streams
+// are reused across requests and warm invocations. Clients are cheap and are created per call site
+// with that call's timeout.
+var sharedTransport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	DialContext: (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).DialContext,
+	ForceAttemptHTTP2:     true,
+	MaxIdleConns:          100,
+	MaxIdleConnsPerHost:   16,
+	MaxConnsPerHost:       16,
+	IdleConnTimeout:       90 * time.Second,
+	TLSHandshakeTimeout:   10 * time.Second,
+	ExpectContinueTimeout: time.Second,
+}
+
+// httpClient returns a client on the shared transport with the given timeout
+func httpClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: sharedTransport, Timeout: timeout}
+}
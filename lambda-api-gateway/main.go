@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
-	"fmt"
 	"log"
 	"os"
 
@@ -11,92 +11,157 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
+// ProcessCallRequest is the body of POST /
+type ProcessCallRequest struct {
+	CallLogsID string `json:"call_logsId" format:"uuid"`
+}
+
+// ProcessCallResponse is the POST / response
+type ProcessCallResponse struct {
+	Status     string `json:"status"`
+	CallLogsID string `json:"call_logsId"`
+	Message    string `json:"message"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// apiRouter serves every endpoint; routes need an apiRoutes entry for validation and the OpenAPI document
+var apiRouter = newRouter()
+
+// newRouter registers the API's routes. Every route is validated; all but /openapi.json are
+// authenticated, and all but /health are rate limited per client so monitoring keeps working.
+func newRouter() *router {
+	r := &router{}
+	r.use(withRequestLogging, withRecover, withErrorReporting)
+
+	// Requests are validated only once authenticated, so anonymous callers can't probe the schema
+	authenticated := []middleware{withSchema, withAuth, withValidation}
+	limited := []middleware{withSchema, withAuth, withValidation, withClientRateLimit}
+
+	// Public so client teams can generate SDKs without credentials
+	r.handle("GET", "/openapi.json", func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleOpenAPI(), nil
+	}, withValidation)
+	// Public so uptime monitors can probe the function without credentials
+	r.handle("GET", "/health/live", func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleLiveness(), nil
+	}, withValidation)
+	r.handle("GET", "/health", func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handleHealth(), nil
+	}, authenticated...)
+
+	r.handle("GET", "/analysis/{id}/subtitles", schemaHandler(handleGetSubtitles), limited...)
+	r.handle("GET", "/analysis/{id}/diff", schemaHandler(handleAnalysisDiff), limited...)
+	r.handle("GET", "/search", schemaHandler(handleSearch), limited...)
+	r.handle("GET", "/search/text", schemaHandler(handleTextSearch), limited...)
+	r.handle("GET", "/experiments/compare", schemaHandler(handleCompareExperiments), limited...)
+
+	// Human review queue
+	r.handle("GET", "/reviews", reviewHandler(func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, _ ReviewAction) events.APIGatewayProxyResponse {
+		return listReviews(db, schema, request)
+	}), limited...)
+	r.handle("GET", "/reviews/{id}", reviewHandler(func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, _ ReviewAction) events.APIGatewayProxyResponse {
+		return getReview(db, schema, request.PathParameters["id"])
+	}), limited...)
+	r.handle("POST", "/reviews/{id}/claim", reviewHandler(func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, body ReviewAction) events.APIGatewayProxyResponse {
+		return claimReview(db, schema, request.PathParameters["id"], body.Reviewer)
+	}), limited...)
+	r.handle("POST", "/reviews/{id}/corrections", reviewHandler(func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, body ReviewAction) events.APIGatewayProxyResponse {
+		return correctReview(db, schema, request.PathParameters["id"], body)
+	}), limited...)
+	r.handle("POST", "/reviews/{id}/complete", reviewHandler(func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, body ReviewAction) events.APIGatewayProxyResponse {
+		return completeReview(db, schema, request.PathParameters["id"], body)
+	}), limited...)
+
+	// Campaign onboarding
+	r.handle("POST", "/questions", questionHandler(createQuestion), limited...)
+	r.handle("PUT", "/questions/{id}", questionHandler(updateQuestion), limited...)
+	r.handle("PUT", "/campaigns/{id}/questions", questionHandler(setCampaignQuestions), limited...)
+	r.handle("GET", "/campaigns/{id}/prompt", questionHandler(previewCampaignPrompt), limited...)
+	r.handle("POST", "/campaigns/{id}/prompt-preview", questionHandler(renderCampaignPrompt), limited...)
+
+	// Ad-hoc recording uploads
+	r.handle("POST", "/uploads", createUpload, limited...)
+	r.handle("GET", "/uploads/{id}", questionHandler(getUpload), limited...)
+
+	r.handle("POST", "/", handleProcessCall, limited...)
+	r.handleFallback("POST", "/", handleProcessCall, limited...)
+
+	return r
+}
+
+// schemaHandler adapts a handler of the request and its schema configuration
+func schemaHandler(handler func(SchemaConfig, events.APIGatewayProxyRequest) events.APIGatewayProxyResponse) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return handler(schemaFromContext(ctx), request), nil
+	}
+}
+
+// HandleRequest answers CORS preflights, routes the request and adds CORS headers for allowed
+// origins to every response
 func HandleRequest(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	log.Printf("🚀 HANDLER STARTED - Method: '%s'", request.HTTPMethod)
-	
-	// Log everything about the request
-	log.Printf("📋 Request Details:")
-	log.Printf("   Method: '%s'", request.HTTPMethod)
-	log.Printf("   Path: '%s'", request.Path)
-	log.Printf("   Body: '%s'", request.Body)
-	log.Printf("   Headers: %+v", request.Headers)
-	
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("❌ PANIC RECOVERED: %v", r)
-		}
-	}()
+	cors := LoadCORSConfig()
+
+	// Preflights carry no credentials, so they're answered before authentication
+	if isPreflight(request) {
+		return handlePreflight(cors, request), nil
+	}
+
+	response, err := apiRouter.serve(ctx, request)
+	applyCORSHeaders(cors, headerValue(request.Headers, "Origin"), &response)
+	return response, err
+}
+
+// handleProcessCall submits a call for processing
+//
+//	POST / {"call_logsId": "..."}
+func handleProcessCall(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	schema := schemaFromContext(ctx)
 
 	// Test environment variables
 	log.Printf("🔑 Environment Variables:")
 	log.Printf("   DB_CONNECTION_STRING exists: %v", os.Getenv("DB_CONNECTION_STRING") != "")
 	log.Printf("   GEMINI_API_KEY exists: %v", os.Getenv("GEMINI_API_KEY") != "")
 
-	// Simple JSON parsing test
-	log.Printf("🔄 Testing JSON parsing...")
-	var req map[string]interface{}
+	// The body was checked against ProcessCallRequest by withValidation
+	var req ProcessCallRequest
 	if err := json.Unmarshal([]byte(request.Body), &req); err != nil {
 		log.Printf("❌ JSON Parse Error: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-				"Access-Control-Allow-Origin": "*",
-			},
-			Body: fmt.Sprintf(`{"error": "JSON parse failed: %s"}`, err.Error()),
-		}, nil
-	}
-	log.Printf("✅ JSON parsed successfully: %+v", req)
-
-	// Check for call_logsId
-	callLogsId, exists := req["call_logsId"]
-	if !exists {
-		log.Printf("❌ Missing call_logsId in request")
-		return events.APIGatewayProxyResponse{
-			StatusCode: 400,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-				"Access-Control-Allow-Origin": "*",
-			},
-			Body: `{"error": "call_logsId is required"}`,
-		}, nil
+		return errorResponse(400, "JSON parse failed: %s", err.Error()), nil
 	}
+	callLogsId := req.CallLogsID
 	log.Printf("✅ call_logsId found: %v", callLogsId)
 
-	// Return success with minimal processing
-	response := map[string]interface{}{
-		"status": "minimal_debug_success",
-		"call_logsId": callLogsId,
-		"message": "Basic request processing successful",
-		"timestamp": "2025-09-08T21:00:00Z",
+	// Per-campaign rate limit so one campaign can't exhaust the Gemini quota
+	if campaignRateLimit().Enabled() {
+		campaignID, err := lookupCampaignID(schema, callLogsId)
+		if err != nil {
+			log.Printf("⚠️ Campaign lookup for rate limiting failed: %v", err)
+		} else if campaignID != "" {
+			if limited := checkRateLimit(schema, "campaign:"+campaignID, campaignRateLimit()); limited != nil {
+				return *limited, nil
+			}
+		}
 	}
 
-	log.Printf("✅ Creating response...")
-	jsonBody, err := json.Marshal(response)
-	if err != nil {
-		log.Printf("❌ Response marshal error: %v", err)
-		return events.APIGatewayProxyResponse{
-			StatusCode: 500,
-			Headers: map[string]string{
-				"Content-Type": "application/json",
-				"Access-Control-Allow-Origin": "*",
-			},
-			Body: `{"error": "Response marshal failed"}`,
-		}, nil
+	// Return success with minimal processing
+	response := ProcessCallResponse{
+		Status:     "minimal_debug_success",
+		CallLogsID: callLogsId,
+		Message:    "Basic request processing successful",
+		Timestamp:  "2025-09-08T21:00:00Z",
 	}
 
 	log.Printf("🎉 SUCCESS - Returning response")
-	return events.APIGatewayProxyResponse{
-		StatusCode: 200,
-		Headers: map[string]string{
-			"Content-Type": "application/json",
-			"Access-Control-Allow-Origin": "*",
-		},
-		Body: string(jsonBody),
-	}, nil
+	return jsonResponse(200, response), nil
 }
 
 func main() {
 	log.Printf("🌟 Lambda starting up...")
-	lambda.Start(HandleRequest)
+	initErrorReporting()
+	if websocketModeEnabled() {
+		lambda.Start(withWebSocketRecover(HandleWebSocket))
+		return
+	}
+	// Routes recover within the router so CORS headers still apply; this catches the rest
+	lambda.Start(withRecover(HandleRequest))
 }
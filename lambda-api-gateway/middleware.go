@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Context keys of the values middleware passes to handlers
+type (
+	schemaContextKey   struct{}
+	clientIDContextKey struct{}
+)
+
+// schemaFromContext returns the schema configuration loaded by withSchema
+func schemaFromContext(ctx context.Context) SchemaConfig {
+	schema, ok := ctx.Value(schemaContextKey{}).(SchemaConfig)
+	if !ok {
+		return DefaultSchemaConfig()
+	}
+	return schema
+}
+
+// clientIDFromContext returns the client identified by withAuth
+func clientIDFromContext(ctx context.Context) string {
+	clientID, _ := ctx.Value(clientIDContextKey{}).(string)
+	return clientID
+}
+
+// withRequestLogging logs every request, with credentials redacted
+func withRequestLogging(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		log.Printf("🚀 HANDLER STARTED - Method: '%s'", request.HTTPMethod)
+
+		// Log everything about the request
+		log.Printf("📋 Request Details:")
+		log.Printf("   Method: '%s'", request.HTTPMethod)
+		log.Printf("   Path: '%s'", request.Path)
+		log.Printf("   Body: '%s'", request.Body)
+		log.Printf("   Headers: %+v", redactHeaders(request.Headers))
+
+		return next(ctx, request)
+	}
+}
+
+// withRecover turns a handler panic into a 500 instead of failing the invocation, logging and
+// reporting it with its stack trace (see recoveredPanic)
+func withRecover(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (response events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				response, err = recoveredPanic(r, requestTags(ctx, request)), nil
+			}
+		}()
+		return next(ctx, request)
+	}
+}
+
+// withErrorReporting reports the 5xx responses of handlers that returned normally; withRecover
+// reports panics
+func withErrorReporting(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		if err == nil && response.StatusCode >= 500 {
+			reportServerError(response, requestTags(ctx, request))
+		}
+		return response, err
+	}
+}
+
+// requestTags identifies a request in error reports
+func requestTags(ctx context.Context, request events.APIGatewayProxyRequest) map[string]string {
+	return map[string]string{
+		"method":     request.HTTPMethod,
+		"route":      routePattern(ctx),
+		"path":       request.Path,
+		"request_id": request.RequestContext.RequestID,
+	}
+}
+
+// withSchema loads the schema configuration for the handler (see schemaFromContext)
+func withSchema(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		schema, err := LoadSchemaConfig()
+		if err != nil {
+			log.Printf("❌ Schema configuration error: %v", err)
+			return errorResponse(500, "Invalid schema configuration"), nil
+		}
+		return next(context.WithValue(ctx, schemaContextKey{}, schema), request)
+	}
+}
+
+// withAuth authenticates the client before the handler does any DB work (see clientIDFromContext).
+// Unauthenticated requests (AUTH_DISABLED, local testing only) are identified by source IP.
+func withAuth(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		clientID := "ip:" + request.RequestContext.Identity.SourceIP
+
+		if !authDisabled() {
+			authenticatedID, err := authenticateRequest(request)
+			if err != nil {
+				if errors.Is(err, errAuthUnavailable) {
+					log.Printf("❌ Authentication unavailable: %v", err)
+					return errorResponse(500, "Authentication unavailable"), nil
+				}
+				log.Printf("❌ Authentication failed: %v", err)
+				return errorResponse(401, "Unauthorized: %s", err.Error()), nil
+			}
+			clientID = authenticatedID
+			log.Printf("✅ Authenticated client: %s", clientID)
+		}
+
+		return next(context.WithValue(ctx, clientIDContextKey{}, clientID), request)
+	}
+}
+
+// withClientRateLimit applies the per-client rate limit; it runs after withSchema and withAuth
+func withClientRateLimit(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if limited := checkRateLimit(schemaFromContext(ctx), "client:"+clientIDFromContext(ctx), clientRateLimit()); limited != nil {
+			return *limited, nil
+		}
+		return next(ctx, request)
+	}
+}
@@ -0,0 +1,507 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// apiRoute describes an endpoint for the OpenAPI document. Request and response bodies are given as
+// values of the Go types the handlers use, so the document's schemas follow the code.
+type apiRoute struct {
+	Method      string
+	Path        string // path parameters as {id}
+	OperationID string
+	Summary     string
+	Query       []apiParam
+	Body        interface{}
+	Responses   map[int]apiResponse
+	// Public routes don't require credentials
+	Public bool
+}
+
+// apiParam is a query parameter
+type apiParam struct {
+	Name        string
+	Description string
+	Type        string // "string" when empty
+	Required    bool
+	Enum        []string
+}
+
+// apiResponse is a response of a route. Error responses without a body have an ErrorResponse body;
+// ContentTypes marks a plain-text body.
+type apiResponse struct {
+	Description  string
+	Body         interface{}
+	ContentTypes []string
+}
+
+// apiRoutes document the routes newRouter registers; keep them in step with it
+var apiRoutes = []apiRoute{
+	{
+		Method: "POST", Path: "/", OperationID: "processCall", Summary: "Submit a call for processing",
+		Body: ProcessCallRequest{},
+		Responses: map[int]apiResponse{
+			200: {Body: ProcessCallResponse{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/health", OperationID: "getHealth",
+		Summary: "Check the database and the Gemini API key and report build info; exempt from rate limits",
+		Responses: map[int]apiResponse{
+			200: {Description: "Every check passed", Body: HealthResponse{}},
+			503: {Description: "A check failed", Body: HealthResponse{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/health/live", OperationID: "getLiveness",
+		Summary: "Report that the function is up, without checking dependencies",
+		Public:  true,
+		Responses: map[int]apiResponse{
+			200: {Body: LivenessResponse{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/openapi.json", OperationID: "getOpenAPI", Summary: "This OpenAPI document",
+		Public: true,
+		Responses: map[int]apiResponse{
+			200: {Body: map[string]interface{}{}},
+		},
+	},
+	{
+		Method: "GET", Path: "/analysis/{id}/subtitles", OperationID: "getSubtitles", Summary: "Captions for a processed call",
+		Query: []apiParam{
+			{Name: "format", Description: "Caption format (default vtt)", Enum: []string{"vtt", "srt"}},
+		},
+		Responses: map[int]apiResponse{
+			200: {Description: "WebVTT or SRT captions", ContentTypes: []string{"text/vtt", "application/x-subrip"}},
+			400: {Description: "Unknown format"},
+			404: {Description: "No subtitles for the call"},
+		},
+	},
+	{
+		Method: "GET", Path: "/analysis/{id}/diff", OperationID: "diffAnalysis", Summary: "Changed answers and transcript similarity between two analysis versions",
+		Query: []apiParam{
+			{Name: "from", Description: "Earlier version, e.g. v1 (default the version before to)"},
+			{Name: "to", Description: "Later version, e.g. v2 (default the latest)"},
+		},
+		Responses: map[int]apiResponse{
+			200: {Body: AnalysisDiff{}},
+			400: {Description: "Invalid version"},
+			404: {Description: "A version isn't stored for the call"},
+		},
+	},
+	{
+		Method: "GET", Path: "/search", OperationID: "searchCalls", Summary: "Calls whose transcripts are semantically similar to a query",
+		Query: []apiParam{
+			{Name: "q", Description: "Free-text query", Required: true},
+			{Name: "campaignId", Description: "Only search this campaign's calls"},
+			{Name: "limit", Description: "Results to return, 1-50 (default 10)", Type: "integer"},
+		},
+		Responses: map[int]apiResponse{
+			200: {Body: SearchResponse{}},
+			400: {Description: "Missing q or invalid limit"},
+			502: {Description: "Embedding the query failed"},
+		},
+	},
+	{
+		Method: "GET", Path: "/search/text", OperationID: "searchTranscripts", Summary: "Calls whose transcriptions match a full-text query",
+		Query: []apiParam{
+			{Name: "q", Description: `Web-search syntax: "quoted phrases", OR and -exclusions`, Required: true},
+			{Name: "campaignId", Description: "Only search this campaign's calls"},
+			{Name: "limit", Description: "Results to return, 1-50 (default 10)", Type: "integer"},
+		},
+		Responses: map[int]apiResponse{
+			200: {Body: TextSearchResponse{}},
+			400: {Description: "Missing q or invalid limit"},
+		},
+	},
+	{
+		Method: "GET", Path: "/experiments/compare", OperationID: "compareExperiments", Summary: "Answer agreement and cost across prompt variants",
+		Query: []apiParam{
+			{Name: "campaignId", Description: "Only compare this campaign's calls"},
+			{Name: "since", Description: "First day, YYYY-MM-DD (default 7 days ago)"},
+			{Name: "until", Description: "Last day, YYYY-MM-DD"},
+		},
+		Responses: map[int]apiResponse{
+			200: {Body: ExperimentComparison{}},
+			400: {Description: "Invalid date"},
+		},
+	},
+	{
+		Method: "GET", Path: "/reviews", OperationID: "listReviews", Summary: "The review queue, oldest first",
+		Query: []apiParam{
+			{Name: "status", Description: "Review status (default pending)", Enum: []string{ReviewPending, ReviewClaimed, ReviewReviewed}},
+			{Name: "campaignId", Description: "Only this campaign's reviews"},
+			{Name: "limit", Description: "Reviews to return, 1-500 (default 50)", Type: "integer"},
+		},
+		Responses: map[int]apiResponse{
+			200: {Body: ReviewList{}},
+			400: {Description: "Invalid status or limit"},
+		},
+	},
+	{
+		Method: "GET", Path: "/reviews/{id}", OperationID: "getReview", Summary: "A review with the model's answers and the corrections so far",
+		Responses: map[int]apiResponse{
+			200: {Body: ReviewDetail{}},
+			404: {Description: "No review for the call"},
+		},
+	},
+	{
+		Method: "POST", Path: "/reviews/{id}/claim", OperationID: "claimReview", Summary: "Claim a review",
+		Body: ReviewAction{},
+		Responses: map[int]apiResponse{
+			200: {Body: ReviewResult{}},
+			404: {Description: "No review for the call"},
+			409: {Description: "Claimed by another reviewer or already completed"},
+		},
+	},
+	{
+		Method: "POST", Path: "/reviews/{id}/corrections", OperationID: "correctReview", Summary: "Correct answers of a claimed review",
+		Body: ReviewAction{},
+		Responses: map[int]apiResponse{
+			200: {Body: ReviewDetail{}},
+			400: {Description: "No corrections given"},
+			404: {Description: "No review for the call"},
+			409: {Description: "The review isn't claimed by the reviewer"},
+		},
+	},
+	{
+		Method: "POST", Path: "/reviews/{id}/complete", OperationID: "completeReview", Summary: "Mark a claimed review as reviewed",
+		Body: ReviewAction{},
+		Responses: map[int]apiResponse{
+			200: {Body: ReviewResult{}},
+			404: {Description: "No review for the call"},
+			409: {Description: "The review isn't claimed by the reviewer"},
+		},
+	},
+	{
+		Method: "POST", Path: "/questions", OperationID: "createQuestion", Summary: "Create a question and link it to campaigns",
+		Body: QuestionRequest{},
+		Responses: map[int]apiResponse{
+			201: {Body: Question{}},
+			400: {Description: "Invalid details or campaign IDs"},
+		},
+	},
+	{
+		Method: "PUT", Path: "/questions/{id}", OperationID: "updateQuestion", Summary: "Replace a question's label and details",
+		Body: QuestionRequest{},
+		Responses: map[int]apiResponse{
+			200: {Body: Question{}},
+			400: {Description: "Invalid details or campaign IDs"},
+			404: {Description: "No question with the ID"},
+		},
+	},
+	{
+		Method: "PUT", Path: "/campaigns/{id}/questions", OperationID: "setCampaignQuestions", Summary: "Replace the questions a campaign asks",
+		Body: CampaignQuestionsRequest{},
+		Responses: map[int]apiResponse{
+			200: {Body: CampaignQuestions{}},
+			400: {Description: "Unknown question IDs"},
+		},
+	},
+	{
+		Method: "GET", Path: "/campaigns/{id}/prompt", OperationID: "previewCampaignPrompt", Summary: "Preview the questions and answer constraints of a campaign's prompt",
+		Responses: map[int]apiResponse{
+			200: {Body: PromptPreview{}},
+		},
+	},
+	{
+		Method: "POST", Path: "/campaigns/{id}/prompt-preview", OperationID: "renderCampaignPrompt",
+		Summary: "Render the prompt the pipeline would send for a campaign, optionally against a sample transcript, without calling Gemini",
+		Body:    PromptRenderRequest{},
+		Responses: map[int]apiResponse{
+			200: {Body: RenderedPrompt{}},
+			400: {Description: "Unknown prompt variant"},
+		},
+	},
+	{
+		Method: "POST", Path: "/uploads", OperationID: "createUpload",
+		Summary: "Get a presigned URL to upload a recording to, and the job that processes it with the given questions",
+		Body:    UploadRequest{},
+		Responses: map[int]apiResponse{
+			201: {Body: UploadJob{}},
+			400: {Description: "Invalid questions"},
+			503: {Description: "Uploads are not configured"},
+		},
+	},
+	{
+		Method: "GET", Path: "/uploads/{id}", OperationID: "getUpload", Summary: "An upload's status, and its result once processed",
+		Responses: map[int]apiResponse{
+			200: {Body: UploadJob{}},
+			404: {Description: "No upload with the ID"},
+		},
+	},
+}
+
+// pathParamPattern matches the {name} parameters of a route path
+var pathParamPattern = regexp.MustCompile(`\{(\w+)\}`)
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+// handleOpenAPI serves the OpenAPI 3.0 document for the API, built once per container
+//
+//	GET /openapi.json
+func handleOpenAPI() events.APIGatewayProxyResponse {
+	openAPIOnce.Do(func() {
+		document, err := json.Marshal(buildOpenAPIDocument())
+		if err != nil {
+			log.Printf("❌ OpenAPI marshal error: %v", err)
+			return
+		}
+		openAPIDocument = document
+	})
+	if openAPIDocument == nil {
+		return errorResponse(500, "Error building OpenAPI document")
+	}
+	return textResponse(200, "application/json", string(openAPIDocument))
+}
+
+// buildOpenAPIDocument builds the OpenAPI document from apiRoutes
+func buildOpenAPIDocument() map[string]interface{} {
+	schemas := &schemaBuilder{components: map[string]interface{}{}}
+	errorSchema := schemas.schema(reflect.TypeOf(ErrorResponse{}))
+	jsonContent := func(schema interface{}) map[string]interface{} {
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	problemContent := map[string]interface{}{problemContentType: map[string]interface{}{"schema": errorSchema}}
+
+	paths := map[string]map[string]interface{}{}
+	for _, route := range apiRoutes {
+		var parameters []interface{}
+		for _, match := range pathParamPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name": match[1], "in": "path", "required": true, "description": "The call's call_logsId",
+				"schema": map[string]interface{}{"type": "string", "format": "uuid"},
+			})
+		}
+		for _, param := range route.Query {
+			schema := map[string]interface{}{"type": "string"}
+			if param.Type != "" {
+				schema["type"] = param.Type
+			}
+			if len(param.Enum) > 0 {
+				schema["enum"] = param.Enum
+			}
+			parameters = append(parameters, map[string]interface{}{
+				"name": param.Name, "in": "query", "required": param.Required, "description": param.Description, "schema": schema,
+			})
+		}
+
+		responses := map[string]interface{}{}
+		statuses := route.Responses
+		if !route.Public {
+			statuses = withCommonResponses(statuses)
+		}
+		statuses = withValidationResponses(route, statuses)
+		for status, response := range statuses {
+			description := response.Description
+			if description == "" {
+				description = http.StatusText(status)
+			}
+			content := map[string]interface{}{}
+			switch {
+			case len(response.ContentTypes) > 0:
+				for _, contentType := range response.ContentTypes {
+					content[contentType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+				}
+			case response.Body != nil:
+				content = jsonContent(schemas.schema(reflect.TypeOf(response.Body)))
+			default:
+				content = problemContent
+			}
+			responses[strconv.Itoa(status)] = map[string]interface{}{"description": description, "content": content}
+		}
+
+		operation := map[string]interface{}{
+			"operationId": route.OperationID,
+			"summary":     route.Summary,
+			"responses":   responses,
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if route.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemas.schema(reflect.TypeOf(route.Body))),
+			}
+		}
+		if route.Public {
+			operation["security"] = []interface{}{}
+		}
+
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][strings.ToLower(route.Method)] = operation
+	}
+
+	header := func(name, description string) map[string]interface{} {
+		return map[string]interface{}{"type": "apiKey", "in": "header", "name": name, "description": description}
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Smart Flo Call Processing API",
+			"version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]interface{}{
+				"clientId":  header("X-Client-Id", "The API client's ID"),
+				"apiKey":    header("X-Api-Key", "The client's API key"),
+				"signature": header("X-Signature", `hex(HMAC-SHA256(hmac_secret, timestamp + "\n" + method + "\n" + path + "\n" + query + "\n" + body)), where query is the sorted, percent-encoded query string`),
+				"timestamp": header("X-Timestamp", "Unix seconds, within 5 minutes of the server's time"),
+			},
+		},
+		// Either an API key or an HMAC signature, always with the client ID
+		"security": []interface{}{
+			map[string]interface{}{"clientId": []string{}, "apiKey": []string{}},
+			map[string]interface{}{"clientId": []string{}, "signature": []string{}, "timestamp": []string{}},
+		},
+	}
+}
+
+// withCommonResponses adds the authentication, rate limit and internal error responses every
+// authenticated route can return
+func withCommonResponses(responses map[int]apiResponse) map[int]apiResponse {
+	all := map[int]apiResponse{
+		401: {Description: "Missing or invalid credentials"},
+		429: {Description: "Rate limited; retry after the Retry-After header's seconds"},
+		500: {Description: "Internal error"},
+	}
+	for status, response := range responses {
+		all[status] = response
+	}
+	return all
+}
+
+// withValidationResponses adds the responses withValidation returns for a route's path parameters and body
+func withValidationResponses(route apiRoute, responses map[int]apiResponse) map[int]apiResponse {
+	all := map[int]apiResponse{}
+	if route.Body != nil || pathParamPattern.MatchString(route.Path) {
+		all[400] = apiResponse{Description: "Invalid request; errors lists the invalid fields"}
+	}
+	if route.Body != nil {
+		all[413] = apiResponse{Description: "Body larger than API_MAX_BODY_BYTES"}
+	}
+	for status, response := range responses {
+		if existing, ok := all[status]; ok && status == 400 {
+			response.Description = existing.Description + ", or " + strings.ToLower(response.Description[:1]) + response.Description[1:]
+		}
+		all[status] = response
+	}
+	return all
+}
+
+// schemaBuilder derives OpenAPI schemas from Go types through their json tags. Named structs become
+// components; a field's doc tag becomes its description and its format tag its format. Pointer and
+// omitempty fields are optional.
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schema returns the schema of a type, or a reference to its component
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		// Any JSON value
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := b.schema(t.Elem())
+		if _, ok := schema["$ref"]; ok {
+			// OpenAPI 3.0 ignores siblings of $ref
+			return map[string]interface{}{"allOf": []interface{}{schema}, "nullable": true}
+		}
+		schema["nullable"] = true
+		return schema
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.components[t.Name()]; !ok {
+			// Reserve the name first so self-referencing types terminate
+			b.components[t.Name()] = map[string]interface{}{}
+			b.components[t.Name()] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// structSchema returns the object schema of a struct's JSON fields
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := b.schema(field.Type)
+		if format := field.Tag.Get("format"); format != "" {
+			if _, ok := schema["$ref"]; !ok {
+				schema["format"] = format
+			}
+		}
+		if doc := field.Tag.Get("doc"); doc != "" {
+			if _, ok := schema["$ref"]; ok {
+				schema = map[string]interface{}{"allOf": []interface{}{schema}}
+			}
+			schema["description"] = doc
+		}
+		properties[name] = schema
+
+		if !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
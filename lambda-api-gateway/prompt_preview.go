@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Prompts the pipeline sends to answer questions
+const (
+	// PromptModeAudio transcribes the recording and answers the questions in one request
+	PromptModeAudio = "audio"
+	// PromptModeTranscript answers the questions from a transcription
+	PromptModeTranscript = "transcript"
+)
+
+// transcriptPlaceholder stands in for the transcription when previewing a transcript prompt without one
+const transcriptPlaceholder = "[transcription from %s]"
+
+// PromptRenderRequest is the body of POST /campaigns/{id}/prompt-preview
+type PromptRenderRequest struct {
+	Transcript string `json:"transcript,omitempty" doc:"Sample transcription to answer the questions from; renders the transcript prompt"`
+	Variant    string `json:"variant,omitempty" doc:"Prompt variant whose instructions are added, as for calls assigned to it"`
+	CallLogsID string `json:"callLogsId,omitempty" doc:"Call of the campaign whose details (duration, agent, campaign and caller region) are added, as the pipeline does"`
+}
+
+// RenderedPrompt is the POST /campaigns/{id}/prompt-preview response
+type RenderedPrompt struct {
+	CampaignID            string   `json:"campaignId"`
+	Mode                  string   `json:"mode" doc:"audio when the recording is sent with the prompt, transcript when the questions are answered from a transcription"`
+	TranscriptionProvider string   `json:"transcriptionProvider"`
+	Variant               string   `json:"variant,omitempty"`
+	OutputLanguage        string   `json:"outputLanguage,omitempty"`
+	QuestionIDs           []string `json:"questionIds" doc:"Question IDs in prompt order; answers are matched to questions by position"`
+	Prompt                string   `json:"prompt" doc:"The prompt text; in audio mode the recording follows it in the same request"`
+	EstimatedTokens       int      `json:"estimatedTokens" doc:"Estimated prompt tokens, excluding the audio"`
+	Warnings              []string `json:"warnings" doc:"Problems that won't fail processing but will degrade answers"`
+}
+
+// renderCampaignPrompt renders the prompt the pipeline would send to answer the campaign's
+// questions, without calling Gemini. With a transcript the questions are answered from it; without
+// one, Gemini campaigns get the audio prompt and other providers the transcript prompt with a
+// placeholder for the provider's transcription.
+//
+//	POST /campaigns/{id}/prompt-preview {"transcript": "[00:01 - 00:04] Agent: Hello...", "variant": "concise", "callLogsId": "<call_logsId>"}
+func renderCampaignPrompt(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	campaignID := request.PathParameters["id"]
+
+	// The body was checked against PromptRenderRequest by withValidation
+	var body PromptRenderRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return errorResponse(400, "JSON parse failed: %s", err.Error())
+	}
+
+	var variantInstructions string
+	if body.Variant != "" {
+		query := fmt.Sprintf(`SELECT COALESCE(instructions, '') FROM %s WHERE name = $1`, schema.Table("prompt_variants"))
+		err := db.QueryRow(query, body.Variant).Scan(&variantInstructions)
+		if err == sql.ErrNoRows {
+			return problemResponse(400, []FieldError{{Field: "variant", Message: "is not a known prompt variant"}}, "Unknown prompt variant %s", body.Variant)
+		}
+		if err != nil {
+			log.Printf("❌ Prompt variant error: %v", err)
+			return errorResponse(500, "Error rendering prompt")
+		}
+	}
+
+	var callDetails string
+	if body.CallLogsID != "" {
+		var err error
+		callDetails, err = loadCallDetailsPrompt(db, schema, campaignID, body.CallLogsID)
+		if err == sql.ErrNoRows {
+			return problemResponse(400, []FieldError{{Field: "callLogsId", Message: "is not a call of the campaign"}}, "Unknown call %s", body.CallLogsID)
+		}
+		if err != nil {
+			log.Printf("❌ Call details error: %v", err)
+			return errorResponse(500, "Error rendering prompt")
+		}
+	}
+
+	preview, err := loadPromptPreview(db, schema, campaignID)
+	if err != nil {
+		log.Printf("❌ Prompt preview error: %v", err)
+		return errorResponse(500, "Error rendering prompt")
+	}
+
+	var provider string
+	settingsQuery := fmt.Sprintf(`SELECT COALESCE(settings->>'transcriptionProvider', '') FROM %s WHERE "campaignId"::text = $1`, schema.Table("campaign_settings"))
+	if err := db.QueryRow(settingsQuery, campaignID).Scan(&provider); err != nil && err != sql.ErrNoRows {
+		log.Printf("❌ Campaign settings error: %v", err)
+		return errorResponse(500, "Error rendering prompt")
+	}
+	if provider = strings.ToLower(provider); provider == "" {
+		provider = "gemini"
+	}
+
+	rendered := RenderedPrompt{
+		CampaignID:            campaignID,
+		TranscriptionProvider: provider,
+		Variant:               body.Variant,
+		OutputLanguage:        preview.OutputLanguage,
+		QuestionIDs:           preview.QuestionIDs,
+		Warnings:              preview.Warnings,
+	}
+	instructions := formatVariantInstructions(variantInstructions) + preview.Examples + callDetails
+
+	switch {
+	case body.Transcript != "":
+		rendered.Mode = PromptModeTranscript
+		rendered.Prompt = fmt.Sprintf(transcriptPromptTemplate, body.Transcript, preview.Questions, preview.Constraints, instructions)
+	case provider != "gemini":
+		rendered.Mode = PromptModeTranscript
+		rendered.Prompt = fmt.Sprintf(transcriptPromptTemplate, fmt.Sprintf(transcriptPlaceholder, provider), preview.Questions, preview.Constraints, instructions)
+	default:
+		rendered.Mode = PromptModeAudio
+		rendered.Prompt = fmt.Sprintf(audioPromptTemplate, diarizationInstructions, preview.Questions, preview.Constraints, instructions)
+	}
+	// A token per three bytes, as the pipeline estimates its requests
+	rendered.EstimatedTokens = (len(rendered.Prompt) + 2) / 3
+
+	return jsonResponse(200, rendered)
+}
+
+// fewShotMaxBytes reads FEW_SHOT_MAX_BYTES, which should match the transcription Lambda's
+func fewShotMaxBytes() int {
+	if value, err := strconv.Atoi(os.Getenv("FEW_SHOT_MAX_BYTES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultFewShotMaxBytes
+}
+
+// loadQuestionExamples loads the campaign's active few-shot examples by question ID, highest priority first
+func loadQuestionExamples(db *sql.DB, schema SchemaConfig, campaignID string) (map[string][]QuestionExample, error) {
+	query := fmt.Sprintf(`
+		SELECT "questionId"::text, excerpt, answer, COALESCE(explanation, '')
+		FROM %s
+		WHERE "isActive" = true AND "campaignId"::text = $1
+		ORDER BY priority DESC, "createdAt", id
+	`, schema.Table("campaign_question_example"))
+
+	rows, err := db.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading question examples: %v", err)
+	}
+	defer rows.Close()
+
+	examples := map[string][]QuestionExample{}
+	for rows.Next() {
+		var questionID string
+		var e QuestionExample
+		if err := rows.Scan(&questionID, &e.Excerpt, &e.Answer, &e.Explanation); err != nil {
+			return nil, fmt.Errorf("error scanning question example: %v", err)
+		}
+		examples[questionID] = append(examples[questionID], e)
+	}
+	return examples, rows.Err()
+}
+
+// buildExamplesPrompt builds the few-shot examples section of the pipeline's prompt and counts the
+// examples left out
+func buildExamplesPrompt(questions []previewQuestion, maxBytes int) (string, int) {
+	examples := make([][]QuestionExample, len(questions))
+	for i, q := range questions {
+		examples[i] = q.Examples
+	}
+	text, _, omitted := renderExamplesPrompt(examples, maxBytes)
+	return text, omitted
+}
@@ -0,0 +1,255 @@
+// This file is duplicated byte for byte in lambda-api-gateway and lambda-transcription, so the API's
+// prompt preview renders exactly what the pipeline sends. The two are separate modules, each built
+// and deployed from its own directory, so they can't share a package. Change both copies together
+// and check they still match with "cmp lambda-api-gateway/prompts.go lambda-transcription/prompts.go".
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultFewShotMaxBytes is how much of the prompt few-shot examples may take unless
+	// FEW_SHOT_MAX_BYTES is set
+	defaultFewShotMaxBytes = 8192
+	// fewShotExcerptBytes caps each example's excerpt, so one long example can't crowd out the rest
+	fewShotExcerptBytes = 1500
+)
+
+// AnswerNotDiscussed is the answer to a question the call didn't address. It keeps "never asked"
+// apart from a "no" or an empty answer in reports.
+const AnswerNotDiscussed = "NOT_DISCUSSED"
+
+// enumJustificationSeparator separates the chosen option from the model's justification
+const enumJustificationSeparator = "|"
+
+// diarizationInstructions tells Gemini how to format the transcription so it can be parsed into segments
+const diarizationInstructions = `Write the transcription as one line per speaker turn, labelling each speaker as either Agent or Customer and prefixing every line with its start and end time:
+[MM:SS - MM:SS] Agent: [what the agent said]
+[MM:SS - MM:SS] Customer: [what the customer said]`
+
+// audioPromptTemplate transcribes the recording sent with it and answers the questions in one request.
+// Its arguments are the diarization instructions, questions, answer constraints and extra instructions.
+const audioPromptTemplate = `
+Please transcribe the following audio file and then answer the questions based on the transcription.
+
+%s
+
+QUESTIONS TO ANSWER:
+%s
+
+ANSWER CONSTRAINTS:
+%s
+
+IMPORTANT: Follow the answer constraints exactly as specified for each question.
+%s
+Please provide your response in the following format:
+TRANSCRIPTION:
+[transcribed text here]
+
+ANSWERS:
+Answer 1: [your answer]
+Answer 2: [your answer]
+etc.
+`
+
+// transcriptPromptTemplate answers the questions from a transcription. Its arguments are the
+// transcription, questions, answer constraints and extra instructions.
+const transcriptPromptTemplate = `
+Please answer the questions based on the following call transcription.
+
+TRANSCRIPTION:
+%s
+
+QUESTIONS TO ANSWER:
+%s
+
+ANSWER CONSTRAINTS:
+%s
+
+IMPORTANT: Follow the answer constraints exactly as specified for each question.
+%s
+Please provide your response in the following format:
+ANSWERS:
+Answer 1: [your answer]
+Answer 2: [your answer]
+etc.
+`
+
+// promptQuestion is the part of a question the prompt is rendered from
+type promptQuestion struct {
+	Group        string
+	QuestionText string
+	AnswerType   string
+	Instructions string
+	Options      []string
+}
+
+// renderQuestionsPrompt renders the numbered questions and their answer constraints for the prompt,
+// asking for free-text answers in outputLanguage when it is set
+func renderQuestionsPrompt(questions []promptQuestion, outputLanguage string) (string, string) {
+	var questionsText strings.Builder
+	var answerConstraints []string
+
+	for i, q := range questions {
+		if q.Group != "" {
+			fmt.Fprintf(&questionsText, "%d. [%s] %s\n", i+1, q.Group, q.QuestionText)
+		} else {
+			fmt.Fprintf(&questionsText, "%d. %s\n", i+1, q.QuestionText)
+		}
+
+		switch {
+		case q.AnswerType == "enum":
+			// Enum answers are always constrained to the listed options, even with custom instructions
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: %s", i+1, enumConstraint(q.Options, q.Instructions)))
+		case q.Instructions != "":
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: %s", i+1, q.Instructions))
+		case q.AnswerType == "boolean":
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be ONLY 'true' or 'false'", i+1))
+		case q.AnswerType == "integer":
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be ONLY a number (no units, no text)", i+1))
+		case q.AnswerType == "description":
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be a descriptive summary", i+1))
+		default:
+			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer should be clear and concise", i+1))
+		}
+	}
+
+	answerConstraints = append(answerConstraints, fmt.Sprintf("All questions: If the call doesn't address a question, answer exactly %s instead of guessing or leaving it blank. "+
+		"Only answer 'false', 'no' or '0' when the call shows that is the answer.", AnswerNotDiscussed))
+
+	if outputLanguage != "" {
+		answerConstraints = append(answerConstraints, fmt.Sprintf("All questions: Write descriptive and free-text answers in %s, whatever language the call is in. "+
+			"Boolean, number, date and option answers keep exactly the format required above, and the transcription stays in the language spoken.", outputLanguage))
+	}
+
+	return questionsText.String(), strings.Join(answerConstraints, "\n")
+}
+
+// enumConstraint builds the answer constraint for an enum question
+func enumConstraint(options []string, instructions string) string {
+	quoted := make([]string, len(options))
+	for i, option := range options {
+		quoted[i] = fmt.Sprintf("'%s'", option)
+	}
+
+	constraint := fmt.Sprintf("Answer must be EXACTLY one of %s, followed by ' %s ' and a one-sentence justification, or %s if the call doesn't address it",
+		strings.Join(quoted, ", "), enumJustificationSeparator, AnswerNotDiscussed)
+	if instructions != "" {
+		constraint = instructions + ". " + constraint
+	}
+	return constraint
+}
+
+// formatVariantInstructions formats a prompt variant's instructions for the prompt
+func formatVariantInstructions(instructions string) string {
+	if instructions == "" {
+		return ""
+	}
+	return fmt.Sprintf("\nADDITIONAL INSTRUCTIONS:\n%s\n", instructions)
+}
+
+// QuestionExample is a few-shot example of a question: an excerpt of another call and the answer it
+// should get, with an optional explanation of why
+type QuestionExample struct {
+	Excerpt     string `json:"excerpt"`
+	Answer      string `json:"answer"`
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// renderExamplesPrompt renders each question's few-shot examples for the prompt within maxBytes,
+// numbered like the questions. Examples are taken in turns, each question's best before any
+// question's second, so every question gets one before the budget runs out; those that don't fit
+// are left out and counted.
+func renderExamplesPrompt(examples [][]QuestionExample, maxBytes int) (text string, included, omitted int) {
+	const header = "\nEXAMPLES (from other calls, showing how the answer constraints apply; answer from this call only):\n"
+
+	var b strings.Builder
+	used := len(header)
+	for round := 0; ; round++ {
+		more := false
+		for i, questionExamples := range examples {
+			if round >= len(questionExamples) {
+				continue
+			}
+			more = true
+
+			e := questionExamples[round]
+			excerpt := strings.TrimSpace(e.Excerpt)
+			if len(excerpt) > fewShotExcerptBytes {
+				excerpt = truncateUTF8(excerpt, fewShotExcerptBytes) + " [...]"
+			}
+			entry := fmt.Sprintf("Question %d example:\nExcerpt: %s\nAnswer: %s\n", i+1, excerpt, strings.TrimSpace(e.Answer))
+			if e.Explanation != "" {
+				entry += fmt.Sprintf("Why: %s\n", strings.TrimSpace(e.Explanation))
+			}
+
+			if used+len(entry) > maxBytes {
+				omitted++
+				continue
+			}
+			b.WriteString(entry)
+			used += len(entry)
+			included++
+		}
+		if !more {
+			break
+		}
+	}
+
+	if included == 0 {
+		return "", 0, omitted
+	}
+	return header + b.String(), included, omitted
+}
+
+// truncateUTF8 shortens s to at most maxBytes without splitting a multi-byte character
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	for maxBytes > 0 && !utf8.RuneStart(s[maxBytes]) {
+		maxBytes--
+	}
+	return s[:maxBytes]
+}
+
+// callDetailsInPrompt reports whether the call's details are added to the question prompts; set
+// PROMPT_CALL_DETAILS=false to leave them out
+func callDetailsInPrompt() bool {
+	return os.Getenv("PROMPT_CALL_DETAILS") != "false"
+}
+
+// formatCallDuration formats seconds as "3m 25s"
+func formatCallDuration(seconds int) string {
+	if seconds < 60 {
+		return fmt.Sprintf("%ds", seconds)
+	}
+	return fmt.Sprintf("%dm %02ds", seconds/60, seconds%60)
+}
+
+// renderCallDetailsPrompt renders a call's duration in seconds, agent, campaign and caller region
+// for the prompt. Empty when none of them is known.
+func renderCallDetailsPrompt(duration int, agentName, campaignName, callerNumber string) string {
+	var details []string
+	if duration > 0 {
+		details = append(details, "Duration: "+formatCallDuration(duration))
+	}
+	if agentName != "" {
+		details = append(details, "Agent name: "+agentName)
+	}
+	if campaignName != "" {
+		details = append(details, "Campaign: "+campaignName)
+	}
+	if region := callerRegion(callerNumber); region != "" {
+		details = append(details, "Caller region: "+region)
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\nCALL DETAILS (from the call record; answer from what was said, using these only as context):\n- %s\n", strings.Join(details, "\n- "))
+}
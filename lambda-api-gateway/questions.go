@@ -0,0 +1,607 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/lib/pq"
+)
+
+// Answer types the transcription pipeline builds answer constraints for
+var questionAnswerTypes = []string{"text", "boolean", "integer", "description", "enum"}
+
+// QuestionDetails is the details JSON of a question, as the transcription pipeline reads it
+type QuestionDetails struct {
+	QuestionText string          `json:"questionText" doc:"The question asked of the call"`
+	AnswerType   string          `json:"answerType,omitempty" doc:"text (default), boolean, integer, description or enum"`
+	Instructions string          `json:"instructions,omitempty" doc:"Answer constraint given to the model instead of the answer type's default"`
+	Group        string          `json:"group,omitempty" doc:"Section the question is listed under in the prompt"`
+	Options      []string        `json:"options,omitempty" doc:"The choices of an enum question, at least two"`
+	ShowIf       json.RawMessage `json:"showIf,omitempty" doc:"A condition on another question's answer, or a list of conditions that must all hold: {\"questionId\": \"<id>\", \"equals\" | \"notEquals\" | \"in\": ...}"`
+}
+
+// QuestionCondition is a showIf condition on another question's answer
+type QuestionCondition struct {
+	QuestionID string        `json:"questionId"`
+	Equals     interface{}   `json:"equals,omitempty"`
+	NotEquals  interface{}   `json:"notEquals,omitempty"`
+	In         []interface{} `json:"in,omitempty"`
+}
+
+// QuestionRequest is the body of POST /questions and PUT /questions/{id}
+type QuestionRequest struct {
+	Label       string          `json:"label,omitempty"`
+	IsActive    *bool           `json:"isActive,omitempty" doc:"Defaults to true for new questions; updates keep the current value"`
+	Details     QuestionDetails `json:"details"`
+	CampaignIDs []string        `json:"campaignIds,omitempty" doc:"Campaigns to link the question to, in addition to its current ones"`
+}
+
+// Question is a question with its details and the campaigns it is linked to
+type Question struct {
+	ID          string          `json:"id"`
+	Label       string          `json:"label"`
+	IsActive    bool            `json:"isActive"`
+	Details     json.RawMessage `json:"details"`
+	CampaignIDs []string        `json:"campaignIds"`
+}
+
+// CampaignQuestionsRequest is the body of PUT /campaigns/{id}/questions
+type CampaignQuestionsRequest struct {
+	QuestionIDs []string `json:"questionIds" doc:"Every question the campaign asks; questions not listed are unlinked"`
+}
+
+// CampaignQuestions is the PUT /campaigns/{id}/questions response
+type CampaignQuestions struct {
+	CampaignID string     `json:"campaignId"`
+	Questions  []Question `json:"questions"`
+}
+
+// PromptPreview is the GET /campaigns/{id}/prompt response
+type PromptPreview struct {
+	CampaignID     string   `json:"campaignId"`
+	OutputLanguage string   `json:"outputLanguage,omitempty"`
+	QuestionIDs    []string `json:"questionIds" doc:"Question IDs in prompt order; answers are matched to questions by position"`
+	Questions      string   `json:"questions" doc:"The QUESTIONS TO ANSWER section of the prompt"`
+	Constraints    string   `json:"constraints" doc:"The ANSWER CONSTRAINTS section of the prompt"`
+	Examples       string   `json:"examples,omitempty" doc:"The few-shot EXAMPLES section of the prompt"`
+	Warnings       []string `json:"warnings" doc:"Problems that won't fail processing but will degrade answers"`
+}
+
+// questionHandler adapts a handler of a question request with an open database
+func questionHandler(handler func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		db, err := openDatabase()
+		if err != nil {
+			log.Printf("❌ Database error: %v", err)
+			return errorResponse(500, "Database unavailable"), nil
+		}
+		defer db.Close()
+
+		return handler(db, schemaFromContext(ctx), request), nil
+	}
+}
+
+// createQuestion creates a question and links it to the given campaigns
+//
+//	POST /questions {"label": "...", "details": {"questionText": "...", "answerType": "boolean"}, "campaignIds": ["..."]}
+func createQuestion(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	return saveQuestion(db, schema, request, "")
+}
+
+// updateQuestion replaces a question's label and details, and links it to any further campaigns
+//
+//	PUT /questions/{id} {"details": {...}, "isActive": false}
+func updateQuestion(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	return saveQuestion(db, schema, request, request.PathParameters["id"])
+}
+
+// saveQuestion validates the question and creates it (questionID "") or updates it
+func saveQuestion(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, questionID string) events.APIGatewayProxyResponse {
+	// The body was checked against QuestionRequest by withValidation
+	var body QuestionRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return errorResponse(400, "JSON parse failed: %s", err.Error())
+	}
+
+	fieldErrors, conditions := validateQuestionDetails(body.Details, "details")
+	for i, campaignID := range body.CampaignIDs {
+		if !uuidPattern.MatchString(campaignID) {
+			fieldErrors = append(fieldErrors, FieldError{Field: fmt.Sprintf("campaignIds[%d]", i), Message: "must be a UUID"})
+		}
+	}
+	if len(fieldErrors) == 0 {
+		conditionErrors, err := checkConditionQuestions(db, schema, conditions, questionID)
+		if err != nil {
+			log.Printf("❌ Question lookup error: %v", err)
+			return errorResponse(500, "Error saving question")
+		}
+		fieldErrors = append(fieldErrors, conditionErrors...)
+	}
+	if len(fieldErrors) > 0 {
+		return problemResponse(400, fieldErrors, "Question validation failed")
+	}
+
+	details, err := marshalQuestionDetails(body.Details)
+	if err != nil {
+		return errorResponse(500, "Error saving question")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("❌ Question transaction error: %v", err)
+		return errorResponse(500, "Error saving question")
+	}
+	defer tx.Rollback()
+
+	c := func(name string) string { return schema.Column("question", name) }
+	status := 200
+	if questionID == "" {
+		isActive := body.IsActive == nil || *body.IsActive
+		query := fmt.Sprintf(`INSERT INTO %s (%s, %s, %s) VALUES (NULLIF($1, ''), $2, $3) RETURNING %s::text`,
+			schema.Table("question"), c("label"), c("isActive"), c("details"), c("id"))
+		if err := tx.QueryRow(query, body.Label, isActive, details).Scan(&questionID); err != nil {
+			log.Printf("❌ Question insert error: %v", err)
+			return errorResponse(500, "Error saving question")
+		}
+		status = 201
+	} else {
+		query := fmt.Sprintf(`UPDATE %s SET %s = NULLIF($2, ''), %s = COALESCE($3, %s), %s = $4 WHERE %s::text = $1`,
+			schema.Table("question"), c("label"), c("isActive"), c("isActive"), c("details"), c("id"))
+		result, err := tx.Exec(query, questionID, body.Label, body.IsActive, details)
+		if err != nil {
+			log.Printf("❌ Question update error: %v", err)
+			return errorResponse(500, "Error saving question")
+		}
+		if updated, _ := result.RowsAffected(); updated == 0 {
+			return errorResponse(404, "No question with ID %s", questionID)
+		}
+	}
+
+	for _, campaignID := range body.CampaignIDs {
+		if err := linkCampaignQuestion(tx, schema, campaignID, questionID); err != nil {
+			log.Printf("❌ Campaign question link error: %v", err)
+			return errorResponse(500, "Error linking question to campaign")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("❌ Question commit error: %v", err)
+		return errorResponse(500, "Error saving question")
+	}
+
+	questions, err := loadQuestions(db, schema, []string{questionID})
+	if err != nil || len(questions) == 0 {
+		log.Printf("❌ Question reload error: %v", err)
+		return errorResponse(500, "Error loading question")
+	}
+	return jsonResponse(status, questions[0])
+}
+
+// setCampaignQuestions replaces the questions linked to a campaign
+//
+//	PUT /campaigns/{id}/questions {"questionIds": ["...", "..."]}
+func setCampaignQuestions(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	campaignID := request.PathParameters["id"]
+
+	var body CampaignQuestionsRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return errorResponse(400, "JSON parse failed: %s", err.Error())
+	}
+
+	var fieldErrors []FieldError
+	for i, questionID := range body.QuestionIDs {
+		if !uuidPattern.MatchString(questionID) {
+			fieldErrors = append(fieldErrors, FieldError{Field: fmt.Sprintf("questionIds[%d]", i), Message: "must be a UUID"})
+		}
+	}
+	if len(fieldErrors) == 0 {
+		existing, err := existingQuestionIDs(db, schema, body.QuestionIDs)
+		if err != nil {
+			log.Printf("❌ Question lookup error: %v", err)
+			return errorResponse(500, "Error linking questions")
+		}
+		for i, questionID := range body.QuestionIDs {
+			if !existing[strings.ToLower(questionID)] {
+				fieldErrors = append(fieldErrors, FieldError{Field: fmt.Sprintf("questionIds[%d]", i), Message: "is not a known question"})
+			}
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return problemResponse(400, fieldErrors, "Campaign questions validation failed")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("❌ Campaign questions transaction error: %v", err)
+		return errorResponse(500, "Error linking questions")
+	}
+	defer tx.Rollback()
+
+	cq := func(name string) string { return schema.Column("campaign_question", name) }
+	unlink := fmt.Sprintf(`DELETE FROM %s WHERE %s::text = $1 AND NOT (%s::text = ANY($2))`,
+		schema.Table("campaign_question"), cq("campaignId"), cq("questionId"))
+	if _, err := tx.Exec(unlink, campaignID, pq.Array(lowerAll(body.QuestionIDs))); err != nil {
+		log.Printf("❌ Campaign question unlink error: %v", err)
+		return errorResponse(500, "Error linking questions")
+	}
+	for _, questionID := range body.QuestionIDs {
+		if err := linkCampaignQuestion(tx, schema, campaignID, questionID); err != nil {
+			log.Printf("❌ Campaign question link error: %v", err)
+			return errorResponse(500, "Error linking questions")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("❌ Campaign questions commit error: %v", err)
+		return errorResponse(500, "Error linking questions")
+	}
+
+	questions, err := loadQuestions(db, schema, body.QuestionIDs)
+	if err != nil {
+		log.Printf("❌ Question reload error: %v", err)
+		return errorResponse(500, "Error loading questions")
+	}
+	return jsonResponse(200, CampaignQuestions{CampaignID: campaignID, Questions: questions})
+}
+
+// previewCampaignPrompt returns the questions and answer constraints sections of the prompt the
+// pipeline builds for the campaign's active questions, with warnings about likely mistakes
+//
+//	GET /campaigns/{id}/prompt
+func previewCampaignPrompt(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	preview, err := loadPromptPreview(db, schema, request.PathParameters["id"])
+	if err != nil {
+		log.Printf("❌ Prompt preview error: %v", err)
+		return errorResponse(500, "Error building prompt preview")
+	}
+	return jsonResponse(200, preview)
+}
+
+// loadPromptPreview builds the questions sections of the campaign's prompt from its active questions
+func loadPromptPreview(db *sql.DB, schema SchemaConfig, campaignID string) (*PromptPreview, error) {
+	q := func(name string) string { return "q." + schema.Column("question", name) }
+	cq := func(name string) string { return "cq." + schema.Column("campaign_question", name) }
+	query := fmt.Sprintf(`
+		SELECT %s::text, %s, %s
+		FROM %s q
+		INNER JOIN %s cq ON %s = %s
+		WHERE %s::text = $1
+		ORDER BY %s
+	`, q("id"), q("isActive"), q("details"),
+		schema.Table("question"), schema.Table("campaign_question"), q("id"), cq("questionId"),
+		cq("campaignId"), q("id"))
+
+	rows, err := db.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading campaign questions: %v", err)
+	}
+	defer rows.Close()
+
+	preview := PromptPreview{CampaignID: campaignID, QuestionIDs: []string{}, Warnings: []string{}}
+	var active []previewQuestion
+	for rows.Next() {
+		var id string
+		var isActive bool
+		var detailsJSON []byte
+		if err := rows.Scan(&id, &isActive, &detailsJSON); err != nil {
+			return nil, fmt.Errorf("error scanning campaign question: %v", err)
+		}
+		if !isActive {
+			preview.Warnings = append(preview.Warnings, fmt.Sprintf("question %s is linked but inactive, so it isn't asked", id))
+			continue
+		}
+
+		var details QuestionDetails
+		if len(detailsJSON) > 0 {
+			if err := json.Unmarshal(detailsJSON, &details); err != nil {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("question %s has unreadable details, which fails processing: %v", id, err))
+				continue
+			}
+		}
+		if errs, _ := validateQuestionDetails(details, "details"); len(errs) > 0 {
+			for _, e := range errs {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("question %s: %s %s", id, e.Field, e.Message))
+			}
+		}
+		active = append(active, previewQuestion{ID: id, Details: details})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading campaign questions: %v", err)
+	}
+
+	// Conditions on questions the campaign doesn't ask never hold
+	asked := map[string]bool{}
+	for _, question := range active {
+		asked[strings.ToLower(question.ID)] = true
+	}
+	for _, question := range active {
+		conditions, _ := parseQuestionConditions(question.Details.ShowIf)
+		for _, condition := range conditions {
+			if !asked[strings.ToLower(condition.QuestionID)] {
+				preview.Warnings = append(preview.Warnings, fmt.Sprintf("question %s depends on question %s, which the campaign doesn't ask", question.ID, condition.QuestionID))
+			}
+		}
+	}
+	if len(active) == 0 {
+		preview.Warnings = append(preview.Warnings, "the campaign has no active questions, so calls are only transcribed")
+	}
+
+	settingsQuery := fmt.Sprintf(`SELECT COALESCE(settings->>'outputLanguage', '') FROM %s WHERE "campaignId"::text = $1`, schema.Table("campaign_settings"))
+	if err := db.QueryRow(settingsQuery, campaignID).Scan(&preview.OutputLanguage); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("error loading campaign settings: %v", err)
+	}
+
+	examples, err := loadQuestionExamples(db, schema, campaignID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range active {
+		active[i].Examples = examples[active[i].ID]
+	}
+
+	preview.Questions, preview.Constraints = buildQuestionsPrompt(active, preview.OutputLanguage)
+	var omitted int
+	preview.Examples, omitted = buildExamplesPrompt(active, fewShotMaxBytes())
+	if omitted > 0 {
+		preview.Warnings = append(preview.Warnings, fmt.Sprintf("%d few-shot examples don't fit within FEW_SHOT_MAX_BYTES and are left out", omitted))
+	}
+	for _, question := range active {
+		preview.QuestionIDs = append(preview.QuestionIDs, question.ID)
+	}
+	return &preview, nil
+}
+
+// validateQuestionDetails checks the details the pipeline relies on, returning the field errors and
+// the parsed showIf conditions
+func validateQuestionDetails(details QuestionDetails, path string) ([]FieldError, []QuestionCondition) {
+	var fieldErrors []FieldError
+	fail := func(field, message string) {
+		fieldErrors = append(fieldErrors, FieldError{Field: joinFieldPath(path, field), Message: message})
+	}
+
+	if strings.TrimSpace(details.QuestionText) == "" {
+		fail("questionText", "must not be empty")
+	}
+
+	answerType := details.AnswerType
+	if answerType != "" && !containsString(questionAnswerTypes, answerType) {
+		fail("answerType", "must be one of "+strings.Join(questionAnswerTypes, ", "))
+	}
+
+	if answerType == "enum" {
+		seen := map[string]bool{}
+		for i, option := range details.Options {
+			option = strings.TrimSpace(option)
+			switch {
+			case option == "":
+				fail(fmt.Sprintf("options[%d]", i), "must not be empty")
+			case seen[strings.ToLower(option)]:
+				fail(fmt.Sprintf("options[%d]", i), "duplicates another option")
+			case strings.Contains(option, enumJustificationSeparator):
+				fail(fmt.Sprintf("options[%d]", i), "must not contain "+enumJustificationSeparator)
+			}
+			seen[strings.ToLower(option)] = true
+		}
+		if len(details.Options) < 2 {
+			fail("options", "enum questions need at least two options")
+		}
+	} else if len(details.Options) > 0 {
+		fail("options", "only enum questions have options")
+	}
+
+	conditions, err := parseQuestionConditions(details.ShowIf)
+	if err != nil {
+		fail("showIf", err.Error())
+	}
+	return fieldErrors, conditions
+}
+
+// parseQuestionConditions reads a showIf value: a single condition or a list of conditions. Each
+// needs a questionId and exactly one of equals, notEquals or in.
+func parseQuestionConditions(raw json.RawMessage) ([]QuestionCondition, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	decode := func(data []byte, v interface{}) error {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	}
+	var conditions []QuestionCondition
+	if raw[0] == '[' {
+		if err := decode(raw, &conditions); err != nil {
+			return nil, fmt.Errorf("must be a condition or a list of conditions: %v", err)
+		}
+	} else {
+		var condition QuestionCondition
+		if err := decode(raw, &condition); err != nil {
+			return nil, fmt.Errorf("must be a condition or a list of conditions: %v", err)
+		}
+		conditions = []QuestionCondition{condition}
+	}
+
+	for i, c := range conditions {
+		if c.QuestionID == "" {
+			return nil, fmt.Errorf("condition %d is missing questionId", i+1)
+		}
+		comparisons := 0
+		for _, set := range []bool{c.Equals != nil, c.NotEquals != nil, c.In != nil} {
+			if set {
+				comparisons++
+			}
+		}
+		if comparisons != 1 {
+			return nil, fmt.Errorf("condition %d needs exactly one of equals, notEquals or in", i+1)
+		}
+	}
+	return conditions, nil
+}
+
+// checkConditionQuestions reports showIf conditions on questions that don't exist or on the question itself
+func checkConditionQuestions(db *sql.DB, schema SchemaConfig, conditions []QuestionCondition, questionID string) ([]FieldError, error) {
+	if len(conditions) == 0 {
+		return nil, nil
+	}
+	ids := make([]string, len(conditions))
+	for i, c := range conditions {
+		ids[i] = c.QuestionID
+	}
+	existing, err := existingQuestionIDs(db, schema, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	var fieldErrors []FieldError
+	for i, c := range conditions {
+		field := "details.showIf"
+		if len(conditions) > 1 {
+			field = fmt.Sprintf("details.showIf[%d]", i)
+		}
+		switch {
+		case strings.EqualFold(c.QuestionID, questionID):
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".questionId", Message: "must not be the question itself"})
+		case !existing[strings.ToLower(c.QuestionID)]:
+			fieldErrors = append(fieldErrors, FieldError{Field: field + ".questionId", Message: "is not a known question"})
+		}
+	}
+	return fieldErrors, nil
+}
+
+// existingQuestionIDs returns which of the IDs are questions, lowercased
+func existingQuestionIDs(db *sql.DB, schema SchemaConfig, ids []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	if len(ids) == 0 {
+		return existing, nil
+	}
+	query := fmt.Sprintf(`SELECT lower(%s::text) FROM %s WHERE lower(%s::text) = ANY($1)`,
+		schema.Column("question", "id"), schema.Table("question"), schema.Column("question", "id"))
+	rows, err := db.Query(query, pq.Array(lowerAll(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("error looking up questions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning question ID: %v", err)
+		}
+		existing[id] = true
+	}
+	return existing, rows.Err()
+}
+
+// linkCampaignQuestion links a question to a campaign unless it already is
+func linkCampaignQuestion(tx *sql.Tx, schema SchemaConfig, campaignID, questionID string) error {
+	cq := func(name string) string { return schema.Column("campaign_question", name) }
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s, %s)
+		SELECT $1, $2
+		WHERE NOT EXISTS (SELECT 1 FROM %s WHERE %s::text = $1 AND lower(%s::text) = lower($2))
+	`, schema.Table("campaign_question"), cq("campaignId"), cq("questionId"),
+		schema.Table("campaign_question"), cq("campaignId"), cq("questionId"))
+	_, err := tx.Exec(query, campaignID, questionID)
+	return err
+}
+
+// loadQuestions returns the questions with the IDs and the campaigns each is linked to, ordered by ID
+func loadQuestions(db *sql.DB, schema SchemaConfig, ids []string) ([]Question, error) {
+	questions := []Question{}
+	if len(ids) == 0 {
+		return questions, nil
+	}
+
+	q := func(name string) string { return "q." + schema.Column("question", name) }
+	cq := func(name string) string { return "cq." + schema.Column("campaign_question", name) }
+	query := fmt.Sprintf(`
+		SELECT %s::text, COALESCE(%s, ''), %s, COALESCE(%s::text, '{}'),
+		       COALESCE(array_agg(%s::text ORDER BY %s::text) FILTER (WHERE %s IS NOT NULL), '{}')
+		FROM %s q
+		LEFT JOIN %s cq ON %s = %s
+		WHERE lower(%s::text) = ANY($1)
+		GROUP BY %s
+		ORDER BY %s
+	`, q("id"), q("label"), q("isActive"), q("details"),
+		cq("campaignId"), cq("campaignId"), cq("campaignId"),
+		schema.Table("question"), schema.Table("campaign_question"), q("id"), cq("questionId"),
+		q("id"), q("id"), q("id"))
+
+	rows, err := db.Query(query, pq.Array(lowerAll(ids)))
+	if err != nil {
+		return nil, fmt.Errorf("error loading questions: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var question Question
+		var details string
+		if err := rows.Scan(&question.ID, &question.Label, &question.IsActive, &details, pq.Array(&question.CampaignIDs)); err != nil {
+			return nil, fmt.Errorf("error scanning question: %v", err)
+		}
+		question.Details = json.RawMessage(details)
+		questions = append(questions, question)
+	}
+	return questions, rows.Err()
+}
+
+// marshalQuestionDetails returns the details JSON stored for a question, with the answer type
+// defaulted to text and the question text and options trimmed
+func marshalQuestionDetails(details QuestionDetails) ([]byte, error) {
+	details.QuestionText = strings.TrimSpace(details.QuestionText)
+	if details.AnswerType == "" {
+		details.AnswerType = "text"
+	}
+	for i := range details.Options {
+		details.Options[i] = strings.TrimSpace(details.Options[i])
+	}
+	return json.Marshal(details)
+}
+
+// previewQuestion is an active question of the prompt preview
+type previewQuestion struct {
+	ID       string
+	Details  QuestionDetails
+	Examples []QuestionExample
+}
+
+// buildQuestionsPrompt builds the questions and answer constraints sections of the pipeline's prompt
+func buildQuestionsPrompt(questions []previewQuestion, outputLanguage string) (string, string) {
+	promptQuestions := make([]promptQuestion, len(questions))
+	for i, question := range questions {
+		q := question.Details
+		promptQuestions[i] = promptQuestion{
+			Group:        q.Group,
+			QuestionText: q.QuestionText,
+			AnswerType:   q.AnswerType,
+			Instructions: q.Instructions,
+			Options:      q.Options,
+		}
+	}
+	return renderQuestionsPrompt(promptQuestions, outputLanguage)
+}
+
+// lowerAll returns the strings lowercased
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RateLimit represents a token bucket: Burst requests may be made at once, refilled at PerMinute per minute
+type RateLimit struct {
+	PerMinute float64
+	Burst     float64
+}
+
+// Enabled reports whether the limit is configured (a zero rate disables limiting)
+func (l RateLimit) Enabled() bool {
+	return l.PerMinute > 0
+}
+
+// rateLimitFromEnv reads "<prefix>_PER_MINUTE" and "<prefix>_BURST", defaulting the burst to the per-minute rate
+func rateLimitFromEnv(prefix string, defaultPerMinute float64) RateLimit {
+	limit := RateLimit{PerMinute: defaultPerMinute}
+	if value, err := strconv.ParseFloat(os.Getenv(prefix+"_PER_MINUTE"), 64); err == nil && value >= 0 {
+		limit.PerMinute = value
+	}
+	limit.Burst = limit.PerMinute
+	if value, err := strconv.ParseFloat(os.Getenv(prefix+"_BURST"), 64); err == nil && value >= 1 {
+		limit.Burst = value
+	}
+	return limit
+}
+
+// clientRateLimit limits every request from a single API client
+func clientRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_CLIENT", 60)
+}
+
+// campaignRateLimit limits processing requests for a single campaign, across all clients
+func campaignRateLimit() RateLimit {
+	return rateLimitFromEnv("RATE_LIMIT_CAMPAIGN", 30)
+}
+
+// takeRateLimitToken atomically refills the bucket and takes one token from it.
+// It returns whether the request is allowed and, if not, how many seconds until a token is available.
+//
+// Buckets live in Postgres so the limit holds across concurrent Lambda instances. The transcription
+// Lambda's Gemini quota (takeGeminiQuotaToken in lambda-transcription/quota.go) keeps its buckets in
+// the same table with copies of both queries; any change to the refill arithmetic or the table must
+// be made to both.
+func takeRateLimitToken(db *sql.DB, schema SchemaConfig, bucketKey string, limit RateLimit) (bool, int, error) {
+	refillPerSecond := limit.PerMinute / 60
+
+	// The WHERE clause leaves the row untouched (and returns nothing) when the bucket is empty
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS bucket ("bucketKey", tokens, "updatedAt")
+		VALUES ($1, $2 - 1, now())
+		ON CONFLICT ("bucketKey")
+		DO UPDATE SET
+			tokens = LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM (now() - bucket."updatedAt")) * $3) - 1,
+			"updatedAt" = now()
+		WHERE LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM (now() - bucket."updatedAt")) * $3) >= 1
+		RETURNING tokens
+	`, schema.Table("api_rate_limit_bucket"))
+
+	var remaining float64
+	err := db.QueryRow(query, bucketKey, limit.Burst, refillPerSecond).Scan(&remaining)
+	if err == nil {
+		return true, 0, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, 0, fmt.Errorf("error updating rate limit bucket: %v", err)
+	}
+
+	// Bucket is empty: work out when the next token arrives
+	var tokens float64
+	balanceQuery := fmt.Sprintf(`
+		SELECT LEAST($2, tokens + EXTRACT(EPOCH FROM (now() - "updatedAt")) * $3)
+		FROM %s
+		WHERE "bucketKey" = $1
+	`, schema.Table("api_rate_limit_bucket"))
+	if err := db.QueryRow(balanceQuery, bucketKey, limit.Burst, refillPerSecond).Scan(&tokens); err != nil {
+		return false, 0, fmt.Errorf("error reading rate limit bucket: %v", err)
+	}
+
+	retryAfter := int(math.Ceil((1 - tokens) / refillPerSecond))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	return false, retryAfter, nil
+}
+
+// checkRateLimit applies a limit to a bucket, returning a 429 response when the bucket is empty.
+// Limiter failures are logged and the request is let through so an unhealthy limiter doesn't take down the API.
+func checkRateLimit(schema SchemaConfig, bucketKey string, limit RateLimit) *events.APIGatewayProxyResponse {
+	if !limit.Enabled() {
+		return nil
+	}
+
+	db, err := pooledDatabase()
+	if err != nil {
+		log.Printf("⚠️ Rate limiter unavailable: %v", err)
+		return nil
+	}
+
+	allowed, retryAfter, err := takeRateLimitToken(db, schema, bucketKey, limit)
+	if err != nil {
+		log.Printf("⚠️ Rate limiter unavailable: %v", err)
+		return nil
+	}
+	if allowed {
+		return nil
+	}
+
+	log.Printf("🚦 Rate limited %s, retry after %ds", bucketKey, retryAfter)
+	response := errorResponse(429, "Too many requests, retry after %d seconds", retryAfter)
+	response.Headers["Retry-After"] = strconv.Itoa(retryAfter)
+	return &response
+}
+
+// lookupCampaignID returns the campaign a call log belongs to, or "" when the call log doesn't exist
+func lookupCampaignID(schema SchemaConfig, callLogsID string) (string, error) {
+	db, err := pooledDatabase()
+	if err != nil {
+		return "", err
+	}
+
+	var campaignID sql.NullString
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s = $1`,
+		schema.Column("call_logs", "campaignId"), schema.Table("call_logs"), schema.Column("call_logs", "id"))
+	err = db.QueryRow(query, callLogsID).Scan(&campaignID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error looking up campaign: %v", err)
+	}
+	return campaignID.String, nil
+}
@@ -0,0 +1,165 @@
+// This file is duplicated byte for byte in lambda-api-gateway and lambda-transcription. The two are
+// separate modules, each built and deployed from its own directory, so they can't share a package.
+// Change both copies together and check they still match with
+// "cmp lambda-api-gateway/rds_iam.go lambda-transcription/rds_iam.go".
+
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// rdsAuthTokenLifetime is how long an RDS IAM authentication token can be used to connect
+const rdsAuthTokenLifetime = 15 * time.Minute
+
+// dbIAMAuthEnabled reports whether database connections authenticate with RDS IAM tokens (DB_IAM_AUTH)
+func dbIAMAuthEnabled() bool {
+	return os.Getenv("DB_IAM_AUTH") == "true"
+}
+
+// rdsIAMConnector connects to PostgreSQL (directly or through RDS Proxy) with a fresh IAM
+// authentication token for every new connection, so expired tokens never need refreshing
+type rdsIAMConnector struct {
+	params map[string]string
+	region string
+}
+
+// newRDSIAMConnector parses the connection string (URL or key=value form). Any password in it is
+// ignored in favour of the token.
+func newRDSIAMConnector(connectionString string) (*rdsIAMConnector, error) {
+	if strings.HasPrefix(connectionString, "postgres://") || strings.HasPrefix(connectionString, "postgresql://") {
+		converted, err := pq.ParseURL(connectionString)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DB_CONNECTION_STRING: %v", err)
+		}
+		connectionString = converted
+	}
+
+	params, err := parseConnectionParams(connectionString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_CONNECTION_STRING: %v", err)
+	}
+	if params["host"] == "" || params["user"] == "" {
+		return nil, fmt.Errorf("DB_CONNECTION_STRING needs a host and user for IAM authentication")
+	}
+	if params["sslmode"] == "disable" {
+		return nil, fmt.Errorf("IAM authentication requires SSL; remove sslmode=disable from DB_CONNECTION_STRING")
+	}
+	if params["port"] == "" {
+		params["port"] = "5432"
+	}
+	delete(params, "password")
+
+	region := os.Getenv("DB_IAM_REGION")
+	if region == "" {
+		region = awsRegion()
+	}
+	return &rdsIAMConnector{params: params, region: region}, nil
+}
+
+// Connect opens a connection authenticated with a newly generated token
+func (c *rdsIAMConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	token, err := rdsAuthToken(net.JoinHostPort(c.params["host"], c.params["port"]), c.params["user"], c.region, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	params := make(map[string]string, len(c.params)+1)
+	for key, value := range c.params {
+		params[key] = value
+	}
+	params["password"] = token
+
+	connector, err := pq.NewConnector(formatConnectionParams(params))
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the PostgreSQL driver
+func (c *rdsIAMConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// rdsAuthToken generates an RDS IAM authentication token: a SigV4-presigned "connect" request for
+// the database user, without its scheme
+func rdsAuthToken(endpoint, user, region string, now time.Time) (string, error) {
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	rawURL := fmt.Sprintf("https://%s/?Action=connect&DBUser=%s", endpoint, awsURIEncode(user, true))
+	signed, err := presignAWSURL("GET", rawURL, "rds-db", region, creds, rdsAuthTokenLifetime, sha256Hex(nil), now)
+	if err != nil {
+		return "", fmt.Errorf("error generating RDS auth token: %v", err)
+	}
+	return strings.TrimPrefix(signed, "https://"), nil
+}
+
+// parseConnectionParams parses a key=value connection string, where values may be single-quoted
+// with backslash escapes
+func parseConnectionParams(connectionString string) (map[string]string, error) {
+	params := make(map[string]string)
+	s := strings.TrimSpace(connectionString)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("missing value in %q", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = strings.TrimLeft(s[eq+1:], " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "'") {
+			i := 1
+			for ; i < len(s) && s[i] != '\''; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("unterminated quoted value for %s", key)
+			}
+			s = s[i+1:]
+		} else {
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value.WriteString(s[:end])
+			s = s[end:]
+		}
+
+		params[key] = value.String()
+		s = strings.TrimLeft(s, " ")
+	}
+	return params, nil
+}
+
+// formatConnectionParams builds a key=value connection string, quoting every value
+func formatConnectionParams(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s='%s'", key, escaper.Replace(params[key]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// ErrorResponse is the body of every error response, an RFC 7807 problem details object
+type ErrorResponse struct {
+	Type   string       `json:"type" doc:"Always about:blank; the status code identifies the problem"`
+	Title  string       `json:"title" doc:"The status code's reason phrase"`
+	Status int          `json:"status"`
+	Detail string       `json:"detail"`
+	Error  string       `json:"error" doc:"Same as detail, for clients that predate problem details"`
+	Errors []FieldError `json:"errors,omitempty" doc:"The invalid fields of a rejected request"`
+}
+
+// FieldError describes why a single request field was rejected
+type FieldError struct {
+	Field   string `json:"field" doc:"JSON path of the field, or the path parameter's name"`
+	Message string `json:"message"`
+}
+
+// jsonResponse builds an API Gateway response with a JSON body
+func jsonResponse(statusCode int, body interface{}) events.APIGatewayProxyResponse {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return errorResponse(500, "Response marshal failed")
+	}
+
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": "application/json",
+		},
+		Body: string(jsonBody),
+	}
+}
+
+// errorResponse builds an API Gateway response with a problem details body
+func errorResponse(statusCode int, format string, args ...interface{}) events.APIGatewayProxyResponse {
+	return problemResponse(statusCode, nil, format, args...)
+}
+
+// problemResponse builds an API Gateway response with a problem details body listing the invalid fields
+func problemResponse(statusCode int, fieldErrors []FieldError, format string, args ...interface{}) events.APIGatewayProxyResponse {
+	detail := fmt.Sprintf(format, args...)
+	response := jsonResponse(statusCode, ErrorResponse{
+		Type:   "about:blank",
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+		Error:  detail,
+		Errors: fieldErrors,
+	})
+	response.Headers["Content-Type"] = problemContentType
+	return response
+}
+
+// textResponse builds an API Gateway response with a plain-text body of the given content type
+func textResponse(statusCode int, contentType, body string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{
+		StatusCode: statusCode,
+		Headers: map[string]string{
+			"Content-Type": contentType,
+		},
+		Body: body,
+	}
+}
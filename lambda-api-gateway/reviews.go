@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// reviewClaimTimeout is how long a claim holds before another reviewer can take the review over
+const reviewClaimTimeout = 2 * time.Hour
+
+// Review statuses
+const (
+	ReviewPending  = "pending"
+	ReviewClaimed  = "claimed"
+	ReviewReviewed = "reviewed"
+)
+
+// Review represents an analysis in the review queue
+type Review struct {
+	CallLogsID string          `json:"call_logsId"`
+	CampaignID string          `json:"campaignId"`
+	Status     string          `json:"status"`
+	Reasons    json.RawMessage `json:"reasons"`
+	ClaimedBy  *string         `json:"claimedBy"`
+	ClaimedAt  *time.Time      `json:"claimedAt"`
+	ReviewedBy *string         `json:"reviewedBy"`
+	ReviewedAt *time.Time      `json:"reviewedAt"`
+	Notes      *string         `json:"notes"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// ReviewCorrection is a reviewer's corrected answer to a question
+type ReviewCorrection struct {
+	QuestionID      string    `json:"questionId"`
+	ModelAnswer     *string   `json:"modelAnswer"`
+	CorrectedAnswer string    `json:"correctedAnswer"`
+	Reviewer        string    `json:"reviewer"`
+	CreatedAt       time.Time `json:"createdAt"`
+}
+
+// ReviewList is the GET /reviews response
+type ReviewList struct {
+	Reviews []Review `json:"reviews"`
+}
+
+// ReviewDetail is the GET /reviews/{id} response
+type ReviewDetail struct {
+	Review       Review             `json:"review"`
+	ModelAnswers map[string]string  `json:"modelAnswers"`
+	Corrections  []ReviewCorrection `json:"corrections"`
+}
+
+// ReviewResult is the response of the claim and complete actions
+type ReviewResult struct {
+	Review Review `json:"review"`
+}
+
+// ReviewAction is the body of the review actions
+type ReviewAction struct {
+	Reviewer    string            `json:"reviewer,omitempty" doc:"Defaults to the authenticated client ID"`
+	Corrections map[string]string `json:"corrections,omitempty" doc:"Corrected answers by question ID (corrections only)"`
+	Notes       string            `json:"notes,omitempty" doc:"Reviewer notes (complete only)"`
+}
+
+// reviewColumns are the analysis_reviews columns scanned by scanReview
+const reviewColumns = `"call_logsId", "campaignId", status, reasons, "claimedBy", "claimedAt", "reviewedBy", "reviewedAt", notes, "createdAt"`
+
+// scanReview scans a row of reviewColumns
+func scanReview(scan func(dest ...interface{}) error) (Review, error) {
+	var review Review
+	var reasons []byte
+	err := scan(&review.CallLogsID, &review.CampaignID, &review.Status, &reasons, &review.ClaimedBy,
+		&review.ClaimedAt, &review.ReviewedBy, &review.ReviewedAt, &review.Notes, &review.CreatedAt)
+	review.Reasons = json.RawMessage(reasons)
+	return review, err
+}
+
+// reviewAction is a review endpoint, given the parsed body of POSTs
+type reviewAction func(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest, body ReviewAction) events.APIGatewayProxyResponse
+
+// reviewHandler adapts a review endpoint:
+//
+//	GET  /reviews?status=pending&campaignId=<id>&limit=50   list the queue, oldest first
+//	GET  /reviews/{id}                                      a review with the model's answers and corrections
+//	POST /reviews/{id}/claim                                claim a review   {"reviewer": "..."}
+//	POST /reviews/{id}/corrections                          correct answers  {"reviewer": "...", "corrections": {"<questionId>": "..."}}
+//	POST /reviews/{id}/complete                             mark reviewed    {"reviewer": "...", "notes": "..."}
+//
+// The reviewer defaults to the authenticated client ID.
+func reviewHandler(action reviewAction) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		var body ReviewAction
+		if request.HTTPMethod == "POST" {
+			if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+				return errorResponse(400, "JSON parse failed: %s", err.Error()), nil
+			}
+			if body.Reviewer == "" {
+				body.Reviewer = clientIDFromContext(ctx)
+			}
+		}
+
+		db, err := openDatabase()
+		if err != nil {
+			log.Printf("❌ Database error: %v", err)
+			return errorResponse(500, "Database unavailable"), nil
+		}
+		defer db.Close()
+
+		return action(db, schemaFromContext(ctx), request, body), nil
+	}
+}
+
+// listReviews returns the review queue, oldest first
+func listReviews(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	status := request.QueryStringParameters["status"]
+	if status == "" {
+		status = ReviewPending
+	}
+	if status != ReviewPending && status != ReviewClaimed && status != ReviewReviewed {
+		return errorResponse(400, "status must be pending, claimed or reviewed")
+	}
+
+	limit := 50
+	if raw := request.QueryStringParameters["limit"]; raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > 500 {
+			return errorResponse(400, "limit must be between 1 and 500")
+		}
+		limit = n
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE status = $1 AND ($2 = '' OR "campaignId"::text = $2)
+		ORDER BY "createdAt"
+		LIMIT $3
+	`, reviewColumns, schema.Table("analysis_reviews"))
+
+	rows, err := db.Query(query, status, request.QueryStringParameters["campaignId"], limit)
+	if err != nil {
+		log.Printf("❌ Review list error: %v", err)
+		return errorResponse(500, "Error listing reviews")
+	}
+	defer rows.Close()
+
+	reviews := []Review{}
+	for rows.Next() {
+		review, err := scanReview(rows.Scan)
+		if err != nil {
+			log.Printf("❌ Review scan error: %v", err)
+			return errorResponse(500, "Error listing reviews")
+		}
+		reviews = append(reviews, review)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Review rows error: %v", err)
+		return errorResponse(500, "Error listing reviews")
+	}
+
+	return jsonResponse(200, ReviewList{Reviews: reviews})
+}
+
+// getReview returns a review with the model's answers and the corrections made so far
+func getReview(db *sql.DB, schema SchemaConfig, callLogsID string) events.APIGatewayProxyResponse {
+	review, err := scanReview(db.QueryRow(fmt.Sprintf(`SELECT %s FROM %s WHERE "call_logsId" = $1`,
+		reviewColumns, schema.Table("analysis_reviews")), callLogsID).Scan)
+	if err == sql.ErrNoRows {
+		return errorResponse(404, "No review found for call_logsId: %s", callLogsID)
+	}
+	if err != nil {
+		log.Printf("❌ Review query error: %v", err)
+		return errorResponse(500, "Error fetching review")
+	}
+
+	modelAnswers, err := modelAnswersForCall(db, schema, callLogsID)
+	if err != nil {
+		log.Printf("❌ Review answers error: %v", err)
+		return errorResponse(500, "Error fetching review")
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT "questionId", "modelAnswer", "correctedAnswer", reviewer, "createdAt"
+		FROM %s
+		WHERE "call_logsId" = $1
+		ORDER BY "questionId"
+	`, schema.Table("analysis_review_corrections")), callLogsID)
+	if err != nil {
+		log.Printf("❌ Review corrections error: %v", err)
+		return errorResponse(500, "Error fetching review")
+	}
+	defer rows.Close()
+
+	corrections := []ReviewCorrection{}
+	for rows.Next() {
+		var c ReviewCorrection
+		if err := rows.Scan(&c.QuestionID, &c.ModelAnswer, &c.CorrectedAnswer, &c.Reviewer, &c.CreatedAt); err != nil {
+			log.Printf("❌ Review corrections scan error: %v", err)
+			return errorResponse(500, "Error fetching review")
+		}
+		corrections = append(corrections, c)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Review corrections rows error: %v", err)
+		return errorResponse(500, "Error fetching review")
+	}
+
+	return jsonResponse(200, ReviewDetail{Review: review, ModelAnswers: modelAnswers, Corrections: corrections})
+}
+
+// modelAnswersForCall reads the answers from the call's stored callAnalysis
+func modelAnswersForCall(db *sql.DB, schema SchemaConfig, callLogsID string) (map[string]string, error) {
+	query := fmt.Sprintf(`SELECT COALESCE(%s->'answers', '{}') FROM %s WHERE %s = $1`,
+		schema.Column("call_logs", "callAnalysis"), schema.Table("call_logs"), schema.Column("call_logs", "id"))
+
+	var answersJSON []byte
+	if err := db.QueryRow(query, callLogsID).Scan(&answersJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return map[string]string{}, nil
+		}
+		return nil, err
+	}
+
+	answers := map[string]string{}
+	if err := json.Unmarshal(answersJSON, &answers); err != nil {
+		return nil, fmt.Errorf("error parsing answers: %v", err)
+	}
+	return answers, nil
+}
+
+// claimReview assigns a pending review to the reviewer. A reviewer can re-claim their own review,
+// and claims older than reviewClaimTimeout can be taken over.
+func claimReview(db *sql.DB, schema SchemaConfig, callLogsID, reviewer string) events.APIGatewayProxyResponse {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET status = 'claimed', "claimedBy" = $2, "claimedAt" = now(), "updatedAt" = now()
+		WHERE "call_logsId" = $1
+		  AND (status = 'pending'
+		       OR (status = 'claimed' AND ("claimedBy" = $2 OR "claimedAt" < now() - $3::interval)))
+		RETURNING %s
+	`, schema.Table("analysis_reviews"), reviewColumns)
+
+	timeout := fmt.Sprintf("%d seconds", int(reviewClaimTimeout.Seconds()))
+	review, err := scanReview(db.QueryRow(query, callLogsID, reviewer, timeout).Scan)
+	if err == sql.ErrNoRows {
+		return reviewConflict(db, schema, callLogsID, reviewer)
+	}
+	if err != nil {
+		log.Printf("❌ Review claim error: %v", err)
+		return errorResponse(500, "Error claiming review")
+	}
+	return jsonResponse(200, ReviewResult{Review: review})
+}
+
+// correctReview stores the reviewer's corrected answers alongside the model's answers
+func correctReview(db *sql.DB, schema SchemaConfig, callLogsID string, body ReviewAction) events.APIGatewayProxyResponse {
+	if len(body.Corrections) == 0 {
+		return errorResponse(400, "corrections are required")
+	}
+	if response := requireClaim(db, schema, callLogsID, body.Reviewer); response != nil {
+		return *response
+	}
+
+	modelAnswers, err := modelAnswersForCall(db, schema, callLogsID)
+	if err != nil {
+		log.Printf("❌ Review answers error: %v", err)
+		return errorResponse(500, "Error saving corrections")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		log.Printf("❌ Review transaction error: %v", err)
+		return errorResponse(500, "Error saving corrections")
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "questionId", "modelAnswer", "correctedAnswer", reviewer, "createdAt")
+		VALUES ($1, $2, $3, $4, $5, now())
+		ON CONFLICT ("call_logsId", "questionId")
+		DO UPDATE SET "modelAnswer" = EXCLUDED."modelAnswer", "correctedAnswer" = EXCLUDED."correctedAnswer",
+		              reviewer = EXCLUDED.reviewer, "createdAt" = EXCLUDED."createdAt"
+	`, schema.Table("analysis_review_corrections"))
+
+	for questionID, corrected := range body.Corrections {
+		var modelAnswer interface{}
+		if answer, ok := modelAnswers[questionID]; ok {
+			modelAnswer = answer
+		}
+		if _, err := tx.Exec(query, callLogsID, questionID, modelAnswer, corrected, body.Reviewer); err != nil {
+			log.Printf("❌ Review correction error: %v", err)
+			return errorResponse(500, "Error saving corrections")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Printf("❌ Review commit error: %v", err)
+		return errorResponse(500, "Error saving corrections")
+	}
+	return getReview(db, schema, callLogsID)
+}
+
+// completeReview marks the reviewer's claimed review as reviewed
+func completeReview(db *sql.DB, schema SchemaConfig, callLogsID string, body ReviewAction) events.APIGatewayProxyResponse {
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET status = 'reviewed', "reviewedBy" = $2, "reviewedAt" = now(), notes = NULLIF($3, ''), "updatedAt" = now()
+		WHERE "call_logsId" = $1 AND status = 'claimed' AND "claimedBy" = $2
+		RETURNING %s
+	`, schema.Table("analysis_reviews"), reviewColumns)
+
+	review, err := scanReview(db.QueryRow(query, callLogsID, body.Reviewer, body.Notes).Scan)
+	if err == sql.ErrNoRows {
+		return reviewConflict(db, schema, callLogsID, body.Reviewer)
+	}
+	if err != nil {
+		log.Printf("❌ Review complete error: %v", err)
+		return errorResponse(500, "Error completing review")
+	}
+	return jsonResponse(200, ReviewResult{Review: review})
+}
+
+// requireClaim returns an error response unless the reviewer holds the review's claim
+func requireClaim(db *sql.DB, schema SchemaConfig, callLogsID, reviewer string) *events.APIGatewayProxyResponse {
+	var status string
+	var claimedBy sql.NullString
+	err := db.QueryRow(fmt.Sprintf(`SELECT status, "claimedBy" FROM %s WHERE "call_logsId" = $1`,
+		schema.Table("analysis_reviews")), callLogsID).Scan(&status, &claimedBy)
+	if err == nil && status == ReviewClaimed && claimedBy.String == reviewer {
+		return nil
+	}
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("❌ Review claim check error: %v", err)
+		response := errorResponse(500, "Error checking review claim")
+		return &response
+	}
+	response := reviewConflict(db, schema, callLogsID, reviewer)
+	return &response
+}
+
+// reviewConflict explains why the reviewer can't act on a review: 404 when it doesn't exist, otherwise 409
+func reviewConflict(db *sql.DB, schema SchemaConfig, callLogsID, reviewer string) events.APIGatewayProxyResponse {
+	var status string
+	var claimedBy sql.NullString
+	err := db.QueryRow(fmt.Sprintf(`SELECT status, "claimedBy" FROM %s WHERE "call_logsId" = $1`,
+		schema.Table("analysis_reviews")), callLogsID).Scan(&status, &claimedBy)
+	switch {
+	case err == sql.ErrNoRows:
+		return errorResponse(404, "No review found for call_logsId: %s", callLogsID)
+	case err != nil:
+		log.Printf("❌ Review query error: %v", err)
+		return errorResponse(500, "Error fetching review")
+	case status == ReviewReviewed:
+		return errorResponse(409, "Review has already been completed")
+	case status == ReviewClaimed && claimedBy.String != reviewer:
+		return errorResponse(409, "Review is claimed by %s", claimedBy.String)
+	}
+	return errorResponse(409, "Review must be claimed by %s first", reviewer)
+}
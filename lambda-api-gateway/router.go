@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// middleware wraps an apiHandler, e.g. to authenticate the request before calling it
+type middleware func(next apiHandler) apiHandler
+
+// route is a handler registered for a method and path pattern
+type route struct {
+	method     string
+	pattern    string
+	segments   []string
+	handler    apiHandler
+	middleware []middleware
+}
+
+// router dispatches API Gateway requests by method and path. Patterns are paths whose {name}
+// segments match any non-empty segment; the matched values are passed to the handler in the
+// request's PathParameters.
+type router struct {
+	routes     []route
+	middleware []middleware
+	fallback   *route
+}
+
+// routeContextKey is the context key of the pattern of the route serving a request
+type routeContextKey struct{}
+
+// routePattern returns the pattern of the route serving the request, or "" when no route matched
+func routePattern(ctx context.Context) string {
+	pattern, _ := ctx.Value(routeContextKey{}).(string)
+	return pattern
+}
+
+// use adds middleware run for every request, before any route middleware, in the order added
+func (r *router) use(mw ...middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// handle registers a handler for a method and pattern, wrapped in the given route middleware
+func (r *router) handle(method, pattern string, handler apiHandler, mw ...middleware) {
+	r.routes = append(r.routes, route{
+		method:     method,
+		pattern:    pattern,
+		segments:   pathSegments(pattern),
+		handler:    handler,
+		middleware: mw,
+	})
+}
+
+// handleFallback registers the handler for requests of a method to paths no route's first segment
+// claims, as the pattern's route. API Gateway delivers processing requests on its resource path
+// (e.g. /smartFloCallProcessingAPI), so it can't be listed up front.
+func (r *router) handleFallback(method, pattern string, handler apiHandler, mw ...middleware) {
+	r.fallback = &route{method: method, pattern: pattern, handler: handler, middleware: mw}
+}
+
+// serve dispatches a request to its route through the router and route middleware. Unknown paths
+// get a 404, and known paths requested with another method a 405 with an Allow header.
+func (r *router) serve(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	matched, params, allowed := r.match(request.HTTPMethod, request.Path)
+
+	var handler apiHandler
+	switch {
+	case matched != nil:
+		request.PathParameters = params
+		ctx = context.WithValue(ctx, routeContextKey{}, matched.pattern)
+		handler = chain(matched.handler, matched.middleware)
+	case len(allowed) > 0:
+		handler = func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			response := errorResponse(405, "Method %s not allowed", request.HTTPMethod)
+			response.Headers["Allow"] = strings.Join(allowed, ", ")
+			return response, nil
+		}
+	default:
+		handler = func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return errorResponse(404, "Not found"), nil
+		}
+	}
+
+	return chain(handler, r.middleware)(ctx, request)
+}
+
+// match finds the route for a request. When none matches, it returns the methods the path is
+// served with, for the 405's Allow header.
+func (r *router) match(method, path string) (*route, map[string]string, []string) {
+	segments := pathSegments(path)
+	claimed := false
+	var allowed []string
+
+	for i := range r.routes {
+		candidate := &r.routes[i]
+		if candidate.segments[0] != "" && candidate.segments[0] == segments[0] {
+			claimed = true
+		}
+		params, ok := matchSegments(candidate.segments, segments)
+		if !ok {
+			continue
+		}
+		if candidate.method == method {
+			return candidate, params, nil
+		}
+		allowed = append(allowed, candidate.method)
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		return nil, nil, allowed
+	}
+	if r.fallback != nil && !claimed {
+		if r.fallback.method == method {
+			return r.fallback, map[string]string{}, nil
+		}
+		return nil, nil, []string{r.fallback.method}
+	}
+	return nil, nil, nil
+}
+
+// matchSegments matches path segments against a pattern's, returning the {name} parameters
+func matchSegments(pattern, segments []string) (map[string]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, segment := range pattern {
+		if match := pathParamPattern.FindStringSubmatch(segment); match != nil && match[0] == segment {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[match[1]] = segments[i]
+		} else if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// pathSegments splits a path into its segments, ignoring leading and trailing slashes
+func pathSegments(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+// chain wraps a handler in middleware so the first middleware runs first
+func chain(handler apiHandler, mw []middleware) apiHandler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// searchEmbeddingModel must match the model the transcription pipeline stores in call_embeddings
+const searchEmbeddingModel = "text-embedding-004"
+
+// Search result limits
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+	searchSnippetRunes = 300
+)
+
+// SearchResult represents a call similar to the search query
+type SearchResult struct {
+	CallLogsID string  `json:"call_logsId"`
+	CampaignID string  `json:"campaignId,omitempty"`
+	Similarity float64 `json:"similarity"`
+	Snippet    string  `json:"snippet"`
+}
+
+// SearchResponse is the GET /search response
+type SearchResponse struct {
+	Query   string         `json:"query"`
+	Results []SearchResult `json:"results"`
+}
+
+// handleSearch finds the calls whose transcripts are most similar to a free-text query
+//
+//	GET /search?q=customer+wants+a+refund&campaignId=<id>&limit=10
+func handleSearch(schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	query := strings.TrimSpace(request.QueryStringParameters["q"])
+	if query == "" {
+		return errorResponse(400, "q is required")
+	}
+	campaignID := request.QueryStringParameters["campaignId"]
+
+	limit, err := searchLimit(request)
+	if err != nil {
+		return errorResponse(400, "%v", err)
+	}
+
+	embedding, err := embedSearchQuery(query)
+	if err != nil {
+		log.Printf("❌ Search embedding error: %v", err)
+		return errorResponse(502, "Error embedding search query")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	sqlQuery := fmt.Sprintf(`
+		SELECT "call_logsId", COALESCE("campaignId", ''), content, 1 - (embedding <=> $1::vector) AS similarity
+		FROM %s
+		WHERE ($2 = '' OR "campaignId"::text = $2)
+		ORDER BY embedding <=> $1::vector
+		LIMIT $3
+	`, schema.Table("call_embeddings"))
+
+	rows, err := db.Query(sqlQuery, embedding, campaignID, limit)
+	if err != nil {
+		log.Printf("❌ Search query error: %v", err)
+		return errorResponse(500, "Error searching calls")
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	for rows.Next() {
+		var result SearchResult
+		var content string
+		if err := rows.Scan(&result.CallLogsID, &result.CampaignID, &content, &result.Similarity); err != nil {
+			log.Printf("❌ Search scan error: %v", err)
+			return errorResponse(500, "Error searching calls")
+		}
+		result.Snippet = searchSnippet(content)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Search rows error: %v", err)
+		return errorResponse(500, "Error searching calls")
+	}
+
+	return jsonResponse(200, SearchResponse{Query: query, Results: results})
+}
+
+// TextSearchResult represents a call whose transcription matches a full-text query
+type TextSearchResult struct {
+	CallLogsID string  `json:"call_logsId"`
+	CampaignID string  `json:"campaignId,omitempty"`
+	Rank       float64 `json:"rank"`
+	Highlight  string  `json:"highlight" doc:"Matching fragments with matches wrapped in <mark> tags"`
+}
+
+// TextSearchResponse is the GET /search/text response
+type TextSearchResponse struct {
+	Query   string             `json:"query"`
+	Results []TextSearchResult `json:"results"`
+}
+
+// handleTextSearch finds the calls whose transcriptions match a full-text query, best matches first, with
+// the matching fragments highlighted. The query accepts web-search syntax: "quoted phrases", OR and -exclusions.
+//
+//	GET /search/text?q="refund request" -cancelled&campaignId=<id>&limit=10
+func handleTextSearch(schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	query := strings.TrimSpace(request.QueryStringParameters["q"])
+	if query == "" {
+		return errorResponse(400, "q is required")
+	}
+	campaignID := request.QueryStringParameters["campaignId"]
+
+	limit, err := searchLimit(request)
+	if err != nil {
+		return errorResponse(400, "%v", err)
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	// Rank and limit first so ts_headline only runs on the returned rows
+	sqlQuery := fmt.Sprintf(`
+		SELECT matches."call_logsId", COALESCE(matches."campaignId"::text, ''), matches.rank,
+		       ts_headline('simple', matches.transcription, websearch_to_tsquery('simple', $1),
+		                   'StartSel=<mark>, StopSel=</mark>, MaxFragments=3, MaxWords=30, MinWords=10')
+		FROM (
+			SELECT "call_logsId", "campaignId", transcription,
+			       ts_rank_cd(search, websearch_to_tsquery('simple', $1)) AS rank
+			FROM %s
+			WHERE search @@ websearch_to_tsquery('simple', $1)
+			  AND ($2 = '' OR "campaignId"::text = $2)
+			ORDER BY rank DESC
+			LIMIT $3
+		) matches
+		ORDER BY matches.rank DESC
+	`, schema.Table("call_transcript_search"))
+
+	rows, err := db.Query(sqlQuery, query, campaignID, limit)
+	if err != nil {
+		log.Printf("❌ Text search query error: %v", err)
+		return errorResponse(500, "Error searching calls")
+	}
+	defer rows.Close()
+
+	results := []TextSearchResult{}
+	for rows.Next() {
+		var result TextSearchResult
+		if err := rows.Scan(&result.CallLogsID, &result.CampaignID, &result.Rank, &result.Highlight); err != nil {
+			log.Printf("❌ Text search scan error: %v", err)
+			return errorResponse(500, "Error searching calls")
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Text search rows error: %v", err)
+		return errorResponse(500, "Error searching calls")
+	}
+
+	return jsonResponse(200, TextSearchResponse{Query: query, Results: results})
+}
+
+// searchLimit reads the limit query parameter, defaulting to defaultSearchLimit
+func searchLimit(request events.APIGatewayProxyRequest) (int, error) {
+	raw := request.QueryStringParameters["limit"]
+	if raw == "" {
+		return defaultSearchLimit, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxSearchLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxSearchLimit)
+	}
+	return n, nil
+}
+
+// embedSearchQuery embeds the query with Gemini and returns it as a pgvector literal
+func embedSearchQuery(query string) (string, error) {
+	apiKey := os.Getenv("GEMINI_API_KEY")
+	if apiKey == "" {
+		return "", fmt.Errorf("GEMINI_API_KEY not set")
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":    "models/" + searchEmbeddingModel,
+		"content":  map[string]interface{}{"parts": []map[string]string{{"text": query}}},
+		"taskType": "RETRIEVAL_QUERY",
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling embedding request: %v", err)
+	}
+
+	embedURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:embedContent", searchEmbeddingModel)
+	req, err := http.NewRequest("POST", embedURL, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating embedding request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// In a header rather than the URL, which a failed request's error quotes
+	req.Header.Set("x-goog-api-key", apiKey)
+
+	resp, err := httpClient(10 * time.Second).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error making embedding request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading embedding response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("gemini embedding API error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var embedResp struct {
+		Embedding struct {
+			Values []float64 `json:"values"`
+		} `json:"embedding"`
+	}
+	if err := json.Unmarshal(respBody, &embedResp); err != nil {
+		return "", fmt.Errorf("error decoding embedding response: %v", err)
+	}
+	if len(embedResp.Embedding.Values) == 0 {
+		return "", fmt.Errorf("empty embedding received from Gemini API")
+	}
+
+	parts := make([]string, len(embedResp.Embedding.Values))
+	for i, value := range embedResp.Embedding.Values {
+		parts[i] = strconv.FormatFloat(value, 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]", nil
+}
+
+// searchSnippet returns the start of a transcript for display in search results
+func searchSnippet(content string) string {
+	if utf8.RuneCountInString(content) <= searchSnippetRunes {
+		return content
+	}
+	return string([]rune(content)[:searchSnippetRunes]) + "…"
+}
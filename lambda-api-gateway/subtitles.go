@@ -0,0 +1,51 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// handleGetSubtitles returns the SRT or WebVTT captions for a processed call
+//
+//	GET /analysis/{id}/subtitles?format=vtt
+func handleGetSubtitles(schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	callLogsID := request.PathParameters["id"]
+	format := strings.ToLower(request.QueryStringParameters["format"])
+	if format == "" {
+		format = "vtt"
+	}
+	if format != "srt" && format != "vtt" {
+		return errorResponse(400, "format must be 'srt' or 'vtt'")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(`
+		SELECT srt, vtt
+		FROM %s
+		WHERE "call_logsId" = $1
+	`, schema.Table("call_subtitles"))
+
+	var srt, vtt string
+	if err := db.QueryRow(query, callLogsID).Scan(&srt, &vtt); err != nil {
+		if err == sql.ErrNoRows {
+			return errorResponse(404, "No subtitles found for call_logsId: %s", callLogsID)
+		}
+		log.Printf("❌ Subtitle query error: %v", err)
+		return errorResponse(500, "Error fetching subtitles")
+	}
+
+	if format == "srt" {
+		return textResponse(200, "application/x-subrip; charset=utf-8", srt)
+	}
+	return textResponse(200, "text/vtt; charset=utf-8", vtt)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultUploadURLLifetime is how long an upload URL stays valid unless UPLOAD_URL_TTL_SECONDS is set
+const defaultUploadURLLifetime = 15 * time.Minute
+
+// UploadRequest is the body of POST /uploads
+type UploadRequest struct {
+	CampaignID string            `json:"campaignId,omitempty" format:"uuid" doc:"Campaign whose settings are used, and whose questions are answered when none are given"`
+	Questions  []QuestionDetails `json:"questions,omitempty" doc:"Questions to answer, identified as q1, q2, ... in the answers and in showIf conditions"`
+}
+
+// UploadJob is an ad-hoc recording upload and, once processed, its result
+type UploadJob struct {
+	ID         string `json:"id"`
+	Status     string `json:"status" doc:"pending until the recording is uploaded, then processing, completed or failed"`
+	CampaignID string `json:"campaignId,omitempty"`
+	// UploadURL is only returned when the job is created
+	UploadURL   string          `json:"uploadUrl,omitempty" doc:"Presigned URL to PUT the recording to"`
+	ExpiresAt   string          `json:"expiresAt,omitempty" doc:"When the upload URL expires"`
+	Result      json.RawMessage `json:"result,omitempty" doc:"The processing response: transcription, answers and processing metadata"`
+	Error       string          `json:"error,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+}
+
+// uploadStorage is where uploaded recordings are stored, read from UPLOAD_S3_BUCKET and
+// UPLOAD_S3_PREFIX (default "uploads")
+func uploadStorage() (bucket, prefix string) {
+	prefix = strings.Trim(os.Getenv("UPLOAD_S3_PREFIX"), "/")
+	if prefix == "" {
+		prefix = "uploads"
+	}
+	return os.Getenv("UPLOAD_S3_BUCKET"), prefix
+}
+
+// uploadURLLifetime reads UPLOAD_URL_TTL_SECONDS
+func uploadURLLifetime() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("UPLOAD_URL_TTL_SECONDS")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultUploadURLLifetime
+}
+
+// createUpload creates an upload job with the questions to answer and returns a presigned URL to
+// PUT the recording to. The S3 notification for the upload has the transcription pipeline process
+// it; poll GET /uploads/{id} for the result.
+//
+//	POST /uploads {"questions": [{"questionText": "Did the customer agree to a demo?", "answerType": "boolean"}]}
+func createUpload(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	schema := schemaFromContext(ctx)
+
+	bucket, prefix := uploadStorage()
+	if bucket == "" {
+		return errorResponse(503, "Uploads are not configured; set UPLOAD_S3_BUCKET"), nil
+	}
+
+	// The body was checked against UploadRequest by withValidation
+	var body UploadRequest
+	if err := json.Unmarshal([]byte(request.Body), &body); err != nil {
+		return errorResponse(400, "JSON parse failed: %s", err.Error()), nil
+	}
+	if body.CampaignID == "" && len(body.Questions) == 0 {
+		return problemResponse(400, []FieldError{{Field: "questions", Message: "is required without a campaignId"}}, "Upload validation failed"), nil
+	}
+	if fieldErrors := validateUploadQuestions(body.Questions); len(fieldErrors) > 0 {
+		return problemResponse(400, fieldErrors, "Upload validation failed"), nil
+	}
+
+	questions := make([]json.RawMessage, len(body.Questions))
+	for i, details := range body.Questions {
+		detailsJSON, err := marshalQuestionDetails(details)
+		if err != nil {
+			return errorResponse(500, "Error creating upload"), nil
+		}
+		questions[i] = detailsJSON
+	}
+	questionsJSON, err := json.Marshal(questions)
+	if err != nil {
+		return errorResponse(500, "Error creating upload"), nil
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		log.Printf("❌ Upload ID error: %v", err)
+		return errorResponse(500, "Error creating upload"), nil
+	}
+	objectKey := prefix + "/" + id
+
+	creds, err := loadAWSCredentials()
+	if err != nil {
+		log.Printf("❌ AWS credentials error: %v", err)
+		return errorResponse(500, "Error creating upload"), nil
+	}
+	now := time.Now().UTC()
+	lifetime := uploadURLLifetime()
+	objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, awsRegion(), awsURIEncode(objectKey, false))
+	uploadURL, err := presignAWSURL("PUT", objectURL, "s3", awsRegion(), creds, lifetime, "UNSIGNED-PAYLOAD", now)
+	if err != nil {
+		log.Printf("❌ Upload URL signing error: %v", err)
+		return errorResponse(500, "Error creating upload"), nil
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable"), nil
+	}
+	defer db.Close()
+
+	job := UploadJob{ID: id, Status: "pending", CampaignID: body.CampaignID, UploadURL: uploadURL, ExpiresAt: now.Add(lifetime).Format(time.RFC3339)}
+	query := fmt.Sprintf(`
+		INSERT INTO %s (id, "objectKey", "campaignId", questions, "createdBy")
+		VALUES ($1, $2, NULLIF($3, '')::uuid, $4, NULLIF($5, ''))
+		RETURNING "createdAt"
+	`, schema.Table("audio_uploads"))
+	if err := db.QueryRow(query, id, objectKey, body.CampaignID, string(questionsJSON), clientIDFromContext(ctx)).Scan(&job.CreatedAt); err != nil {
+		log.Printf("❌ Upload insert error: %v", err)
+		return errorResponse(500, "Error creating upload"), nil
+	}
+
+	return jsonResponse(201, job), nil
+}
+
+// getUpload returns an upload job's status, and its result once processed
+//
+//	GET /uploads/{id}
+func getUpload(db *sql.DB, schema SchemaConfig, request events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	id := request.PathParameters["id"]
+
+	query := fmt.Sprintf(`
+		SELECT id::text, status, COALESCE("campaignId"::text, ''), COALESCE(result::text, ''), COALESCE(error, ''),
+		       "createdAt", "completedAt"
+		FROM %s
+		WHERE id::text = $1
+	`, schema.Table("audio_uploads"))
+
+	var job UploadJob
+	var result string
+	var completedAt sql.NullTime
+	err := db.QueryRow(query, id).Scan(&job.ID, &job.Status, &job.CampaignID, &result, &job.Error, &job.CreatedAt, &completedAt)
+	if err == sql.ErrNoRows {
+		return errorResponse(404, "No upload with ID %s", id)
+	}
+	if err != nil {
+		log.Printf("❌ Upload query error: %v", err)
+		return errorResponse(500, "Error loading upload")
+	}
+	if result != "" {
+		job.Result = json.RawMessage(result)
+	}
+	if completedAt.Valid {
+		job.CompletedAt = &completedAt.Time
+	}
+	return jsonResponse(200, job)
+}
+
+// validateUploadQuestions checks the questions of an upload; showIf conditions refer to the other
+// questions by position, as q1, q2, ...
+func validateUploadQuestions(questions []QuestionDetails) []FieldError {
+	var fieldErrors []FieldError
+	for i, details := range questions {
+		path := fmt.Sprintf("questions[%d]", i)
+		errs, conditions := validateQuestionDetails(details, path)
+		fieldErrors = append(fieldErrors, errs...)
+
+		for j, condition := range conditions {
+			n, err := strconv.Atoi(strings.TrimPrefix(condition.QuestionID, "q"))
+			field := joinFieldPath(path, "showIf")
+			if len(conditions) > 1 {
+				field = fmt.Sprintf("%s[%d]", field, j)
+			}
+			switch {
+			case !strings.HasPrefix(condition.QuestionID, "q") || err != nil || n < 1 || n > len(questions):
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".questionId", Message: fmt.Sprintf("must be one of q1 to q%d", len(questions))})
+			case n == i+1:
+				fieldErrors = append(fieldErrors, FieldError{Field: field + ".questionId", Message: "must not be the question itself"})
+			}
+		}
+	}
+	return fieldErrors
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// defaultMaxBodyBytes is the largest request body accepted when API_MAX_BODY_BYTES is not set
+const defaultMaxBodyBytes = 64 * 1024
+
+// uuidPattern matches a UUID in its canonical 8-4-4-4-12 hex form
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// apiHandler handles an API Gateway request; middleware wraps one apiHandler in another
+type apiHandler func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// maxBodyBytes returns the request body limit from API_MAX_BODY_BYTES
+func maxBodyBytes() int {
+	if value, err := strconv.Atoi(os.Getenv("API_MAX_BODY_BYTES")); err == nil && value > 0 {
+		return value
+	}
+	return defaultMaxBodyBytes
+}
+
+// withValidation rejects malformed requests before they reach the handler:
+//   - bodies over API_MAX_BODY_BYTES get a 413
+//   - path parameters of the routed apiRoute must be UUIDs
+//   - JSON bodies must match the schema of the route's Body type (see validateJSONBody)
+//
+// Rejections are problem details listing every invalid field.
+func withValidation(next apiHandler) apiHandler {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if limit := maxBodyBytes(); len(request.Body) > limit {
+			return errorResponse(413, "Request body exceeds %d bytes", limit), nil
+		}
+
+		route := findAPIRoute(request.HTTPMethod, routePattern(ctx))
+		if route == nil {
+			return next(ctx, request)
+		}
+
+		var fieldErrors []FieldError
+		for name, value := range request.PathParameters {
+			if !uuidPattern.MatchString(value) {
+				fieldErrors = append(fieldErrors, FieldError{Field: name, Message: "must be a UUID"})
+			}
+		}
+
+		if route.Body != nil {
+			if strings.TrimSpace(request.Body) == "" {
+				fieldErrors = append(fieldErrors, FieldError{Field: "body", Message: "is required"})
+			} else {
+				errs, err := validateJSONBody(request.Body, reflect.TypeOf(route.Body))
+				if err != nil {
+					return errorResponse(400, "JSON parse failed: %s", err.Error()), nil
+				}
+				fieldErrors = append(fieldErrors, errs...)
+			}
+		}
+
+		if len(fieldErrors) > 0 {
+			return problemResponse(400, fieldErrors, "Request validation failed"), nil
+		}
+		return next(ctx, request)
+	}
+}
+
+// findAPIRoute returns the apiRoute documenting a method and route pattern, or nil when none does
+func findAPIRoute(method, pattern string) *apiRoute {
+	for i := range apiRoutes {
+		if apiRoutes[i].Method == method && apiRoutes[i].Path == pattern {
+			return &apiRoutes[i]
+		}
+	}
+	return nil
+}
+
+// validateJSONBody checks a JSON body against the schema of a Go type, following the same rules as
+// the OpenAPI document: fields without omitempty that aren't pointers are required, unknown fields are
+// rejected and a format tag of "uuid" requires a UUID. It returns an error when the body isn't JSON.
+func validateJSONBody(body string, t reflect.Type) ([]FieldError, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+
+	var fieldErrors []FieldError
+	validateValue(t, value, "", "", &fieldErrors)
+	return fieldErrors, nil
+}
+
+// validateValue appends the ways a decoded JSON value doesn't match a Go type to fieldErrors
+func validateValue(t reflect.Type, value interface{}, path, format string, fieldErrors *[]FieldError) {
+	fail := func(message string) {
+		field := path
+		if field == "" {
+			field = "body"
+		}
+		*fieldErrors = append(*fieldErrors, FieldError{Field: field, Message: message})
+	}
+
+	switch t {
+	case rawMessageType:
+		return
+	case timeType:
+		s, ok := value.(string)
+		if _, err := time.Parse(time.RFC3339, s); !ok || err != nil {
+			fail("must be an RFC 3339 date-time")
+		}
+		return
+	}
+
+	if t.Kind() == reflect.Ptr {
+		if value != nil {
+			validateValue(t.Elem(), value, path, format, fieldErrors)
+		}
+		return
+	}
+	if value == nil {
+		// encoding/json leaves the zero value; required fields are checked by the enclosing struct
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			fail("must be a string")
+		} else if format == "uuid" && !uuidPattern.MatchString(s) {
+			fail("must be a UUID")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			fail("must be a boolean")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := value.(json.Number); !ok {
+			fail("must be an integer")
+		} else if _, err := n.Int64(); err != nil {
+			fail("must be an integer")
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			fail("must be a number")
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		for i, item := range items {
+			validateValue(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i), "", fieldErrors)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for key, item := range object {
+			validateValue(t.Elem(), item, joinFieldPath(path, key), "", fieldErrors)
+		}
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		validateStruct(t, object, path, fieldErrors)
+	}
+}
+
+// validateStruct checks an object's fields against a struct's JSON fields
+func validateStruct(t reflect.Type, object map[string]interface{}, path string, fieldErrors *[]FieldError) {
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		known[name] = true
+
+		fieldPath := joinFieldPath(path, name)
+		value, present := object[name]
+		if (!present || value == nil) && !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			*fieldErrors = append(*fieldErrors, FieldError{Field: fieldPath, Message: "is required"})
+			continue
+		}
+		if present {
+			validateValue(field.Type, value, fieldPath, field.Tag.Get("format"), fieldErrors)
+		}
+	}
+
+	var unknown []string
+	for name := range object {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		*fieldErrors = append(*fieldErrors, FieldError{Field: joinFieldPath(path, name), Message: "is not a known field"})
+	}
+}
+
+// joinFieldPath appends a property name to a JSON field path
+func joinFieldPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
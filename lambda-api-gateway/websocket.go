@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// websocketModeEnabled reports whether the function serves the progress WebSocket API instead of
+// the REST API (API_MODE=websocket), so one binary can back both API Gateway APIs
+func websocketModeEnabled() bool {
+	return os.Getenv("API_MODE") == "websocket"
+}
+
+// HandleWebSocket subscribes WebSocket connections to a job's progress. Clients connect with
+//
+//	wss://{api}/{stage}?jobId={job_id or call_logsId}
+//
+// and receive a JSON message as each processing stage starts; the transcription Lambda pushes them.
+// Browsers can't set headers on WebSockets, so credentials may also be given as the clientId and
+// apiKey query parameters.
+func HandleWebSocket(ctx context.Context, request events.APIGatewayWebsocketProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch request.RequestContext.RouteKey {
+	case "$connect":
+		return handleWebSocketConnect(request), nil
+	case "$disconnect":
+		return handleWebSocketDisconnect(request), nil
+	}
+	// Clients only listen; subscriptions are made when connecting
+	return errorResponse(400, "Messages aren't accepted; connect with ?jobId= to subscribe"), nil
+}
+
+// handleWebSocketConnect authenticates the client and subscribes the connection to the job
+func handleWebSocketConnect(request events.APIGatewayWebsocketProxyRequest) events.APIGatewayProxyResponse {
+	connectionID := request.RequestContext.ConnectionID
+	jobID := request.QueryStringParameters["jobId"]
+	if jobID == "" {
+		return errorResponse(400, "jobId query parameter is required")
+	}
+
+	clientID := "ip:" + request.RequestContext.Identity.SourceIP
+	if !authDisabled() {
+		authenticatedID, err := authenticateRequest(websocketAuthRequest(request))
+		if err != nil {
+			if errors.Is(err, errAuthUnavailable) {
+				log.Printf("❌ Authentication unavailable: %v", err)
+				return errorResponse(500, "Authentication unavailable")
+			}
+			log.Printf("❌ WebSocket authentication failed: %v", err)
+			return errorResponse(401, "Unauthorized: %s", err.Error())
+		}
+		clientID = authenticatedID
+	}
+
+	schema, err := LoadSchemaConfig()
+	if err != nil {
+		log.Printf("❌ Schema configuration error: %v", err)
+		return errorResponse(500, "Invalid schema configuration")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("connectionId", "jobId", "clientId", "callbackUrl")
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT ("connectionId") DO UPDATE SET "jobId" = EXCLUDED."jobId", "connectedAt" = now()
+	`, schema.Table("progress_subscriptions"))
+	if _, err := db.Exec(query, connectionID, jobID, clientID, websocketCallbackURL(request)); err != nil {
+		log.Printf("❌ Progress subscription error: %v", err)
+		return errorResponse(500, "Error subscribing to progress")
+	}
+
+	log.Printf("✅ %s subscribed to job %s (%s)", clientID, jobID, connectionID)
+	return events.APIGatewayProxyResponse{StatusCode: 200}
+}
+
+// handleWebSocketDisconnect removes the connection's subscription
+func handleWebSocketDisconnect(request events.APIGatewayWebsocketProxyRequest) events.APIGatewayProxyResponse {
+	schema, err := LoadSchemaConfig()
+	if err != nil {
+		log.Printf("❌ Schema configuration error: %v", err)
+		return errorResponse(500, "Invalid schema configuration")
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		log.Printf("❌ Database error: %v", err)
+		return errorResponse(500, "Database unavailable")
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(`DELETE FROM %s WHERE "connectionId" = $1`, schema.Table("progress_subscriptions"))
+	if _, err := db.Exec(query, request.RequestContext.ConnectionID); err != nil {
+		log.Printf("❌ Progress unsubscribe error: %v", err)
+		return errorResponse(500, "Error unsubscribing from progress")
+	}
+	return events.APIGatewayProxyResponse{StatusCode: 200}
+}
+
+// websocketAuthRequest adapts a $connect request for authenticateRequest, taking X-Client-Id and
+// X-Api-Key from the clientId and apiKey query parameters when the headers aren't set. Signed
+// connections sign "GET", the path "/" and the connection's query string (including jobId) with an
+// empty body.
+func websocketAuthRequest(request events.APIGatewayWebsocketProxyRequest) events.APIGatewayProxyRequest {
+	headers := make(map[string]string, len(request.Headers)+2)
+	for name, value := range request.Headers {
+		headers[name] = value
+	}
+	if headerValue(headers, "X-Client-Id") == "" {
+		headers["X-Client-Id"] = request.QueryStringParameters["clientId"]
+	}
+	if headerValue(headers, "X-Api-Key") == "" && request.QueryStringParameters["apiKey"] != "" {
+		headers["X-Api-Key"] = request.QueryStringParameters["apiKey"]
+	}
+	return events.APIGatewayProxyRequest{
+		HTTPMethod:                      "GET",
+		Path:                            "/",
+		Headers:                         headers,
+		QueryStringParameters:           request.QueryStringParameters,
+		MultiValueQueryStringParameters: request.MultiValueQueryStringParameters,
+	}
+}
+
+// websocketCallbackURL is the management API endpoint the pipeline posts the connection's messages
+// to. WEBSOCKET_CALLBACK_URL overrides it for APIs behind a custom domain.
+func websocketCallbackURL(request events.APIGatewayWebsocketProxyRequest) string {
+	if url := os.Getenv("WEBSOCKET_CALLBACK_URL"); url != "" {
+		return url
+	}
+	return fmt.Sprintf("https://%s/%s", request.RequestContext.DomainName, request.RequestContext.Stage)
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Abuse flag categories
+const (
+	AbuseCategoryProfanity = "profanity"
+	AbuseCategoryAbuse     = "abuse"
+)
+
+// EventAgentAbuse is the SNS "event_type" of abusive-agent alerts
+const EventAgentAbuse = "call.abuse.agent"
+
+// AbuseFlag is a profane or abusive utterance, located in the transcription
+type AbuseFlag struct {
+	Speaker  string `json:"speaker"`
+	Category string `json:"category"`
+	Severity string `json:"severity,omitempty"`
+	Quote    string `json:"quote"`
+	// Start is the start of the segment the quote was found in; nil when it couldn't be located
+	Start     *float64 `json:"start,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+	Reason    string   `json:"reason,omitempty"`
+}
+
+// AbuseCheck represents the profanity and abuse section of the call analysis
+type AbuseCheck struct {
+	Flags []AbuseFlag `json:"flags"`
+	// AgentAbusive is set when the agent was abusive, which triggers an alert
+	AgentAbusive bool   `json:"agent_abusive"`
+	AlertSent    bool   `json:"alert_sent,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// AgentAbuseAlert is published to ABUSE_ALERT_SNS_TOPIC_ARN for calls with an abusive agent
+type AgentAbuseAlert struct {
+	Type         string      `json:"type"`
+	CallLogsID   string      `json:"call_logsId"`
+	CampaignID   string      `json:"campaignId,omitempty"`
+	CampaignName string      `json:"campaignName,omitempty"`
+	AgentName    string      `json:"agentName,omitempty"`
+	StartDate    string      `json:"startDate,omitempty"`
+	Flags        []AbuseFlag `json:"flags"`
+	OccurredAt   string      `json:"occurredAt"`
+}
+
+// DetectAbuse asks the model for profanity and abuse by either party, quoting each utterance, and
+// locates the quotes in the transcription's segments. A detection failure is recorded in the result.
+func (tp *TranscriptionPipeline) DetectAbuse(transcription string, segments []TranscriptSegment) *AbuseCheck {
+	prompt := fmt.Sprintf(`
+You are reviewing a call-center call for profanity and abusive language by either the agent or the customer.
+The call may be in Hindi, English or a mix (including romanized Hindi).
+
+Flag every utterance that is:
+- "profanity": swearing or vulgar language, including casual swearing not aimed at anyone
+- "abuse": insults, slurs, threats, harassment or demeaning language aimed at the other party
+
+For each flag give the speaker ("Agent" or "Customer"), the category, a severity ("low", "medium" or "high"),
+a short verbatim quote from the transcription and a one-sentence reason. Don't flag mild frustration or polite disagreement.
+
+TRANSCRIPTION:
+%s
+
+Respond with a JSON array only (empty if nothing is flagged), in this format:
+[{"speaker": "Agent", "category": "abuse", "severity": "high", "quote": "quote from the call", "reason": "why"}]
+`, transcription)
+
+	check := &AbuseCheck{Flags: []AbuseFlag{}}
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		check.Error = fmt.Sprintf("error detecting abuse: %v", err)
+		return check
+	}
+
+	var flags []AbuseFlag
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &flags); err != nil {
+		check.Error = fmt.Sprintf("error parsing abuse flags: %v", err)
+		return check
+	}
+
+	for _, flag := range flags {
+		flag.Category = strings.ToLower(strings.TrimSpace(flag.Category))
+		flag.Quote = strings.TrimSpace(flag.Quote)
+		if flag.Quote == "" || (flag.Category != AbuseCategoryProfanity && flag.Category != AbuseCategoryAbuse) {
+			continue
+		}
+		flag.Speaker = normalizeSpeaker(flag.Speaker)
+		flag.Severity = strings.ToLower(strings.TrimSpace(flag.Severity))
+
+		if segment, ok := findQuoteSegment(segments, flag.Speaker, flag.Quote); ok {
+			start := segment.Start
+			flag.Start = &start
+			flag.Timestamp = formatTimestamp(start)
+			// The transcription is the authority on who said it, when it knows
+			if segment.Speaker != SpeakerUnknown {
+				flag.Speaker = segment.Speaker
+			}
+		}
+
+		check.Flags = append(check.Flags, flag)
+		if flag.Speaker == SpeakerAgent && flag.Category == AbuseCategoryAbuse {
+			check.AgentAbusive = true
+		}
+	}
+
+	return check
+}
+
+// findQuoteSegment returns the first segment containing the quote, preferring the given speaker's
+func findQuoteSegment(segments []TranscriptSegment, speaker, quote string) (TranscriptSegment, bool) {
+	needle := strings.ToLower(strings.Join(strings.Fields(quote), " "))
+	var fallback *TranscriptSegment
+	for i, segment := range segments {
+		if !strings.Contains(strings.ToLower(strings.Join(strings.Fields(segment.Text), " ")), needle) {
+			continue
+		}
+		if segment.Speaker == speaker {
+			return segment, true
+		}
+		if fallback == nil {
+			fallback = &segments[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return TranscriptSegment{}, false
+}
+
+// SendAgentAbuseAlert publishes an abusive-agent alert to the SNS topic in ABUSE_ALERT_SNS_TOPIC_ARN.
+// Alerting is best-effort: failures are logged and don't affect the call. It reports whether the alert was sent.
+func (tp *TranscriptionPipeline) SendAgentAbuseAlert(callData *CallData, check *AbuseCheck) bool {
+	topicARN := os.Getenv("ABUSE_ALERT_SNS_TOPIC_ARN")
+	if topicARN == "" {
+		return false
+	}
+
+	var agentFlags []AbuseFlag
+	for _, flag := range check.Flags {
+		if flag.Speaker == SpeakerAgent {
+			agentFlags = append(agentFlags, flag)
+		}
+	}
+
+	alert := AgentAbuseAlert{
+		Type:         EventAgentAbuse,
+		CallLogsID:   callData.ID,
+		CampaignID:   callData.CampaignID,
+		CampaignName: callData.CampaignName,
+		AgentName:    callData.AgentName,
+		StartDate:    callData.StartDate,
+		Flags:        agentFlags,
+		OccurredAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	message, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Error marshaling abuse alert for %s: %v", callData.ID, err)
+		return false
+	}
+
+	if err := snsPublish(topicARN, string(message), map[string]string{"event_type": EventAgentAbuse}); err != nil {
+		log.Printf("Error publishing abuse alert for %s to SNS: %v", callData.ID, err)
+		return false
+	}
+	return true
+}
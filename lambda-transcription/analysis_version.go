@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrAnalysisConflict means another invocation saved the call's analysis after this one read the
+// call, e.g. a retry that overlapped a slow original run; the first save wins
+var ErrAnalysisConflict = errors.New("analysis was saved by another invocation")
+
+// checkAnalysisVersion locks the call's call_logs row for the rest of the transaction, so saves of
+// the same call are serialized, and fails with ErrAnalysisConflict when the call's latest analysis
+// version isn't the one read with the call. Versions are written by the postgres result store.
+func (tp *TranscriptionPipeline) checkAnalysisVersion(tx *Tx, callData *CallData) error {
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	lockQuery := fmt.Sprintf(`SELECT 1 FROM %s WHERE %s = $1 FOR UPDATE`, tp.schema.Table("call_logs"), c("id"))
+	var locked int
+	if err := tx.QueryRow(lockQuery, callData.ID).Scan(&locked); err != nil {
+		return fmt.Errorf("error locking call for save: %v", err)
+	}
+
+	versionQuery := fmt.Sprintf(`SELECT COALESCE(MAX(version), 0) FROM %s WHERE "call_logsId" = $1`, tp.schema.Table("call_analysis_versions"))
+	var version int
+	if err := tx.QueryRow(versionQuery, callData.ID).Scan(&version); err != nil {
+		return fmt.Errorf("error reading analysis version: %v", err)
+	}
+
+	if version != callData.AnalysisVersion {
+		return fmt.Errorf("%w: read version %d, now version %d", ErrAnalysisConflict, callData.AnalysisVersion, version)
+	}
+	return nil
+}
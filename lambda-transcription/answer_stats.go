@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Answer kinds counted in the campaign answer aggregates
+const (
+	AnswerKindBoolean = "boolean"
+	AnswerKindOption  = "option"
+	AnswerKindNumeric = "numeric"
+	// AnswerKindNotDiscussed counts the calls that didn't address the question, kept apart from "false"
+	AnswerKindNotDiscussed = "not_discussed"
+)
+
+// numericAnswerPattern matches the answers averaged as numbers; the 0022 migration uses the same pattern
+var numericAnswerPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// AnswerFact is an answer counted in the campaign answer aggregates
+type AnswerFact struct {
+	QuestionID string
+	Kind       string
+	Answer     string
+}
+
+// answerFacts classifies the analysis's answers for the aggregates: valid enum options, yes/no answers
+// (stored as "true"/"false"), NOT_DISCUSSED and plain numbers. Free-text answers aren't aggregated,
+// nor are duplicate rows' answers, which the row they copy already counts.
+func answerFacts(analysisData CallAnalysisData) []AnswerFact {
+	if analysisData.DuplicateOf != nil {
+		return nil
+	}
+	var facts []AnswerFact
+	for questionID, raw := range analysisData.Answers {
+		answer := strings.TrimSpace(raw)
+		fact := AnswerFact{QuestionID: questionID, Answer: answer}
+
+		switch {
+		case answer == AnswerNotDiscussed:
+			fact.Kind = AnswerKindNotDiscussed
+		case analysisData.EnumAnswers[questionID].Valid:
+			fact.Kind = AnswerKindOption
+		case strings.EqualFold(answer, "true") || strings.EqualFold(answer, "yes"):
+			fact.Kind, fact.Answer = AnswerKindBoolean, "true"
+		case strings.EqualFold(answer, "false") || strings.EqualFold(answer, "no"):
+			fact.Kind, fact.Answer = AnswerKindBoolean, "false"
+		case numericAnswerPattern.MatchString(answer):
+			fact.Kind = AnswerKindNumeric
+		default:
+			continue
+		}
+		facts = append(facts, fact)
+	}
+	return facts
+}
+
+// replaceAnswerFacts swaps the call's counted answers for facts in call_answer_facts, taking the old
+// ones out of campaign_answer_counts/campaign_answer_numeric and adding the new ones. Nil facts just
+// removes the call from the aggregates. Updates are serialized per campaign so concurrent saves
+// can't deadlock on the shared aggregate rows.
+func (tp *TranscriptionPipeline) replaceAnswerFacts(tx *Tx, callLogsID string, facts []AnswerFact) error {
+	factsTable := tp.schema.Table("call_answer_facts")
+	countsTable := tp.schema.Table("campaign_answer_counts")
+	numericTable := tp.schema.Table("campaign_answer_numeric")
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+
+	// The call's campaign now, and the campaigns its old facts were counted under
+	lockQuery := fmt.Sprintf(`
+		SELECT pg_advisory_xact_lock(hashtext('answer_stats:' || "campaignId"))
+		FROM (
+			SELECT %s::text AS "campaignId" FROM %s WHERE %s = $1 AND %s IS NOT NULL
+			UNION
+			SELECT "campaignId"::text FROM %s WHERE "call_logsId" = $1
+			ORDER BY 1
+		) campaigns
+	`, c("campaignId"), tp.schema.Table("call_logs"), c("id"), c("campaignId"), factsTable)
+	if _, err := tx.Exec(lockQuery, callLogsID); err != nil {
+		return fmt.Errorf("error locking answer aggregates: %v", err)
+	}
+
+	removeQueries := []string{
+		fmt.Sprintf(`
+			UPDATE %s a SET calls = a.calls - 1, "updatedAt" = now()
+			FROM %s f
+			WHERE f."call_logsId" = $1 AND f.kind <> '%s'
+			  AND a."campaignId" = f."campaignId" AND a."questionId" = f."questionId" AND a.answer = f.answer
+		`, countsTable, factsTable, AnswerKindNumeric),
+		fmt.Sprintf(`
+			UPDATE %s a SET calls = a.calls - 1, total = a.total - f."numericValue", "updatedAt" = now()
+			FROM %s f
+			WHERE f."call_logsId" = $1 AND f.kind = '%s'
+			  AND a."campaignId" = f."campaignId" AND a."questionId" = f."questionId"
+		`, numericTable, factsTable, AnswerKindNumeric),
+		fmt.Sprintf(`
+			DELETE FROM %s a USING %s f
+			WHERE f."call_logsId" = $1 AND a.calls <= 0
+			  AND a."campaignId" = f."campaignId" AND a."questionId" = f."questionId"
+		`, countsTable, factsTable),
+		fmt.Sprintf(`
+			DELETE FROM %s a USING %s f
+			WHERE f."call_logsId" = $1 AND a.calls <= 0
+			  AND a."campaignId" = f."campaignId" AND a."questionId" = f."questionId"
+		`, numericTable, factsTable),
+		fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, factsTable),
+	}
+	for _, query := range removeQueries {
+		if _, err := tx.Exec(query, callLogsID); err != nil {
+			return fmt.Errorf("error removing old answers from aggregates: %v", err)
+		}
+	}
+
+	if len(facts) == 0 {
+		return nil
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "questionId", "campaignId", kind, answer, "numericValue")
+		SELECT $1, $2::text, %s, $3::text, $4::text, $5::numeric
+		FROM %s
+		WHERE %s = $1 AND %s IS NOT NULL
+	`, factsTable, c("campaignId"), tp.schema.Table("call_logs"), c("id"), c("campaignId"))
+	for _, fact := range facts {
+		var numericValue interface{}
+		if fact.Kind == AnswerKindNumeric {
+			numericValue = fact.Answer
+		}
+		if _, err := tx.Exec(insertQuery, callLogsID, fact.QuestionID, fact.Kind, fact.Answer, numericValue); err != nil {
+			return fmt.Errorf("error saving answer fact: %v", err)
+		}
+	}
+
+	addQueries := []string{
+		fmt.Sprintf(`
+			INSERT INTO %[1]s AS a ("campaignId", "questionId", answer, calls, "updatedAt")
+			SELECT "campaignId", "questionId", answer, 1, now()
+			FROM %[2]s
+			WHERE "call_logsId" = $1 AND kind <> '%[3]s'
+			ON CONFLICT ("campaignId", "questionId", answer)
+			DO UPDATE SET calls = a.calls + 1, "updatedAt" = now()
+		`, countsTable, factsTable, AnswerKindNumeric),
+		fmt.Sprintf(`
+			INSERT INTO %[1]s AS a ("campaignId", "questionId", calls, total, "updatedAt")
+			SELECT "campaignId", "questionId", 1, "numericValue", now()
+			FROM %[2]s
+			WHERE "call_logsId" = $1 AND kind = '%[3]s'
+			ON CONFLICT ("campaignId", "questionId")
+			DO UPDATE SET calls = a.calls + 1, total = a.total + EXCLUDED.total, "updatedAt" = now()
+		`, numericTable, factsTable, AnswerKindNumeric),
+	}
+	for _, query := range addQueries {
+		if _, err := tx.Exec(query, callLogsID); err != nil {
+			return fmt.Errorf("error adding answers to aggregates: %v", err)
+		}
+	}
+
+	return nil
+}
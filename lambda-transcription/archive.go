@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Artifact types archived to S3
+const (
+	ArtifactGeminiRequest  = "gemini_request"
+	ArtifactGeminiResponse = "gemini_response"
+	ArtifactTranscription  = "transcription"
+	ArtifactTranslation    = "translation"
+	ArtifactAnalysis       = "analysis"
+)
+
+// GeminiExchange represents a raw Gemini request/response pair captured for archival
+type GeminiExchange struct {
+	Name     string          `json:"name"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// ArtifactRef represents an archived artifact's location in S3
+type ArtifactRef struct {
+	Type   string `json:"type"`
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+}
+
+// recordGeminiExchange keeps the raw request/response of a Gemini call when archival is enabled.
+// Inline audio is replaced by its size so the archived request stays small.
+func (tp *TranscriptionPipeline) recordGeminiExchange(name string, requestData GeminiRequest, responseBody []byte) {
+	if tp.artifactsBucket == "" {
+		return
+	}
+
+	redacted := requestData
+	redacted.Contents = make([]Content, len(requestData.Contents))
+	for i, content := range requestData.Contents {
+		parts := make([]Part, len(content.Parts))
+		for j, part := range content.Parts {
+			parts[j] = part
+			if part.InlineData != nil {
+				parts[j].InlineData = &InlineData{
+					MimeType: part.InlineData.MimeType,
+					Data:     fmt.Sprintf("<%d base64 characters omitted>", part.InlineData.encodedLen()),
+				}
+			}
+		}
+		redacted.Contents[i] = Content{Parts: parts}
+	}
+
+	requestJSON, err := json.Marshal(redacted)
+	if err != nil {
+		return
+	}
+
+	// Keep non-JSON error bodies as a JSON string
+	responseJSON := json.RawMessage(responseBody)
+	if !json.Valid(responseBody) {
+		responseJSON, _ = json.Marshal(string(responseBody))
+	}
+
+	tp.geminiExchanges = append(tp.geminiExchanges, GeminiExchange{
+		Name:     name,
+		Request:  requestJSON,
+		Response: responseJSON,
+	})
+}
+
+// artifactKeyPrefix builds the S3 prefix for a call's artifacts. Raw and derived artifacts live under
+// separate top-level prefixes so lifecycle rules can expire raw provider payloads independently:
+//
+//	{prefix}/{raw|derived}/campaign={campaignId}/date=YYYY-MM-DD/call={call_logsId}/{timestamp}-
+func artifactKeyPrefix(basePrefix, class, campaignID, callLogsID string, now time.Time) string {
+	parts := []string{
+		class,
+		"campaign=" + campaignID,
+		"date=" + now.UTC().Format("2006-01-02"),
+		"call=" + callLogsID,
+		now.UTC().Format("20060102T150405Z") + "-",
+	}
+	if basePrefix = strings.Trim(basePrefix, "/"); basePrefix != "" {
+		parts = append([]string{basePrefix}, parts...)
+	}
+	return strings.Join(parts, "/")
+}
+
+// ArchiveArtifacts writes the raw Gemini exchanges, the transcription and the derived analysis to S3
+func (tp *TranscriptionPipeline) ArchiveArtifacts(callData *CallData, analysisData CallAnalysisData) ([]ArtifactRef, error) {
+	now := time.Now()
+	rawPrefix := artifactKeyPrefix(tp.artifactsPrefix, "raw", callData.CampaignID, callData.ID, now)
+	derivedPrefix := artifactKeyPrefix(tp.artifactsPrefix, "derived", callData.CampaignID, callData.ID, now)
+
+	type artifact struct {
+		artifactType string
+		key          string
+		contentType  string
+		body         []byte
+	}
+	var artifacts []artifact
+
+	for i, exchange := range tp.geminiExchanges {
+		name := fmt.Sprintf("%02d-%s", i+1, exchange.Name)
+		artifacts = append(artifacts,
+			artifact{ArtifactGeminiRequest, rawPrefix + name + "-request.json", "application/json", exchange.Request},
+			artifact{ArtifactGeminiResponse, rawPrefix + name + "-response.json", "application/json", exchange.Response},
+		)
+	}
+
+	analysisJSON, err := json.Marshal(analysisData)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling analysis data: %v", err)
+	}
+
+	artifacts = append(artifacts,
+		artifact{ArtifactTranscription, derivedPrefix + "transcription.txt", "text/plain; charset=utf-8", []byte(analysisData.Transcription)},
+		artifact{ArtifactAnalysis, derivedPrefix + "analysis.json", "application/json", analysisJSON},
+	)
+	if analysisData.TranslatedTranscription != "" {
+		artifacts = append(artifacts,
+			artifact{ArtifactTranslation, derivedPrefix + "translation.txt", "text/plain; charset=utf-8", []byte(analysisData.TranslatedTranscription)})
+	}
+
+	refs := make([]ArtifactRef, 0, len(artifacts))
+	for _, a := range artifacts {
+		if err := s3PutObject(tp.artifactsBucket, a.key, a.contentType, a.body); err != nil {
+			return nil, fmt.Errorf("error uploading %s: %v", a.key, err)
+		}
+		refs = append(refs, ArtifactRef{Type: a.artifactType, Bucket: tp.artifactsBucket, Key: a.key})
+	}
+
+	return refs, nil
+}
+
+// archiveCall archives the call's artifacts and records their keys. A failure is logged, reported
+// and recorded in the analysis's processing_metadata.
+func (tp *TranscriptionPipeline) archiveCall(callData *CallData, analysisData CallAnalysisData) {
+	refs, err := tp.ArchiveArtifacts(callData, analysisData)
+	if err == nil {
+		err = tp.SaveArtifactRefs(tp.repo, callData.ID, refs)
+	}
+	if err != nil {
+		log.Printf("Failed to archive artifacts for %s: %v", callData.ID, err)
+		tp.reportError(fmt.Errorf("failed to archive artifacts: %w", err))
+		tp.RecordArchiveError(callData.ID, err)
+	}
+}
+
+// SaveArtifactRefs stores the S3 keys of the archived artifacts
+func (tp *TranscriptionPipeline) SaveArtifactRefs(db Execer, callLogsID string, refs []ArtifactRef) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "artifactType", bucket, "s3Key", "createdAt")
+		VALUES ($1, $2, $3, $4, now())
+	`, tp.schema.Table("call_artifacts"))
+
+	for _, ref := range refs {
+		if _, err := db.Exec(query, callLogsID, ref.Type, ref.Bucket, ref.Key); err != nil {
+			return fmt.Errorf("error saving artifact key: %v", err)
+		}
+	}
+
+	return nil
+}
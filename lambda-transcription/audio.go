@@ -0,0 +1,390 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	// preprocessedSampleRate is the rate recordings are downsampled to; speech models don't use more
+	preprocessedSampleRate = 16000
+	// ffmpegBitrate is the MP3 bitrate of recordings transcoded with ffmpeg
+	ffmpegBitrate = "32k"
+	// keptSilence is how much of a stripped silence remains, so turns stay separated
+	keptSilence = 500 * time.Millisecond
+
+	defaultSilenceThresholdDB = -40.0
+	defaultMinSilence         = time.Second
+	ffmpegTimeout             = 2 * time.Minute
+)
+
+// WAV format codes
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatALaw       = 6
+	wavFormatMuLaw      = 7
+	wavFormatExtensible = 0xFFFE
+)
+
+// preprocessAudio converts the recording to 16kHz mono (and strips long silences when enabled)
+// before it's sent to the transcription provider. PCM and G.711 WAVs are converted in Go; other
+// formats need ffmpeg. Preprocessing is best-effort: on failure, or when the result isn't smaller,
+// the original recording is used.
+func (tp *TranscriptionPipeline) preprocessAudio(audioContent []byte) []byte {
+	if !tp.audioPreprocessing {
+		return audioContent
+	}
+
+	var processed []byte
+	var err error
+	if wav, ok := decodeWAV(audioContent); ok {
+		samples := downsample(wav.mono(), wav.sampleRate, preprocessedSampleRate)
+		rate := min(wav.sampleRate, preprocessedSampleRate)
+		if tp.stripSilence {
+			samples = stripSilence(samples, rate, silenceThresholdDB(), minSilence())
+		}
+		processed = encodeWAV(samples, rate)
+	} else if ffmpeg := ffmpegPath(); ffmpeg != "" {
+		processed, err = transcodeWithFFmpeg(ffmpeg, audioContent, tp.stripSilence)
+	} else {
+		return audioContent
+	}
+
+	if err != nil {
+		log.Printf("Error preprocessing audio, using the original recording: %v", err)
+		return audioContent
+	}
+	if len(processed) == 0 || len(processed) >= len(audioContent) {
+		return audioContent
+	}
+
+	log.Printf("Preprocessed audio from %d to %d bytes", len(audioContent), len(processed))
+	return processed
+}
+
+// silenceThresholdDB reads the level below which audio counts as silence (AUDIO_SILENCE_THRESHOLD_DB)
+func silenceThresholdDB() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("AUDIO_SILENCE_THRESHOLD_DB"), 64); err == nil && v < 0 {
+		return v
+	}
+	return defaultSilenceThresholdDB
+}
+
+// minSilence reads how long a silence must last to be stripped (AUDIO_MIN_SILENCE_SECONDS)
+func minSilence() time.Duration {
+	if v, err := strconv.ParseFloat(os.Getenv("AUDIO_MIN_SILENCE_SECONDS"), 64); err == nil && v > 0 {
+		return time.Duration(v * float64(time.Second))
+	}
+	return defaultMinSilence
+}
+
+// ffmpegPath returns the ffmpeg binary from AUDIO_FFMPEG_PATH, the PATH or the Lambda layer
+// location /opt/bin/ffmpeg, or "" when there is none
+func ffmpegPath() string {
+	if path := os.Getenv("AUDIO_FFMPEG_PATH"); path != "" {
+		return path
+	}
+	if path, err := exec.LookPath("ffmpeg"); err == nil {
+		return path
+	}
+	if _, err := os.Stat("/opt/bin/ffmpeg"); err == nil {
+		return "/opt/bin/ffmpeg"
+	}
+	return ""
+}
+
+// transcodeWithFFmpeg converts the recording to a 16kHz mono MP3
+func transcodeWithFFmpeg(ffmpeg string, audioContent []byte, strip bool) ([]byte, error) {
+	args := []string{"-hide_banner", "-loglevel", "error", "-i", "pipe:0", "-ac", "1", "-ar", strconv.Itoa(preprocessedSampleRate)}
+	if strip {
+		args = append(args, "-af", fmt.Sprintf("silenceremove=stop_periods=-1:stop_duration=%.2f:stop_threshold=%.0fdB:stop_silence=%.2f",
+			minSilence().Seconds(), silenceThresholdDB(), keptSilence.Seconds()))
+	}
+	args = append(args, "-c:a", "libmp3lame", "-b:a", ffmpegBitrate, "-f", "mp3", "pipe:1")
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpegTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, args...)
+	cmd.Stdin = bytes.NewReader(audioContent)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// wavAudio is a decoded WAV with one slice of samples in [-1, 1] per channel
+type wavAudio struct {
+	channels   [][]float64
+	sampleRate int
+}
+
+// mono downmixes the channels by averaging them
+func (w *wavAudio) mono() []float64 {
+	if len(w.channels) == 1 {
+		return w.channels[0]
+	}
+	samples := make([]float64, len(w.channels[0]))
+	for _, channel := range w.channels {
+		for i, s := range channel {
+			samples[i] += s / float64(len(w.channels))
+		}
+	}
+	return samples
+}
+
+// decodeWAV decodes PCM, float and G.711 (A-law/µ-law) WAVs. ok is false for anything else.
+func decodeWAV(data []byte) (*wavAudio, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, false
+	}
+
+	var format, channels, bitsPerSample int
+	var sampleRate int
+	var pcm []byte
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		body := data[offset+8:]
+		if size < 0 || size > len(body) {
+			// Streamed WAVs can declare a bogus data size; take what's there
+			size = len(body)
+		}
+		body = body[:size]
+
+		switch id {
+		case "fmt ":
+			if len(body) < 16 {
+				return nil, false
+			}
+			format = int(binary.LittleEndian.Uint16(body[0:2]))
+			channels = int(binary.LittleEndian.Uint16(body[2:4]))
+			sampleRate = int(binary.LittleEndian.Uint32(body[4:8]))
+			bitsPerSample = int(binary.LittleEndian.Uint16(body[14:16]))
+			if format == wavFormatExtensible && len(body) >= 26 {
+				// The first two bytes of the sub-format GUID are the actual format code
+				format = int(binary.LittleEndian.Uint16(body[24:26]))
+			}
+		case "data":
+			pcm = body
+		}
+
+		offset += 8 + size + size%2
+	}
+
+	if pcm == nil || channels == 0 || sampleRate == 0 {
+		return nil, false
+	}
+
+	decode := wavSampleDecoder(format, bitsPerSample)
+	if decode == nil {
+		return nil, false
+	}
+
+	bytesPerSample := bitsPerSample / 8
+	frameSize := bytesPerSample * channels
+	frames := len(pcm) / frameSize
+	samples := make([][]float64, channels)
+	for c := range samples {
+		samples[c] = make([]float64, frames)
+		for i := 0; i < frames; i++ {
+			start := i*frameSize + c*bytesPerSample
+			samples[c][i] = decode(pcm[start : start+bytesPerSample])
+		}
+	}
+
+	return &wavAudio{channels: samples, sampleRate: sampleRate}, true
+}
+
+// wavSampleDecoder returns the decoder of a single sample, or nil for unsupported formats
+func wavSampleDecoder(format, bitsPerSample int) func([]byte) float64 {
+	switch {
+	case format == wavFormatPCM && bitsPerSample == 8:
+		return func(b []byte) float64 { return (float64(b[0]) - 128) / 128 }
+	case format == wavFormatPCM && bitsPerSample == 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / 32768 }
+	case format == wavFormatPCM && bitsPerSample == 24:
+		return func(b []byte) float64 {
+			return float64(int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24)>>8) / 8388608
+		}
+	case format == wavFormatPCM && bitsPerSample == 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648 }
+	case format == wavFormatFloat && bitsPerSample == 32:
+		return func(b []byte) float64 { return float64(math.Float32frombits(binary.LittleEndian.Uint32(b))) }
+	case format == wavFormatMuLaw && bitsPerSample == 8:
+		return func(b []byte) float64 { return float64(decodeMuLaw(b[0])) / 32768 }
+	case format == wavFormatALaw && bitsPerSample == 8:
+		return func(b []byte) float64 { return float64(decodeALaw(b[0])) / 32768 }
+	}
+	return nil
+}
+
+// decodeMuLaw expands a G.711 µ-law sample to 16-bit PCM
+func decodeMuLaw(u byte) int16 {
+	u = ^u
+	magnitude := ((int(u&0x0F) << 3) + 0x84) << ((u & 0x70) >> 4)
+	if u&0x80 != 0 {
+		return int16(0x84 - magnitude)
+	}
+	return int16(magnitude - 0x84)
+}
+
+// decodeALaw expands a G.711 A-law sample to 16-bit PCM
+func decodeALaw(a byte) int16 {
+	a ^= 0x55
+	magnitude := int(a&0x0F) << 4
+	segment := int(a&0x70) >> 4
+	switch segment {
+	case 0:
+		magnitude += 8
+	case 1:
+		magnitude += 0x108
+	default:
+		magnitude = (magnitude + 0x108) << (segment - 1)
+	}
+	if a&0x80 != 0 {
+		return int16(magnitude)
+	}
+	return int16(-magnitude)
+}
+
+// downsample reduces the sample rate by averaging the source samples covered by each output
+// sample, which also filters out most of the frequencies the lower rate can't represent.
+// Audio at or below the target rate is returned unchanged.
+func downsample(samples []float64, from, to int) []float64 {
+	if from <= to {
+		return samples
+	}
+
+	ratio := float64(from) / float64(to)
+	out := make([]float64, int(float64(len(samples))/ratio))
+	for i := range out {
+		start := int(float64(i) * ratio)
+		end := min(int(float64(i+1)*ratio), len(samples))
+		var sum float64
+		for _, s := range samples[start:end] {
+			sum += s
+		}
+		out[i] = sum / float64(end-start)
+	}
+	return out
+}
+
+// stripSilence shortens every silence longer than minimum to keptSilence. Silence is measured
+// in 20ms frames whose RMS level is below thresholdDB.
+func stripSilence(samples []float64, sampleRate int, thresholdDB float64, minimum time.Duration) []float64 {
+	frameSize := sampleRate / 50
+	if frameSize == 0 {
+		return samples
+	}
+	minFrames := int(minimum.Seconds() * 50)
+	keptFrames := int(keptSilence.Seconds() * 50)
+
+	frameCount := (len(samples) + frameSize - 1) / frameSize
+	silent := make([]bool, frameCount)
+	for f := range silent {
+		frame := samples[f*frameSize : min((f+1)*frameSize, len(samples))]
+		var sumSquares float64
+		for _, s := range frame {
+			sumSquares += s * s
+		}
+		rms := math.Sqrt(sumSquares / float64(len(frame)))
+		silent[f] = rms == 0 || 20*math.Log10(rms) < thresholdDB
+	}
+
+	out := make([]float64, 0, len(samples))
+	for f := 0; f < frameCount; {
+		run := 1
+		for f+run < frameCount && silent[f+run] == silent[f] {
+			run++
+		}
+		keep := run
+		if silent[f] && run >= minFrames {
+			keep = min(run, keptFrames)
+		}
+		out = append(out, samples[f*frameSize:min((f+keep)*frameSize, len(samples))]...)
+		f += run
+	}
+	return out
+}
+
+// encodeWAV encodes mono samples as a 16-bit PCM WAV
+func encodeWAV(samples []float64, sampleRate int) []byte {
+	dataSize := len(samples) * 2
+	var buf bytes.Buffer
+	buf.Grow(44 + dataSize)
+
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []interface{}{
+		uint32(16), uint16(wavFormatPCM), uint16(1), uint32(sampleRate), uint32(sampleRate * 2), uint16(2), uint16(16),
+	} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+
+	pcm := make([]byte, dataSize)
+	for i, s := range samples {
+		s = math.Max(-1, math.Min(1, s))
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(int16(math.Round(s*32767))))
+	}
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
+
+// audioMimeType identifies the recording's format from its header, defaulting to MP3. Videos whose
+// audio couldn't be extracted keep their video type.
+func audioMimeType(audioContent []byte) string {
+	if mimeType := videoMimeType(audioContent); mimeType != "" {
+		return mimeType
+	}
+	switch {
+	case len(audioContent) >= 12 && string(audioContent[0:4]) == "RIFF" && string(audioContent[8:12]) == "WAVE":
+		return "audio/wav"
+	case len(audioContent) >= 4 && string(audioContent[0:4]) == "OggS":
+		return "audio/ogg"
+	case len(audioContent) >= 4 && string(audioContent[0:4]) == "fLaC":
+		return "audio/flac"
+	case len(audioContent) >= 8 && string(audioContent[4:8]) == "ftyp":
+		return "audio/mp4"
+	}
+	return "audio/mpeg"
+}
+
+// audioFileExtension returns the file extension for the recording's format, for APIs that go by file name
+func audioFileExtension(audioContent []byte) string {
+	switch audioMimeType(audioContent) {
+	case "audio/wav":
+		return ".wav"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/flac":
+		return ".flac"
+	case "audio/mp4":
+		return ".m4a"
+	case "video/mp4":
+		return ".mp4"
+	case "video/quicktime":
+		return ".mov"
+	case "video/3gpp":
+		return ".3gp"
+	case "video/webm":
+		return ".webm"
+	}
+	return ".mp3"
+}
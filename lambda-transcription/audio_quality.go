@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Audio quality actions for recordings scoring below AUDIO_QUALITY_MIN_SCORE
+const (
+	AudioQualityActionFlag = "flag"
+	AudioQualityActionSkip = "skip"
+)
+
+const (
+	defaultAudioQualityMinScore = 40
+	// clippedLevel is the sample level treated as clipped
+	clippedLevel = 0.99
+	// floorDB is the level given to digitally silent frames
+	floorDB = -100.0
+)
+
+// AudioQuality scores how usable a recording is before it's transcribed, so hallucinated
+// transcripts of unusable audio can be told apart from real ones
+type AudioQuality struct {
+	// Score is 0 (unusable) to 100 (clean), from the measurements below
+	Score int `json:"score"`
+	// ClippingRatio is the share of samples at full scale
+	ClippingRatio float64 `json:"clipping_ratio"`
+	// SNRDB estimates the signal-to-noise ratio as the spread between loud and quiet 20ms frames
+	SNRDB float64 `json:"snr_db"`
+	// SilenceRatio is the share of 20ms frames below the silence threshold
+	SilenceRatio    float64 `json:"silence_ratio"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// BelowThreshold is set when Score is below AUDIO_QUALITY_MIN_SCORE
+	BelowThreshold bool `json:"below_threshold,omitempty"`
+}
+
+// audioQualityMinScore reads the score below which recordings are flagged or skipped (AUDIO_QUALITY_MIN_SCORE)
+func audioQualityMinScore() int {
+	if v, err := strconv.Atoi(os.Getenv("AUDIO_QUALITY_MIN_SCORE")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultAudioQualityMinScore
+}
+
+// audioQualityAction reads what happens to recordings below the minimum score (AUDIO_QUALITY_ACTION)
+func audioQualityAction() string {
+	if os.Getenv("AUDIO_QUALITY_ACTION") == AudioQualityActionSkip {
+		return AudioQualityActionSkip
+	}
+	return AudioQualityActionFlag
+}
+
+// assessAudioQuality scores the recording when the quality check is enabled. WAVs are decoded in
+// Go and other formats with ffmpeg; without it, or when decoding fails, the recording isn't scored
+// and nil is returned.
+func (tp *TranscriptionPipeline) assessAudioQuality(audioContent []byte) *AudioQuality {
+	if !tp.audioQualityCheck {
+		return nil
+	}
+
+	wav, ok := decodeWAV(audioContent)
+	if !ok {
+		ffmpeg := ffmpegPath()
+		if ffmpeg == "" {
+			return nil
+		}
+		converted, err := ffmpegToWAV(ffmpeg, audioContent)
+		if err != nil {
+			log.Printf("Error decoding recording for the quality check: %v", err)
+			return nil
+		}
+		if wav, ok = decodeWAV(converted); !ok {
+			return nil
+		}
+	}
+
+	quality := measureAudioQuality(wav.mono(), wav.sampleRate, silenceThresholdDB())
+	quality.BelowThreshold = quality.Score < audioQualityMinScore()
+	return quality
+}
+
+// measureAudioQuality measures clipping, the SNR estimate and the silence ratio in 20ms frames and
+// combines them into a score. The SNR is the spread between the 90th and 10th percentile frame
+// levels: speech over the noise floor. The score scales with the SNR up to 30dB, halves as
+// clipping reaches 1% of samples and drops to 0 as speech falls below a fifth of the recording.
+func measureAudioQuality(samples []float64, sampleRate int, thresholdDB float64) *AudioQuality {
+	quality := &AudioQuality{}
+	frameSize := sampleRate / 50
+	if frameSize == 0 || len(samples) == 0 {
+		return quality
+	}
+	quality.DurationSeconds = roundTo(float64(len(samples))/float64(sampleRate), 1)
+
+	clipped := 0
+	var levels []float64
+	silentFrames := 0
+	for start := 0; start < len(samples); start += frameSize {
+		frame := samples[start:min(start+frameSize, len(samples))]
+		var sumSquares float64
+		for _, s := range frame {
+			sumSquares += s * s
+			if math.Abs(s) >= clippedLevel {
+				clipped++
+			}
+		}
+		level := floorDB
+		if rms := math.Sqrt(sumSquares / float64(len(frame))); rms > 0 {
+			level = math.Max(20*math.Log10(rms), floorDB)
+		}
+		if level < thresholdDB {
+			silentFrames++
+		}
+		levels = append(levels, level)
+	}
+	sort.Float64s(levels)
+
+	percentile := func(p float64) float64 { return levels[int(p*float64(len(levels)-1))] }
+	quality.SNRDB = roundTo(math.Max(percentile(0.9)-percentile(0.1), 0), 1)
+	quality.ClippingRatio = roundTo(float64(clipped)/float64(len(samples)), 4)
+	quality.SilenceRatio = roundTo(float64(silentFrames)/float64(len(levels)), 3)
+
+	snrScore := math.Min(quality.SNRDB/30, 1)
+	clippingPenalty := math.Min(quality.ClippingRatio/0.01, 1)
+	speechScore := math.Min((1-quality.SilenceRatio)/0.2, 1)
+	quality.Score = int(math.Round(100 * snrScore * (1 - clippingPenalty/2) * speechScore))
+	return quality
+}
+
+// audioQualitySkipReason is the skip reason of a recording below the minimum score
+func audioQualitySkipReason(quality *AudioQuality) string {
+	return fmt.Sprintf("audio quality score %d is below the minimum of %d", quality.Score, audioQualityMinScore())
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Backfill job statuses
+const (
+	BackfillRunning   = "running"
+	BackfillCompleted = "completed"
+)
+
+const (
+	// defaultBackfillConcurrency is how many calls a backfill processes at once when BACKFILL_CONCURRENCY is not set
+	defaultBackfillConcurrency = 4
+	// defaultBackfillPageSize is how many calls are listed per keyset page when BACKFILL_PAGE_SIZE is not set
+	defaultBackfillPageSize = 50
+	// defaultBackfillTimeMargin is how long before the Lambda deadline a backfill stops starting calls,
+	// enough for the calls in flight to finish
+	defaultBackfillTimeMargin = 2 * time.Minute
+)
+
+// ErrBackfillNotFound means the backfill job to resume doesn't exist
+var ErrBackfillNotFound = errors.New("backfill job not found")
+
+// ErrBackfillLeased means another invocation is running the backfill job
+var ErrBackfillLeased = errors.New("backfill job is being run by another invocation")
+
+// BackfillRequest configures the "backfill" action. A new backfill gives the campaign and date
+// range (YYYY-MM-DD, until inclusive and optional); a continuation gives only the job ID.
+type BackfillRequest struct {
+	JobID      int64  `json:"jobId,omitempty"`
+	CampaignID string `json:"campaignId,omitempty"`
+	Since      string `json:"since,omitempty"`
+	Until      string `json:"until,omitempty"`
+	// Reprocess includes calls that already have an analysis
+	Reprocess bool `json:"reprocess,omitempty"`
+}
+
+// BackfillJob is a backfill's progress as checkpointed in backfill_jobs
+type BackfillJob struct {
+	ID          int64  `json:"jobId"`
+	CampaignID  string `json:"campaignId"`
+	Since       string `json:"since"`
+	Until       string `json:"until,omitempty"`
+	Reprocess   bool   `json:"reprocess,omitempty"`
+	Status      string `json:"status"`
+	Processed   int    `json:"processed"`
+	Skipped     int    `json:"skipped"`
+	Failed      int    `json:"failed"`
+	LastError   string `json:"lastError,omitempty"`
+	Invocations int    `json:"invocations"`
+	// Continued is set when this invocation invoked the function again to carry on
+	Continued bool `json:"continued,omitempty"`
+
+	cursor backfillCall
+	// finishedAhead are the calls past the cursor that finished before the job halted
+	finishedAhead []string
+	leaseOwner    string
+}
+
+// backfillCall is a call to backfill with its keyset pagination key
+type backfillCall struct {
+	ID        string
+	StartDate string
+	StartTime string
+}
+
+// backfillConcurrency reads how many calls a backfill processes at once (BACKFILL_CONCURRENCY)
+func backfillConcurrency() int {
+	if n, err := strconv.Atoi(os.Getenv("BACKFILL_CONCURRENCY")); err == nil && n > 0 {
+		return n
+	}
+	return defaultBackfillConcurrency
+}
+
+// backfillPageSize reads how many calls are listed per page (BACKFILL_PAGE_SIZE)
+func backfillPageSize() int {
+	if n, err := strconv.Atoi(os.Getenv("BACKFILL_PAGE_SIZE")); err == nil && n > 0 {
+		return n
+	}
+	return defaultBackfillPageSize
+}
+
+// backfillTimeMargin reads how long before the deadline a backfill stops starting calls (BACKFILL_TIME_MARGIN_SECONDS)
+func backfillTimeMargin() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("BACKFILL_TIME_MARGIN_SECONDS")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultBackfillTimeMargin
+}
+
+// backfillSelfInvokeEnabled reports whether an unfinished backfill invokes the function again
+// (BACKFILL_SELF_INVOKE=false leaves resuming to the caller, e.g. a Step Functions loop)
+func backfillSelfInvokeEnabled() bool {
+	return os.Getenv("BACKFILL_SELF_INVOKE") != "false"
+}
+
+// HandleBackfill starts or resumes a backfill and runs it until it completes or the invocation
+// nears its deadline. An unfinished backfill returns 202 and, unless BACKFILL_SELF_INVOKE is
+// false, invokes the function again with its job ID. A backfill halted by the Gemini quota or an
+// open circuit breaker returns 429 and isn't continued; resume it later with its job ID.
+func (tp *TranscriptionPipeline) HandleBackfill(ctx context.Context, request BackfillRequest) LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	if request.JobID == 0 {
+		if err := validateBackfillRequest(request); err != nil {
+			return LambdaResponse{StatusCode: 400, Error: err.Error()}
+		}
+		jobID, err := tp.CreateBackfillJob(request)
+		if err != nil {
+			return LambdaResponse{StatusCode: 500, Error: err.Error()}
+		}
+		request.JobID = jobID
+	}
+
+	job, err := tp.LeaseBackfillJob(request.JobID)
+	switch {
+	case errors.Is(err, ErrBackfillNotFound):
+		return LambdaResponse{StatusCode: 404, Error: err.Error()}
+	case errors.Is(err, ErrBackfillLeased):
+		return LambdaResponse{StatusCode: 409, Error: err.Error()}
+	case err != nil:
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	if job.Status == BackfillCompleted {
+		return LambdaResponse{StatusCode: 200, Body: job}
+	}
+
+	// A backfill that can't start a call before its deadline would continue itself forever
+	deadline, _ := ctx.Deadline()
+	if !deadline.IsZero() && time.Until(deadline) < backfillTimeMargin() {
+		tp.ReleaseBackfillJob(job)
+		return LambdaResponse{StatusCode: 500, Body: job, Error: "the function timeout must be longer than BACKFILL_TIME_MARGIN_SECONDS"}
+	}
+	halted, runErr := tp.RunBackfill(job, deadline)
+	tp.ReleaseBackfillJob(job)
+	if runErr != nil {
+		return LambdaResponse{StatusCode: 500, Body: job, Error: runErr.Error()}
+	}
+
+	switch {
+	case job.Status == BackfillCompleted:
+		return LambdaResponse{StatusCode: 200, Body: job}
+	case halted != nil:
+		return LambdaResponse{StatusCode: http.StatusTooManyRequests, Body: job, Error: halted.Error(), ErrorCategory: errorCategory(halted)}
+	}
+
+	if runningInLambda() && backfillSelfInvokeEnabled() {
+		if err := invokeSelfAsync(LambdaRequest{Action: "backfill", Backfill: &BackfillRequest{JobID: job.ID}}); err != nil {
+			log.Printf("Error continuing backfill %d: %v", job.ID, err)
+			return LambdaResponse{StatusCode: 500, Body: job, Error: fmt.Sprintf("error continuing backfill: %v", err)}
+		}
+		job.Continued = true
+	}
+	return LambdaResponse{StatusCode: http.StatusAccepted, Body: job}
+}
+
+// validateBackfillRequest checks a new backfill's campaign and dates
+func validateBackfillRequest(request BackfillRequest) error {
+	if request.CampaignID == "" || request.Since == "" {
+		return fmt.Errorf("backfill.campaignId and backfill.since are required")
+	}
+	if _, err := time.Parse("2006-01-02", request.Since); err != nil {
+		return fmt.Errorf("invalid backfill.since date: %v", err)
+	}
+	if request.Until != "" {
+		if _, err := time.Parse("2006-01-02", request.Until); err != nil {
+			return fmt.Errorf("invalid backfill.until date: %v", err)
+		}
+	}
+	return nil
+}
+
+// CreateBackfillJob records a new backfill and returns its job ID
+func (tp *TranscriptionPipeline) CreateBackfillJob(request BackfillRequest) (int64, error) {
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("campaignId", since, until, reprocess)
+		VALUES ($1, $2::date, NULLIF($3, '')::date, $4)
+		RETURNING id
+	`, tp.schema.Table("backfill_jobs"))
+
+	var jobID int64
+	if err := tp.repo.QueryRow(query, request.CampaignID, request.Since, request.Until, request.Reprocess).Scan(&jobID); err != nil {
+		return 0, fmt.Errorf("error creating backfill job: %v", err)
+	}
+	return jobID, nil
+}
+
+// LeaseBackfillJob takes the job's lease and reads its checkpoint. The lease lasts as long as a
+// processing lock and is renewed at every checkpoint, so a crashed invocation's lease expires on
+// its own. Completed jobs are returned without a lease.
+func (tp *TranscriptionPipeline) LeaseBackfillJob(jobID int64) (*BackfillJob, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("error generating lease owner: %v", err)
+	}
+	owner := hex.EncodeToString(token)
+
+	query := fmt.Sprintf(`
+		UPDATE %s SET
+			"leaseOwner" = CASE WHEN status = $3 THEN $2 END,
+			"leaseUntil" = CASE WHEN status = $3 THEN now() + $4::interval END,
+			invocations = invocations + CASE WHEN status = $3 THEN 1 ELSE 0 END,
+			"updatedAt" = now()
+		WHERE id = $1 AND (status <> $3 OR "leaseUntil" IS NULL OR "leaseUntil" < now())
+		RETURNING "campaignId"::text, since::text, COALESCE(until::text, ''), reprocess, status,
+		          COALESCE("cursorDate"::text, ''), COALESCE("cursorTime", ''), COALESCE("cursorId", ''),
+		          processed, skipped, failed, COALESCE("lastError", ''), invocations, "finishedAhead"::text
+	`, tp.schema.Table("backfill_jobs"))
+
+	ttl := fmt.Sprintf("%d seconds", int(processingLockTTL().Seconds()))
+	job := &BackfillJob{ID: jobID, leaseOwner: owner}
+	var finishedAhead string
+	err := tp.repo.QueryRow(query, jobID, owner, BackfillRunning, ttl).Scan(&job.CampaignID, &job.Since, &job.Until,
+		&job.Reprocess, &job.Status, &job.cursor.StartDate, &job.cursor.StartTime, &job.cursor.ID,
+		&job.Processed, &job.Skipped, &job.Failed, &job.LastError, &job.Invocations, &finishedAhead)
+	if err == sql.ErrNoRows {
+		var exists bool
+		existsQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE id = $1)`, tp.schema.Table("backfill_jobs"))
+		if err := tp.repo.QueryRow(existsQuery, jobID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("error reading backfill job: %v", err)
+		}
+		if !exists {
+			return nil, ErrBackfillNotFound
+		}
+		return nil, ErrBackfillLeased
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error leasing backfill job: %v", err)
+	}
+	if err := json.Unmarshal([]byte(finishedAhead), &job.finishedAhead); err != nil {
+		return nil, fmt.Errorf("error parsing backfill job's finished calls: %v", err)
+	}
+	return job, nil
+}
+
+// CheckpointBackfillJob saves the job's cursor, finished calls and counts and renews its lease. It
+// fails if the lease expired and another invocation took the job over.
+func (tp *TranscriptionPipeline) CheckpointBackfillJob(job *BackfillJob) error {
+	query := fmt.Sprintf(`
+		UPDATE %s SET
+			status = $3, "cursorDate" = NULLIF($4, '')::date, "cursorTime" = $5, "cursorId" = NULLIF($6, ''),
+			processed = $7, skipped = $8, failed = $9, "lastError" = NULLIF($10, ''),
+			"leaseUntil" = now() + $11::interval, "updatedAt" = now(),
+			"completedAt" = CASE WHEN $3 = $12 THEN now() END, "finishedAhead" = $13::jsonb
+		WHERE id = $1 AND "leaseOwner" = $2
+	`, tp.schema.Table("backfill_jobs"))
+
+	ttl := fmt.Sprintf("%d seconds", int(processingLockTTL().Seconds()))
+	result, err := tp.repo.Exec(query, job.ID, job.leaseOwner, job.Status, job.cursor.StartDate, job.cursor.StartTime,
+		job.cursor.ID, job.Processed, job.Skipped, job.Failed, job.LastError, ttl, BackfillCompleted, job.finishedAheadJSON())
+	if err != nil {
+		return fmt.Errorf("error checkpointing backfill job: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrBackfillLeased
+	}
+	return nil
+}
+
+// ReleaseBackfillJob gives up the job's lease so its continuation can take it straight away.
+// A failed release is only logged: the lease expires on its own.
+func (tp *TranscriptionPipeline) ReleaseBackfillJob(job *BackfillJob) {
+	query := fmt.Sprintf(`
+		UPDATE %s SET "leaseOwner" = NULL, "leaseUntil" = NULL, "updatedAt" = now()
+		WHERE id = $1 AND "leaseOwner" = $2
+	`, tp.schema.Table("backfill_jobs"))
+	if _, err := tp.repo.Exec(query, job.ID, job.leaseOwner); err != nil {
+		log.Printf("Error releasing backfill job %d: %v", job.ID, err)
+	}
+}
+
+// ListBackfillPage returns the next page of the job's calls after its cursor, in
+// (start_date, start_time, id) order, leaving out those that finished before the job halted
+func (tp *TranscriptionPipeline) ListBackfillPage(job *BackfillJob, limit int) ([]backfillCall, error) {
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	startDate := c("start_date") + "::date"
+	startTime := fmt.Sprintf("COALESCE(%s::text, '')", c("start_time"))
+	id := c("id") + "::text"
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, %[2]s::text, %[3]s
+		FROM %[4]s
+		WHERE %[5]s = $1
+		  AND %[2]s >= $2::date
+		  AND ($3 = '' OR %[2]s <= NULLIF($3, '')::date)
+		  AND COALESCE(%[6]s, '') <> ''
+		  AND ($4 OR %[7]s IS NULL)
+		  AND ($5 = '' OR (%[2]s, %[3]s, %[1]s) > (NULLIF($5, '')::date, $6::text, $7::text))
+		  AND NOT $8::jsonb @> to_jsonb(%[1]s)
+		ORDER BY %[2]s, %[3]s, %[1]s
+		LIMIT %[8]d
+	`, id, startDate, startTime, tp.schema.Table("call_logs"), c("campaignId"), c("recording_url"), c("callAnalysis"), limit)
+
+	rows, err := tp.repo.Query(query, job.CampaignID, job.Since, job.Until, job.Reprocess,
+		job.cursor.StartDate, job.cursor.StartTime, job.cursor.ID, job.finishedAheadJSON())
+	if err != nil {
+		return nil, fmt.Errorf("error listing calls to backfill: %v", err)
+	}
+	defer rows.Close()
+
+	var calls []backfillCall
+	for rows.Next() {
+		var call backfillCall
+		if err := rows.Scan(&call.ID, &call.StartDate, &call.StartTime); err != nil {
+			return nil, fmt.Errorf("error scanning call row: %v", err)
+		}
+		calls = append(calls, call)
+	}
+	return calls, rows.Err()
+}
+
+// backfillResult is the outcome of one call of a page
+type backfillResult struct {
+	index int
+	err   error
+}
+
+// RunBackfill processes the job's calls page by page with up to BACKFILL_CONCURRENCY calls at
+// once. After each call the cursor moves past every call that has finished without a call before
+// it still running, and is checkpointed, so a resumed job neither skips nor repeats calls. No call
+// is started within BACKFILL_TIME_MARGIN_SECONDS of the deadline (none for a zero deadline).
+// halted is the error that stopped the job early when the Gemini quota is exhausted or a circuit
+// breaker is open; the call that hit it is retried when the job resumes. Calls after it that had
+// already finished are counted and kept in finishedAhead, so the resumed job doesn't process them
+// (and pay for them) again.
+func (tp *TranscriptionPipeline) RunBackfill(job *BackfillJob, deadline time.Time) (halted error, err error) {
+	concurrency := backfillConcurrency()
+	margin := backfillTimeMargin()
+	outOfTime := func() bool { return !deadline.IsZero() && time.Until(deadline) < margin }
+
+	for !outOfTime() {
+		calls, err := tp.ListBackfillPage(job, backfillPageSize())
+		if err != nil {
+			return nil, err
+		}
+		if len(calls) == 0 {
+			job.Status = BackfillCompleted
+			return nil, tp.CheckpointBackfillJob(job)
+		}
+
+		results := make(chan backfillResult)
+		errs := make([]error, len(calls))
+		finished := make([]bool, len(calls))
+		dispatched, inFlight, next := 0, 0, 0
+		var stopErr error
+
+		for {
+			for stopErr == nil && halted == nil && inFlight < concurrency && dispatched < len(calls) && !outOfTime() {
+				index := dispatched
+				dispatched++
+				inFlight++
+				go func() {
+					results <- backfillResult{index: index, err: processBackfillCall(calls[index].ID, job.Reprocess)}
+				}()
+			}
+			if inFlight == 0 {
+				break
+			}
+
+			result := <-results
+			inFlight--
+			if errors.Is(result.err, ErrGeminiQuotaExhausted) || errors.Is(result.err, ErrCircuitOpen) {
+				if halted == nil {
+					halted = result.err
+				}
+				continue
+			}
+			errs[result.index], finished[result.index] = result.err, true
+
+			// Advance the cursor over the finished calls; a halted call is left for the next run
+			advanced := false
+			for next < dispatched && finished[next] {
+				job.count(calls[next].ID, errs[next])
+				job.cursor = calls[next]
+				next++
+				advanced = true
+			}
+			if advanced && stopErr == nil {
+				stopErr = tp.CheckpointBackfillJob(job)
+			}
+		}
+
+		if stopErr != nil {
+			return halted, stopErr
+		}
+		if halted != nil {
+			// The cursor can't pass the halted call, so the calls after it that finished are recorded by ID
+			for index := next; index < dispatched; index++ {
+				if finished[index] {
+					job.count(calls[index].ID, errs[index])
+					job.finishedAhead = append(job.finishedAhead, calls[index].ID)
+				}
+			}
+			job.LastError = halted.Error()
+			return halted, tp.CheckpointBackfillJob(job)
+		}
+	}
+	return nil, nil
+}
+
+// finishedAheadJSON is finishedAhead as the JSON array stored in backfill_jobs
+func (job *BackfillJob) finishedAheadJSON() string {
+	if len(job.finishedAhead) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(job.finishedAhead)
+	return string(data)
+}
+
+// count adds a finished call's outcome to the job's counts
+func (job *BackfillJob) count(callLogsID string, err error) {
+	switch {
+	case err == nil:
+		job.Processed++
+	case errors.Is(err, ErrAlreadyProcessed):
+		job.Skipped++
+	default:
+		job.Failed++
+		job.LastError = fmt.Sprintf("%s: %v", callLogsID, err)
+	}
+}
+
+// processBackfillCall processes one call with a fresh pipeline, so per-call state doesn't leak
+// between calls processed at the same time
+func processBackfillCall(callLogsID string, reprocess bool) error {
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	pipeline.reprocess = reprocess
+
+	_, err = pipeline.ProcessCall(callLogsID)
+	return err
+}
+
+// invokeSelfAsync invokes the running Lambda function again with the request, without waiting for it
+func invokeSelfAsync(request LambdaRequest) error {
+	functionName := os.Getenv("AWS_LAMBDA_FUNCTION_NAME")
+	if functionName == "" {
+		return fmt.Errorf("AWS_LAMBDA_FUNCTION_NAME is not set")
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("error marshaling lambda request: %v", err)
+	}
+
+	endpoint := fmt.Sprintf("https://lambda.%s.amazonaws.com/2015-03-31/functions/%s/invocations", awsRegion(), awsURIEncode(functionName, true))
+	_, err = doAWSRequest("POST", endpoint, "lambda", map[string]string{"X-Amz-Invocation-Type": "Event"}, body)
+	return err
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrBudgetExceeded means the campaign has spent its daily token or cost budget
+var ErrBudgetExceeded = errors.New("campaign daily budget exceeded")
+
+// What happens to a campaign's calls once its daily budget is spent
+const (
+	BudgetReject    = "reject"
+	BudgetDowngrade = "downgrade"
+)
+
+// defaultBudgetDowngradeModel is the model over-budget campaigns are downgraded to unless the budget names one
+const defaultBudgetDowngradeModel = "gemini-2.5-flash"
+
+// CampaignBudget caps a campaign's Gemini spend per UTC day. Either limit may be zero to leave it unset.
+//
+//	"budget": {"dailyTokens": 5000000, "dailyCostUsd": 20, "onExceeded": "downgrade"}
+type CampaignBudget struct {
+	// DailyTokens caps prompt plus output tokens
+	DailyTokens int64 `json:"dailyTokens,omitempty"`
+	// DailyCostUsd caps the estimated cost at geminiModelPrices
+	DailyCostUsd float64 `json:"dailyCostUsd,omitempty"`
+	// OnExceeded is "reject" (the default) to fail calls with ErrBudgetExceeded, or "downgrade" to
+	// process them with DowngradeModel
+	OnExceeded     string `json:"onExceeded,omitempty"`
+	DowngradeModel string `json:"downgradeModel,omitempty"`
+}
+
+// modelPrice is a Gemini model's USD price per million tokens
+type modelPrice struct {
+	Input, Output float64
+}
+
+// geminiModelPrices are the list prices budgets estimate costs at. Unknown models are priced as
+// gemini-2.5-pro, so a new model errs towards exhausting the budget early.
+var geminiModelPrices = map[string]modelPrice{
+	"gemini-2.5-pro":        {Input: 1.25, Output: 10},
+	"gemini-2.5-flash":      {Input: 0.30, Output: 2.50},
+	"gemini-2.5-flash-lite": {Input: 0.10, Output: 0.40},
+	"gemini-2.0-flash":      {Input: 0.10, Output: 0.40},
+}
+
+// geminiRequestCost estimates the USD cost of a request to the model
+func geminiRequestCost(model string, usage *UsageMetadata) float64 {
+	price, ok := geminiModelPrices[strings.TrimPrefix(model, "models/")]
+	if !ok {
+		price = geminiModelPrices[defaultGeminiModel]
+	}
+	return (float64(usage.PromptTokenCount)*price.Input + float64(usage.CandidatesTokenCount)*price.Output) / 1e6
+}
+
+// applyCampaignBudget makes the campaign's Gemini requests count towards its daily usage and checks
+// its budget: a spent budget returns ErrBudgetExceeded, or downgrades the call's model. Usage
+// lookup failures are logged and the call goes ahead.
+func (tp *TranscriptionPipeline) applyCampaignBudget(campaignID string, budget *CampaignBudget) error {
+	tp.usageCampaignID = campaignID
+	tp.budgetModel = ""
+	if budget == nil || (budget.DailyTokens <= 0 && budget.DailyCostUsd <= 0) {
+		return nil
+	}
+
+	tokens, cost, err := tp.campaignDailyUsage(campaignID)
+	if err != nil {
+		log.Printf("Campaign usage unavailable, not enforcing the budget of %s: %v", campaignID, err)
+		return nil
+	}
+
+	var exceeded string
+	switch {
+	case budget.DailyTokens > 0 && tokens >= budget.DailyTokens:
+		exceeded = fmt.Sprintf("%d of %d tokens used today", tokens, budget.DailyTokens)
+	case budget.DailyCostUsd > 0 && cost >= budget.DailyCostUsd:
+		exceeded = fmt.Sprintf("$%.2f of $%.2f spent today", cost, budget.DailyCostUsd)
+	default:
+		return nil
+	}
+
+	if budget.OnExceeded == BudgetDowngrade {
+		tp.budgetModel = budget.DowngradeModel
+		if tp.budgetModel == "" {
+			tp.budgetModel = defaultBudgetDowngradeModel
+		}
+		tp.metadata.BudgetDowngrade = tp.budgetModel
+		log.Printf("Campaign %s is over budget (%s), downgrading to %s", campaignID, exceeded, tp.budgetModel)
+		return nil
+	}
+	return fmt.Errorf("%w: campaign %s has %s", ErrBudgetExceeded, campaignID, exceeded)
+}
+
+// campaignDailyUsage reads the campaign's tokens and estimated cost so far today (UTC)
+func (tp *TranscriptionPipeline) campaignDailyUsage(campaignID string) (int64, float64, error) {
+	query := fmt.Sprintf(`
+		SELECT "promptTokens" + "outputTokens", "costUsd"
+		FROM %s
+		WHERE "campaignId" = $1 AND day = (now() AT TIME ZONE 'UTC')::date
+	`, tp.schema.Table("campaign_daily_usage"))
+
+	var tokens int64
+	var cost float64
+	err := tp.repo.QueryRow(query, campaignID).Scan(&tokens, &cost)
+	if err == sql.ErrNoRows {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("error reading campaign usage: %v", err)
+	}
+	return tokens, cost, nil
+}
+
+// recordCampaignUsage adds a Gemini request to the current campaign's usage for today. Dry runs are
+// counted too, since their tokens are billed. Failures are logged and don't affect the call.
+func (tp *TranscriptionPipeline) recordCampaignUsage(model string, usage *UsageMetadata) {
+	if tp.usageCampaignID == "" || usage == nil || tp.repo == nil {
+		return
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS usage ("campaignId", day, requests, "promptTokens", "outputTokens", "costUsd", "updatedAt")
+		VALUES ($1, (now() AT TIME ZONE 'UTC')::date, 1, $2, $3, $4, now())
+		ON CONFLICT ("campaignId", day)
+		DO UPDATE SET requests = usage.requests + 1,
+		              "promptTokens" = usage."promptTokens" + EXCLUDED."promptTokens",
+		              "outputTokens" = usage."outputTokens" + EXCLUDED."outputTokens",
+		              "costUsd" = usage."costUsd" + EXCLUDED."costUsd",
+		              "updatedAt" = now()
+	`, tp.schema.Table("campaign_daily_usage"))
+	if _, err := tp.repo.Exec(query, tp.usageCampaignID, usage.PromptTokenCount, usage.CandidatesTokenCount, geminiRequestCost(model, usage)); err != nil {
+		log.Printf("Error recording usage of campaign %s: %v", tp.usageCampaignID, err)
+	}
+}
@@ -9,7 +9,7 @@ rm -f main bootstrap deployment.zip
 
 # Build for Linux (required for AWS Lambda)
 echo "🔨 Compiling Go binary..."
-GOOS=linux GOARCH=amd64 go build -o bootstrap main.go
+GOOS=linux GOARCH=amd64 go build -o bootstrap .
 
 if [ $? -eq 0 ]; then
     echo "✅ Build successful!"
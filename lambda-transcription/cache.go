@@ -0,0 +1,118 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CachedTranscription represents a previously computed transcription and its answers
+type CachedTranscription struct {
+	Transcription string
+	Answers       map[string]string
+	Words         []TranscriptWord
+}
+
+// sha256Hex returns the hex-encoded SHA-256 digest of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// questionsFingerprint identifies a question set so cached answers are only reused for the same questions
+func questionsFingerprint(questions []Question) string {
+	var b strings.Builder
+	for _, q := range questions {
+		fmt.Fprintf(&b, "%s\x1f%s\x1f%s\x1f%s\x1e", q.ID, q.QuestionText, q.AnswerType, q.Instructions)
+		// Examples change the answers; questions without any keep their earlier fingerprint
+		for _, e := range q.Examples {
+			fmt.Fprintf(&b, "\x1d%s\x1f%s\x1f%s", e.Excerpt, e.Answer, e.Explanation)
+		}
+	}
+	return sha256Hex([]byte(b.String()))
+}
+
+// GetCachedTranscriptionByURL looks up a cached result by the SHA-256 of the recording URL
+func (tp *TranscriptionPipeline) GetCachedTranscriptionByURL(urlHash, questionsHash string) (*CachedTranscription, error) {
+	query := fmt.Sprintf(`
+		SELECT transcription, answers, words
+		FROM %s
+		WHERE "urlHash" = $1 AND "questionsHash" = $2
+		ORDER BY "createdAt" DESC
+		LIMIT 1
+	`, tp.schema.Table("transcription_cache"))
+	return tp.scanCachedTranscription(tp.repo.QueryRow(query, urlHash, questionsHash))
+}
+
+// GetCachedTranscriptionByContent looks up a cached result by the SHA-256 of the audio bytes
+func (tp *TranscriptionPipeline) GetCachedTranscriptionByContent(contentHash, questionsHash string) (*CachedTranscription, error) {
+	query := fmt.Sprintf(`
+		SELECT transcription, answers, words
+		FROM %s
+		WHERE "contentHash" = $1 AND "questionsHash" = $2
+	`, tp.schema.Table("transcription_cache"))
+	return tp.scanCachedTranscription(tp.repo.QueryRow(query, contentHash, questionsHash))
+}
+
+// scanCachedTranscription reads a cache row, returning nil when there is no cached result
+func (tp *TranscriptionPipeline) scanCachedTranscription(row *Row) (*CachedTranscription, error) {
+	var cached CachedTranscription
+	var answersJSON, wordsJSON []byte
+
+	if err := row.Scan(&cached.Transcription, &answersJSON, &wordsJSON); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading transcription cache: %v", err)
+	}
+
+	if err := json.Unmarshal(answersJSON, &cached.Answers); err != nil {
+		return nil, fmt.Errorf("error parsing cached answers: %v", err)
+	}
+	if cached.Answers == nil {
+		cached.Answers = make(map[string]string)
+	}
+
+	if len(wordsJSON) > 0 {
+		if err := json.Unmarshal(wordsJSON, &cached.Words); err != nil {
+			return nil, fmt.Errorf("error parsing cached words: %v", err)
+		}
+	}
+
+	return &cached, nil
+}
+
+// SaveTranscriptionCache stores a transcription result for reuse by duplicate recordings
+func (tp *TranscriptionPipeline) SaveTranscriptionCache(urlHash, contentHash, questionsHash string, result *TranscriptionResult) error {
+	answersJSON, err := json.Marshal(result.Answers)
+	if err != nil {
+		return fmt.Errorf("error marshaling cached answers: %v", err)
+	}
+
+	// Words are only returned by some providers
+	var wordsJSON interface{}
+	if len(result.Words) > 0 {
+		data, err := json.Marshal(result.Words)
+		if err != nil {
+			return fmt.Errorf("error marshaling cached words: %v", err)
+		}
+		wordsJSON = string(data)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("urlHash", "contentHash", "questionsHash", transcription, answers, words, "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6, now())
+		ON CONFLICT ("contentHash", "questionsHash")
+		DO UPDATE SET "urlHash" = EXCLUDED."urlHash", transcription = EXCLUDED.transcription,
+		              answers = EXCLUDED.answers, words = EXCLUDED.words, "createdAt" = EXCLUDED."createdAt"
+	`, tp.schema.Table("transcription_cache"))
+
+	if _, err := tp.repo.Exec(query, urlHash, contentHash, questionsHash, result.Transcription, string(answersJSON), wordsJSON); err != nil {
+		return fmt.Errorf("error saving transcription cache: %v", err)
+	}
+
+	return nil
+}
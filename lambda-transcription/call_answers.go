@@ -0,0 +1,26 @@
+package main
+
+import "fmt"
+
+// replaceCallAnswers swaps the call's rows in call_answers for the analysis's answers, typed with
+// their question's details.answerType ("text" when the question is gone or has none). Skipped
+// questions have no row.
+func (tp *TranscriptionPipeline) replaceCallAnswers(tx *Tx, callLogsID string, answers map[string]string) error {
+	answersTable := tp.schema.Table("call_answers")
+	q := func(name string) string { return "q." + tp.schema.Column("question", name) }
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, answersTable), callLogsID); err != nil {
+		return fmt.Errorf("error clearing call answers: %v", err)
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "questionId", answer, "answerType")
+		SELECT $1, $2::text, $3, COALESCE((SELECT %s->>'answerType' FROM %s q WHERE %s::text = $2::text), 'text')
+	`, answersTable, q("details"), tp.schema.Table("question"), q("id"))
+	for questionID, answer := range answers {
+		if _, err := tx.Exec(insertQuery, callLogsID, questionID, answer); err != nil {
+			return fmt.Errorf("error saving answer to question %s: %v", questionID, err)
+		}
+	}
+	return nil
+}
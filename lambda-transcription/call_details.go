@@ -0,0 +1,30 @@
+package main
+
+// callDetailsPrompt renders the details of the call being processed for the question prompts, so
+// questions like "did the agent introduce themselves by name" can be answered. Empty for voice
+// notes, inline audio and calls without details.
+func (tp *TranscriptionPipeline) callDetailsPrompt() string {
+	call := tp.promptCall
+	if call == nil || !callDetailsInPrompt() {
+		return ""
+	}
+	return renderCallDetailsPrompt(call.Duration, call.AgentName, call.CampaignName, call.CallerIDNumber)
+}
+
+// CallPhoneNumbers are the normalized numbers of a call, stored in its analysis
+type CallPhoneNumbers struct {
+	Caller *PhoneNumber `json:"caller,omitempty"`
+	CallTo *PhoneNumber `json:"call_to,omitempty"`
+}
+
+// callPhoneNumbers normalizes the call's caller and called numbers; nil when neither can be
+func callPhoneNumbers(callData *CallData) *CallPhoneNumbers {
+	numbers := &CallPhoneNumbers{
+		Caller: parsePhoneNumber(callData.CallerIDNumber),
+		CallTo: parsePhoneNumber(callData.CallToNumber),
+	}
+	if numbers.Caller == nil && numbers.CallTo == nil {
+		return nil
+	}
+	return numbers
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"log"
+)
+
+// Defaults of ProfileLearning
+const (
+	defaultProfileExplorePercent = 10
+	defaultProfileMinCalls       = 50
+)
+
+// Why a call was processed with its profile
+const (
+	ProfileReasonOverride = "override"
+	// ProfileReasonLearning spreads calls over the candidates until each has MinCalls
+	ProfileReasonLearning = "learning"
+	// ProfileReasonExploring keeps trying the other candidates with ExplorePercent of the calls
+	ProfileReasonExploring = "exploring"
+	ProfileReasonBest      = "best"
+)
+
+// CampaignProfile is a combination of output language, Gemini model and prompt variant a campaign's
+// calls can be processed with. Empty fields keep the campaign's defaults. A variant brings its own
+// model, so Model only applies to profiles without one.
+type CampaignProfile struct {
+	OutputLanguage string `json:"outputLanguage,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Variant        string `json:"variant,omitempty"`
+}
+
+// ProfileLearning picks each call's profile among the candidates by the share of their answers
+// that passed validation, so campaigns don't have to be tuned by hand.
+//
+//	"profileLearning": {"candidates": [{}, {"model": "gemini-2.5-flash"}, {"variant": "terse"}], "explorePercent": 10, "minCalls": 50}
+type ProfileLearning struct {
+	Candidates []CampaignProfile `json:"candidates"`
+	// ExplorePercent of the calls go to the other candidates once the best one is known (default 10)
+	ExplorePercent int `json:"explorePercent,omitempty"`
+	// MinCalls is how many calls each candidate gets before the best is picked (default 50)
+	MinCalls int `json:"minCalls,omitempty"`
+	// Override pins the campaign's calls to a profile; they still count towards its statistics
+	Override *CampaignProfile `json:"override,omitempty"`
+}
+
+// ProfileStats are a profile's answer validation counts for a campaign
+type ProfileStats struct {
+	CampaignProfile
+	Calls          int     `json:"calls"`
+	CheckedAnswers int     `json:"checked_answers"`
+	ValidAnswers   int     `json:"valid_answers"`
+	ValidationRate float64 `json:"validation_rate"`
+}
+
+// ProfileChoice is the profile a call was processed with and why, recorded in the processing metadata
+type ProfileChoice struct {
+	CampaignProfile
+	Reason string `json:"reason"`
+}
+
+// selectCampaignProfile picks the profile of the campaign's call: the override, a candidate short of
+// MinCalls, a random other candidate for ExplorePercent of the calls, or else the candidate with
+// the best validation rate. If the statistics can't be read the first candidate is used.
+func (tp *TranscriptionPipeline) selectCampaignProfile(campaignID, callLogsID string, learning *ProfileLearning) *ProfileChoice {
+	if learning == nil {
+		return nil
+	}
+	if learning.Override != nil {
+		return &ProfileChoice{CampaignProfile: *learning.Override, Reason: ProfileReasonOverride}
+	}
+	if len(learning.Candidates) == 0 {
+		return nil
+	}
+
+	stats, err := tp.campaignProfileStats(campaignID, learning.Candidates)
+	if err != nil {
+		log.Printf("Profile statistics unavailable for campaign %s, using its first candidate: %v", campaignID, err)
+		return &ProfileChoice{CampaignProfile: learning.Candidates[0], Reason: ProfileReasonLearning}
+	}
+
+	bucket := variantBucket("profile:" + callLogsID)
+	minCalls := learning.MinCalls
+	if minCalls <= 0 {
+		minCalls = defaultProfileMinCalls
+	}
+	var learningCandidates []CampaignProfile
+	for _, s := range stats {
+		if s.Calls < minCalls {
+			learningCandidates = append(learningCandidates, s.CampaignProfile)
+		}
+	}
+	if len(learningCandidates) > 0 {
+		return &ProfileChoice{CampaignProfile: learningCandidates[bucket%len(learningCandidates)], Reason: ProfileReasonLearning}
+	}
+
+	best := 0
+	for i, s := range stats {
+		if s.ValidationRate > stats[best].ValidationRate {
+			best = i
+		}
+	}
+
+	explorePercent := learning.ExplorePercent
+	if explorePercent <= 0 {
+		explorePercent = defaultProfileExplorePercent
+	}
+	if len(stats) > 1 && bucket < explorePercent {
+		other := bucket % (len(stats) - 1)
+		if other >= best {
+			other++
+		}
+		return &ProfileChoice{CampaignProfile: stats[other].CampaignProfile, Reason: ProfileReasonExploring}
+	}
+	return &ProfileChoice{CampaignProfile: stats[best].CampaignProfile, Reason: ProfileReasonBest}
+}
+
+// campaignProfileStats reads the statistics of each candidate, in the candidates' order; candidates
+// without calls yet have zero counts
+func (tp *TranscriptionPipeline) campaignProfileStats(campaignID string, candidates []CampaignProfile) ([]ProfileStats, error) {
+	query := fmt.Sprintf(`
+		SELECT "outputLanguage", model, variant, calls, "checkedAnswers", "validAnswers"
+		FROM %s
+		WHERE "campaignId" = $1
+	`, tp.schema.Table("campaign_profile_stats"))
+
+	rows, err := tp.repo.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching profile statistics: %v", err)
+	}
+	defer rows.Close()
+
+	recorded := make(map[CampaignProfile]ProfileStats)
+	for rows.Next() {
+		var s ProfileStats
+		if err := rows.Scan(&s.OutputLanguage, &s.Model, &s.Variant, &s.Calls, &s.CheckedAnswers, &s.ValidAnswers); err != nil {
+			return nil, fmt.Errorf("error scanning profile statistics row: %v", err)
+		}
+		recorded[s.CampaignProfile] = s
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating profile statistics: %v", err)
+	}
+
+	stats := make([]ProfileStats, len(candidates))
+	for i, candidate := range candidates {
+		s, ok := recorded[candidate]
+		if !ok {
+			s = ProfileStats{CampaignProfile: candidate}
+		}
+		if s.CheckedAnswers > 0 {
+			s.ValidationRate = roundTo(float64(s.ValidAnswers)/float64(s.CheckedAnswers), 4)
+		}
+		stats[i] = s
+	}
+	return stats, nil
+}
+
+// answerValidation counts the call's answers checked and those that passed, as review's answer
+// problems do: every question that wasn't skipped needs an answer that is a valid option for enum
+// questions and wasn't rejected by grounding, and every outcome field a convertible answer
+func answerValidation(questions []Question, analysis *CallAnalysisData) (checked, valid int) {
+	rejected := make(map[string]bool)
+	if analysis.Grounding != nil {
+		for _, id := range analysis.Grounding.Rejected {
+			rejected[id] = true
+		}
+	}
+	for _, q := range questions {
+		if _, skipped := analysis.SkippedQuestions[q.ID]; skipped {
+			continue
+		}
+		checked++
+		if enumAnswer, ok := analysis.EnumAnswers[q.ID]; ok && !enumAnswer.Valid {
+			continue
+		}
+		if _, answered := analysis.Answers[q.ID]; answered && !rejected[q.ID] {
+			valid++
+		}
+	}
+	return checked + len(analysis.OutcomeErrors), valid
+}
+
+// RecordProfileStats adds the call's answer validation to its profile's statistics
+func (tp *TranscriptionPipeline) RecordProfileStats(db Execer, campaignID string, profile CampaignProfile, checked, valid int) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS s ("campaignId", "outputLanguage", model, variant, calls, "checkedAnswers", "validAnswers", "updatedAt")
+		VALUES ($1, $2, $3, $4, 1, $5, $6, now())
+		ON CONFLICT ("campaignId", "outputLanguage", model, variant)
+		DO UPDATE SET calls = s.calls + 1, "checkedAnswers" = s."checkedAnswers" + EXCLUDED."checkedAnswers",
+		              "validAnswers" = s."validAnswers" + EXCLUDED."validAnswers", "updatedAt" = now()
+	`, tp.schema.Table("campaign_profile_stats"))
+
+	if _, err := db.Exec(query, campaignID, profile.OutputLanguage, profile.Model, profile.Variant, checked, valid); err != nil {
+		return fmt.Errorf("error saving profile statistics: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// CampaignSettings represents per-campaign pipeline overrides stored as JSON in campaign_settings
+type CampaignSettings struct {
+	// TranscriptionProvider overrides TRANSCRIPTION_PROVIDER for the campaign
+	TranscriptionProvider string `json:"transcriptionProvider,omitempty"`
+	// GeminiTenant names the entry of the GEMINI_TENANT_KEYS_SECRET_ID secret whose API key and quota the
+	// campaign's Gemini requests use; empty uses GEMINI_API_KEY
+	GeminiTenant string `json:"geminiTenant,omitempty"`
+	// GeminiFallbackModels overrides GEMINI_FALLBACK_MODELS, the models tried in order when the
+	// configured one is unavailable; an empty list disables fallback for the campaign
+	GeminiFallbackModels []string `json:"geminiFallbackModels,omitempty"`
+	// OutcomeFields maps question answers into typed rows of call_outcomes
+	OutcomeFields []OutcomeField `json:"outcomeFields,omitempty"`
+	// CRM pushes the call's results to a Salesforce or HubSpot record
+	CRM *CRMConfig `json:"crm,omitempty"`
+	// PromptExperiment assigns calls to prompt/model variants for A/B testing
+	PromptExperiment *PromptExperiment `json:"promptExperiment,omitempty"`
+	// Review flags analyses for human review
+	Review *ReviewSettings `json:"review,omitempty"`
+	// Eligibility overrides the pipeline-wide pre-flight duration and recording URL rules
+	Eligibility *EligibilityRules `json:"eligibility,omitempty"`
+	// OutputLanguage is the language answers and QA rationales are written in (e.g. "English"),
+	// whatever language the call is in; empty keeps the model's default
+	OutputLanguage string `json:"outputLanguage,omitempty"`
+	// Intents is the taxonomy the call's outcome is classified into, e.g. interested / callback / not-interested
+	Intents []IntentLabel `json:"intents,omitempty"`
+	// Retention overrides RETENTION_DAYS/RETENTION_MODE; applied by the "retention" action, not per call
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Budget caps the campaign's daily Gemini tokens or cost
+	Budget *CampaignBudget `json:"budget,omitempty"`
+	// DebugResponses archives every raw Gemini response of the campaign's calls to S3 for replaying
+	DebugResponses bool `json:"debugResponses,omitempty"`
+	// TwoPhase answers the questions from the transcription in a separate, cheaper text-only request
+	TwoPhase *TwoPhaseSettings `json:"twoPhase,omitempty"`
+	// ProfileLearning picks the output language, model and prompt variant of each call from the
+	// candidates with the best answer validation rates
+	ProfileLearning *ProfileLearning `json:"profileLearning,omitempty"`
+}
+
+// GetCampaignSettings retrieves the campaign's settings, returning defaults when none are configured
+func (tp *TranscriptionPipeline) GetCampaignSettings(campaignID string) (*CampaignSettings, error) {
+	query := fmt.Sprintf(`
+		SELECT settings
+		FROM %s
+		WHERE "campaignId" = $1
+	`, tp.schema.Table("campaign_settings"))
+
+	var settingsJSON []byte
+	err := tp.repo.QueryRow(query, campaignID).Scan(&settingsJSON)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return &CampaignSettings{}, nil
+		}
+		return nil, fmt.Errorf("error fetching campaign settings: %v", err)
+	}
+
+	var settings CampaignSettings
+	if len(settingsJSON) > 0 {
+		if err := json.Unmarshal(settingsJSON, &settings); err != nil {
+			return nil, fmt.Errorf("error parsing campaign settings: %v", err)
+		}
+	}
+
+	return &settings, nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// identicalChannelRatio is the relative difference below which two channels are the same mono signal
+	identicalChannelRatio = 0.02
+	// silentChannelPeak is the peak level below which a channel has no speech to transcribe
+	silentChannelPeak = 0.001
+)
+
+// stereoAgentChannel reads which channel carries the agent (STEREO_AGENT_CHANNEL: left or right)
+func stereoAgentChannel() int {
+	if strings.EqualFold(os.Getenv("STEREO_AGENT_CHANNEL"), "right") {
+		return 1
+	}
+	return 0
+}
+
+// splitStereoChannels splits a split-channel stereo recording into separate agent and customer
+// recordings. ok is false for mono recordings, stereo recordings whose channels carry the same
+// signal, and formats that can't be decoded (non-WAV recordings need ffmpeg).
+func splitStereoChannels(audioContent []byte) (agent, customer []byte, ok bool) {
+	ffmpeg := ffmpegPath()
+
+	wav, ok := decodeWAV(audioContent)
+	if !ok && ffmpeg != "" {
+		converted, err := ffmpegToWAV(ffmpeg, audioContent)
+		if err != nil {
+			log.Printf("Error decoding recording for channel splitting: %v", err)
+			return nil, nil, false
+		}
+		wav, ok = decodeWAV(converted)
+	}
+	if !ok || len(wav.channels) != 2 || sameSignal(wav.channels[0], wav.channels[1]) {
+		return nil, nil, false
+	}
+
+	agentChannel := stereoAgentChannel()
+	encode := func(samples []float64) []byte {
+		if peakLevel(samples) < silentChannelPeak {
+			return nil
+		}
+		audio := encodeWAV(downsample(samples, wav.sampleRate, preprocessedSampleRate), min(wav.sampleRate, preprocessedSampleRate))
+		if ffmpeg != "" {
+			if mp3, err := transcodeWithFFmpeg(ffmpeg, audio, false); err == nil && len(mp3) > 0 {
+				return mp3
+			}
+		}
+		return audio
+	}
+
+	return encode(wav.channels[agentChannel]), encode(wav.channels[1-agentChannel]), true
+}
+
+// ffmpegToWAV converts the recording to a 16kHz 16-bit PCM WAV, keeping its channels
+func ffmpegToWAV(ffmpeg string, audioContent []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpegTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+		"-ar", strconv.Itoa(preprocessedSampleRate), "-c:a", "pcm_s16le", "-f", "wav", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audioContent)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// sameSignal reports whether two channels carry (nearly) the same audio, as in mono recordings saved as stereo
+func sameSignal(left, right []float64) bool {
+	var difference, total float64
+	for i := range left {
+		difference += math.Abs(left[i] - right[i])
+		total += math.Abs(left[i]) + math.Abs(right[i])
+	}
+	return total == 0 || difference/total < identicalChannelRatio
+}
+
+// peakLevel returns the largest absolute sample
+func peakLevel(samples []float64) float64 {
+	var peak float64
+	for _, s := range samples {
+		peak = math.Max(peak, math.Abs(s))
+	}
+	return peak
+}
+
+// transcribeSplitChannels transcribes the agent and customer channels separately, labels every
+// segment by its channel and merges them by start time, then answers the questions from the
+// merged transcription. A nil channel was silent and has no turns.
+func (tp *TranscriptionPipeline) transcribeSplitChannels(provider string, agentAudio, customerAudio []byte, questions []Question) (string, map[string]string, []TranscriptWord, error) {
+	transcriber, err := NewTranscriber(provider, tp)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	var segments []TranscriptSegment
+	var words []TranscriptWord
+	for _, channel := range []struct {
+		speaker string
+		audio   []byte
+	}{{SpeakerAgent, agentAudio}, {SpeakerCustomer, customerAudio}} {
+		if channel.audio == nil {
+			continue
+		}
+
+		transcript, err := transcriber.Transcribe(channel.audio)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to transcribe %s channel with %s: %w", strings.ToLower(channel.speaker), transcriber.Name(), err)
+		}
+		tp.metadata.TranscriptionModel = transcript.Model
+
+		channelSegments := transcript.Segments
+		if len(channelSegments) == 0 {
+			channelSegments = parseDiarizedTranscript(transcript.Text)
+		}
+		if len(channelSegments) == 0 && strings.TrimSpace(transcript.Text) != "" {
+			// Without timestamps the channel can't be interleaved; keep its text as a single turn
+			channelSegments = []TranscriptSegment{{Text: strings.TrimSpace(transcript.Text), End: transcript.Duration}}
+		}
+		for _, segment := range channelSegments {
+			segment.Speaker = channel.speaker
+			segments = append(segments, segment)
+		}
+		for _, word := range transcript.Words {
+			word.Speaker = channel.speaker
+			words = append(words, word)
+		}
+	}
+
+	if len(segments) == 0 {
+		return "", nil, nil, fmt.Errorf("no speech found in either channel")
+	}
+
+	// Stable, so simultaneous turns keep the agent first
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	sort.SliceStable(words, func(i, j int) bool { return words[i].Start < words[j].Start })
+	transcription := formatTranscriptSegments(segments)
+
+	answers := make(map[string]string)
+	if len(questions) > 0 {
+		answers, err = tp.AnswerQuestionsFromTranscript(transcription, questions)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to answer questions: %w", err)
+		}
+	}
+
+	return transcription, answers, words, nil
+}
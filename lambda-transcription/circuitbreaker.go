@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults, overridable via CIRCUIT_BREAKER_FAILURE_THRESHOLD and CIRCUIT_BREAKER_OPEN_SECONDS
+const (
+	defaultBreakerFailureThreshold = 3
+	defaultBreakerOpenDuration     = 60 * time.Second
+)
+
+// ErrCircuitOpen is returned when a dependency's circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// Package-level breakers survive across warm Lambda invocations; Gemini's are per key and model
+// (see currentGeminiBreaker)
+var databaseBreaker = NewCircuitBreaker("database", breakerFailureThreshold(), breakerOpenDuration())
+
+// CircuitBreaker fails fast after consecutive dependency failures.
+// After failureThreshold consecutive failures the breaker opens for openDuration; once that
+// elapses a single trial call is let through (half-open) and its outcome closes or re-opens it.
+type CircuitBreaker struct {
+	name             string
+	failureThreshold int
+	openDuration     time.Duration
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	openedAt            time.Time
+	trialInFlight       bool
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(name string, failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+	}
+}
+
+// Allow returns an error wrapping ErrCircuitOpen if calls to the dependency should not be attempted
+func (cb *CircuitBreaker) Allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	if cb.openedAt.IsZero() {
+		return nil
+	}
+
+	remaining := cb.openDuration - time.Since(cb.openedAt)
+	if remaining > 0 {
+		return fmt.Errorf("%w for %s, retry after %ds", ErrCircuitOpen, cb.name, int(remaining.Seconds())+1)
+	}
+
+	// Half-open: let a single trial call through
+	if cb.trialInFlight {
+		return fmt.Errorf("%w for %s, trial call in progress", ErrCircuitOpen, cb.name)
+	}
+	cb.trialInFlight = true
+	return nil
+}
+
+// RecordSuccess closes the breaker and resets the failure count
+func (cb *CircuitBreaker) RecordSuccess() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutiveFailures = 0
+	cb.openedAt = time.Time{}
+	cb.trialInFlight = false
+}
+
+// RecordFailure counts a failure and opens the breaker once the threshold is reached
+func (cb *CircuitBreaker) RecordFailure() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.consecutiveFailures++
+	if cb.trialInFlight || cb.consecutiveFailures >= cb.failureThreshold {
+		cb.openedAt = time.Now()
+	}
+	cb.trialInFlight = false
+}
+
+// breakerFailureThreshold reads the consecutive failure threshold from the environment
+func breakerFailureThreshold() int {
+	if value, err := strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_FAILURE_THRESHOLD")); err == nil && value > 0 {
+		return value
+	}
+	return defaultBreakerFailureThreshold
+}
+
+// breakerOpenDuration reads how long an open breaker rejects calls from the environment
+func breakerOpenDuration() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("CIRCUIT_BREAKER_OPEN_SECONDS")); err == nil && value > 0 {
+		return time.Duration(value) * time.Second
+	}
+	return defaultBreakerOpenDuration
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+const cliUsage = `Usage: transcribe <command> [flags]
+
+Commands:
+  run       Process a single call, voice note or audio file
+  backfill  Process a campaign's calls from a date onwards
+  replay    Regenerate analyses from archived Gemini responses without transcribing again
+  migrate   Apply pending database migrations
+  digest    Build and send the daily processing digest
+  evaluate  Score providers and prompt variants against the golden calls
+  retention Anonymize or purge calls past their campaign's retention period
+  profiles  Show a campaign's learned profiles and their validation rates
+
+Run "transcribe <command> -h" for a command's flags.
+Configuration is read from the environment and .env, as in Lambda.
+`
+
+// runCLI runs a CLI command against the configured database and returns the process exit code
+func runCLI(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, cliUsage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "run":
+		err = cliRun(args[1:])
+	case "backfill":
+		err = cliBackfill(args[1:])
+	case "replay":
+		err = cliReplay(args[1:])
+	case "migrate":
+		err = cliMigrate(args[1:])
+	case "digest":
+		err = cliDigest(args[1:])
+	case "evaluate":
+		err = cliEvaluate(args[1:])
+	case "retention":
+		err = cliRetention(args[1:])
+	case "profiles":
+		err = cliProfiles(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(cliUsage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], cliUsage)
+		return 2
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// cliRun processes a single call, voice note or audio file and prints the result
+func cliRun(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	callID := flags.String("call-id", "", "call_logs ID to process (this, --message-id or --audio-file is required)")
+	messageID := flags.String("message-id", "", "messages ID of a voice note to process instead of a call")
+	audioFile := flags.String("audio-file", "", "local recording to process without a call_logs row; nothing is saved")
+	campaignID := flags.String("campaign", "", "campaign whose questions are answered for --audio-file")
+	provider := flags.String("provider", "", "transcription provider override (gemini, openai, deepgram)")
+	dryRun := flags.Bool("dry-run", false, "process the call without saving anything and print the would-be analysis")
+	questionsFile := flags.String("questions", "", "JSON file of ad-hoc questions to answer instead of the campaign's; nothing is saved")
+	questionsMode := flags.String("questions-mode", QuestionsModeReplace, "replace the campaign's questions with --questions, or append to them")
+	flags.Parse(args)
+
+	given := 0
+	for _, source := range []string{*callID, *messageID, *audioFile} {
+		if source != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		flags.Usage()
+		return fmt.Errorf("one of --call-id, --message-id and --audio-file is required")
+	}
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	if *provider != "" {
+		pipeline.transcriptionProvider = *provider
+	}
+	pipeline.SetDryRun(*dryRun)
+	// Running a call by hand is an explicit request to (re)process it
+	pipeline.reprocess = true
+	if *questionsFile != "" {
+		data, readErr := os.ReadFile(*questionsFile)
+		if readErr != nil {
+			return fmt.Errorf("error reading %s: %v", *questionsFile, readErr)
+		}
+		var questions []map[string]interface{}
+		if err := json.Unmarshal(data, &questions); err != nil {
+			return fmt.Errorf("error parsing %s: %v", *questionsFile, err)
+		}
+		if err := pipeline.SetAdHocQuestions(questions, *questionsMode); err != nil {
+			return err
+		}
+	}
+
+	var result map[string]interface{}
+	if *audioFile != "" {
+		audioContent, readErr := os.ReadFile(*audioFile)
+		if readErr != nil {
+			return fmt.Errorf("error reading %s: %v", *audioFile, readErr)
+		}
+		result, err = pipeline.ProcessInlineAudio(InlineAudio{
+			Data:       base64.StdEncoding.EncodeToString(audioContent),
+			CampaignID: *campaignID,
+			Reference:  *audioFile,
+		})
+	} else if *messageID != "" {
+		result, err = pipeline.ProcessVoiceNote(*messageID)
+	} else {
+		result, err = pipeline.ProcessCall(*callID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return printJSON(result)
+}
+
+// cliBackfill processes every matching call of a campaign, one at a time
+func cliBackfill(args []string) error {
+	flags := flag.NewFlagSet("backfill", flag.ExitOnError)
+	campaignID := flags.String("campaign", "", "campaign ID to backfill (required)")
+	since := flags.String("since", "", "first call date to include, YYYY-MM-DD (required)")
+	until := flags.String("until", "", "last call date to include, YYYY-MM-DD")
+	limit := flags.Int("limit", 0, "maximum number of calls to process (0 for no limit)")
+	all := flags.Bool("all", false, "reprocess calls that already have an analysis")
+	dryRun := flags.Bool("dry-run", false, "list the calls that would be processed without processing them")
+	provider := flags.String("provider", "", "transcription provider override (gemini, openai, deepgram)")
+	flags.Parse(args)
+
+	if *campaignID == "" || *since == "" {
+		flags.Usage()
+		return fmt.Errorf("--campaign and --since are required")
+	}
+	if _, err := time.Parse("2006-01-02", *since); err != nil {
+		return fmt.Errorf("invalid --since date: %v", err)
+	}
+	if *until != "" {
+		if _, err := time.Parse("2006-01-02", *until); err != nil {
+			return fmt.Errorf("invalid --until date: %v", err)
+		}
+	}
+
+	lister, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	if err := lister.ConnectToDatabase(); err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	callIDs, err := lister.ListBackfillCallIDs(*campaignID, *since, *until, !*all, *limit)
+	lister.CloseDatabase()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Found %d calls to process\n", len(callIDs))
+	if *dryRun {
+		for _, id := range callIDs {
+			fmt.Println(id)
+		}
+		return nil
+	}
+
+	failed := 0
+	for i, id := range callIDs {
+		// A fresh pipeline per call so per-call state (e.g. archived Gemini exchanges) doesn't leak
+		pipeline, err := NewTranscriptionPipelineFromEnv()
+		if err != nil {
+			return err
+		}
+		if *provider != "" {
+			pipeline.transcriptionProvider = *provider
+		}
+		pipeline.reprocess = *all
+
+		if _, err := pipeline.ProcessCall(id); err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: failed: %v\n", i+1, len(callIDs), id, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: done\n", i+1, len(callIDs), id)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calls failed", failed, len(callIDs))
+	}
+	return nil
+}
+
+// cliReplay re-parses the archived process_audio responses of a call, or of a campaign's calls, and
+// saves the regenerated analyses. Calls without an archived response are skipped.
+func cliReplay(args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	callID := flags.String("call-id", "", "call_logs ID to replay (this or --campaign is required)")
+	campaignID := flags.String("campaign", "", "campaign whose calls are replayed")
+	since := flags.String("since", "", "first call date to include with --campaign, YYYY-MM-DD (required with --campaign)")
+	until := flags.String("until", "", "last call date to include with --campaign, YYYY-MM-DD")
+	limit := flags.Int("limit", 0, "maximum number of calls to replay with --campaign (0 for no limit)")
+	dryRun := flags.Bool("dry-run", false, "print the regenerated analyses without saving them")
+	flags.Parse(args)
+
+	if (*callID == "") == (*campaignID == "") {
+		flags.Usage()
+		return fmt.Errorf("one of --call-id and --campaign is required")
+	}
+
+	callIDs := []string{*callID}
+	if *campaignID != "" {
+		if _, err := time.Parse("2006-01-02", *since); err != nil {
+			return fmt.Errorf("--since is required with --campaign as YYYY-MM-DD: %v", err)
+		}
+		if *until != "" {
+			if _, err := time.Parse("2006-01-02", *until); err != nil {
+				return fmt.Errorf("invalid --until date: %v", err)
+			}
+		}
+
+		lister, err := NewTranscriptionPipelineFromEnv()
+		if err != nil {
+			return err
+		}
+		if err := lister.ConnectToDatabase(); err != nil {
+			return fmt.Errorf("failed to connect to database: %v", err)
+		}
+		callIDs, err = lister.ListBackfillCallIDs(*campaignID, *since, *until, false, *limit)
+		lister.CloseDatabase()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Found %d calls to replay\n", len(callIDs))
+	}
+
+	failed, skipped := 0, 0
+	for i, id := range callIDs {
+		// A fresh pipeline per call, as for backfills
+		pipeline, err := NewTranscriptionPipelineFromEnv()
+		if err != nil {
+			return err
+		}
+		pipeline.SetDryRun(*dryRun)
+
+		result, err := pipeline.ReplayCall(id)
+		switch {
+		case errors.Is(err, ErrNoArchivedResponse):
+			skipped++
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: skipped: %v\n", i+1, len(callIDs), id, err)
+			continue
+		case err != nil:
+			failed++
+			fmt.Fprintf(os.Stderr, "[%d/%d] %s: failed: %v\n", i+1, len(callIDs), id, err)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[%d/%d] %s: done\n", i+1, len(callIDs), id)
+		if *dryRun {
+			if err := printJSON(result); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(callIDs) == 1 && skipped == 1 {
+		return fmt.Errorf("call %s has no archived process_audio response", callIDs[0])
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d calls failed", failed, len(callIDs))
+	}
+	return nil
+}
+
+// ListBackfillCallIDs returns the IDs of a campaign's calls with recordings between since and until
+// (inclusive, YYYY-MM-DD; until may be empty), oldest first
+func (tp *TranscriptionPipeline) ListBackfillCallIDs(campaignID, since, until string, onlyMissing bool, limit int) ([]string, error) {
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s = $1
+		  AND %s >= $2::date
+		  AND ($3 = '' OR %s <= NULLIF($3, '')::date)
+		  AND COALESCE(%s, '') <> ''
+		  AND (NOT $4 OR %s IS NULL)
+		ORDER BY %s, %s
+	`, c("id"), tp.schema.Table("call_logs"), c("campaignId"),
+		c("start_date"), c("start_date"), c("recording_url"), c("callAnalysis"),
+		c("start_date"), c("start_time"))
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := tp.repo.Query(query, campaignID, since, until, onlyMissing)
+	if err != nil {
+		return nil, fmt.Errorf("error listing calls to backfill: %v", err)
+	}
+	defer rows.Close()
+
+	var callIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning call row: %v", err)
+		}
+		callIDs = append(callIDs, id)
+	}
+
+	return callIDs, rows.Err()
+}
+
+// cliMigrate applies pending database migrations
+func cliMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	flags.Parse(args)
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+
+	response := pipeline.HandleMigrate()
+	if response.Error != "" {
+		return fmt.Errorf("%s", response.Error)
+	}
+	return printJSON(response.Body)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// cliDigest builds the processing digest for a day and sends it, or prints it with --dry-run
+func cliDigest(args []string) error {
+	flags := flag.NewFlagSet("digest", flag.ExitOnError)
+	date := flags.String("date", "", "day to report on, YYYY-MM-DD in DIGEST_TIMEZONE (default yesterday)")
+	dryRun := flags.Bool("dry-run", false, "print the digest and its Slack rendering without sending it")
+	flags.Parse(args)
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+
+	if !*dryRun {
+		response := pipeline.HandleDigest(*date)
+		if response.Error != "" {
+			return fmt.Errorf("%s", response.Error)
+		}
+		return printJSON(response.Body)
+	}
+
+	if err := pipeline.ConnectToDatabase(); err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer pipeline.CloseDatabase()
+
+	digest, err := pipeline.BuildDigest(*date)
+	if err != nil {
+		return err
+	}
+	text, err := renderDigestSlack(digest)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, text)
+	return printJSON(digest)
+}
+
+// cliEvaluate runs an evaluation against the golden calls and prints the report
+func cliEvaluate(args []string) error {
+	flags := flag.NewFlagSet("evaluate", flag.ExitOnError)
+	campaignID := flags.String("campaign", "", "only evaluate this campaign's golden calls")
+	providers := flags.String("providers", "", "comma-separated transcription providers (default TRANSCRIPTION_PROVIDER)")
+	variants := flags.String("variants", "", `comma-separated prompt variants, "default" for the default prompt (default "default")`)
+	limit := flags.Int("limit", 0, "maximum number of golden calls (0 for all)")
+	noSave := flags.Bool("no-save", false, "print the report without storing it in evaluation_runs")
+	flags.Parse(args)
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	pipeline.SetDryRun(*noSave)
+
+	response := pipeline.HandleEvaluate(EvaluationConfig{
+		CampaignID: *campaignID,
+		Providers:  splitList(*providers),
+		Variants:   splitList(*variants),
+		Limit:      *limit,
+	})
+	if response.Error != "" {
+		return fmt.Errorf("%s", response.Error)
+	}
+	return printJSON(response.Body)
+}
+
+// cliRetention applies the retention policies, or lists the expired calls with --dry-run
+func cliRetention(args []string) error {
+	flags := flag.NewFlagSet("retention", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "list the calls past their retention period without changing them")
+	flags.Parse(args)
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	pipeline.SetDryRun(*dryRun)
+
+	response := pipeline.HandleRetention()
+	if response.Error != "" {
+		printJSON(response.Body)
+		return fmt.Errorf("%s", response.Error)
+	}
+	return printJSON(response.Body)
+}
+
+// cliProfiles prints the statistics of a campaign's profileLearning candidates
+func cliProfiles(args []string) error {
+	flags := flag.NewFlagSet("profiles", flag.ExitOnError)
+	campaignID := flags.String("campaign", "", "campaign whose profiles are shown (required)")
+	flags.Parse(args)
+
+	if *campaignID == "" {
+		flags.Usage()
+		return fmt.Errorf("--campaign is required")
+	}
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return err
+	}
+	if err := pipeline.ConnectToDatabase(); err != nil {
+		return fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer pipeline.CloseDatabase()
+
+	settings, err := pipeline.GetCampaignSettings(*campaignID)
+	if err != nil {
+		return err
+	}
+	if settings.ProfileLearning == nil {
+		return fmt.Errorf("campaign %s has no profileLearning setting", *campaignID)
+	}
+	stats, err := pipeline.campaignProfileStats(*campaignID, settings.ProfileLearning.Candidates)
+	if err != nil {
+		return err
+	}
+	return printJSON(map[string]interface{}{
+		"candidates": stats,
+		"override":   settings.ProfileLearning.Override,
+	})
+}
+
+// splitList splits a comma-separated flag value, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
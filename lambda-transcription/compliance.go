@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Compliance rule types
+const (
+	ComplianceRuleMandatory  = "mandatory"
+	ComplianceRuleProhibited = "prohibited"
+)
+
+// ComplianceRule represents a keyword or regex the campaign's calls are checked against
+type ComplianceRule struct {
+	ID       string `json:"id"`
+	Label    string `json:"label"`
+	Pattern  string `json:"pattern"`
+	RuleType string `json:"rule_type"`
+	IsRegex  bool   `json:"is_regex"`
+	// WithinSeconds requires a mandatory rule to match a turn starting in the call's first seconds; 0 for anywhere
+	WithinSeconds int `json:"within_seconds,omitempty"`
+}
+
+// ComplianceFinding represents a mandatory phrase that was missed or a prohibited phrase that was said
+type ComplianceFinding struct {
+	RuleID  string   `json:"rule_id"`
+	Label   string   `json:"label"`
+	Pattern string   `json:"pattern"`
+	Matches []string `json:"matches,omitempty"`
+	// Error is why a rule that couldn't be checked counts as missed
+	Error string `json:"error,omitempty"`
+}
+
+// TimedComplianceCheck records when a mandatory rule with a window (e.g. the recording notice) was
+// first said, as per-call proof of notice
+type TimedComplianceCheck struct {
+	RuleID        string `json:"rule_id"`
+	Label         string `json:"label"`
+	WithinSeconds int    `json:"within_seconds"`
+	Passed        bool   `json:"passed"`
+	// DetectedAt is the start of the first turn the rule matched, even when it was too late; nil when never said
+	DetectedAt *float64 `json:"detected_at,omitempty"`
+	Timestamp  string   `json:"timestamp,omitempty"`
+	Speaker    string   `json:"speaker,omitempty"`
+	Quote      string   `json:"quote,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
+// ComplianceResult represents the compliance section of the call analysis
+type ComplianceResult struct {
+	Passed          bool                   `json:"passed"`
+	RulesChecked    int                    `json:"rules_checked"`
+	MandatoryMisses []ComplianceFinding    `json:"mandatory_misses"`
+	ProhibitedHits  []ComplianceFinding    `json:"prohibited_hits"`
+	TimedChecks     []TimedComplianceCheck `json:"timed_checks,omitempty"`
+	Errors          []string               `json:"errors,omitempty"`
+}
+
+// GetComplianceRulesForCampaign retrieves the active keyword/regex rules for the campaign
+func (tp *TranscriptionPipeline) GetComplianceRulesForCampaign(campaignID string) ([]ComplianceRule, error) {
+	query := fmt.Sprintf(`
+		SELECT id, label, pattern, "ruleType", "isRegex", COALESCE("withinSeconds", 0)
+		FROM %s
+		WHERE "isActive" = true AND "campaignId" = $1
+		ORDER BY id
+	`, tp.schema.Table("campaign_compliance_rule"))
+
+	rows, err := tp.repo.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching compliance rules for campaign: %v", err)
+	}
+	defer rows.Close()
+
+	var rules []ComplianceRule
+	for rows.Next() {
+		var r ComplianceRule
+		if err := rows.Scan(&r.ID, &r.Label, &r.Pattern, &r.RuleType, &r.IsRegex, &r.WithinSeconds); err != nil {
+			return nil, fmt.Errorf("error scanning compliance rule row: %v", err)
+		}
+		rules = append(rules, r)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating compliance rules: %v", err)
+	}
+
+	return rules, nil
+}
+
+// checkCompliance checks the transcription against the campaign's compliance rules.
+// Mandatory rules must match at least once, within their window when they have one (see
+// checkTimedRule); prohibited rules must not match at all. A mandatory rule with an invalid pattern
+// and a rule of unknown type can't be checked, so they count as missed and the call doesn't pass:
+// a passed result is proof every mandatory rule was said.
+func checkCompliance(transcription string, segments []TranscriptSegment, rules []ComplianceRule) *ComplianceResult {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	result := &ComplianceResult{
+		MandatoryMisses: []ComplianceFinding{},
+		ProhibitedHits:  []ComplianceFinding{},
+	}
+
+	for _, rule := range rules {
+		pattern := rule.Pattern
+		if !rule.IsRegex {
+			pattern = regexp.QuoteMeta(pattern)
+		}
+
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			message := fmt.Sprintf("invalid pattern: %v", err)
+			result.Errors = append(result.Errors, fmt.Sprintf("rule %s: %s", rule.ID, message))
+			if strings.ToLower(rule.RuleType) != ComplianceRuleProhibited {
+				result.MandatoryMisses = append(result.MandatoryMisses, ComplianceFinding{RuleID: rule.ID, Label: rule.Label, Pattern: rule.Pattern, Error: message})
+				if rule.WithinSeconds > 0 {
+					result.TimedChecks = append(result.TimedChecks, TimedComplianceCheck{RuleID: rule.ID, Label: rule.Label, WithinSeconds: rule.WithinSeconds, Error: message})
+				}
+			}
+			continue
+		}
+		result.RulesChecked++
+
+		matches := re.FindAllString(transcription, -1)
+		finding := ComplianceFinding{
+			RuleID:  rule.ID,
+			Label:   rule.Label,
+			Pattern: rule.Pattern,
+		}
+
+		switch strings.ToLower(rule.RuleType) {
+		case ComplianceRuleMandatory:
+			if rule.WithinSeconds > 0 {
+				check := checkTimedRule(rule, re, segments)
+				result.TimedChecks = append(result.TimedChecks, check)
+				if !check.Passed {
+					result.MandatoryMisses = append(result.MandatoryMisses, finding)
+				}
+			} else if len(matches) == 0 {
+				result.MandatoryMisses = append(result.MandatoryMisses, finding)
+			}
+		case ComplianceRuleProhibited:
+			if len(matches) > 0 {
+				finding.Matches = matches
+				result.ProhibitedHits = append(result.ProhibitedHits, finding)
+			}
+		default:
+			finding.Error = fmt.Sprintf("unknown rule type %q", rule.RuleType)
+			result.Errors = append(result.Errors, fmt.Sprintf("rule %s: %s", rule.ID, finding.Error))
+			result.MandatoryMisses = append(result.MandatoryMisses, finding)
+		}
+	}
+
+	result.Passed = len(result.MandatoryMisses) == 0 && len(result.ProhibitedHits) == 0 && len(result.Errors) == 0
+
+	return result
+}
+
+// checkTimedRule finds the first diarized turn matching a mandatory rule with a window. The rule
+// passes when that turn starts within the window; without timestamps it can't be verified and fails.
+func checkTimedRule(rule ComplianceRule, re *regexp.Regexp, segments []TranscriptSegment) TimedComplianceCheck {
+	check := TimedComplianceCheck{RuleID: rule.ID, Label: rule.Label, WithinSeconds: rule.WithinSeconds}
+	if len(segments) == 0 {
+		check.Error = "transcription has no timestamps"
+		return check
+	}
+
+	for _, segment := range segments {
+		match := re.FindString(segment.Text)
+		if match == "" {
+			continue
+		}
+		start := segment.Start
+		check.DetectedAt = &start
+		check.Timestamp = formatTimestamp(start)
+		check.Speaker = segment.Speaker
+		check.Quote = match
+		check.Passed = start < float64(rule.WithinSeconds)
+		break
+	}
+	return check
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CRM providers
+const (
+	CRMProviderSalesforce = "salesforce"
+	CRMProviderHubSpot    = "hubspot"
+)
+
+const (
+	// crmCredentialsTTL is how long CRM credentials (and Salesforce tokens) are cached across warm invocations
+	crmCredentialsTTL = 5 * time.Minute
+	// crmMaxAttempts bounds the attempts per CRM request; 429s, 5xx responses and network errors are retried
+	crmMaxAttempts = 3
+
+	salesforceAPIVersion = "v59.0"
+	hubSpotAPIBaseURL    = "https://api.hubapi.com"
+)
+
+// CRMConfig configures the push of a campaign's call results to a CRM record.
+//
+// FieldMapping maps CRM field names to sources:
+//
+//	answer:<questionId>   the question's (validated) answer
+//	outcome:<field>       a typed value from the campaign's outcomeFields
+//	call:<name>           call_logsId, campaignId, call_id, campaign_name, agent_name, caller_id_number,
+//	                      call_to_number, start_date, start_time or duration
+//	qa_score, compliance_passed, intent, intent_rationale, provider, processed_at, transcription
+//	literal:<value>       a fixed value
+type CRMConfig struct {
+	Provider string `json:"provider"`
+	// Object is the Salesforce sObject (e.g. "Task") or HubSpot object type (e.g. "calls")
+	Object string `json:"object"`
+	// ExternalIDField is the CRM field holding the call_logsId, making pushes upserts so
+	// reprocessing a call updates its record instead of creating a duplicate
+	ExternalIDField string            `json:"externalIdField,omitempty"`
+	FieldMapping    map[string]string `json:"fieldMapping"`
+}
+
+// CRMCredentials holds a provider's API credentials. Salesforce uses AccessToken, or a token minted
+// with the client credentials flow from ClientID and ClientSecret; HubSpot uses a private app AccessToken.
+type CRMCredentials struct {
+	InstanceURL  string `json:"instance_url,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// CRMSyncResult records the outcome of a CRM push in the analysis
+type CRMSyncResult struct {
+	Provider string `json:"provider"`
+	Object   string `json:"object"`
+	RecordID string `json:"record_id,omitempty"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+var (
+	crmCredentialsMu       sync.Mutex
+	crmCredentials         map[string]CRMCredentials
+	crmCredentialsLoadedAt time.Time
+)
+
+// loadCRMCredentials returns the provider's credentials from the Secrets Manager secret named by
+// CRM_SECRET_ID (a JSON object keyed by provider name), refreshing them after crmCredentialsTTL
+func loadCRMCredentials(provider string) (*CRMCredentials, error) {
+	secretID := os.Getenv("CRM_SECRET_ID")
+	if secretID == "" {
+		return nil, fmt.Errorf("CRM_SECRET_ID is not configured")
+	}
+
+	crmCredentialsMu.Lock()
+	defer crmCredentialsMu.Unlock()
+
+	if err := refreshCRMCredentials(secretID); err != nil {
+		return nil, err
+	}
+
+	creds, ok := crmCredentials[provider]
+	if !ok {
+		return nil, fmt.Errorf("no CRM credentials for provider %s", provider)
+	}
+
+	// Mint a Salesforce token once per cache period; it outlives crmCredentialsTTL
+	if provider == CRMProviderSalesforce && creds.AccessToken == "" {
+		token, err := salesforceClientCredentialsToken(creds)
+		if err != nil {
+			return nil, err
+		}
+		creds.AccessToken = token
+		crmCredentials[provider] = creds
+	}
+
+	return &creds, nil
+}
+
+// refreshCRMCredentials reloads the CRM secret once crmCredentialsTTL has passed; the caller holds crmCredentialsMu
+func refreshCRMCredentials(secretID string) error {
+	if crmCredentials != nil && time.Since(crmCredentialsLoadedAt) < crmCredentialsTTL {
+		return nil
+	}
+
+	secretString, err := getSecretString(secretID)
+	if err != nil {
+		return fmt.Errorf("error loading CRM credentials: %v", err)
+	}
+	var providers map[string]CRMCredentials
+	if err := json.Unmarshal([]byte(secretString), &providers); err != nil {
+		return fmt.Errorf("error parsing CRM credentials: %v", err)
+	}
+	crmCredentials = providers
+	crmCredentialsLoadedAt = time.Now()
+	return nil
+}
+
+// buildCRMFields resolves the field mapping against the call's results. Sources without a value
+// (e.g. unanswered questions) are left out so they don't clear existing CRM data.
+func buildCRMFields(mapping map[string]string, callData *CallData, analysis *CallAnalysisData, outcomes []CallOutcome) (map[string]interface{}, error) {
+	callFields := map[string]interface{}{
+		"call_logsId":      callData.ID,
+		"campaignId":       callData.CampaignID,
+		"call_id":          callData.CallID,
+		"campaign_name":    callData.CampaignName,
+		"agent_name":       callData.AgentName,
+		"caller_id_number": callData.CallerIDNumber,
+		"call_to_number":   callData.CallToNumber,
+		"start_date":       callData.StartDate,
+		"start_time":       callData.StartTime,
+		"duration":         callData.Duration,
+	}
+
+	fields := make(map[string]interface{}, len(mapping))
+	for field, source := range mapping {
+		kind, name, _ := strings.Cut(source, ":")
+
+		var value interface{}
+		switch kind {
+		case "answer":
+			if answer, ok := analysis.Answers[name]; ok {
+				value = answer
+			}
+		case "outcome":
+			for _, outcome := range outcomes {
+				if outcome.Field == name {
+					value = outcome.Value
+				}
+			}
+		case "call":
+			v, ok := callFields[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown CRM source %q for field %s", source, field)
+			}
+			value = v
+		case "literal":
+			value = name
+		case "qa_score":
+			if analysis.QAScorecard != nil && analysis.QAScorecard.Error == "" && len(analysis.QAScorecard.Criteria) > 0 {
+				value = analysis.QAScorecard.CompositeScore
+			}
+		case "compliance_passed":
+			if analysis.Compliance != nil && analysis.Compliance.RulesChecked > 0 {
+				value = analysis.Compliance.Passed
+			}
+		case "intent":
+			if analysis.Intent != nil {
+				value = analysis.Intent.Label
+			}
+		case "intent_rationale":
+			if analysis.Intent != nil && analysis.Intent.Label != "" {
+				value = analysis.Intent.Rationale
+			}
+		case "provider":
+			value = analysis.Provider
+		case "processed_at":
+			value = analysis.ProcessedAt
+		case "transcription":
+			value = analysis.Transcription
+		default:
+			return nil, fmt.Errorf("unknown CRM source %q for field %s", source, field)
+		}
+
+		if value != nil && value != "" {
+			fields[field] = value
+		}
+	}
+	return fields, nil
+}
+
+// PushToCRM maps the call's results onto the campaign's CRM object and creates or upserts the record
+func (tp *TranscriptionPipeline) PushToCRM(config *CRMConfig, callData *CallData, analysis *CallAnalysisData, outcomes []CallOutcome) *CRMSyncResult {
+	provider := strings.ToLower(config.Provider)
+	result := &CRMSyncResult{Provider: provider, Object: config.Object}
+
+	fields, err := buildCRMFields(config.FieldMapping, callData, analysis, outcomes)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	creds, err := loadCRMCredentials(provider)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	switch provider {
+	case CRMProviderSalesforce:
+		result.RecordID, result.Attempts, err = pushSalesforceRecord(creds, config, callData.ID, fields)
+	case CRMProviderHubSpot:
+		result.RecordID, result.Attempts, err = pushHubSpotRecord(creds, config, callData.ID, fields)
+	default:
+		err = fmt.Errorf("unknown CRM provider: %s", config.Provider)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// salesforceClientCredentialsToken mints an access token with the OAuth client credentials flow
+func salesforceClientCredentialsToken(creds CRMCredentials) (string, error) {
+	if creds.InstanceURL == "" || creds.ClientID == "" || creds.ClientSecret == "" {
+		return "", fmt.Errorf("salesforce credentials need access_token, or instance_url, client_id and client_secret")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", creds.ClientID)
+	form.Set("client_secret", creds.ClientSecret)
+
+	headers := map[string]string{"Content-Type": "application/x-www-form-urlencoded"}
+	respBody, _, err := sendCRMRequest("POST", strings.TrimSuffix(creds.InstanceURL, "/")+"/services/oauth2/token", headers, []byte(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("error fetching Salesforce token: %v", err)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(respBody, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("error decoding Salesforce token response: %s", string(respBody))
+	}
+	return token.AccessToken, nil
+}
+
+// pushSalesforceRecord upserts the record by external ID, or creates it when no external ID field is configured
+func pushSalesforceRecord(creds *CRMCredentials, config *CRMConfig, callLogsID string, fields map[string]interface{}) (string, int, error) {
+	if creds.InstanceURL == "" {
+		return "", 0, fmt.Errorf("salesforce credentials are missing instance_url")
+	}
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return "", 0, fmt.Errorf("error marshaling Salesforce record: %v", err)
+	}
+
+	base := fmt.Sprintf("%s/services/data/%s/sobjects/%s", strings.TrimSuffix(creds.InstanceURL, "/"),
+		salesforceAPIVersion, url.PathEscape(config.Object))
+	method, endpoint := "POST", base+"/"
+	if config.ExternalIDField != "" {
+		method = "PATCH"
+		endpoint = fmt.Sprintf("%s/%s/%s", base, url.PathEscape(config.ExternalIDField), url.PathEscape(callLogsID))
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + creds.AccessToken,
+		"Content-Type":  "application/json",
+	}
+	respBody, attempts, err := sendCRMRequest(method, endpoint, headers, body)
+	if err != nil {
+		return "", attempts, err
+	}
+
+	// Updates by external ID may return no body
+	var created struct {
+		ID string `json:"id"`
+	}
+	if len(respBody) > 0 {
+		if err := json.Unmarshal(respBody, &created); err != nil {
+			return "", attempts, fmt.Errorf("error decoding Salesforce response: %v", err)
+		}
+	}
+	return created.ID, attempts, nil
+}
+
+// pushHubSpotRecord upserts the record by the external ID property, or creates it when none is configured
+func pushHubSpotRecord(creds *CRMCredentials, config *CRMConfig, callLogsID string, fields map[string]interface{}) (string, int, error) {
+	// HubSpot properties are strings
+	properties := make(map[string]string, len(fields))
+	for name, value := range fields {
+		properties[name] = fmt.Sprint(value)
+	}
+
+	objectURL := fmt.Sprintf("%s/crm/v3/objects/%s", hubSpotAPIBaseURL, url.PathEscape(config.Object))
+	var payload interface{} = map[string]interface{}{"properties": properties}
+	endpoint := objectURL
+	if config.ExternalIDField != "" {
+		endpoint = objectURL + "/batch/upsert"
+		payload = map[string]interface{}{
+			"inputs": []map[string]interface{}{{
+				"idProperty": config.ExternalIDField,
+				"id":         callLogsID,
+				"properties": properties,
+			}},
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return "", 0, fmt.Errorf("error marshaling HubSpot record: %v", err)
+	}
+
+	headers := map[string]string{
+		"Authorization": "Bearer " + creds.AccessToken,
+		"Content-Type":  "application/json",
+	}
+	respBody, attempts, err := sendCRMRequest("POST", endpoint, headers, body)
+	if err != nil {
+		return "", attempts, err
+	}
+
+	var resp struct {
+		ID      string `json:"id"`
+		Results []struct {
+			ID string `json:"id"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return "", attempts, fmt.Errorf("error decoding HubSpot response: %v", err)
+	}
+	if len(resp.Results) > 0 {
+		return resp.Results[0].ID, attempts, nil
+	}
+	return resp.ID, attempts, nil
+}
+
+// sendCRMRequest sends a CRM API request, retrying rate-limited, server and network errors with
+// exponential backoff. It returns the response body and the number of attempts made.
+func sendCRMRequest(method, endpoint string, headers map[string]string, body []byte) ([]byte, int, error) {
+	client := apiClient()
+	backoff := time.Second
+
+	var lastErr error
+	for attempt := 1; attempt <= crmMaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest(method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, attempt, fmt.Errorf("error creating CRM request: %v", err)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("error making CRM request: %v", err)
+			continue
+		}
+		respBody, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("error reading CRM response: %v", err)
+			continue
+		}
+
+		if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+			return respBody, attempt, nil
+		}
+		lastErr = fmt.Errorf("CRM API error: status %d, body: %s", resp.StatusCode, string(respBody))
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return nil, attempt, lastErr
+		}
+	}
+
+	return nil, crmMaxAttempts, lastErr
+}
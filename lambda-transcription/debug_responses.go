@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDebugResponsesPrefix  = "debug-responses"
+	defaultDebugResponsesTTLDays = 7
+)
+
+// debugResponsesStorage is where raw responses are archived: DEBUG_RESPONSES_S3_BUCKET, falling
+// back to ARTIFACTS_S3_BUCKET, under DEBUG_RESPONSES_S3_PREFIX (default "debug-responses")
+func debugResponsesStorage() (bucket, prefix string) {
+	bucket = os.Getenv("DEBUG_RESPONSES_S3_BUCKET")
+	if bucket == "" {
+		bucket = os.Getenv("ARTIFACTS_S3_BUCKET")
+	}
+	prefix = strings.Trim(os.Getenv("DEBUG_RESPONSES_S3_PREFIX"), "/")
+	if prefix == "" {
+		prefix = defaultDebugResponsesPrefix
+	}
+	return bucket, prefix
+}
+
+// debugResponsesTTLDays is how long archived responses are kept (DEBUG_RESPONSES_TTL_DAYS). S3
+// doesn't expire objects by itself; they are tagged so a lifecycle rule can.
+func debugResponsesTTLDays() int {
+	return envLimit("DEBUG_RESPONSES_TTL_DAYS", defaultDebugResponsesTTLDays)
+}
+
+// archiveProviderResponse uploads a raw Gemini response, including error responses, when the
+// campaign has debugResponses set, and references it from the processing metadata. It is uploaded
+// straight away so calls that go on to fail leave it behind too; the key is logged for those, whose
+// metadata isn't saved. Failures are logged and don't affect the call.
+func (tp *TranscriptionPipeline) archiveProviderResponse(name, model string, statusCode int, body []byte) {
+	if !tp.debugResponses {
+		return
+	}
+	bucket, prefix := debugResponsesStorage()
+	if bucket == "" {
+		log.Printf("Not archiving the %s response: debugResponses is set but no DEBUG_RESPONSES_S3_BUCKET or ARTIFACTS_S3_BUCKET is configured", name)
+		return
+	}
+
+	call := tp.errorContext.CallLogsID
+	if call == "" {
+		call = tp.errorContext.MessageID
+	}
+	if call == "" {
+		call = "inline"
+	}
+	campaign := tp.errorContext.CampaignID
+	if campaign == "" {
+		campaign = "none"
+	}
+
+	now := time.Now().UTC()
+	ttlDays := debugResponsesTTLDays()
+	key := fmt.Sprintf("%s/campaign=%s/date=%s/call=%s/%s-%02d-%s-%s-%d.json",
+		prefix, campaign, now.Format("2006-01-02"), call, now.Format("20060102T150405Z"),
+		len(tp.metadata.ProviderResponses)+1, name, strings.ReplaceAll(strings.TrimPrefix(model, "models/"), "/", "_"), statusCode)
+
+	// Non-JSON error bodies are kept as they are
+	contentType := "application/json"
+	if !json.Valid(body) {
+		contentType = "text/plain; charset=utf-8"
+	}
+	headers := map[string]string{
+		"Content-Type":          contentType,
+		"X-Amz-Tagging":         fmt.Sprintf("ttl-days=%d", ttlDays),
+		"X-Amz-Meta-Expires-At": now.AddDate(0, 0, ttlDays).Format(time.RFC3339),
+	}
+	if err := s3PutObjectWithHeaders(bucket, key, headers, body); err != nil {
+		log.Printf("Error archiving the %s response: %v", name, err)
+		return
+	}
+
+	log.Printf("Archived the %s response (status %d) to s3://%s/%s", name, statusCode, bucket, key)
+	tp.metadata.ProviderResponses = append(tp.metadata.ProviderResponses, ArtifactRef{Type: ArtifactGeminiResponse, Bucket: bucket, Key: key})
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+const (
+	deepgramListenURL    = "https://api.deepgram.com/v1/listen"
+	defaultDeepgramModel = "nova-2"
+)
+
+// DeepgramTranscriber transcribes audio with Deepgram, returning word-level timestamps and speaker labels
+type DeepgramTranscriber struct {
+	apiKey   string
+	model    string
+	language string
+}
+
+// deepgramResponse represents the subset of the /v1/listen response we use
+type deepgramResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
+	Results struct {
+		Channels []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
+				Transcript string         `json:"transcript"`
+				Words      []deepgramWord `json:"words"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+		Utterances []struct {
+			Start      float64 `json:"start"`
+			End        float64 `json:"end"`
+			Speaker    int     `json:"speaker"`
+			Transcript string  `json:"transcript"`
+		} `json:"utterances"`
+	} `json:"results"`
+}
+
+type deepgramWord struct {
+	Word           string  `json:"word"`
+	PunctuatedWord string  `json:"punctuated_word"`
+	Start          float64 `json:"start"`
+	End            float64 `json:"end"`
+	Confidence     float64 `json:"confidence"`
+	Speaker        int     `json:"speaker"`
+}
+
+// NewDeepgramTranscriber creates a Deepgram backend. DEEPGRAM_MODEL and DEEPGRAM_LANGUAGE
+// (e.g. "hi") optionally override the defaults; without a language Deepgram detects it.
+func NewDeepgramTranscriber(apiKey string) (*DeepgramTranscriber, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("DEEPGRAM_API_KEY is required for the deepgram transcription provider")
+	}
+
+	model := os.Getenv("DEEPGRAM_MODEL")
+	if model == "" {
+		model = defaultDeepgramModel
+	}
+
+	return &DeepgramTranscriber{
+		apiKey:   apiKey,
+		model:    model,
+		language: os.Getenv("DEEPGRAM_LANGUAGE"),
+	}, nil
+}
+
+// Name returns the provider name
+func (d *DeepgramTranscriber) Name() string {
+	return ProviderDeepgram
+}
+
+// Transcribe sends the raw audio to Deepgram with diarization enabled and normalizes the result
+func (d *DeepgramTranscriber) Transcribe(audioContent []byte) (*Transcript, error) {
+	params := url.Values{}
+	params.Set("model", d.model)
+	params.Set("diarize", "true")
+	params.Set("utterances", "true")
+	params.Set("punctuate", "true")
+	params.Set("smart_format", "true")
+	if d.language != "" {
+		params.Set("language", d.language)
+	} else {
+		params.Set("detect_language", "true")
+	}
+
+	req, err := http.NewRequest("POST", deepgramListenURL+"?"+params.Encode(), bytes.NewReader(audioContent))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", audioMimeType(audioContent))
+	req.Header.Set("Authorization", "Token "+d.apiKey)
+
+	resp, err := providerClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, providerAPIError("deepgram", resp.StatusCode, body)
+	}
+
+	var dgResp deepgramResponse
+	if err := json.NewDecoder(resp.Body).Decode(&dgResp); err != nil {
+		return nil, fmt.Errorf("%w: error decoding Deepgram response: %v", ErrParseFailure, err)
+	}
+
+	if len(dgResp.Results.Channels) == 0 || len(dgResp.Results.Channels[0].Alternatives) == 0 {
+		return nil, fmt.Errorf("no transcription returned by Deepgram")
+	}
+
+	return d.toTranscript(dgResp), nil
+}
+
+// toTranscript maps Deepgram words and utterances onto the shared Transcript.
+// Deepgram numbers speakers in order of appearance; the first speaker is treated as the agent
+// (who opens outbound calls) and every other speaker as the customer.
+func (d *DeepgramTranscriber) toTranscript(dgResp deepgramResponse) *Transcript {
+	channel := dgResp.Results.Channels[0]
+	alternative := channel.Alternatives[0]
+
+	firstSpeaker := -1
+	if len(alternative.Words) > 0 {
+		firstSpeaker = alternative.Words[0].Speaker
+	}
+	speakerLabel := func(speaker int) string {
+		if speaker == firstSpeaker {
+			return SpeakerAgent
+		}
+		return SpeakerCustomer
+	}
+
+	words := make([]TranscriptWord, 0, len(alternative.Words))
+	for _, w := range alternative.Words {
+		text := w.PunctuatedWord
+		if text == "" {
+			text = w.Word
+		}
+		words = append(words, TranscriptWord{
+			Word:       text,
+			Start:      w.Start,
+			End:        w.End,
+			Speaker:    speakerLabel(w.Speaker),
+			Confidence: w.Confidence,
+		})
+	}
+
+	segments := make([]TranscriptSegment, 0, len(dgResp.Results.Utterances))
+	for _, u := range dgResp.Results.Utterances {
+		segments = append(segments, TranscriptSegment{
+			Speaker: speakerLabel(u.Speaker),
+			Start:   u.Start,
+			End:     u.End,
+			Text:    strings.TrimSpace(u.Transcript),
+		})
+	}
+
+	text := strings.TrimSpace(alternative.Transcript)
+	if len(segments) > 0 {
+		text = formatTranscriptSegments(segments)
+	}
+
+	return &Transcript{
+		Text:     text,
+		Language: channel.DetectedLanguage,
+		Duration: dgResp.Metadata.Duration,
+		Segments: segments,
+		Words:    words,
+		Provider: ProviderDeepgram,
+		Model:    d.model,
+	}
+}
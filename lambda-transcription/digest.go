@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	_ "time/tzdata" // DIGEST_TIMEZONE must resolve on the Lambda runtime, which ships no zoneinfo
+)
+
+//go:embed templates/digest_email.html templates/digest_slack.txt
+var digestTemplatesFS embed.FS
+
+// Digest defaults
+const (
+	defaultDigestLowScoreThreshold = 50.0
+	defaultDigestMaxLowScoreCalls  = 5
+)
+
+// Processing run statuses
+const (
+	ProcessingRunSucceeded = "succeeded"
+	ProcessingRunFailed    = "failed"
+	ProcessingRunSkipped   = "skipped"
+)
+
+// Digest summarizes a day of call processing per campaign
+type Digest struct {
+	Date      string           `json:"date"`
+	Timezone  string           `json:"timezone"`
+	Processed int              `json:"processed"`
+	Failed    int              `json:"failed"`
+	Skipped   int              `json:"skipped"`
+	Campaigns []CampaignDigest `json:"campaigns"`
+}
+
+// CampaignDigest summarizes one campaign's calls for the digest
+type CampaignDigest struct {
+	CampaignID        string         `json:"campaignId"`
+	CampaignName      string         `json:"campaignName"`
+	Processed         int            `json:"processed"`
+	Failed            int            `json:"failed"`
+	Skipped           int            `json:"skipped"`
+	FailureCategories map[string]int `json:"failureCategories,omitempty"`
+	ScoredCalls       int            `json:"scoredCalls"`
+	AverageScore      float64        `json:"averageScore,omitempty"`
+	LowScoreCalls     []LowScoreCall `json:"lowScoreCalls,omitempty"`
+}
+
+// LowScoreCall is a call whose QA composite score fell below the digest threshold
+type LowScoreCall struct {
+	CallLogsID string  `json:"call_logsId"`
+	AgentName  string  `json:"agentName,omitempty"`
+	Score      float64 `json:"score"`
+}
+
+// RecordProcessingRun stores the outcome of a processing attempt in call_processing_runs; analysis is
+// the saved analysis, if any. Recording is best-effort: a failure is logged and doesn't affect the call.
+func (tp *TranscriptionPipeline) RecordProcessingRun(callLogsID string, analysis *CallAnalysisData, processErr error) {
+	status, category, message, skipReason := ProcessingRunSucceeded, "", "", ""
+	if processErr != nil {
+		status, category, message = ProcessingRunFailed, errorCategory(processErr), processErr.Error()
+	} else if analysis != nil && analysis.SkipReason != "" {
+		status, skipReason = ProcessingRunSkipped, analysis.SkipReason
+	}
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", status, "errorCategory", error, "skipReason")
+		VALUES ($1, (SELECT %s FROM %s WHERE %s = $1), $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))
+	`, tp.schema.Table("call_processing_runs"), c("campaignId"), tp.schema.Table("call_logs"), c("id"))
+
+	if _, err := tp.repo.Exec(query, callLogsID, status, category, message, skipReason); err != nil {
+		log.Printf("Error recording processing run for %s: %v", callLogsID, err)
+	}
+}
+
+// digestLocation returns the timezone whose calendar days the digest covers (DIGEST_TIMEZONE, default UTC)
+func digestLocation() (*time.Location, error) {
+	name := os.Getenv("DIGEST_TIMEZONE")
+	if name == "" {
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DIGEST_TIMEZONE: %v", err)
+	}
+	return location, nil
+}
+
+// digestLowScoreThreshold reads the QA score below which calls are listed (DIGEST_LOW_SCORE_THRESHOLD)
+func digestLowScoreThreshold() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("DIGEST_LOW_SCORE_THRESHOLD"), 64); err == nil {
+		return v
+	}
+	return defaultDigestLowScoreThreshold
+}
+
+// digestMaxLowScoreCalls reads how many low-scoring calls are listed per campaign (DIGEST_MAX_LOW_SCORE_CALLS)
+func digestMaxLowScoreCalls() int {
+	if v, err := strconv.Atoi(os.Getenv("DIGEST_MAX_LOW_SCORE_CALLS")); err == nil && v >= 0 {
+		return v
+	}
+	return defaultDigestMaxLowScoreCalls
+}
+
+// BuildDigest summarizes the calls processed on the given day (YYYY-MM-DD in DIGEST_TIMEZONE, default yesterday).
+// A call processed several times counts once, with the outcome of its latest attempt.
+func (tp *TranscriptionPipeline) BuildDigest(date string) (*Digest, error) {
+	location, err := digestLocation()
+	if err != nil {
+		return nil, err
+	}
+
+	var start time.Time
+	if date == "" {
+		now := time.Now().In(location)
+		start = time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, location)
+	} else {
+		start, err = time.ParseInLocation("2006-01-02", date, location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest date: %v", err)
+		}
+	}
+	end := start.AddDate(0, 0, 1)
+
+	digest := &Digest{Date: start.Format("2006-01-02"), Timezone: location.String()}
+	campaigns := make(map[string]*CampaignDigest)
+	campaign := func(id string) *CampaignDigest {
+		if campaigns[id] == nil {
+			campaigns[id] = &CampaignDigest{CampaignID: id}
+		}
+		return campaigns[id]
+	}
+
+	c := func(name string) string { return "cl." + tp.schema.Column("call_logs", name) }
+	latestRuns := fmt.Sprintf(`
+		WITH latest AS (
+			SELECT DISTINCT ON ("call_logsId") "call_logsId", COALESCE("campaignId"::text, '') AS "campaignId",
+			       status, COALESCE("errorCategory", '') AS "errorCategory"
+			FROM %s
+			WHERE "createdAt" >= $1 AND "createdAt" < $2
+			ORDER BY "call_logsId", "createdAt" DESC
+		)
+	`, tp.schema.Table("call_processing_runs"))
+
+	// Outcome counts per campaign and failure cause
+	countsQuery := latestRuns + fmt.Sprintf(`
+		SELECT l."campaignId", COALESCE(MAX(%s), ''), l.status, l."errorCategory", count(*)
+		FROM latest l
+		LEFT JOIN %s cl ON %s = l."call_logsId"
+		GROUP BY l."campaignId", l.status, l."errorCategory"
+	`, c("campaign_name"), tp.schema.Table("call_logs"), c("id"))
+
+	rows, err := tp.repo.Query(countsQuery, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error querying processing runs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var campaignID, campaignName, status, category string
+		var count int
+		if err := rows.Scan(&campaignID, &campaignName, &status, &category, &count); err != nil {
+			return nil, fmt.Errorf("error scanning processing runs: %v", err)
+		}
+
+		cd := campaign(campaignID)
+		if campaignName != "" {
+			cd.CampaignName = campaignName
+		}
+		if status == ProcessingRunFailed {
+			if category == "" {
+				category = "other"
+			}
+			if cd.FailureCategories == nil {
+				cd.FailureCategories = make(map[string]int)
+			}
+			cd.FailureCategories[category] += count
+			cd.Failed += count
+			digest.Failed += count
+		} else if status == ProcessingRunSkipped {
+			cd.Skipped += count
+			digest.Skipped += count
+		} else {
+			cd.Processed += count
+			digest.Processed += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading processing runs: %v", err)
+	}
+
+	// QA scores of the successfully processed calls, lowest first
+	scoresQuery := latestRuns + fmt.Sprintf(`
+		SELECT l."campaignId", l."call_logsId", COALESCE(%s, ''),
+		       (%s->'qa_scorecard'->>'composite_score')::float8 AS score
+		FROM latest l
+		JOIN %s cl ON %s = l."call_logsId"
+		WHERE l.status = '%s'
+		  AND %s->'qa_scorecard'->>'composite_score' IS NOT NULL
+		  AND COALESCE(%s->'qa_scorecard'->>'error', '') = ''
+		ORDER BY score
+	`, c("agent_name"), c("callAnalysis"), tp.schema.Table("call_logs"), c("id"),
+		ProcessingRunSucceeded, c("callAnalysis"), c("callAnalysis"))
+
+	scoreRows, err := tp.repo.Query(scoresQuery, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("error querying QA scores: %v", err)
+	}
+	defer scoreRows.Close()
+
+	threshold, maxLowScoreCalls := digestLowScoreThreshold(), digestMaxLowScoreCalls()
+	for scoreRows.Next() {
+		var campaignID string
+		var call LowScoreCall
+		if err := scoreRows.Scan(&campaignID, &call.CallLogsID, &call.AgentName, &call.Score); err != nil {
+			return nil, fmt.Errorf("error scanning QA scores: %v", err)
+		}
+
+		cd := campaign(campaignID)
+		cd.AverageScore += call.Score
+		cd.ScoredCalls++
+		if call.Score < threshold && len(cd.LowScoreCalls) < maxLowScoreCalls {
+			cd.LowScoreCalls = append(cd.LowScoreCalls, call)
+		}
+	}
+	if err := scoreRows.Err(); err != nil {
+		return nil, fmt.Errorf("error reading QA scores: %v", err)
+	}
+
+	for _, cd := range campaigns {
+		if cd.ScoredCalls > 0 {
+			cd.AverageScore = roundTo(cd.AverageScore/float64(cd.ScoredCalls), 1)
+		}
+		if cd.CampaignName == "" {
+			cd.CampaignName = cd.CampaignID
+		}
+		if cd.CampaignName == "" {
+			cd.CampaignName = "Unknown campaign"
+		}
+		digest.Campaigns = append(digest.Campaigns, *cd)
+	}
+	sort.Slice(digest.Campaigns, func(i, j int) bool {
+		return digest.Campaigns[i].CampaignName < digest.Campaigns[j].CampaignName
+	})
+
+	return digest, nil
+}
+
+// renderDigestEmail renders the digest's email subject and HTML body
+func renderDigestEmail(digest *Digest) (string, string, error) {
+	tmpl, err := htmltemplate.ParseFS(digestTemplatesFS, "templates/digest_email.html")
+	if err != nil {
+		return "", "", fmt.Errorf("error parsing email template: %v", err)
+	}
+
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, digest); err != nil {
+		return "", "", fmt.Errorf("error rendering email template: %v", err)
+	}
+
+	subject := fmt.Sprintf("Call processing digest for %s: %d processed, %d failed", digest.Date, digest.Processed, digest.Failed)
+	return subject, body.String(), nil
+}
+
+// renderDigestSlack renders the digest as Slack mrkdwn
+func renderDigestSlack(digest *Digest) (string, error) {
+	// Slack treats &, < and > as control characters in message text
+	escape := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	tmpl, err := texttemplate.New("digest_slack.txt").
+		Funcs(texttemplate.FuncMap{"slack": escape.Replace}).
+		ParseFS(digestTemplatesFS, "templates/digest_slack.txt")
+	if err != nil {
+		return "", fmt.Errorf("error parsing Slack template: %v", err)
+	}
+
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, digest); err != nil {
+		return "", fmt.Errorf("error rendering Slack template: %v", err)
+	}
+	return text.String(), nil
+}
+
+// postSlackMessage posts a message to a Slack incoming webhook
+func postSlackMessage(webhookURL, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return fmt.Errorf("error marshaling Slack message: %v", err)
+	}
+
+	resp, err := apiClient().Post(webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error posting to Slack: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("slack webhook error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// DeliverDigest sends the digest by SES email (DIGEST_EMAIL_FROM and comma-separated DIGEST_EMAIL_TO) and/or
+// Slack (DIGEST_SLACK_WEBHOOK_URL), returning the channels it was delivered to
+func DeliverDigest(digest *Digest) ([]string, error) {
+	var delivered []string
+
+	from, to := os.Getenv("DIGEST_EMAIL_FROM"), os.Getenv("DIGEST_EMAIL_TO")
+	if from != "" && to != "" {
+		subject, html, err := renderDigestEmail(digest)
+		if err != nil {
+			return delivered, err
+		}
+		var recipients []string
+		for _, address := range strings.Split(to, ",") {
+			if address = strings.TrimSpace(address); address != "" {
+				recipients = append(recipients, address)
+			}
+		}
+		if err := sesSendEmail(from, recipients, subject, html); err != nil {
+			return delivered, fmt.Errorf("error emailing digest: %v", err)
+		}
+		delivered = append(delivered, "email")
+	}
+
+	if webhookURL := os.Getenv("DIGEST_SLACK_WEBHOOK_URL"); webhookURL != "" {
+		text, err := renderDigestSlack(digest)
+		if err != nil {
+			return delivered, err
+		}
+		if err := postSlackMessage(webhookURL, text); err != nil {
+			return delivered, err
+		}
+		delivered = append(delivered, "slack")
+	}
+
+	if len(delivered) == 0 {
+		return nil, fmt.Errorf("no digest channel configured (set DIGEST_EMAIL_FROM and DIGEST_EMAIL_TO, or DIGEST_SLACK_WEBHOOK_URL)")
+	}
+	return delivered, nil
+}
+
+// HandleDigest builds and delivers the daily digest for the given day (default yesterday)
+func (tp *TranscriptionPipeline) HandleDigest(date string) LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	digest, err := tp.BuildDigest(date)
+	if err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+
+	delivered, err := DeliverDigest(digest)
+	body := map[string]interface{}{"digest": digest, "delivered": delivered}
+	if err != nil {
+		return LambdaResponse{StatusCode: 500, Body: body, Error: err.Error()}
+	}
+
+	return LambdaResponse{StatusCode: 200, Body: body}
+}
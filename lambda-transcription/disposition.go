@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"time"
+)
+
+// Call dispositions. Only conversations are transcribed and have their questions answered.
+const (
+	DispositionConversation = "conversation"
+	DispositionVoicemail    = "voicemail"
+	DispositionIVROnly      = "ivr_only"
+	DispositionNoAnswer     = "no_answer"
+	DispositionDeadAir      = "dead_air"
+)
+
+const (
+	// defaultDispositionModel is the cheap model that classifies recordings before transcription
+	defaultDispositionModel = "gemini-2.5-flash"
+	// minSpeech is the least detected speech a WAV recording needs to be sent for classification
+	minSpeech = time.Second
+)
+
+const dispositionPrompt = `Classify this phone call recording. Respond with JSON only: {"disposition": "...", "reason": "..."}
+
+disposition must be one of:
+- "conversation": a live person spoke with the caller, however briefly
+- "voicemail": the call reached an answering machine or voicemail greeting (with or without a message left)
+- "ivr_only": only automated menus, hold music or recorded announcements, with no live person
+- "no_answer": only ringing, busy or network tones, or a carrier message such as "the number you are calling is not reachable"
+- "dead_air": silence or noise with no speech
+
+reason is a short explanation of the classification.`
+
+// dispositionModel reads the Gemini model used for classification (DISPOSITION_MODEL)
+func dispositionModel() string {
+	if model := os.Getenv("DISPOSITION_MODEL"); model != "" {
+		return model
+	}
+	return defaultDispositionModel
+}
+
+// classifyCallDisposition classifies the recording before transcription. WAV recordings without
+// speech are dead air without asking the model; everything else is classified by a cheap Gemini model.
+func (tp *TranscriptionPipeline) classifyCallDisposition(audioContent []byte) (string, string, error) {
+	if wav, ok := decodeWAV(audioContent); ok {
+		if speech := speechDuration(wav.mono(), wav.sampleRate, silenceThresholdDB()); speech < minSpeech {
+			return DispositionDeadAir, fmt.Sprintf("%.1fs of speech detected", speech.Seconds()), nil
+		}
+	}
+
+	requestData := GeminiRequest{
+		Contents: []Content{
+			{
+				Parts: []Part{
+					{
+						Text: dispositionPrompt,
+					},
+					{
+						InlineData: audioInlineData(audioContent),
+					},
+				},
+			},
+		},
+		GenerationConfig: &GenerationConfig{ResponseMimeType: "application/json"},
+	}
+
+	tp.modelOverride = dispositionModel()
+	responseText, err := tp.generateContent("classify_disposition", requestData, 30*time.Second)
+	tp.modelOverride = ""
+	if err != nil {
+		return "", "", err
+	}
+
+	var classification struct {
+		Disposition string `json:"disposition"`
+		Reason      string `json:"reason"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &classification); err != nil {
+		return "", "", fmt.Errorf("%w: error parsing disposition: %v", ErrParseFailure, err)
+	}
+
+	switch classification.Disposition {
+	case DispositionConversation, DispositionVoicemail, DispositionIVROnly, DispositionNoAnswer, DispositionDeadAir:
+		return classification.Disposition, classification.Reason, nil
+	}
+	return "", "", fmt.Errorf("unknown disposition: %q", classification.Disposition)
+}
+
+// detectDisposition classifies the recording when disposition detection is enabled. Classification
+// is best-effort: a failure is logged and the call is processed as a conversation.
+func (tp *TranscriptionPipeline) detectDisposition(audioContent []byte) (string, string) {
+	if !tp.dispositionDetection {
+		return "", ""
+	}
+
+	disposition, reason, err := tp.classifyCallDisposition(audioContent)
+	if err != nil {
+		log.Printf("Error classifying call disposition, processing as a conversation: %v", err)
+		return "", ""
+	}
+	return disposition, reason
+}
+
+// speechDuration measures how much of the audio is above the silence threshold, in 20ms frames
+func speechDuration(samples []float64, sampleRate int, thresholdDB float64) time.Duration {
+	frameSize := sampleRate / 50
+	if frameSize == 0 {
+		return 0
+	}
+
+	speechFrames := 0
+	for start := 0; start < len(samples); start += frameSize {
+		frame := samples[start:min(start+frameSize, len(samples))]
+		var sumSquares float64
+		for _, s := range frame {
+			sumSquares += s * s
+		}
+		if rms := math.Sqrt(sumSquares / float64(len(frame))); rms > 0 && 20*math.Log10(rms) >= thresholdDB {
+			speechFrames++
+		}
+	}
+	return time.Duration(speechFrames) * 20 * time.Millisecond
+}
+
+// completeWithDisposition saves the analysis of a call that isn't a conversation: its disposition,
+// without transcription, answers, compliance or QA scoring. The analysis is nil on a dry run.
+func (tp *TranscriptionPipeline) completeWithDisposition(callData *CallData, transcriptionResult *TranscriptionResult, variantName string) (map[string]interface{}, *CallAnalysisData, error) {
+	usage := tp.usage
+	analysisData := CallAnalysisData{
+		Answers:            map[string]string{},
+		CallDisposition:    transcriptionResult.Disposition,
+		DispositionReason:  transcriptionResult.DispositionReason,
+		AudioQuality:       transcriptionResult.AudioQuality,
+		Provider:           transcriptionResult.Provider,
+		PromptVariant:      variantName,
+		Usage:              &usage,
+		ProcessingMetadata: tp.processingMetadata(),
+		ProcessedAt:        time.Now().Format(time.RFC3339),
+	}
+
+	result := map[string]interface{}{
+		"call_logsId":         callData.ID,
+		"campaignId":          callData.CampaignID,
+		"call_disposition":    analysisData.CallDisposition,
+		"disposition_reason":  analysisData.DispositionReason,
+		"answers":             analysisData.Answers,
+		"provider":            analysisData.Provider,
+		"processing_metadata": analysisData.ProcessingMetadata,
+		"processed_at":        analysisData.ProcessedAt,
+	}
+
+	if tp.dryRun {
+		result["dry_run"] = true
+		result["analysis"] = analysisData
+		return result, nil, nil
+	}
+
+	tp.reportStage(StageSaving)
+	saveStart := time.Now()
+	if err := tp.SaveCallAnalysis(callData, analysisData); err != nil {
+		return nil, nil, fmt.Errorf("failed to save call analysis: %w", err)
+	}
+	tp.StreamAnalysis(callData, analysisData)
+
+	if tp.artifactsBucket != "" {
+		tp.archiveCall(callData, analysisData)
+	}
+	tp.RecordSaveDuration(callData.ID, saveStart)
+
+	return result, &analysisData, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordingAuthTTL is how long recording credentials are cached across warm invocations
+const recordingAuthTTL = 5 * time.Minute
+
+// Recording authentication types
+const (
+	RecordingAuthBasic  = "basic"
+	RecordingAuthBearer = "bearer"
+)
+
+// RecordingAuth represents the credentials of one recording provider, applied to downloads from its hosts
+type RecordingAuth struct {
+	// Hosts are the recording hostnames this entry applies to; "*.example.com" matches any subdomain
+	Hosts    []string          `json:"hosts"`
+	Type     string            `json:"type,omitempty"`
+	Username string            `json:"username,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Token    string            `json:"token,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// RefreshURL is the provider endpoint that mints a fresh link for an expired recording URL
+	RefreshURL string `json:"refresh_url,omitempty"`
+}
+
+var (
+	recordingAuthMu       sync.Mutex
+	recordingAuth         map[string]RecordingAuth
+	recordingAuthLoadedAt time.Time
+)
+
+// loadRecordingAuth returns the per-provider recording credentials from the Secrets Manager secret named by
+// RECORDING_AUTH_SECRET_ID (a JSON object keyed by provider name), refreshing them after recordingAuthTTL.
+// Without RECORDING_AUTH_SECRET_ID recordings are downloaded anonymously.
+func loadRecordingAuth() (map[string]RecordingAuth, error) {
+	secretID := os.Getenv("RECORDING_AUTH_SECRET_ID")
+	if secretID == "" {
+		return nil, nil
+	}
+
+	recordingAuthMu.Lock()
+	defer recordingAuthMu.Unlock()
+
+	if recordingAuth != nil && time.Since(recordingAuthLoadedAt) < recordingAuthTTL {
+		return recordingAuth, nil
+	}
+
+	secretString, err := getSecretString(secretID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading recording credentials: %v", err)
+	}
+
+	var providers map[string]RecordingAuth
+	if err := json.Unmarshal([]byte(secretString), &providers); err != nil {
+		return nil, fmt.Errorf("error parsing recording credentials: %v", err)
+	}
+
+	recordingAuth = providers
+	recordingAuthLoadedAt = time.Now()
+	return recordingAuth, nil
+}
+
+// recordingAuthForURL returns the provider name and credentials that apply to a recording URL, if any
+func recordingAuthForURL(providers map[string]RecordingAuth, recordingURL string) (string, *RecordingAuth) {
+	u, err := url.Parse(recordingURL)
+	if err != nil {
+		return "", nil
+	}
+	host := strings.ToLower(u.Hostname())
+
+	for name, auth := range providers {
+		for _, pattern := range auth.Hosts {
+			pattern = strings.ToLower(pattern)
+			if host == pattern || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+				auth := auth
+				return name, &auth
+			}
+		}
+	}
+	return "", nil
+}
+
+// apply adds the provider's credentials to a download request
+func (a *RecordingAuth) apply(req *http.Request) error {
+	switch strings.ToLower(a.Type) {
+	case "":
+	case RecordingAuthBasic:
+		req.SetBasicAuth(a.Username, a.Password)
+	case RecordingAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	default:
+		return fmt.Errorf("unknown recording auth type: %s", a.Type)
+	}
+
+	for name, value := range a.Headers {
+		req.Header.Set(name, value)
+	}
+	return nil
+}
+
+// recordingRefreshEndpoint returns the link refresh hook for a provider, falling back to
+// RECORDING_URL_REFRESH_ENDPOINT; "" means expired links can't be refreshed
+func recordingRefreshEndpoint(auth *RecordingAuth) string {
+	if auth != nil && auth.RefreshURL != "" {
+		return auth.RefreshURL
+	}
+	return os.Getenv("RECORDING_URL_REFRESH_ENDPOINT")
+}
+
+// recordingLinkExpired reports whether a download status code is what providers return for expired presigned links
+func recordingLinkExpired(statusCode int) bool {
+	return statusCode == http.StatusForbidden || statusCode == http.StatusGone
+}
+
+// refreshRecordingURL asks the provider's refresh hook for a fresh link to a recording.
+// The hook is POSTed {"recording_url": "..."} with the provider's credentials and must respond with {"url": "..."}.
+func refreshRecordingURL(endpoint, recordingURL string, auth *RecordingAuth) (string, error) {
+	body, err := json.Marshal(map[string]string{"recording_url": recordingURL})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling refresh request: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("error creating refresh request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if auth != nil {
+		if err := auth.apply(req); err != nil {
+			return "", err
+		}
+	}
+
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error calling refresh endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading refresh response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("refresh endpoint error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var refreshed struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(respBody, &refreshed); err != nil {
+		return "", fmt.Errorf("error decoding refresh response: %v", err)
+	}
+	if refreshed.URL == "" {
+		return "", fmt.Errorf("refresh endpoint returned no url")
+	}
+
+	return refreshed.URL, nil
+}
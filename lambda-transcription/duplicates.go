@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Rules by which two call_logs rows are the same call
+const (
+	// DuplicateRuleCallID matches rows with the same provider call_id
+	DuplicateRuleCallID = "call_id"
+	// DuplicateRuleRecordingURL matches rows whose recording URLs are the same without their query
+	// string and fragment, which carry the expiring signatures of presigned URLs
+	DuplicateRuleRecordingURL = "recording_url"
+)
+
+// DuplicateLink is stored in the analysis of a call_logs row that duplicates another row's call,
+// whose analysis it carries instead of being processed again
+type DuplicateLink struct {
+	// CallLogsID is the row that was processed
+	CallLogsID string `json:"call_logsId"`
+	// Rule is the rule the rows matched by, and Key their shared call_id or canonical recording URL
+	Rule string `json:"rule"`
+	Key  string `json:"key"`
+}
+
+// duplicateDetectionEnabled reports whether calls are checked for duplicates before being processed;
+// set DUPLICATE_CALL_DETECTION=false to process every row
+func duplicateDetectionEnabled() bool {
+	return os.Getenv("DUPLICATE_CALL_DETECTION") != "false"
+}
+
+// canonicalRecordingURL is the recording URL without its query string and fragment
+func canonicalRecordingURL(recordingURL string) string {
+	recordingURL, _, _ = strings.Cut(recordingURL, "#")
+	recordingURL, _, _ = strings.Cut(recordingURL, "?")
+	return recordingURL
+}
+
+// findDuplicateCall looks for another analysed row of the call's campaign with the same call_id or
+// canonical recording URL. A call_id match is preferred, then the row analysed first; rows that are
+// duplicates themselves are passed over, so every duplicate links to the row that was processed.
+func (tp *TranscriptionPipeline) findDuplicateCall(callData *CallData) (*DuplicateLink, error) {
+	recordingURL := canonicalRecordingURL(callData.RecordingURL)
+	if callData.CallID == "" && recordingURL == "" {
+		return nil, nil
+	}
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	callLogs := tp.schema.Table("call_logs")
+	query := fmt.Sprintf(`
+		SELECT %[2]s, $2 <> '' AND %[3]s = $2
+		FROM %[1]s
+		WHERE %[5]s = $1 AND %[2]s <> $4
+		  AND %[6]s IS NOT NULL AND %[6]s->'duplicate_of' IS NULL
+		  AND (($2 <> '' AND %[3]s = $2) OR ($3 <> '' AND split_part(split_part(%[4]s, '#', 1), '?', 1) = $3))
+		ORDER BY 2 DESC, (SELECT MIN("createdAt") FROM %[7]s WHERE "call_logsId" = %[1]s.%[2]s) NULLS LAST
+		LIMIT 1
+	`, callLogs, c("id"), c("call_id"), c("recording_url"), c("campaignId"), c("callAnalysis"), tp.schema.Table("call_analysis_versions"))
+
+	var canonicalID string
+	var byCallID bool
+	err := tp.repo.QueryRow(query, callData.CampaignID, callData.CallID, recordingURL, callData.ID).Scan(&canonicalID, &byCallID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error looking for duplicate calls: %v", err)
+	}
+
+	if byCallID {
+		return &DuplicateLink{CallLogsID: canonicalID, Rule: DuplicateRuleCallID, Key: callData.CallID}, nil
+	}
+	return &DuplicateLink{CallLogsID: canonicalID, Rule: DuplicateRuleRecordingURL, Key: recordingURL}, nil
+}
+
+// lockDuplicateKeys leases the call's call_id and canonical recording URL in call_processing_locks,
+// so two rows of one call arriving together aren't both processed: the second fails with
+// ErrDuplicateInProgress until the first has saved its analysis, and is linked to it on retry.
+func (tp *TranscriptionPipeline) lockDuplicateKeys(callData *CallData) ([]*ProcessingLock, error) {
+	keys := []struct{ rule, key string }{
+		{DuplicateRuleCallID, callData.CallID},
+		{DuplicateRuleRecordingURL, canonicalRecordingURL(callData.RecordingURL)},
+	}
+
+	var locks []*ProcessingLock
+	for _, k := range keys {
+		if k.key == "" {
+			continue
+		}
+		lock, err := tp.AcquireProcessingLock(duplicateKeyLockID(callData.CampaignID, k.rule, k.key))
+		if err != nil {
+			tp.releaseLocks(locks)
+			if errors.Is(err, ErrCallLocked) {
+				return nil, fmt.Errorf("%w (%s %s)", ErrDuplicateInProgress, k.rule, k.key)
+			}
+			return nil, err
+		}
+		locks = append(locks, lock)
+	}
+	return locks, nil
+}
+
+// releaseLocks releases each of the locks
+func (tp *TranscriptionPipeline) releaseLocks(locks []*ProcessingLock) {
+	for _, lock := range locks {
+		tp.ReleaseProcessingLock(lock)
+	}
+}
+
+// linkDuplicateCall saves a copy of the canonical row's analysis as the call's, with the link in
+// duplicate_of, instead of processing the call again
+func (tp *TranscriptionPipeline) linkDuplicateCall(callData *CallData, link *DuplicateLink) (map[string]interface{}, *CallAnalysisData, error) {
+	canonicalJSON, err := tp.GetCallAnalysis(link.CallLogsID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if canonicalJSON == nil {
+		return nil, nil, fmt.Errorf("call %s, which this call duplicates, has no analysis", link.CallLogsID)
+	}
+	var analysisData CallAnalysisData
+	if err := json.Unmarshal(canonicalJSON, &analysisData); err != nil {
+		return nil, nil, fmt.Errorf("error parsing the analysis of call %s: %v", link.CallLogsID, err)
+	}
+	analysisData.DuplicateOf = link
+	// The tokens were spent on the canonical row
+	analysisData.Usage = nil
+	analysisData.ProcessedAt = time.Now().Format(time.RFC3339)
+	analysisData.ProcessingMetadata = tp.processingMetadata()
+
+	result := map[string]interface{}{
+		"call_logsId":         callData.ID,
+		"campaignId":          callData.CampaignID,
+		"duplicate_of":        link,
+		"transcription":       analysisData.Transcription,
+		"answers":             analysisData.Answers,
+		"processing_metadata": analysisData.ProcessingMetadata,
+		"processed_at":        analysisData.ProcessedAt,
+	}
+
+	if tp.dryRun {
+		result["dry_run"] = true
+		result["analysis"] = analysisData
+		return result, nil, nil
+	}
+
+	if err := tp.SaveCallAnalysis(callData, analysisData); err != nil {
+		return nil, nil, fmt.Errorf("failed to save call analysis: %w", err)
+	}
+	return result, &analysisData, nil
+}
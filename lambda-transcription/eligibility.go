@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EligibilityRules are the pre-flight checks a call must pass to be processed. Zero values disable a check.
+type EligibilityRules struct {
+	// MinDurationSeconds skips shorter calls, e.g. abandoned calls
+	MinDurationSeconds int `json:"minDurationSeconds,omitempty"`
+	// MaxDurationSeconds skips longer calls
+	MaxDurationSeconds int `json:"maxDurationSeconds,omitempty"`
+	// RecordingURLPatterns are regular expressions; the recording URL must match one of them
+	RecordingURLPatterns []string `json:"recordingUrlPatterns,omitempty"`
+	// Campaigns is the allowlist of campaign IDs (environment only)
+	Campaigns []string `json:"-"`
+
+	patterns []*regexp.Regexp
+}
+
+// LoadEligibilityRules reads the pipeline-wide pre-flight rules from PREFLIGHT_MIN_DURATION_SECONDS,
+// PREFLIGHT_MAX_DURATION_SECONDS, PREFLIGHT_RECORDING_URL_PATTERNS and PREFLIGHT_CAMPAIGNS (comma-separated)
+func LoadEligibilityRules() (EligibilityRules, error) {
+	var rules EligibilityRules
+	for name, target := range map[string]*int{
+		"PREFLIGHT_MIN_DURATION_SECONDS": &rules.MinDurationSeconds,
+		"PREFLIGHT_MAX_DURATION_SECONDS": &rules.MaxDurationSeconds,
+	} {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			return rules, fmt.Errorf("invalid %s: %q", name, value)
+		}
+		*target = seconds
+	}
+
+	rules.RecordingURLPatterns = splitList(os.Getenv("PREFLIGHT_RECORDING_URL_PATTERNS"))
+	rules.Campaigns = splitList(os.Getenv("PREFLIGHT_CAMPAIGNS"))
+
+	if err := rules.compile(); err != nil {
+		return rules, fmt.Errorf("invalid PREFLIGHT_RECORDING_URL_PATTERNS: %v", err)
+	}
+	return rules, nil
+}
+
+// compile compiles the recording URL patterns
+func (r *EligibilityRules) compile() error {
+	r.patterns = nil
+	for _, pattern := range r.RecordingURLPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return err
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return nil
+}
+
+// withCampaignRules returns the rules with the campaign's overrides applied: each non-zero
+// setting of the campaign replaces the pipeline-wide one
+func (r EligibilityRules) withCampaignRules(campaign *EligibilityRules) (EligibilityRules, error) {
+	if campaign == nil {
+		return r, nil
+	}
+	if campaign.MinDurationSeconds > 0 {
+		r.MinDurationSeconds = campaign.MinDurationSeconds
+	}
+	if campaign.MaxDurationSeconds > 0 {
+		r.MaxDurationSeconds = campaign.MaxDurationSeconds
+	}
+	if len(campaign.RecordingURLPatterns) > 0 {
+		r.RecordingURLPatterns = campaign.RecordingURLPatterns
+		if err := r.compile(); err != nil {
+			return r, fmt.Errorf("invalid campaign recording URL pattern: %v", err)
+		}
+	}
+	return r, nil
+}
+
+// campaignAllowed reports whether the campaign is on the allowlist (or there is no allowlist)
+func (r EligibilityRules) campaignAllowed(campaignID string) bool {
+	if len(r.Campaigns) == 0 {
+		return true
+	}
+	for _, id := range r.Campaigns {
+		if strings.EqualFold(id, campaignID) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipReason returns why the call is ineligible for processing, or "" when it passes every check
+func (r EligibilityRules) skipReason(callData *CallData) string {
+	if !r.campaignAllowed(callData.CampaignID) {
+		return fmt.Sprintf("campaign %s is not on the allowlist", callData.CampaignID)
+	}
+	// A NULL duration reads as 0; the duration checks only apply when it is known
+	if r.MinDurationSeconds > 0 && callData.Duration > 0 && callData.Duration < r.MinDurationSeconds {
+		return fmt.Sprintf("duration %ds is below the minimum of %ds", callData.Duration, r.MinDurationSeconds)
+	}
+	if r.MaxDurationSeconds > 0 && callData.Duration > r.MaxDurationSeconds {
+		return fmt.Sprintf("duration %ds is above the maximum of %ds", callData.Duration, r.MaxDurationSeconds)
+	}
+	if len(r.patterns) > 0 {
+		for _, re := range r.patterns {
+			if re.MatchString(callData.RecordingURL) {
+				return ""
+			}
+		}
+		return "recording URL doesn't match an allowed pattern"
+	}
+	return ""
+}
+
+// skipCall saves an analysis that records why the call was skipped, with the recording's quality
+// score when that was the reason, so it isn't picked up again by backfills. The analysis is nil on
+// a dry run.
+func (tp *TranscriptionPipeline) skipCall(callData *CallData, reason string, quality *AudioQuality) (map[string]interface{}, *CallAnalysisData, error) {
+	analysisData := CallAnalysisData{
+		Answers:      map[string]string{},
+		SkipReason:   reason,
+		AudioQuality: quality,
+		ProcessedAt:  time.Now().Format(time.RFC3339),
+	}
+
+	result := map[string]interface{}{
+		"call_logsId":  callData.ID,
+		"campaignId":   callData.CampaignID,
+		"skipped":      true,
+		"skip_reason":  reason,
+		"processed_at": analysisData.ProcessedAt,
+	}
+	if quality != nil {
+		result["audio_quality"] = quality
+	}
+
+	if tp.dryRun {
+		result["dry_run"] = true
+		return result, nil, nil
+	}
+
+	if err := tp.SaveCallAnalysis(callData, analysisData); err != nil {
+		return nil, nil, fmt.Errorf("failed to save call analysis: %w", err)
+	}
+	return result, &analysisData, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// embeddingModel produces 768-dimensional embeddings, matching the call_embeddings.embedding column
+	embeddingModel = "text-embedding-004"
+	// maxEmbeddingBytes keeps the embedded text within the model's input limit
+	maxEmbeddingBytes = 8000
+)
+
+// Embedding task types
+const (
+	EmbeddingTaskDocument = "RETRIEVAL_DOCUMENT"
+	EmbeddingTaskQuery    = "RETRIEVAL_QUERY"
+)
+
+// EmbedContentRequest represents the request to the Gemini embedContent API
+type EmbedContentRequest struct {
+	Model    string  `json:"model"`
+	Content  Content `json:"content"`
+	TaskType string  `json:"taskType,omitempty"`
+}
+
+// EmbedContentResponse represents the response from the Gemini embedContent API
+type EmbedContentResponse struct {
+	Embedding struct {
+		Values []float64 `json:"values"`
+	} `json:"embedding"`
+}
+
+// VertexPredictRequest represents the request to the Vertex AI predict API of embedding models
+type VertexPredictRequest struct {
+	Instances []VertexEmbeddingInstance `json:"instances"`
+}
+
+// VertexEmbeddingInstance is a text to embed with the Vertex AI predict API
+type VertexEmbeddingInstance struct {
+	Content  string `json:"content"`
+	TaskType string `json:"task_type,omitempty"`
+}
+
+// VertexPredictResponse represents the response from the Vertex AI predict API of embedding models
+type VertexPredictResponse struct {
+	Predictions []struct {
+		Embeddings struct {
+			Values []float64 `json:"values"`
+		} `json:"embeddings"`
+	} `json:"predictions"`
+}
+
+// GenerateEmbedding returns the Gemini embedding of the text for the given task type
+func (tp *TranscriptionPipeline) GenerateEmbedding(text, taskType string) ([]float64, error) {
+	text = truncateUTF8(text, maxEmbeddingBytes)
+
+	// Vertex AI serves embedding models through predict rather than embedContent
+	method := "embedContent"
+	var requestData interface{} = EmbedContentRequest{
+		Model:    "models/" + embeddingModel,
+		Content:  Content{Parts: []Part{{Text: text}}},
+		TaskType: taskType,
+	}
+	if geminiBackend() == GeminiBackendVertex {
+		method = "predict"
+		requestData = VertexPredictRequest{Instances: []VertexEmbeddingInstance{{Content: text, TaskType: taskType}}}
+	}
+
+	jsonData, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling embedding request: %v", err)
+	}
+
+	if err := tp.waitForGeminiQuota(embeddingModel); err != nil {
+		return nil, err
+	}
+
+	req, err := tp.newGeminiRequest(embeddingModel, method, jsonRequestBody(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("error creating embedding request: %v", err)
+	}
+
+	resp, err := sendGeminiRequest(tp.currentGeminiBreaker(embeddingModel), req, 30*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error making embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading embedding response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gemini embedding API error: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+
+	var values []float64
+	if method == "predict" {
+		var predictResp VertexPredictResponse
+		if err := json.Unmarshal(respBody, &predictResp); err != nil {
+			return nil, fmt.Errorf("error decoding embedding response: %v", err)
+		}
+		if len(predictResp.Predictions) > 0 {
+			values = predictResp.Predictions[0].Embeddings.Values
+		}
+	} else {
+		var embedResp EmbedContentResponse
+		if err := json.Unmarshal(respBody, &embedResp); err != nil {
+			return nil, fmt.Errorf("error decoding embedding response: %v", err)
+		}
+		values = embedResp.Embedding.Values
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("empty embedding received from Gemini API")
+	}
+
+	return values, nil
+}
+
+// vectorLiteral formats an embedding as a pgvector literal, e.g. "[0.1,0.2]"
+func vectorLiteral(values []float64) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		parts[i] = strconv.FormatFloat(value, 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// SaveCallEmbedding embeds the transcription and stores it for semantic search
+func (tp *TranscriptionPipeline) SaveCallEmbedding(callLogsID, campaignID, transcription string) error {
+	embedding, err := tp.GenerateEmbedding(transcription, EmbeddingTaskDocument)
+	if err != nil {
+		return err
+	}
+
+	content := truncateUTF8(transcription, maxEmbeddingBytes)
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", model, content, embedding, "updatedAt")
+		VALUES ($1, $2, $3, $4, $5::vector, now())
+		ON CONFLICT ("call_logsId")
+		DO UPDATE SET "campaignId" = EXCLUDED."campaignId", model = EXCLUDED.model, content = EXCLUDED.content,
+		              embedding = EXCLUDED.embedding, "updatedAt" = EXCLUDED."updatedAt"
+	`, tp.schema.Table("call_embeddings"))
+
+	if _, err := tp.repo.Exec(query, callLogsID, campaignID, embeddingModel, content, vectorLiteral(embedding)); err != nil {
+		return fmt.Errorf("error saving call embedding: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Entity types extracted from the transcription
+const (
+	EntityProduct  = "product"
+	EntityAmount   = "amount"
+	EntityDate     = "date"
+	EntityLocation = "location"
+	EntityOrderID  = "order_id"
+)
+
+// CallEntity is a structured entity mentioned in the call. Text is always the verbatim mention;
+// the typed fields are only set for their entity types and when the mention could be normalized.
+type CallEntity struct {
+	Type    string `json:"type"`
+	Text    string `json:"text"`
+	Speaker string `json:"speaker,omitempty"`
+	// Value is the normalized product name, location or order ID
+	Value string `json:"value,omitempty"`
+	// Amount and Currency (ISO 4217) are set for amounts
+	Amount   *float64 `json:"amount,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+	// Date (YYYY-MM-DD) and Time (HH:MM, 24-hour) are set for dates and times, resolved against the call date
+	Date string `json:"date,omitempty"`
+	Time string `json:"time,omitempty"`
+	// Start is the start of the segment the mention was found in; nil when it couldn't be located
+	Start     *float64 `json:"start,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+}
+
+// EntityExtraction represents the entities section of the call analysis
+type EntityExtraction struct {
+	Entities []CallEntity `json:"entities"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// ExtractEntities asks the model for the products, amounts, dates and times, locations and order IDs
+// mentioned in the call, then validates the normalized values and locates each mention in the
+// transcription's segments. callDate (the call's start_date) resolves relative dates such as
+// "tomorrow". An extraction failure is recorded in the result.
+func (tp *TranscriptionPipeline) ExtractEntities(transcription, callDate string, segments []TranscriptSegment) *EntityExtraction {
+	prompt := fmt.Sprintf(`
+Extract the structured entities mentioned in the following call transcription. The call may be in Hindi, English or a mix.
+The call took place on %s; resolve relative dates and times ("kal", "next Monday", "shaam 5 baje") against it.
+
+Entity types:
+- "product": a product or service name; value is the product name in English as it would appear in a catalogue
+- "amount": a price, payment, discount or other monetary amount; amount is the number and currency the ISO 4217 code (default INR)
+- "date": a date and/or time, e.g. for a delivery, callback or payment; date is YYYY-MM-DD and time is HH:MM (24-hour), each only if stated
+- "location": an address, city, area or landmark; value is the location in English
+- "order_id": an order, invoice, ticket or reference number; value is the identifier without spaces
+
+For each entity give the type, the verbatim mention from the transcription as text, the speaker ("Agent" or "Customer")
+and the normalized fields for its type. List an entity once per mention that adds information; skip repeated identical mentions.
+
+TRANSCRIPTION:
+%s
+
+Respond with a JSON array only (empty if there are none), in this format:
+[{"type": "amount", "text": "paanch sau rupaye", "speaker": "Customer", "amount": 500, "currency": "INR"}]
+`, callReferenceDate(callDate), transcription)
+
+	extraction := &EntityExtraction{Entities: []CallEntity{}}
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		extraction.Error = fmt.Sprintf("error extracting entities: %v", err)
+		return extraction
+	}
+
+	var entities []CallEntity
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &entities); err != nil {
+		extraction.Error = fmt.Sprintf("error parsing entities: %v", err)
+		return extraction
+	}
+
+	for _, entity := range entities {
+		entity.Type = strings.ToLower(strings.TrimSpace(entity.Type))
+		entity.Text = strings.TrimSpace(entity.Text)
+		if entity.Text == "" || !normalizeEntity(&entity) {
+			continue
+		}
+		entity.Speaker = normalizeSpeaker(entity.Speaker)
+
+		if segment, ok := findQuoteSegment(segments, entity.Speaker, entity.Text); ok {
+			start := segment.Start
+			entity.Start = &start
+			entity.Timestamp = formatTimestamp(start)
+			if segment.Speaker != SpeakerUnknown {
+				entity.Speaker = segment.Speaker
+			}
+		}
+
+		extraction.Entities = append(extraction.Entities, entity)
+	}
+
+	return extraction
+}
+
+// normalizeEntity clears the fields that don't belong to the entity's type and drops normalized
+// values that don't parse. It returns false for unknown types.
+func normalizeEntity(entity *CallEntity) bool {
+	value, amount, currency, date, clock := strings.TrimSpace(entity.Value), entity.Amount, entity.Currency, entity.Date, entity.Time
+	entity.Value, entity.Amount, entity.Currency, entity.Date, entity.Time = "", nil, "", "", ""
+
+	switch entity.Type {
+	case EntityProduct, EntityLocation:
+		entity.Value = value
+	case EntityOrderID:
+		entity.Value = strings.Join(strings.Fields(value), "")
+	case EntityAmount:
+		if amount != nil && *amount >= 0 {
+			entity.Amount = amount
+			entity.Currency = strings.ToUpper(strings.TrimSpace(currency))
+			if entity.Currency == "" {
+				entity.Currency = "INR"
+			}
+		}
+	case EntityDate:
+		entity.Date, entity.Time = normalizeDate(date), normalizeClockTime(clock)
+	default:
+		return false
+	}
+	return true
+}
+
+// callReferenceDate returns the call's start_date as YYYY-MM-DD, for resolving relative dates
+// mentioned on the call. start_date may be read as a date or a full timestamp; today is used when
+// it is missing.
+func callReferenceDate(startDate string) string {
+	if len(startDate) > 10 {
+		startDate = startDate[:10]
+	}
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
+		return time.Now().Format("2006-01-02")
+	}
+	return startDate
+}
+
+// normalizeDate returns a model-provided YYYY-MM-DD date, or "" when it doesn't parse
+func normalizeDate(value string) string {
+	normalized, err := convertOutcomeValue(value, OutcomeTypeDate)
+	if err != nil {
+		return ""
+	}
+	return normalized.(string)
+}
+
+// normalizeClockTime returns a model-provided HH:MM (24-hour) time, or "" when it doesn't parse
+func normalizeClockTime(value string) string {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return ""
+	}
+	return parsed.Format("15:04")
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Error categories surfaced in the Lambda response so callers can tell failure modes apart
+const (
+	ErrorCategoryGeminiBlocked    = "gemini_blocked"
+	ErrorCategoryCircuitOpen      = "circuit_open"
+	ErrorCategoryCallNotFound     = "call_not_found"
+	ErrorCategoryMessageNotFound  = "message_not_found"
+	ErrorCategoryNoRecording      = "no_recording_url"
+	ErrorCategoryAlreadyProcessed = "already_processed"
+	ErrorCategoryInProgress       = "in_progress"
+	ErrorCategoryDuplicateRunning = "duplicate_in_progress"
+	ErrorCategoryConflict         = "analysis_conflict"
+	ErrorCategoryProviderFailure  = "provider_failure"
+	ErrorCategoryQuotaExhausted   = "quota_exhausted"
+	ErrorCategoryDownloadFailed   = "download_failed"
+	ErrorCategoryRateLimited      = "provider_rate_limited"
+	ErrorCategoryParseFailure     = "parse_failure"
+	ErrorCategoryInvalidAudio     = "invalid_audio"
+	ErrorCategoryInvalidQuestions = "invalid_questions"
+	ErrorCategoryBudgetExceeded   = "budget_exceeded"
+	ErrorCategoryTooLarge         = "recording_too_large"
+	ErrorCategoryPanic            = "internal_panic"
+	ErrorCategoryNoArchive        = "no_archived_response"
+)
+
+// Permanent ProcessCall failures, which retrying won't fix
+var (
+	ErrCallNotFound     = errors.New("call not found")
+	ErrNoRecordingURL   = errors.New("no recording URL found for this call")
+	ErrAlreadyProcessed = errors.New("call already has an analysis")
+)
+
+// Provider failures, which usually succeed on retry. They are wrapped with %w so callers can branch
+// with errors.Is instead of matching the error text.
+var (
+	ErrDownloadFailed      = errors.New("recording download failed")
+	ErrProviderRateLimited = errors.New("provider rate limited")
+	ErrParseFailure        = errors.New("provider response could not be parsed")
+	ErrEmptyResponse       = errors.New("empty response received")
+)
+
+// ProviderError wraps a failure to download or transcribe the recording, which is usually transient
+type ProviderError struct {
+	Err error
+}
+
+func (e *ProviderError) Error() string { return e.Err.Error() }
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// providerAPIError returns the error of a transcription provider's non-200 response, marking rate limits
+func providerAPIError(provider string, statusCode int, body []byte) error {
+	if statusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("%s API error: status %d, body: %s: %w", provider, statusCode, body, ErrProviderRateLimited)
+	}
+	return fmt.Errorf("%s API error: status %d, body: %s", provider, statusCode, body)
+}
+
+// errorCategory classifies a processing error for the Lambda response
+func errorCategory(err error) string {
+	var blocked *GeminiBlockedError
+	var provider *ProviderError
+	switch {
+	case errors.As(err, &blocked):
+		return ErrorCategoryGeminiBlocked
+	case errors.Is(err, ErrCircuitOpen):
+		return ErrorCategoryCircuitOpen
+	case errors.Is(err, ErrGeminiQuotaExhausted):
+		return ErrorCategoryQuotaExhausted
+	case errors.Is(err, ErrCallNotFound):
+		return ErrorCategoryCallNotFound
+	case errors.Is(err, ErrMessageNotFound):
+		return ErrorCategoryMessageNotFound
+	case errors.Is(err, ErrNoRecordingURL), errors.Is(err, ErrNoMediaURL):
+		return ErrorCategoryNoRecording
+	case errors.Is(err, ErrNoArchivedResponse):
+		return ErrorCategoryNoArchive
+	case errors.Is(err, ErrInvalidAudio):
+		return ErrorCategoryInvalidAudio
+	case errors.Is(err, ErrInvalidQuestions):
+		return ErrorCategoryInvalidQuestions
+	case errors.Is(err, ErrBudgetExceeded):
+		return ErrorCategoryBudgetExceeded
+	case errors.Is(err, ErrRecordingTooLarge):
+		return ErrorCategoryTooLarge
+	case errors.Is(err, ErrAlreadyProcessed), errors.Is(err, ErrMessageAlreadyProcessed):
+		return ErrorCategoryAlreadyProcessed
+	case errors.Is(err, ErrCallLocked):
+		return ErrorCategoryInProgress
+	case errors.Is(err, ErrDuplicateInProgress):
+		return ErrorCategoryDuplicateRunning
+	case errors.Is(err, ErrAnalysisConflict):
+		return ErrorCategoryConflict
+	case errors.Is(err, ErrProviderRateLimited):
+		return ErrorCategoryRateLimited
+	case errors.Is(err, ErrDownloadFailed):
+		return ErrorCategoryDownloadFailed
+	case errors.Is(err, ErrParseFailure), errors.Is(err, ErrEmptyResponse):
+		return ErrorCategoryParseFailure
+	case errors.As(err, &provider):
+		return ErrorCategoryProviderFailure
+	}
+	return ""
+}
+
+// errorStatusCode maps a processing error onto the Lambda response status code, so callers can
+// retry transient failures and drop permanent ones: 400, 404 and 422 won't succeed on retry, 409 means
+// the work is already done or under way, 402 that the campaign's daily budget is spent, 413 that the recording doesn't fit the function's memory, 429 asks to
+// retry once the Gemini quota or the provider's rate limit frees up, 424 is a failed download or transcription provider, 502 a provider response
+// that couldn't be used, 503 asks to retry once another row of the same call is processed and 500 anything else
+func errorStatusCode(err error) int {
+	var provider *ProviderError
+	switch {
+	case errors.Is(err, ErrInvalidAudio), errors.Is(err, ErrInvalidQuestions):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrCallNotFound), errors.Is(err, ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrNoRecordingURL), errors.Is(err, ErrNoMediaURL), errors.Is(err, ErrNoArchivedResponse):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrAlreadyProcessed), errors.Is(err, ErrMessageAlreadyProcessed), errors.Is(err, ErrCallLocked), errors.Is(err, ErrAnalysisConflict):
+		return http.StatusConflict
+	case errors.Is(err, ErrBudgetExceeded):
+		return http.StatusPaymentRequired
+	case errors.Is(err, ErrRecordingTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrGeminiQuotaExhausted), errors.Is(err, ErrProviderRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrDuplicateInProgress):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrDownloadFailed), errors.As(err, &provider):
+		return http.StatusFailedDependency
+	case errors.Is(err, ErrParseFailure), errors.Is(err, ErrEmptyResponse):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// errorRetryable reports whether retrying the request may succeed. Invalid audio, missing calls and recordings,
+// recordings too large for the function's memory, work that is done or under way and content Gemini blocked fail the same way every time; anything
+// else, including unclassified errors, is worth retrying.
+func errorRetryable(err error) bool {
+	var blocked *GeminiBlockedError
+	if errors.As(err, &blocked) {
+		return false
+	}
+	switch errorStatusCode(err) {
+	case http.StatusBadRequest, http.StatusPaymentRequired, http.StatusNotFound, http.StatusRequestEntityTooLarge, http.StatusConflict, http.StatusUnprocessableEntity:
+		return false
+	}
+	return true
+}
+
+// errorResponse builds the Lambda response of a failed request, with its category and retryability
+func errorResponse(err error) LambdaResponse {
+	retryable := errorRetryable(err)
+	return LambdaResponse{
+		StatusCode:    errorStatusCode(err),
+		Error:         err.Error(),
+		ErrorCategory: errorCategory(err),
+		Retryable:     &retryable,
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// EvaluationConfig selects the golden calls and the provider/variant combinations to evaluate
+type EvaluationConfig struct {
+	// CampaignID limits the evaluation to one campaign's golden calls
+	CampaignID string `json:"campaignId,omitempty"`
+	// Providers to evaluate (default TRANSCRIPTION_PROVIDER, or gemini)
+	Providers []string `json:"providers,omitempty"`
+	// Variants are prompt_variants names; "default" (or "") is the default prompt
+	Variants []string `json:"variants,omitempty"`
+	// Limit caps the number of golden calls (0 for all)
+	Limit int `json:"limit,omitempty"`
+}
+
+// GoldenCall is a call with a human-verified transcript and answers
+type GoldenCall struct {
+	CallLogsID string
+	CampaignID string
+	Transcript string
+	Answers    map[string]string
+}
+
+// EvaluationReport is the outcome of an evaluation run
+type EvaluationReport struct {
+	RunID          int64               `json:"run_id,omitempty"`
+	CampaignID     string              `json:"campaignId,omitempty"`
+	GoldenCalls    int                 `json:"golden_calls"`
+	Configurations []EvaluationSummary `json:"configurations"`
+}
+
+// EvaluationSummary aggregates the results of one provider/model/variant combination
+type EvaluationSummary struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// AnswerModel is the Gemini model answering the questions when the provider only transcribes
+	AnswerModel string `json:"answer_model,omitempty"`
+	Variant     string `json:"variant"`
+	Calls       int    `json:"calls"`
+	Failed      int    `json:"failed"`
+	// WER is the word error rate over all golden transcripts (nil when none have one)
+	WER            *float64                     `json:"wer"`
+	AnswerAccuracy *float64                     `json:"answer_accuracy"`
+	AnswersCorrect int                          `json:"answers_correct"`
+	AnswersTotal   int                          `json:"answers_total"`
+	Questions      map[string]*QuestionAccuracy `json:"questions,omitempty"`
+	Usage          TokenUsage                   `json:"usage"`
+	CostUsd        float64                      `json:"cost_usd,omitempty"`
+	Results        []EvaluationCallResult       `json:"results"`
+
+	wordErrors     int
+	referenceWords int
+}
+
+// QuestionAccuracy is the answer accuracy of a single question
+type QuestionAccuracy struct {
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// EvaluationCallResult is the result of one golden call under one configuration
+type EvaluationCallResult struct {
+	CallLogsID string `json:"call_logsId"`
+	// Provider is set when a fallback provider produced the transcription
+	Provider       string                    `json:"provider,omitempty"`
+	WER            *float64                  `json:"wer,omitempty"`
+	WordErrors     int                       `json:"word_errors,omitempty"`
+	ReferenceWords int                       `json:"reference_words,omitempty"`
+	AnswersCorrect int                       `json:"answers_correct"`
+	AnswersTotal   int                       `json:"answers_total"`
+	Mismatches     map[string]AnswerMismatch `json:"mismatches,omitempty"`
+	Error          string                    `json:"error,omitempty"`
+}
+
+// AnswerMismatch is a model answer that differs from the verified one
+type AnswerMismatch struct {
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// ListGoldenCalls retrieves the golden calls, optionally for a single campaign
+func (tp *TranscriptionPipeline) ListGoldenCalls(campaignID string, limit int) ([]GoldenCall, error) {
+	query := fmt.Sprintf(`
+		SELECT "call_logsId", "campaignId", transcript, answers
+		FROM %s
+		WHERE $1 = '' OR "campaignId"::text = $1
+		ORDER BY "createdAt", "call_logsId"
+	`, tp.schema.Table("golden_calls"))
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	rows, err := tp.repo.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing golden calls: %v", err)
+	}
+	defer rows.Close()
+
+	var calls []GoldenCall
+	for rows.Next() {
+		var call GoldenCall
+		var answersJSON []byte
+		if err := rows.Scan(&call.CallLogsID, &call.CampaignID, &call.Transcript, &answersJSON); err != nil {
+			return nil, fmt.Errorf("error scanning golden call row: %v", err)
+		}
+		if err := json.Unmarshal(answersJSON, &call.Answers); err != nil {
+			return nil, fmt.Errorf("error parsing answers of golden call %s: %v", call.CallLogsID, err)
+		}
+		calls = append(calls, call)
+	}
+
+	return calls, rows.Err()
+}
+
+// RunEvaluation processes every golden call with each provider/variant combination and scores
+// the transcripts (WER) and answers (accuracy) against the verified ones. Nothing is saved to the
+// calls; the transcription cache is bypassed so every combination is actually run.
+func (tp *TranscriptionPipeline) RunEvaluation(config EvaluationConfig) (*EvaluationReport, error) {
+	tp.cacheEnabled = false
+
+	providers := config.Providers
+	if len(providers) == 0 {
+		providers = []string{tp.transcriptionProvider}
+	}
+	for i, provider := range providers {
+		providers[i] = strings.ToLower(provider)
+		if providers[i] == "" {
+			providers[i] = ProviderGemini
+		}
+		if _, err := NewTranscriber(providers[i], tp); err != nil {
+			return nil, err
+		}
+	}
+
+	variantNames := config.Variants
+	if len(variantNames) == 0 {
+		variantNames = []string{""}
+	}
+	variants := make([]*PromptVariant, len(variantNames))
+	for i, name := range variantNames {
+		if name == "" || name == "default" {
+			continue
+		}
+		variant, err := tp.GetPromptVariant(name)
+		if err != nil {
+			return nil, err
+		}
+		variants[i] = variant
+	}
+
+	goldenCalls, err := tp.ListGoldenCalls(config.CampaignID, config.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &EvaluationReport{CampaignID: config.CampaignID, GoldenCalls: len(goldenCalls)}
+	questionsByCampaign := make(map[string][]Question)
+	languageByCampaign := make(map[string]string)
+
+	for _, provider := range providers {
+		for _, variant := range variants {
+			tp.usePromptVariant(variant)
+			summary := EvaluationSummary{
+				Provider:  provider,
+				Model:     tp.transcriptionModel(provider),
+				Variant:   "default",
+				Questions: make(map[string]*QuestionAccuracy),
+				Results:   []EvaluationCallResult{},
+			}
+			if provider != ProviderGemini {
+				summary.AnswerModel = tp.geminiModel()
+			}
+			if variant != nil {
+				summary.Variant = variant.Name
+			}
+
+			var usage TokenUsage
+			for _, golden := range goldenCalls {
+				questions, ok := questionsByCampaign[golden.CampaignID]
+				if !ok {
+					questions, err = tp.GetQuestionsForCampaign(golden.CampaignID)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get questions for campaign: %v", err)
+					}
+					questionsByCampaign[golden.CampaignID] = questions
+
+					// Answer in the campaign's output language, as production does
+					settings, err := tp.GetCampaignSettings(golden.CampaignID)
+					if err != nil {
+						return nil, fmt.Errorf("failed to get campaign settings: %v", err)
+					}
+					languageByCampaign[golden.CampaignID] = settings.OutputLanguage
+				}
+
+				tp.usePromptVariant(variant)
+				tp.outputLanguage = languageByCampaign[golden.CampaignID]
+				tp.promptCall = nil
+				tp.geminiExchanges = nil
+				summary.addResult(golden, tp.evaluateGoldenCall(golden, questions, provider))
+				usage.Requests += tp.usage.Requests
+				usage.PromptTokens += tp.usage.PromptTokens
+				usage.OutputTokens += tp.usage.OutputTokens
+			}
+
+			summary.Usage = usage
+			if variant != nil {
+				summary.CostUsd = variant.cost(usage)
+			}
+			summary.finish()
+			report.Configurations = append(report.Configurations, summary)
+		}
+	}
+	tp.usePromptVariant(nil)
+
+	return report, nil
+}
+
+// evaluateGoldenCall processes a golden call's recording and compares the result with the verified transcript and answers
+func (tp *TranscriptionPipeline) evaluateGoldenCall(golden GoldenCall, questions []Question, provider string) EvaluationCallResult {
+	result := EvaluationCallResult{CallLogsID: golden.CallLogsID}
+
+	callData, err := tp.GetCallData(golden.CallLogsID)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get call data: %v", err)
+		return result
+	}
+	if callData.RecordingURL == "" {
+		result.Error = "no recording URL found for this call"
+		return result
+	}
+
+	transcription, err := tp.TranscribeRecording(callData.RecordingURL, questions, provider)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if transcription.Provider != provider {
+		result.Provider = transcription.Provider
+	}
+
+	answers, _ := validateEnumAnswers(questions, transcription.Answers)
+	answers, _ = applyQuestionConditions(questions, answers)
+
+	if golden.Transcript != "" {
+		result.WordErrors, result.ReferenceWords = wordErrors(transcriptWords(golden.Transcript), transcriptWords(transcription.Transcription))
+		if result.ReferenceWords > 0 {
+			wer := roundTo(float64(result.WordErrors)/float64(result.ReferenceWords), 4)
+			result.WER = &wer
+		}
+	}
+
+	for questionID, expected := range golden.Answers {
+		result.AnswersTotal++
+		actual := answers[questionID]
+		if normalizeAnswer(actual) == normalizeAnswer(expected) {
+			result.AnswersCorrect++
+			continue
+		}
+		if result.Mismatches == nil {
+			result.Mismatches = make(map[string]AnswerMismatch)
+		}
+		result.Mismatches[questionID] = AnswerMismatch{Expected: expected, Actual: actual}
+	}
+
+	return result
+}
+
+// addResult adds a golden call's result to the summary's totals
+func (s *EvaluationSummary) addResult(golden GoldenCall, result EvaluationCallResult) {
+	s.Calls++
+	s.Results = append(s.Results, result)
+	if result.Error != "" {
+		s.Failed++
+		return
+	}
+
+	s.wordErrors += result.WordErrors
+	s.referenceWords += result.ReferenceWords
+	s.AnswersCorrect += result.AnswersCorrect
+	s.AnswersTotal += result.AnswersTotal
+
+	for questionID := range golden.Answers {
+		question, ok := s.Questions[questionID]
+		if !ok {
+			question = &QuestionAccuracy{}
+			s.Questions[questionID] = question
+		}
+		question.Total++
+		if _, mismatch := result.Mismatches[questionID]; !mismatch {
+			question.Correct++
+		}
+	}
+}
+
+// finish computes the summary's rates
+func (s *EvaluationSummary) finish() {
+	if s.referenceWords > 0 {
+		wer := roundTo(float64(s.wordErrors)/float64(s.referenceWords), 4)
+		s.WER = &wer
+	}
+	if s.AnswersTotal > 0 {
+		accuracy := roundTo(float64(s.AnswersCorrect)/float64(s.AnswersTotal), 4)
+		s.AnswerAccuracy = &accuracy
+	}
+	for _, question := range s.Questions {
+		question.Accuracy = roundTo(float64(question.Correct)/float64(question.Total), 4)
+	}
+}
+
+// transcriptionModel returns the model the provider transcribes with
+func (tp *TranscriptionPipeline) transcriptionModel(provider string) string {
+	switch provider {
+	case ProviderOpenAI:
+		if model := os.Getenv("OPENAI_TRANSCRIPTION_MODEL"); model != "" {
+			return model
+		}
+		return defaultWhisperModel
+	case ProviderDeepgram:
+		if model := os.Getenv("DEEPGRAM_MODEL"); model != "" {
+			return model
+		}
+		return defaultDeepgramModel
+	}
+	return tp.geminiModel()
+}
+
+// transcriptWords splits a transcript into lowercase words for WER, ignoring punctuation and,
+// for diarized transcripts, the timestamps and speaker labels
+func transcriptWords(transcript string) []string {
+	if segments := parseDiarizedTranscript(transcript); len(segments) > 0 {
+		texts := make([]string, len(segments))
+		for i, segment := range segments {
+			texts[i] = segment.Text
+		}
+		transcript = strings.Join(texts, " ")
+	}
+
+	return strings.FieldsFunc(strings.ToLower(transcript), func(r rune) bool {
+		// Marks are kept so Devanagari vowel signs stay part of their words
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && !unicode.IsMark(r)
+	})
+}
+
+// wordErrors returns the word-level edit distance (substitutions, deletions and insertions)
+// between the reference and the hypothesis, and the number of reference words
+func wordErrors(reference, hypothesis []string) (int, int) {
+	previous := make([]int, len(hypothesis)+1)
+	current := make([]int, len(hypothesis)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(reference); i++ {
+		current[0] = i
+		for j := 1; j <= len(hypothesis); j++ {
+			substitution := previous[j-1]
+			if reference[i-1] != hypothesis[j-1] {
+				substitution++
+			}
+			current[j] = min(substitution, previous[j]+1, current[j-1]+1)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(hypothesis)], len(reference)
+}
+
+// SaveEvaluationRun stores the report and returns its run ID
+func (tp *TranscriptionPipeline) SaveEvaluationRun(report *EvaluationReport) (int64, error) {
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling evaluation report: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("campaignId", report)
+		VALUES (NULLIF($1, '')::uuid, $2)
+		RETURNING id
+	`, tp.schema.Table("evaluation_runs"))
+
+	var id int64
+	if err := tp.repo.QueryRow(query, report.CampaignID, reportJSON).Scan(&id); err != nil {
+		return 0, fmt.Errorf("error saving evaluation run: %v", err)
+	}
+	return id, nil
+}
+
+// HandleEvaluate runs an evaluation and stores its report, unless the pipeline is in dry-run mode
+func (tp *TranscriptionPipeline) HandleEvaluate(config EvaluationConfig) LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	report, err := tp.RunEvaluation(config)
+	if err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+
+	if !tp.dryRun {
+		report.RunID, err = tp.SaveEvaluationRun(report)
+		if err != nil {
+			return LambdaResponse{StatusCode: 500, Body: report, Error: err.Error()}
+		}
+	}
+
+	return LambdaResponse{StatusCode: 200, Body: report}
+}
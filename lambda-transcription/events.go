@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// Analysis event types, used as the EventBridge detail-type and the SNS "event_type" message attribute
+const (
+	EventAnalysisCompleted = "call.analysis.completed"
+	EventAnalysisFailed    = "call.analysis.failed"
+	EventAnalysisSkipped   = "call.analysis.skipped"
+)
+
+// analysisEventSource is the EventBridge source of analysis events
+const analysisEventSource = "smartflo.call-transcription"
+
+// AnalysisEvent is published when a call's analysis completes, fails or is skipped
+type AnalysisEvent struct {
+	Type          string                `json:"type"`
+	CallLogsID    string                `json:"call_logsId"`
+	CampaignID    string                `json:"campaignId,omitempty"`
+	OccurredAt    string                `json:"occurredAt"`
+	Summary       *AnalysisEventSummary `json:"summary,omitempty"`
+	Error         string                `json:"error,omitempty"`
+	ErrorCategory string                `json:"errorCategory,omitempty"`
+	Retryable     bool                  `json:"retryable,omitempty"`
+	SkipReason    string                `json:"skipReason,omitempty"`
+}
+
+// AnalysisEventSummary summarizes a completed analysis; subscribers fetch the full callAnalysis if they need it
+type AnalysisEventSummary struct {
+	Provider          string   `json:"provider"`
+	CacheHit          bool     `json:"cacheHit"`
+	ProcessedAt       string   `json:"processedAt"`
+	QuestionsAnswered int      `json:"questionsAnswered"`
+	QuestionsSkipped  int      `json:"questionsSkipped"`
+	CompliancePassed  *bool    `json:"compliancePassed,omitempty"`
+	QAScore           *float64 `json:"qaScore,omitempty"`
+	Intent            string   `json:"intent,omitempty"`
+}
+
+// newAnalysisEvent builds the event for a processing attempt; analysis is nil when processing failed
+func newAnalysisEvent(callLogsID, campaignID string, analysis *CallAnalysisData, processErr error) AnalysisEvent {
+	event := AnalysisEvent{
+		Type:       EventAnalysisCompleted,
+		CallLogsID: callLogsID,
+		CampaignID: campaignID,
+		OccurredAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if processErr != nil {
+		event.Type = EventAnalysisFailed
+		event.Error = processErr.Error()
+		event.ErrorCategory = errorCategory(processErr)
+		event.Retryable = errorRetryable(processErr)
+		return event
+	}
+
+	if analysis != nil && analysis.SkipReason != "" {
+		event.Type = EventAnalysisSkipped
+		event.SkipReason = analysis.SkipReason
+		return event
+	}
+
+	if analysis != nil {
+		event.Summary = summarizeAnalysis(analysis)
+	}
+	return event
+}
+
+// summarizeAnalysis returns the summary of a completed analysis
+func summarizeAnalysis(analysis *CallAnalysisData) *AnalysisEventSummary {
+	summary := &AnalysisEventSummary{
+		Provider:          analysis.Provider,
+		CacheHit:          analysis.CacheHit,
+		ProcessedAt:       analysis.ProcessedAt,
+		QuestionsAnswered: len(analysis.Answers),
+		QuestionsSkipped:  len(analysis.SkippedQuestions),
+	}
+	if analysis.Compliance != nil && analysis.Compliance.RulesChecked > 0 {
+		summary.CompliancePassed = &analysis.Compliance.Passed
+	}
+	if analysis.QAScorecard != nil && analysis.QAScorecard.Error == "" && len(analysis.QAScorecard.Criteria) > 0 {
+		summary.QAScore = &analysis.QAScorecard.CompositeScore
+	}
+	if analysis.Intent != nil {
+		summary.Intent = analysis.Intent.Label
+	}
+	return summary
+}
+
+// PublishAnalysisEvent publishes the outcome of a processing attempt to the SNS topic in EVENTS_SNS_TOPIC_ARN
+// and/or the EventBridge bus in EVENTS_EVENT_BUS_NAME. Publishing is best-effort: failures are logged and
+// don't affect the call.
+func (tp *TranscriptionPipeline) PublishAnalysisEvent(callLogsID, campaignID string, analysis *CallAnalysisData, processErr error) {
+	topicARN, eventBus := os.Getenv("EVENTS_SNS_TOPIC_ARN"), os.Getenv("EVENTS_EVENT_BUS_NAME")
+	if topicARN == "" && eventBus == "" {
+		return
+	}
+
+	event := newAnalysisEvent(callLogsID, campaignID, analysis, processErr)
+	detail, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error marshaling %s event for %s: %v", event.Type, callLogsID, err)
+		return
+	}
+
+	if topicARN != "" {
+		if err := snsPublish(topicARN, string(detail), map[string]string{"event_type": event.Type}); err != nil {
+			log.Printf("Error publishing %s event for %s to SNS: %v", event.Type, callLogsID, err)
+		}
+	}
+
+	if eventBus != "" {
+		if err := putEventBridgeEvent(eventBus, analysisEventSource, event.Type, string(detail)); err != nil {
+			log.Printf("Error publishing %s event for %s to EventBridge: %v", event.Type, callLogsID, err)
+		}
+	}
+}
+
+// putEventBridgeEvent puts a single event on an EventBridge bus
+func putEventBridgeEvent(eventBus, source, detailType, detail string) error {
+	payload := map[string]interface{}{
+		"Entries": []map[string]string{{
+			"EventBusName": eventBus,
+			"Source":       source,
+			"DetailType":   detailType,
+			"Detail":       detail,
+		}},
+	}
+
+	var out struct {
+		FailedEntryCount int `json:"FailedEntryCount"`
+		Entries          []struct {
+			ErrorCode    string `json:"ErrorCode"`
+			ErrorMessage string `json:"ErrorMessage"`
+		} `json:"Entries"`
+	}
+	if err := callAWSJSON("events", "events", "AWSEvents.PutEvents", "1.1", payload, &out); err != nil {
+		return err
+	}
+	if out.FailedEntryCount > 0 && len(out.Entries) > 0 {
+		return fmt.Errorf("event rejected: %s: %s", out.Entries[0].ErrorCode, out.Entries[0].ErrorMessage)
+	}
+	return nil
+}
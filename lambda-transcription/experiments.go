@@ -0,0 +1,195 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+)
+
+// defaultGeminiModel is used for Gemini requests unless a prompt variant selects another model
+const defaultGeminiModel = "gemini-2.5-pro"
+
+// PromptVariant is a named prompt/model variant from prompt_variants
+type PromptVariant struct {
+	Name         string
+	Model        string
+	Instructions string
+	// Costs are USD per million tokens
+	InputCostPerMillion  float64
+	OutputCostPerMillion float64
+}
+
+// PromptExperiment assigns a campaign's calls to prompt variants by percentage. Calls outside
+// the allocated percentages use the default prompt. A single variant at 100% switches the whole campaign.
+type PromptExperiment struct {
+	Variants []VariantAllocation `json:"variants"`
+	// ShadowVariants are also run on every call, storing only their answers and usage for comparison
+	ShadowVariants []string `json:"shadowVariants,omitempty"`
+}
+
+// VariantAllocation is the percentage of a campaign's calls assigned to a variant
+type VariantAllocation struct {
+	Name    string `json:"name"`
+	Percent int    `json:"percent"`
+}
+
+// TokenUsage counts the Gemini tokens spent on a call
+type TokenUsage struct {
+	Requests     int `json:"requests"`
+	PromptTokens int `json:"prompt_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// UsageMetadata is the token accounting of a Gemini response
+type UsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// variantBucket maps a call to a stable bucket in [0, 100), so a reprocessed call keeps its variant
+func variantBucket(callLogsID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(callLogsID))
+	return int(h.Sum32() % 100)
+}
+
+// assign returns the variant the call is allocated to, or "" for the default prompt
+func (e *PromptExperiment) assign(callLogsID string) string {
+	bucket := variantBucket(callLogsID)
+	cumulative := 0
+	for _, allocation := range e.Variants {
+		cumulative += allocation.Percent
+		if bucket < cumulative {
+			return allocation.Name
+		}
+	}
+	return ""
+}
+
+// GetPromptVariant retrieves a variant by name
+func (tp *TranscriptionPipeline) GetPromptVariant(name string) (*PromptVariant, error) {
+	query := fmt.Sprintf(`
+		SELECT name, model, instructions, "inputCostPerMillion", "outputCostPerMillion"
+		FROM %s
+		WHERE name = $1
+	`, tp.schema.Table("prompt_variants"))
+
+	var variant PromptVariant
+	err := tp.repo.QueryRow(query, name).Scan(&variant.Name, &variant.Model, &variant.Instructions,
+		&variant.InputCostPerMillion, &variant.OutputCostPerMillion)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("unknown prompt variant: %s", name)
+		}
+		return nil, fmt.Errorf("error fetching prompt variant: %v", err)
+	}
+	return &variant, nil
+}
+
+// usePromptVariant switches the pipeline to a variant (nil for the default prompt) and resets the token usage
+func (tp *TranscriptionPipeline) usePromptVariant(variant *PromptVariant) {
+	tp.variant = variant
+	tp.usage = TokenUsage{}
+}
+
+// geminiModel returns the Gemini model for the current request: the pinned model, if any, then an
+// over-budget campaign's downgrade model, otherwise the current variant's, then the learned profile's
+func (tp *TranscriptionPipeline) geminiModel() string {
+	if tp.modelOverride != "" {
+		return tp.modelOverride
+	}
+	if tp.budgetModel != "" {
+		return tp.budgetModel
+	}
+	if tp.variant != nil && tp.variant.Model != "" {
+		return tp.variant.Model
+	}
+	if tp.profile != nil && tp.profile.Model != "" {
+		return tp.profile.Model
+	}
+	return defaultGeminiModel
+}
+
+// variantInstructions returns the current variant's extra prompt instructions, formatted for the prompt
+func (tp *TranscriptionPipeline) variantInstructions() string {
+	if tp.variant == nil {
+		return ""
+	}
+	return formatVariantInstructions(tp.variant.Instructions)
+}
+
+// recordUsage adds a Gemini response's token counts to the call's usage
+func (tp *TranscriptionPipeline) recordUsage(usage *UsageMetadata) {
+	tp.usage.Requests++
+	if usage != nil {
+		tp.usage.PromptTokens += usage.PromptTokenCount
+		tp.usage.OutputTokens += usage.CandidatesTokenCount
+	}
+}
+
+// cost estimates the USD cost of the usage at the variant's token prices
+func (v *PromptVariant) cost(usage TokenUsage) float64 {
+	return roundTo((float64(usage.PromptTokens)*v.InputCostPerMillion+float64(usage.OutputTokens)*v.OutputCostPerMillion)/1e6, 6)
+}
+
+// SavePromptVariantResult stores a variant's answers and token usage for a call
+func (tp *TranscriptionPipeline) SavePromptVariantResult(db Execer, callLogsID, campaignID string, variant *PromptVariant, answers map[string]string, usage TokenUsage, shadow bool) error {
+	answersJSON, err := json.Marshal(answers)
+	if err != nil {
+		return fmt.Errorf("error marshaling variant answers: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", variant, model, shadow, answers, "promptTokens", "outputTokens", "costUsd", "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now())
+		ON CONFLICT ("call_logsId", variant)
+		DO UPDATE SET model = EXCLUDED.model, shadow = EXCLUDED.shadow, answers = EXCLUDED.answers,
+		              "promptTokens" = EXCLUDED."promptTokens", "outputTokens" = EXCLUDED."outputTokens",
+		              "costUsd" = EXCLUDED."costUsd", "createdAt" = EXCLUDED."createdAt"
+	`, tp.schema.Table("prompt_variant_results"))
+
+	model := variant.Model
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	if _, err := db.Exec(query, callLogsID, campaignID, variant.Name, model, shadow, string(answersJSON),
+		usage.PromptTokens, usage.OutputTokens, variant.cost(usage)); err != nil {
+		return fmt.Errorf("error saving prompt variant result: %v", err)
+	}
+	return nil
+}
+
+// runShadowVariants processes the recording again with each shadow variant and stores their answers
+// for agreement comparisons. Shadow runs are best-effort: failures are logged and don't affect the call.
+func (tp *TranscriptionPipeline) runShadowVariants(experiment *PromptExperiment, assigned string, callData *CallData, questions []Question, provider string) {
+	primary := tp.variant
+	defer func() { tp.variant = primary }()
+
+	for _, name := range experiment.ShadowVariants {
+		if name == assigned {
+			continue
+		}
+
+		variant, err := tp.GetPromptVariant(name)
+		if err != nil {
+			log.Printf("Error running shadow variant %s for %s: %v", name, callData.ID, err)
+			continue
+		}
+		tp.usePromptVariant(variant)
+
+		result, err := tp.TranscribeRecording(callData.RecordingURL, questions, provider)
+		if err != nil {
+			log.Printf("Error running shadow variant %s for %s: %v", name, callData.ID, err)
+			continue
+		}
+		answers, _ := validateEnumAnswers(questions, result.Answers)
+		answers, _ = applyQuestionConditions(questions, answers)
+
+		if err := tp.SavePromptVariantResult(tp.repo, callData.ID, callData.CampaignID, variant, answers, tp.usage, true); err != nil {
+			log.Printf("Error saving shadow variant %s for %s: %v", name, callData.ID, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import "fmt"
+
+// fewShotMaxBytes is the prompt space few-shot examples may take (FEW_SHOT_MAX_BYTES)
+func fewShotMaxBytes() int {
+	return envLimit("FEW_SHOT_MAX_BYTES", defaultFewShotMaxBytes)
+}
+
+// GetQuestionExamplesForCampaign retrieves the campaign's active few-shot examples by question ID,
+// highest priority first
+func (tp *TranscriptionPipeline) GetQuestionExamplesForCampaign(campaignID string) (map[string][]QuestionExample, error) {
+	query := fmt.Sprintf(`
+		SELECT "questionId"::text, excerpt, answer, COALESCE(explanation, '')
+		FROM %s
+		WHERE "isActive" = true AND "campaignId" = $1
+		ORDER BY priority DESC, "createdAt", id
+	`, tp.schema.Table("campaign_question_example"))
+
+	rows, err := tp.repo.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching question examples for campaign: %v", err)
+	}
+	defer rows.Close()
+
+	examples := make(map[string][]QuestionExample)
+	for rows.Next() {
+		var questionID string
+		var e QuestionExample
+		if err := rows.Scan(&questionID, &e.Excerpt, &e.Answer, &e.Explanation); err != nil {
+			return nil, fmt.Errorf("error scanning question example row: %v", err)
+		}
+		examples[questionID] = append(examples[questionID], e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating question examples: %v", err)
+	}
+
+	return examples, nil
+}
+
+// buildExamplesPrompt renders the questions' few-shot examples for the prompt within maxBytes
+func buildExamplesPrompt(questions []Question, maxBytes int) (text string, included, omitted int) {
+	examples := make([][]QuestionExample, len(questions))
+	for i, q := range questions {
+		examples[i] = q.Examples
+	}
+	return renderExamplesPrompt(examples, maxBytes)
+}
+
+// examplesPrompt returns the few-shot examples section of the current call's prompt and records
+// how many examples it holds in the processing metadata
+func (tp *TranscriptionPipeline) examplesPrompt(questions []Question) string {
+	text, included, omitted := buildExamplesPrompt(questions, fewShotMaxBytes())
+	tp.metadata.FewShotExamples, tp.metadata.FewShotExamplesOmitted = included, omitted
+	return text
+}
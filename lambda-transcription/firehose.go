@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// AnalysisStreamRecord is the record streamed to Firehose for each completed analysis: the stored
+// callAnalysis plus the keys needed to join it back to the call
+type AnalysisStreamRecord struct {
+	CallLogsID   string `json:"call_logsId"`
+	CampaignID   string `json:"campaignId"`
+	CampaignName string `json:"campaign_name,omitempty"`
+	AgentName    string `json:"agent_name,omitempty"`
+	StartDate    string `json:"start_date,omitempty"`
+	CallAnalysisData
+}
+
+// StreamAnalysis sends the completed analysis to the Firehose delivery stream named by
+// FIREHOSE_DELIVERY_STREAM, if configured. Records are newline-terminated JSON so the
+// stream's S3/Redshift destination can load them without a record separator. Streaming is
+// best-effort: failures are logged and don't affect the call.
+func (tp *TranscriptionPipeline) StreamAnalysis(callData *CallData, analysis CallAnalysisData) {
+	stream := os.Getenv("FIREHOSE_DELIVERY_STREAM")
+	if stream == "" {
+		return
+	}
+
+	// Word timings can push long calls past Firehose's 1,000 KiB record limit
+	analysis.Words = nil
+
+	record := AnalysisStreamRecord{
+		CallLogsID:       callData.ID,
+		CampaignID:       callData.CampaignID,
+		CampaignName:     callData.CampaignName,
+		AgentName:        callData.AgentName,
+		StartDate:        callData.StartDate,
+		CallAnalysisData: analysis,
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("Error marshaling analysis record for %s: %v", callData.ID, err)
+		return
+	}
+	data = append(data, '\n')
+
+	payload := map[string]interface{}{
+		"DeliveryStreamName": stream,
+		"Record":             map[string]string{"Data": base64.StdEncoding.EncodeToString(data)},
+	}
+	if err := callAWSJSON("firehose", "firehose", "Firehose_20150804.PutRecord", "1.1", payload, nil); err != nil {
+		log.Printf("Error streaming analysis for %s to Firehose: %v", callData.ID, err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Follow-up task owners: who committed to doing it
+const (
+	FollowUpOwnerAgent    = "agent"
+	FollowUpOwnerCustomer = "customer"
+)
+
+// FollowUpTask is a commitment made on the call, e.g. "send the catalogue on WhatsApp by tomorrow"
+type FollowUpTask struct {
+	Owner string `json:"owner"`
+	// Assignee is the agent's name for agent tasks
+	Assignee string `json:"assignee,omitempty"`
+	Task     string `json:"task"`
+	// DueDate (YYYY-MM-DD) and DueTime (HH:MM) are only set when a deadline was stated or implied
+	DueDate   string   `json:"due_date,omitempty"`
+	DueTime   string   `json:"due_time,omitempty"`
+	Quote     string   `json:"quote,omitempty"`
+	Start     *float64 `json:"start,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+}
+
+// FollowUps represents the follow-up section of the call analysis
+type FollowUps struct {
+	Tasks []FollowUpTask `json:"tasks"`
+	Error string         `json:"error,omitempty"`
+}
+
+// FollowUpWebhookPayload is posted to FOLLOWUP_WEBHOOK_URL for calls with follow-up tasks
+type FollowUpWebhookPayload struct {
+	CallLogsID   string         `json:"call_logsId"`
+	CampaignID   string         `json:"campaignId,omitempty"`
+	CampaignName string         `json:"campaignName,omitempty"`
+	AgentName    string         `json:"agentName,omitempty"`
+	CallerNumber string         `json:"callerNumber,omitempty"`
+	Tasks        []FollowUpTask `json:"tasks"`
+}
+
+// GenerateFollowUps asks the model for the commitments made on the call, with the due date and
+// time inferred from the conversation, and locates each commitment in the transcription's segments.
+// A generation failure is recorded in the result.
+func (tp *TranscriptionPipeline) GenerateFollowUps(transcription string, callData *CallData, segments []TranscriptSegment) *FollowUps {
+	prompt := fmt.Sprintf(`
+List the follow-up tasks committed to on the following call: things the agent or the customer said they would do after the call
+(e.g. send a quotation, call back, deliver an order, make a payment, share documents). The call may be in Hindi, English or a mix.
+The call took place on %s; resolve relative deadlines ("kal", "Monday tak", "do ghante mein") against it.
+
+For each task give:
+- owner: "agent" or "customer", whoever committed to it
+- task: a short imperative description in English, e.g. "Send the product catalogue on WhatsApp"
+- due_date (YYYY-MM-DD) and due_time (HH:MM, 24-hour), only if a deadline was stated or clearly implied
+- quote: the verbatim commitment from the transcription
+
+Only include actual commitments, not possibilities that were discussed and declined.
+
+TRANSCRIPTION:
+%s
+
+Respond with a JSON array only (empty if there are none), in this format:
+[{"owner": "agent", "task": "Call the customer back", "due_date": "2025-10-01", "due_time": "17:00", "quote": "main aapko kal shaam 5 baje call karta hoon"}]
+`, callReferenceDate(callData.StartDate), transcription)
+
+	followUps := &FollowUps{Tasks: []FollowUpTask{}}
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		followUps.Error = fmt.Sprintf("error generating follow-up tasks: %v", err)
+		return followUps
+	}
+
+	var tasks []FollowUpTask
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &tasks); err != nil {
+		followUps.Error = fmt.Sprintf("error parsing follow-up tasks: %v", err)
+		return followUps
+	}
+
+	for _, task := range tasks {
+		task.Owner = strings.ToLower(strings.TrimSpace(task.Owner))
+		task.Task = strings.TrimSpace(task.Task)
+		if task.Task == "" || (task.Owner != FollowUpOwnerAgent && task.Owner != FollowUpOwnerCustomer) {
+			continue
+		}
+		task.DueDate, task.DueTime = normalizeDate(task.DueDate), normalizeClockTime(task.DueTime)
+		if task.DueDate == "" {
+			// A time alone isn't a deadline
+			task.DueTime = ""
+		}
+		task.Quote = strings.TrimSpace(task.Quote)
+		task.Assignee, task.Start, task.Timestamp = "", nil, ""
+		if task.Owner == FollowUpOwnerAgent {
+			task.Assignee = callData.AgentName
+		}
+
+		speaker := SpeakerCustomer
+		if task.Owner == FollowUpOwnerAgent {
+			speaker = SpeakerAgent
+		}
+		if task.Quote != "" {
+			if segment, ok := findQuoteSegment(segments, speaker, task.Quote); ok {
+				start := segment.Start
+				task.Start = &start
+				task.Timestamp = formatTimestamp(start)
+			}
+		}
+
+		followUps.Tasks = append(followUps.Tasks, task)
+	}
+
+	return followUps
+}
+
+// SaveCallFollowUps replaces the call's rows in call_followups with the given tasks, in the analysis
+// transaction
+func (tp *TranscriptionPipeline) SaveCallFollowUps(tx *Tx, callLogsID, campaignID string, tasks []FollowUpTask) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, tp.schema.Table("call_followups"))
+	if _, err := tx.Exec(deleteQuery, callLogsID); err != nil {
+		return fmt.Errorf("error clearing follow-up tasks: %v", err)
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", position, "campaignId", owner, assignee, task, "dueDate", "dueTime", quote, "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')::date, NULLIF($8, '')::time, $9, now())
+	`, tp.schema.Table("call_followups"))
+
+	for i, task := range tasks {
+		if _, err := tx.Exec(insertQuery, callLogsID, i+1, campaignID, task.Owner, task.Assignee, task.Task,
+			task.DueDate, task.DueTime, task.Quote); err != nil {
+			return fmt.Errorf("error saving follow-up task: %v", err)
+		}
+	}
+	return nil
+}
+
+// PushFollowUps posts the call's follow-up tasks to the task system webhook in FOLLOWUP_WEBHOOK_URL.
+// With FOLLOWUP_WEBHOOK_SECRET set, the body is signed in the X-Signature header as
+// hex(HMAC-SHA256(secret, body)). Pushing is best-effort: failures are logged and don't affect the call.
+func (tp *TranscriptionPipeline) PushFollowUps(callData *CallData, tasks []FollowUpTask) {
+	webhookURL := os.Getenv("FOLLOWUP_WEBHOOK_URL")
+	if webhookURL == "" || len(tasks) == 0 {
+		return
+	}
+
+	body, err := json.Marshal(FollowUpWebhookPayload{
+		CallLogsID:   callData.ID,
+		CampaignID:   callData.CampaignID,
+		CampaignName: callData.CampaignName,
+		AgentName:    callData.AgentName,
+		CallerNumber: callData.CallerIDNumber,
+		Tasks:        tasks,
+	})
+	if err != nil {
+		log.Printf("Error marshaling follow-up tasks for %s: %v", callData.ID, err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error creating follow-up webhook request for %s: %v", callData.ID, err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret := os.Getenv("FOLLOWUP_WEBHOOK_SECRET"); secret != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		log.Printf("Error posting follow-up tasks for %s: %v", callData.ID, err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(resp.Body)
+		log.Printf("Follow-up webhook error for %s: status %d, body: %s", callData.ID, resp.StatusCode, string(respBody))
+	}
+}
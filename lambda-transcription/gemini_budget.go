@@ -0,0 +1,374 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Request strategies recorded in the analysis's request_budget
+const (
+	// RequestStrategySingle sends the recording in one request
+	RequestStrategySingle = "single"
+	// RequestStrategyChunked splits the recording and transcribes the chunks separately
+	RequestStrategyChunked = "chunked"
+	// RequestStrategyTruncated drops the middle of the transcription from a text prompt
+	RequestStrategyTruncated = "truncated"
+)
+
+const (
+	// Gemini's documented limits: inline requests up to 20 MB, 1M input tokens and 64K output tokens
+	defaultGeminiMaxRequestBytes = 20 * 1024 * 1024
+	defaultGeminiMaxInputTokens  = 1048576
+	defaultGeminiMaxOutputTokens = 65536
+	// requestBudgetHeadroom is the share of each limit requests are planned against, since the
+	// token counts are estimates
+	requestBudgetHeadroom = 0.9
+
+	// audioTokensPerSecond is what Gemini bills for a second of audio input
+	audioTokensPerSecond = 32
+	// transcriptTokensPerSecond estimates the diarized transcription's output per second of audio,
+	// generous enough for Devanagari, which tokenizes poorly
+	transcriptTokensPerSecond = 10
+	// answerTokens estimates the output of one answer
+	answerTokens = 150
+	// requestOverheadBytes covers the request's JSON around the prompt and audio
+	requestOverheadBytes = 4096
+	// assumedBitrate estimates the duration of recordings whose format doesn't state one; it's low,
+	// so the duration errs long
+	assumedBitrate = 16000
+
+	// chunkBoundaryWindow is how far from a chunk boundary the cut may move to land in a pause
+	chunkBoundaryWindow = 5.0
+	// chunkBoundaryFrame is the length of the frames compared when looking for the pause
+	chunkBoundaryFrame = 0.05
+)
+
+// RequestBudget records the size estimate of a call's Gemini requests and how they were fitted
+// within the model's limits
+type RequestBudget struct {
+	Strategy              string  `json:"strategy"`
+	AudioSeconds          float64 `json:"audio_seconds,omitempty"`
+	EstimatedRequestBytes int     `json:"estimated_request_bytes,omitempty"`
+	EstimatedInputTokens  int     `json:"estimated_input_tokens,omitempty"`
+	EstimatedOutputTokens int     `json:"estimated_output_tokens,omitempty"`
+	MaxOutputTokens       int     `json:"max_output_tokens,omitempty"`
+	// Limit is the limit that forced chunking: request_bytes, input_tokens or output_tokens
+	Limit  string `json:"limit,omitempty"`
+	Chunks int    `json:"chunks,omitempty"`
+	// TruncatedCharacters is how much of the transcription was left out of the question prompt
+	TruncatedCharacters int `json:"truncated_characters,omitempty"`
+}
+
+// envLimit reads a positive integer limit from the environment
+func envLimit(name string, fallback int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// geminiMaxRequestBytes is the largest request sent to Gemini (GEMINI_MAX_REQUEST_BYTES)
+func geminiMaxRequestBytes() int {
+	return envLimit("GEMINI_MAX_REQUEST_BYTES", defaultGeminiMaxRequestBytes)
+}
+
+// geminiMaxInputTokens is the model's input token limit (GEMINI_MAX_INPUT_TOKENS)
+func geminiMaxInputTokens() int {
+	return envLimit("GEMINI_MAX_INPUT_TOKENS", defaultGeminiMaxInputTokens)
+}
+
+// geminiMaxOutputTokens is the model's output token limit (GEMINI_MAX_OUTPUT_TOKENS), which audio
+// requests ask for in full so long transcriptions aren't cut off
+func geminiMaxOutputTokens() int {
+	return envLimit("GEMINI_MAX_OUTPUT_TOKENS", defaultGeminiMaxOutputTokens)
+}
+
+// usableLimit is the part of a limit requests are planned against
+func usableLimit(limit int) int {
+	return int(float64(limit) * requestBudgetHeadroom)
+}
+
+// estimateTokens estimates the tokens of a prompt of the given length in bytes. A token per three
+// bytes overestimates English and roughly matches Devanagari.
+func estimateTokens(length int) int {
+	return (length + 2) / 3
+}
+
+// planAudioRequest estimates the single request that would transcribe the recording and answer the
+// questions, and switches to chunking when it would exceed the request size or either token limit
+func (tp *TranscriptionPipeline) planAudioRequest(audioContent []byte, questions []Question) *RequestBudget {
+	questionsText, constraintsText, _ := buildQuestionsPrompt(questions, tp.outputLanguage)
+	promptLength := len(diarizationInstructions) + len(questionsText) + len(constraintsText) + len(tp.variantInstructions()) + len(tp.examplesPrompt(questions)) + len(tp.callDetailsPrompt())
+
+	seconds := estimateAudioSeconds(audioContent)
+	budget := &RequestBudget{
+		Strategy:              RequestStrategySingle,
+		AudioSeconds:          math.Round(seconds),
+		EstimatedRequestBytes: base64.StdEncoding.EncodedLen(len(audioContent)) + promptLength + requestOverheadBytes,
+		EstimatedInputTokens:  int(seconds*audioTokensPerSecond) + estimateTokens(promptLength),
+		EstimatedOutputTokens: int(seconds*transcriptTokensPerSecond) + len(questions)*answerTokens,
+		MaxOutputTokens:       geminiMaxOutputTokens(),
+	}
+
+	for _, limit := range []struct {
+		name              string
+		estimate, maximum int
+	}{
+		{"request_bytes", budget.EstimatedRequestBytes, geminiMaxRequestBytes()},
+		{"input_tokens", budget.EstimatedInputTokens, geminiMaxInputTokens()},
+		{"output_tokens", budget.EstimatedOutputTokens, geminiMaxOutputTokens()},
+	} {
+		usable := usableLimit(limit.maximum)
+		if chunks := (limit.estimate + usable - 1) / usable; chunks > 1 && chunks > budget.Chunks {
+			budget.Strategy, budget.Limit, budget.Chunks = RequestStrategyChunked, limit.name, chunks
+		}
+	}
+
+	return budget
+}
+
+// transcribeInChunks transcribes a recording too large for one request in chunks, shifts each
+// chunk's segments by its offset into the recording and answers the questions from the merged
+// transcription. The chunk count is recorded in the budget.
+func (tp *TranscriptionPipeline) transcribeInChunks(audioContent []byte, questions []Question, budget *RequestBudget) (string, map[string]string, error) {
+	maxChunkBytes := usableLimit(geminiMaxRequestBytes())/4*3 - len(diarizationInstructions) - requestOverheadBytes
+	chunks, err := splitAudioChunks(audioContent, budget.Chunks, maxChunkBytes)
+	if err != nil {
+		return "", nil, err
+	}
+	budget.Chunks = len(chunks)
+
+	var segments []TranscriptSegment
+	for i, chunk := range chunks {
+		text, err := tp.TranscribeAudioOnly(chunk.audio)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to transcribe chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+
+		chunkSegments := parseDiarizedTranscript(text)
+		if len(chunkSegments) == 0 && strings.TrimSpace(text) != "" {
+			// Without timestamps the chunk's text is kept as a single turn
+			chunkSegments = []TranscriptSegment{{Speaker: SpeakerUnknown, Text: strings.TrimSpace(text), End: chunk.duration}}
+		}
+		for _, segment := range chunkSegments {
+			segment.Start += chunk.offset
+			segment.End += chunk.offset
+			segments = append(segments, segment)
+		}
+	}
+
+	if len(segments) == 0 {
+		return "", nil, fmt.Errorf("empty transcription received from Gemini API")
+	}
+	transcription := formatTranscriptSegments(segments)
+	tp.metadata.TranscriptionModel = tp.servedModel
+
+	answers := make(map[string]string)
+	if len(questions) > 0 {
+		answers, err = tp.AnswerQuestionsFromTranscript(transcription, questions)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to answer questions: %w", err)
+		}
+	}
+
+	return transcription, answers, nil
+}
+
+// audioChunk is a piece of a recording, offset seconds into it
+type audioChunk struct {
+	audio    []byte
+	offset   float64
+	duration float64
+}
+
+// splitAudioChunks splits the recording into about the given number of chunks of at most maxBytes
+// each, cutting in the quietest moment near each boundary so words aren't split. Chunks
+// are MP3 with ffmpeg and 16-bit WAV without; non-WAV recordings can only be split with ffmpeg.
+func splitAudioChunks(audioContent []byte, chunks, maxBytes int) ([]audioChunk, error) {
+	if err := checkChunkingMemory(len(audioContent), estimateAudioSeconds(audioContent)); err != nil {
+		return nil, err
+	}
+	ffmpeg := ffmpegPath()
+
+	wav, ok := decodeWAV(audioContent)
+	if !ok && ffmpeg != "" {
+		converted, err := ffmpegToWAV(ffmpeg, audioContent)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding recording for chunking: %v", err)
+		}
+		wav, ok = decodeWAV(converted)
+	}
+	if !ok {
+		return nil, fmt.Errorf("recording of %d bytes exceeds the Gemini request limits and can't be split into chunks without ffmpeg", len(audioContent))
+	}
+
+	sampleRate := min(wav.sampleRate, preprocessedSampleRate)
+	samples := downsample(wav.mono(), wav.sampleRate, preprocessedSampleRate)
+
+	bytesPerSecond := sampleRate * 2
+	if ffmpeg != "" {
+		bytesPerSecond = 4000 // ffmpegBitrate
+	}
+	maxSamples := maxBytes / bytesPerSecond * sampleRate
+	if maxSamples <= 0 {
+		return nil, fmt.Errorf("request size limit of %d bytes is too small for audio", maxBytes)
+	}
+	chunkSamples := min(len(samples)/max(chunks, 1)+1, maxSamples)
+	window := int(chunkBoundaryWindow * float64(sampleRate))
+
+	var result []audioChunk
+	for start := 0; start < len(samples); {
+		end := len(samples)
+		if end-start > min(chunkSamples+window, maxSamples) {
+			boundary := start + chunkSamples
+			end = quietestPoint(samples, sampleRate, max(boundary-window, start+1), min(boundary+window, start+maxSamples))
+		}
+
+		audio := encodeWAV(samples[start:end], sampleRate)
+		if ffmpeg != "" {
+			if mp3, err := transcodeWithFFmpeg(ffmpeg, audio, false); err == nil && len(mp3) > 0 {
+				audio = mp3
+			}
+		}
+		result = append(result, audioChunk{
+			audio:    audio,
+			offset:   float64(start) / float64(sampleRate),
+			duration: float64(end-start) / float64(sampleRate),
+		})
+		start = end
+	}
+
+	return result, nil
+}
+
+// quietestPoint returns the middle of the quietest frame between from and to, so a chunk ends in a
+// pause rather than mid-word
+func quietestPoint(samples []float64, sampleRate, from, to int) int {
+	frame := int(chunkBoundaryFrame * float64(sampleRate))
+	if frame <= 0 || from+frame > to {
+		return to
+	}
+
+	best, bestEnergy := to, math.Inf(1)
+	for at := from; at+frame <= to; at += frame {
+		var energy float64
+		for _, s := range samples[at : at+frame] {
+			energy += s * s
+		}
+		if energy < bestEnergy {
+			best, bestEnergy = at+frame/2, energy
+		}
+	}
+	return best
+}
+
+// fitTranscriptionToPrompt truncates the transcription so a prompt with otherLength bytes besides
+// it stays within the input token limit, keeping its beginning and end. It returns the number of
+// characters left out.
+func fitTranscriptionToPrompt(transcription string, otherLength int) (string, int) {
+	available := usableLimit(geminiMaxInputTokens())*3 - otherLength
+	if len(transcription) <= available {
+		return transcription, 0
+	}
+	if available <= 0 {
+		return "", len([]rune(transcription))
+	}
+
+	// Openings and closings carry most of what questions ask about; keep two thirds from the start
+	head := truncateUTF8(transcription, available*2/3)
+	if i := strings.LastIndex(head, "\n"); i > 0 {
+		head = head[:i]
+	}
+	tail := transcription[len(transcription)-available/3:]
+	if i := strings.Index(tail, "\n"); i >= 0 {
+		tail = tail[i+1:]
+	}
+	for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+		tail = tail[1:]
+	}
+
+	omitted := len([]rune(transcription)) - len([]rune(head)) - len([]rune(tail))
+	return fmt.Sprintf("%s\n[... %d characters omitted ...]\n%s", head, omitted, tail), omitted
+}
+
+// recordTruncation notes a truncated transcription in the current call's request budget
+func (tp *TranscriptionPipeline) recordTruncation(omitted int) {
+	if tp.requestBudget == nil {
+		tp.requestBudget = &RequestBudget{Strategy: RequestStrategyTruncated}
+	}
+	tp.requestBudget.TruncatedCharacters += omitted
+}
+
+// estimateAudioSeconds estimates the recording's duration from its header: exactly for WAV, from the
+// first frame's bitrate for MP3 and from assumedBitrate for other formats
+func estimateAudioSeconds(audioContent []byte) float64 {
+	if seconds, ok := wavDuration(audioContent); ok {
+		return seconds
+	}
+	if seconds, ok := mp3Duration(audioContent); ok {
+		return seconds
+	}
+	return float64(len(audioContent)) * 8 / assumedBitrate
+}
+
+// wavDuration reads a WAV's duration from its fmt and data chunk headers without decoding it
+func wavDuration(data []byte) (float64, bool) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return 0, false
+	}
+
+	byteRate := 0
+	for offset := 12; offset+8 <= len(data); {
+		id := string(data[offset : offset+4])
+		size := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		switch {
+		case id == "fmt " && offset+20 <= len(data):
+			byteRate = int(binary.LittleEndian.Uint32(data[offset+16 : offset+20]))
+		case id == "data" && byteRate > 0:
+			// Streamed WAVs may leave the size unset
+			size = min(size, len(data)-offset-8)
+			return float64(size) / float64(byteRate), true
+		}
+		offset += 8 + size + size%2
+	}
+	return 0, false
+}
+
+// MP3 layer III bitrates in kbps by bitrate index, for MPEG-1 and for MPEG-2/2.5
+var (
+	mp3BitratesV1 = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mp3BitratesV2 = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+)
+
+// mp3Duration estimates an MP3's duration from the bitrate of its first frame, skipping any ID3v2
+// tag. Variable-bitrate files are estimated at their first frame's bitrate.
+func mp3Duration(data []byte) (float64, bool) {
+	offset := 0
+	if len(data) >= 10 && string(data[0:3]) == "ID3" {
+		// The tag size is syncsafe: 7 bits per byte
+		offset = 10 + (int(data[6])<<21 | int(data[7])<<14 | int(data[8])<<7 | int(data[9]))
+	}
+
+	// Look for the first frame header within the next few KB
+	for limit := min(len(data)-4, offset+8192); offset <= limit && offset >= 0; offset++ {
+		if data[offset] != 0xFF || data[offset+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version, layer, bitrateIndex := (data[offset+1]>>3)&0x03, (data[offset+1]>>1)&0x03, int(data[offset+2]>>4)
+		if layer != 0x01 || version == 0x01 || bitrateIndex == 0 || bitrateIndex == 0x0F {
+			continue
+		}
+		kbps := mp3BitratesV2[bitrateIndex]
+		if version == 0x03 {
+			kbps = mp3BitratesV1[bitrateIndex]
+		}
+		return float64(len(data)-offset) * 8 / float64(kbps*1000), true
+	}
+	return 0, false
+}
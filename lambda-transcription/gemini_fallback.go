@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// GeminiAPIError is a non-200 response from the Gemini API
+type GeminiAPIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *GeminiAPIError) Error() string {
+	return fmt.Sprintf("gemini API error: status %d, body: %s", e.StatusCode, e.Body)
+}
+
+// Is makes rate limited responses match ErrProviderRateLimited
+func (e *GeminiAPIError) Is(target error) bool {
+	return target == ErrProviderRateLimited && e.StatusCode == http.StatusTooManyRequests
+}
+
+// geminiFallbackModels returns the fallback ladder of the current call: the campaign's
+// geminiFallbackModels, or else GEMINI_FALLBACK_MODELS (comma-separated, empty disables fallback)
+func (tp *TranscriptionPipeline) geminiFallbackModels() []string {
+	if tp.fallbackModels != nil {
+		return tp.fallbackModels
+	}
+	var models []string
+	for _, model := range strings.Split(os.Getenv("GEMINI_FALLBACK_MODELS"), ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+// geminiModelLadder returns the models a request is tried on, in order: the current model, then
+// the fallback models after it in the ladder (all of them when it isn't in the ladder). Prompt
+// variants only use their own model, so experiments compare like with like.
+func (tp *TranscriptionPipeline) geminiModelLadder() []string {
+	primary := tp.geminiModel()
+	if tp.variant != nil {
+		return []string{primary}
+	}
+
+	fallbacks := tp.geminiFallbackModels()
+	inLadder := false
+	for i, model := range fallbacks {
+		if model == primary {
+			fallbacks, inLadder = fallbacks[i+1:], true
+			break
+		}
+	}
+	if !inLadder && tp.budgetModel != "" {
+		// A downgraded call mustn't fall back to the pricier models ahead of it
+		fallbacks = nil
+	}
+	return append([]string{primary}, fallbacks...)
+}
+
+// geminiModelUnavailable reports whether a request failed because the model was unavailable (rate
+// limited, overloaded, timed out, or its breaker or quota bucket is exhausted), so another model
+// may succeed. Blocks and bad requests would fail on any model.
+func geminiModelUnavailable(err error) bool {
+	var apiErr *GeminiAPIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrGeminiQuotaExhausted) ||
+		errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// generateContentWithFallback sends the request down the model ladder until a model answers,
+// recording the model that did in servedModel and counting fallbacks in the processing metadata
+func (tp *TranscriptionPipeline) generateContentWithFallback(name string, requestData GeminiRequest, timeout time.Duration) (string, error) {
+	ladder := tp.geminiModelLadder()
+
+	var text string
+	var err error
+	for i, model := range ladder {
+		if i > 0 {
+			log.Printf("Gemini model %s unavailable for %s (%v), falling back to %s", ladder[i-1], name, err, model)
+		}
+		text, err = tp.sendGenerateContent(name, model, requestData, timeout)
+		if err == nil {
+			tp.servedModel = model
+			if i > 0 {
+				tp.metadata.ModelFallbacks++
+			}
+			return text, nil
+		}
+		if !geminiModelUnavailable(err) {
+			break
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// SafetySetting overrides the blocking threshold for a harm category
+type SafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// SafetyRating represents Gemini's assessment of a prompt or candidate for one harm category
+type SafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked,omitempty"`
+}
+
+// PromptFeedback is returned instead of candidates when the prompt itself was blocked
+type PromptFeedback struct {
+	BlockReason   string         `json:"blockReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
+}
+
+// GeminiBlockedError is returned when Gemini refuses to answer because of a safety or recitation block
+type GeminiBlockedError struct {
+	// Stage is "prompt" when the input was blocked and "candidate" when the output was
+	Stage         string
+	Reason        string
+	SafetyRatings []SafetyRating
+}
+
+func (e *GeminiBlockedError) Error() string {
+	var flagged []string
+	for _, rating := range e.SafetyRatings {
+		if rating.Blocked || rating.Probability == "HIGH" || rating.Probability == "MEDIUM" {
+			flagged = append(flagged, fmt.Sprintf("%s=%s", rating.Category, rating.Probability))
+		}
+	}
+	message := fmt.Sprintf("gemini blocked the %s (%s)", e.Stage, e.Reason)
+	if len(flagged) > 0 {
+		message += ": " + strings.Join(flagged, ", ")
+	}
+	return message
+}
+
+// SafetyBlock reports whether the block came from the safety filters, which relaxed settings can lift
+func (e *GeminiBlockedError) SafetyBlock() bool {
+	return e.Reason == "SAFETY"
+}
+
+// blockingFinishReasons are candidate finish reasons that mean the output was withheld
+var blockingFinishReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+}
+
+// relaxedSafetySettings turns off blocking for the adjustable harm categories. Call recordings
+// routinely contain heated or explicit language that trips the default thresholds.
+func relaxedSafetySettings() []SafetySetting {
+	categories := []string{
+		"HARM_CATEGORY_HARASSMENT",
+		"HARM_CATEGORY_HATE_SPEECH",
+		"HARM_CATEGORY_SEXUALLY_EXPLICIT",
+		"HARM_CATEGORY_DANGEROUS_CONTENT",
+	}
+	settings := make([]SafetySetting, len(categories))
+	for i, category := range categories {
+		settings[i] = SafetySetting{Category: category, Threshold: "BLOCK_NONE"}
+	}
+	return settings
+}
+
+// geminiSafetyRetryEnabled reports whether safety-blocked requests are retried with relaxed settings
+// (on by default; GEMINI_SAFETY_RETRY=false disables it)
+func geminiSafetyRetryEnabled() bool {
+	return os.Getenv("GEMINI_SAFETY_RETRY") != "false"
+}
+
+// geminiResponseText returns the text of the first candidate, or a GeminiBlockedError when the
+// prompt or the candidate was blocked
+func geminiResponseText(geminiResp GeminiResponse) (string, error) {
+	if feedback := geminiResp.PromptFeedback; feedback != nil && feedback.BlockReason != "" {
+		return "", &GeminiBlockedError{Stage: "prompt", Reason: feedback.BlockReason, SafetyRatings: feedback.SafetyRatings}
+	}
+
+	if len(geminiResp.Candidates) == 0 {
+		return "", fmt.Errorf("no response generated from Gemini API")
+	}
+
+	candidate := geminiResp.Candidates[0]
+	if blockingFinishReasons[candidate.FinishReason] {
+		return "", &GeminiBlockedError{Stage: "candidate", Reason: candidate.FinishReason, SafetyRatings: candidate.SafetyRatings}
+	}
+
+	if len(candidate.Content.Parts) == 0 {
+		if candidate.FinishReason != "" && candidate.FinishReason != "STOP" {
+			return "", fmt.Errorf("no content parts in Gemini response (finish reason %s)", candidate.FinishReason)
+		}
+		return "", fmt.Errorf("no content parts in Gemini response")
+	}
+
+	return candidate.Content.Parts[0].Text, nil
+}
+
+// geminiBlockFallbackProvider returns the provider used when Gemini blocks a recording
+// (GEMINI_BLOCK_FALLBACK_PROVIDER, e.g. "openai" or "deepgram"), or "" for no fallback
+func geminiBlockFallbackProvider() string {
+	provider := strings.ToLower(os.Getenv("GEMINI_BLOCK_FALLBACK_PROVIDER"))
+	if provider == ProviderGemini {
+		return ""
+	}
+	return provider
+}
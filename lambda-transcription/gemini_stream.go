@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// streamBufferSize is the write buffer between the base64 encoder and the request body pipe
+const streamBufferSize = 32 * 1024
+
+// audioInlineData is an inline audio part whose bytes are base64-encoded as the request is sent,
+// instead of being held as a base64 string
+func audioInlineData(audioContent []byte) *InlineData {
+	return &InlineData{MimeType: audioMimeType(audioContent), audio: audioContent}
+}
+
+// encodedLen is the length of the part's base64 data
+func (d *InlineData) encodedLen() int {
+	if d.audio != nil {
+		return base64.StdEncoding.EncodedLen(len(d.audio))
+	}
+	return len(d.Data)
+}
+
+// geminiRequestBody is a Gemini request's JSON with its inline audio cut out: segments[i] comes
+// before audio[i], and the last segment after the last audio. The audio is base64-encoded straight
+// into the request body as it is sent, so a request holds the recording once rather than also as a
+// base64 string and again inside the JSON buffer.
+type geminiRequestBody struct {
+	segments [][]byte
+	audio    [][]byte
+}
+
+// jsonRequestBody is the body of a request without inline audio
+func jsonRequestBody(data []byte) *geminiRequestBody {
+	return &geminiRequestBody{segments: [][]byte{data}}
+}
+
+// encodeGeminiRequest marshals the request with a placeholder for each inline audio part and cuts
+// the JSON at the placeholders
+func encodeGeminiRequest(requestData GeminiRequest) (*geminiRequestBody, error) {
+	body := &geminiRequestBody{}
+
+	var placeholders []string
+	withPlaceholders := requestData
+	withPlaceholders.Contents = make([]Content, len(requestData.Contents))
+	for i, content := range requestData.Contents {
+		parts := make([]Part, len(content.Parts))
+		for j, part := range content.Parts {
+			parts[j] = part
+			if part.InlineData != nil && part.InlineData.audio != nil {
+				placeholder := fmt.Sprintf("inline-audio-%d-%d-%d", i, j, len(part.InlineData.audio))
+				parts[j].InlineData = &InlineData{MimeType: part.InlineData.MimeType, Data: placeholder}
+				placeholders = append(placeholders, placeholder)
+				body.audio = append(body.audio, part.InlineData.audio)
+			}
+		}
+		withPlaceholders.Contents[i] = Content{Parts: parts}
+	}
+
+	data, err := json.Marshal(withPlaceholders)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, placeholder := range placeholders {
+		// The prompt could contain the placeholder text only by a remarkable coincidence; refuse rather than guess
+		if bytes.Count(data, []byte(placeholder)) != 1 {
+			return nil, fmt.Errorf("request contains the inline audio placeholder %s", placeholder)
+		}
+		before, after, _ := bytes.Cut(data, []byte(placeholder))
+		body.segments = append(body.segments, before)
+		data = after
+	}
+	body.segments = append(body.segments, data)
+	return body, nil
+}
+
+// Len is the length of the request body as sent
+func (b *geminiRequestBody) Len() int {
+	length := 0
+	for _, segment := range b.segments {
+		length += len(segment)
+	}
+	for _, audio := range b.audio {
+		length += base64.StdEncoding.EncodedLen(len(audio))
+	}
+	return length
+}
+
+// Reader streams the request body, encoding the audio as it is read. Each call starts over, so a
+// request can be sent again.
+func (b *geminiRequestBody) Reader() io.ReadCloser {
+	if len(b.audio) == 0 {
+		return io.NopCloser(bytes.NewReader(b.segments[0]))
+	}
+	return &streamingBody{body: b}
+}
+
+// streamingBody encodes the body into a pipe once it is first read, so a request that is never
+// sent (e.g. refused by the circuit breaker) doesn't leave the encoder blocked on the pipe
+type streamingBody struct {
+	body   *geminiRequestBody
+	reader *io.PipeReader
+}
+
+func (s *streamingBody) Read(p []byte) (int, error) {
+	if s.reader == nil {
+		reader, writer := io.Pipe()
+		go func() {
+			// Closing the reader, e.g. when the request fails, ends the write with an error
+			writer.CloseWithError(s.body.writeTo(writer))
+		}()
+		s.reader = reader
+	}
+	return s.reader.Read(p)
+}
+
+func (s *streamingBody) Close() error {
+	if s.reader == nil {
+		return nil
+	}
+	return s.reader.Close()
+}
+
+// newStreamingRequest creates a POST of the body, which is streamed as it is sent. The length is
+// known up front, so the body goes with a Content-Length rather than chunked.
+func newStreamingRequest(endpoint string, body *geminiRequestBody) (*http.Request, error) {
+	req, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = body.Reader()
+	req.ContentLength = int64(body.Len())
+	req.GetBody = func() (io.ReadCloser, error) { return body.Reader(), nil }
+	return req, nil
+}
+
+// writeTo writes the body to w
+func (b *geminiRequestBody) writeTo(w io.Writer) error {
+	buffered := bufio.NewWriterSize(w, streamBufferSize)
+	for i, segment := range b.segments {
+		if _, err := buffered.Write(segment); err != nil {
+			return err
+		}
+		if i >= len(b.audio) {
+			continue
+		}
+		encoder := base64.NewEncoder(base64.StdEncoding, buffered)
+		if _, err := encoder.Write(b.audio[i]); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	}
+	return buffered.Flush()
+}
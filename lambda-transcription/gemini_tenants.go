@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// geminiTenantKeysTTL is how long the tenant API keys are cached across warm invocations
+const geminiTenantKeysTTL = 5 * time.Minute
+
+// GeminiTenantKey is a tenant's Gemini API key, from the GEMINI_TENANT_KEYS_SECRET_ID secret (a JSON
+// object keyed by tenant name). On Vertex AI, VertexProject bills the tenant's requests to its own
+// project instead. QuotaPerMinute and QuotaBurst override GEMINI_QUOTA_PER_MINUTE and
+// GEMINI_QUOTA_BURST for the tenant's quota bucket, e.g. to match the limits of its own project.
+type GeminiTenantKey struct {
+	APIKey         string  `json:"apiKey,omitempty"`
+	VertexProject  string  `json:"vertexProject,omitempty"`
+	QuotaPerMinute float64 `json:"quotaPerMinute,omitempty"`
+	QuotaBurst     float64 `json:"quotaBurst,omitempty"`
+}
+
+// geminiTenant is the tenant whose key the current call's Gemini requests use
+type geminiTenant struct {
+	name string
+	key  GeminiTenantKey
+}
+
+var (
+	geminiTenantKeysMu       sync.Mutex
+	geminiTenantKeys         map[string]GeminiTenantKey
+	geminiTenantKeysLoadedAt time.Time
+
+	// geminiBreakers are keyed by tenant and model, so one tenant's failing key or an overloaded
+	// model doesn't open the breaker for the others
+	geminiBreakersMu sync.Mutex
+	geminiBreakers   = map[string]*CircuitBreaker{}
+)
+
+// loadGeminiTenantKey returns the tenant's key, refreshing the secret after geminiTenantKeysTTL
+func loadGeminiTenantKey(tenant string) (*GeminiTenantKey, error) {
+	secretID := os.Getenv("GEMINI_TENANT_KEYS_SECRET_ID")
+	if secretID == "" {
+		return nil, fmt.Errorf("campaign uses Gemini tenant %s but GEMINI_TENANT_KEYS_SECRET_ID is not set", tenant)
+	}
+
+	geminiTenantKeysMu.Lock()
+	defer geminiTenantKeysMu.Unlock()
+
+	if err := refreshGeminiTenantKeys(secretID); err != nil {
+		return nil, err
+	}
+
+	// Vertex AI authenticates with the service account, so tenants there need no API key
+	key, ok := geminiTenantKeys[tenant]
+	if !ok || (key.APIKey == "" && geminiBackend() != GeminiBackendVertex) {
+		return nil, fmt.Errorf("no Gemini API key for tenant %s", tenant)
+	}
+	return &key, nil
+}
+
+// refreshGeminiTenantKeys reloads the tenant keys secret once geminiTenantKeysTTL has passed; the
+// caller holds geminiTenantKeysMu
+func refreshGeminiTenantKeys(secretID string) error {
+	if geminiTenantKeys != nil && time.Since(geminiTenantKeysLoadedAt) < geminiTenantKeysTTL {
+		return nil
+	}
+
+	secretString, err := getSecretString(secretID)
+	if err != nil {
+		return fmt.Errorf("error loading Gemini tenant keys: %v", err)
+	}
+	var tenants map[string]GeminiTenantKey
+	if err := json.Unmarshal([]byte(secretString), &tenants); err != nil {
+		return fmt.Errorf("error parsing Gemini tenant keys: %v", err)
+	}
+	geminiTenantKeys = tenants
+	geminiTenantKeysLoadedAt = time.Now()
+	return nil
+}
+
+// useGeminiTenant routes the current call's Gemini requests through the tenant's key, quota and
+// circuit breaker; an empty tenant uses GEMINI_API_KEY and the shared quota
+func (tp *TranscriptionPipeline) useGeminiTenant(tenant string) error {
+	tp.geminiTenant = nil
+	tp.metadata.GeminiTenant = ""
+	if tenant == "" {
+		return nil
+	}
+
+	key, err := loadGeminiTenantKey(tenant)
+	if err != nil {
+		return err
+	}
+	tp.geminiTenant = &geminiTenant{name: tenant, key: *key}
+	tp.metadata.GeminiTenant = tenant
+	return nil
+}
+
+// currentGeminiAPIKey returns the API key of the current call's Gemini requests
+func (tp *TranscriptionPipeline) currentGeminiAPIKey() string {
+	if tp.geminiTenant != nil {
+		return tp.geminiTenant.key.APIKey
+	}
+	return tp.geminiAPIKey
+}
+
+// geminiQuotaBucket returns the quota bucket and limits of the current call's requests to the model.
+// Tenants get buckets of their own, so one customer's traffic doesn't use up another's quota.
+func (tp *TranscriptionPipeline) geminiQuotaBucket(model string) (string, GeminiQuota) {
+	quota := geminiQuotaFromEnv()
+	if tp.geminiTenant == nil {
+		return "gemini:" + model, quota
+	}
+
+	key := tp.geminiTenant.key
+	if key.QuotaPerMinute > 0 {
+		quota.PerMinute, quota.Burst = key.QuotaPerMinute, key.QuotaPerMinute
+	}
+	if key.QuotaBurst >= 1 {
+		quota.Burst = key.QuotaBurst
+	}
+	return "gemini:" + tp.geminiTenant.name + ":" + model, quota
+}
+
+// currentGeminiBreaker returns the circuit breaker of the current call's Gemini key and the model
+func (tp *TranscriptionPipeline) currentGeminiBreaker(model string) *CircuitBreaker {
+	name := "gemini:" + model
+	if tp.geminiTenant != nil {
+		name = "gemini:" + tp.geminiTenant.name + ":" + model
+	}
+
+	geminiBreakersMu.Lock()
+	defer geminiBreakersMu.Unlock()
+
+	breaker, ok := geminiBreakers[name]
+	if !ok {
+		breaker = NewCircuitBreaker(name, breakerFailureThreshold(), breakerOpenDuration())
+		geminiBreakers[name] = breaker
+	}
+	return breaker
+}
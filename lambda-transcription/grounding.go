@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnswerNotFoundInCall replaces answers the transcription gives no evidence for
+const AnswerNotFoundInCall = "not found in call"
+
+// AnswerEvidence is the transcript evidence cited for an answer. Quote is verbatim from the
+// transcription; Timestamp is the model's approximate time, replaced by the start of the segment
+// the quote was found in when it could be located.
+type AnswerEvidence struct {
+	Quote     string   `json:"quote,omitempty"`
+	Start     *float64 `json:"start,omitempty"`
+	Timestamp string   `json:"timestamp,omitempty"`
+	Grounded  bool     `json:"grounded"`
+}
+
+// AnswerGrounding represents the grounding section of the call analysis. Rejected lists the
+// questions whose answers were replaced with "not found in call".
+type AnswerGrounding struct {
+	Evidence map[string]AnswerEvidence `json:"evidence"`
+	Rejected []string                  `json:"rejected,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+// groundingCitation is one entry of the model's verification response
+type groundingCitation struct {
+	Answer    int    `json:"answer"`
+	Quote     string `json:"quote"`
+	Timestamp string `json:"timestamp"`
+}
+
+// GroundAnswers asks the model to cite a verbatim quote and approximate timestamp from the
+// transcription for each answer, then checks that each quote really occurs in it. Answers without
+// a quote that can be found are replaced with "not found in call" and dropped from enumAnswers.
+// Answers of "no", "false" or NOT_DISCUSSED need no evidence, since the transcription can only show
+// what was said. A verification failure keeps the answers and is recorded in the result.
+func (tp *TranscriptionPipeline) GroundAnswers(transcription string, questions []Question, answers map[string]string, enumAnswers map[string]EnumAnswer, segments []TranscriptSegment) *AnswerGrounding {
+	grounding := &AnswerGrounding{Evidence: map[string]AnswerEvidence{}}
+
+	var answered []Question
+	for _, q := range questions {
+		if answer, ok := answers[q.ID]; ok && !answerNeedsNoEvidence(answer) {
+			answered = append(answered, q)
+		}
+	}
+	if len(answered) == 0 {
+		return grounding
+	}
+
+	var answersText strings.Builder
+	for i, q := range answered {
+		fmt.Fprintf(&answersText, "%d. Question: %s\n   Answer: %s\n", i+1, q.QuestionText, answers[q.ID])
+	}
+
+	prompt := fmt.Sprintf(`
+The following answers were given about a call from its transcription. The call may be in Hindi, English or a mix.
+For each answer, cite the evidence for it: a short verbatim quote from the transcription, copied exactly as written,
+and the approximate timestamp (MM:SS) it was said at. If the transcription doesn't support the answer, give an empty quote.
+Don't paraphrase, translate or combine separate lines into one quote.
+
+TRANSCRIPTION:
+%s
+
+ANSWERS:
+%s
+Respond with a JSON array only, one entry per answer, in this format:
+[{"answer": 1, "quote": "haan main kal payment kar dunga", "timestamp": "01:23"}]
+`, transcription, answersText.String())
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		grounding.Error = fmt.Sprintf("error verifying answers: %v", err)
+		return grounding
+	}
+
+	var citations []groundingCitation
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &citations); err != nil {
+		grounding.Error = fmt.Sprintf("error parsing answer evidence: %v", err)
+		return grounding
+	}
+
+	cited := make(map[int]groundingCitation, len(citations))
+	for _, citation := range citations {
+		cited[citation.Answer] = citation
+	}
+
+	normalizedTranscription := normalizeQuoteText(transcription)
+	for i, q := range answered {
+		citation := cited[i+1]
+		evidence := AnswerEvidence{Quote: strings.TrimSpace(citation.Quote), Timestamp: strings.TrimSpace(citation.Timestamp)}
+
+		if quote := normalizeQuoteText(evidence.Quote); quote != "" && strings.Contains(normalizedTranscription, quote) {
+			evidence.Grounded = true
+			if segment, ok := findQuoteSegment(segments, SpeakerUnknown, evidence.Quote); ok {
+				start := segment.Start
+				evidence.Start = &start
+				evidence.Timestamp = formatTimestamp(start)
+			}
+		}
+
+		if !evidence.Grounded {
+			answers[q.ID] = AnswerNotFoundInCall
+			delete(enumAnswers, q.ID)
+			grounding.Rejected = append(grounding.Rejected, q.ID)
+		}
+		grounding.Evidence[q.ID] = evidence
+	}
+	sort.Strings(grounding.Rejected)
+
+	return grounding
+}
+
+// answerNeedsNoEvidence reports whether an answer is a negative yes/no answer or NOT_DISCUSSED,
+// which are grounded by the absence of evidence rather than a quote
+func answerNeedsNoEvidence(answer string) bool {
+	if answer == AnswerNotDiscussed {
+		return true
+	}
+	switch strings.ToLower(strings.TrimSuffix(strings.TrimSpace(answer), ".")) {
+	case "no", "false":
+		return true
+	}
+	return false
+}
+
+// normalizeQuoteText lowercases text and collapses whitespace, for matching quotes against the transcription
+func normalizeQuoteText(text string) string {
+	return strings.ToLower(strings.Join(strings.Fields(text), " "))
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"lambda-transcription/pipelinepb"
+)
+
+// grpcModeEnabled reports whether the binary should run as a gRPC server for internal services (SERVER_MODE=grpc)
+func grpcModeEnabled() bool {
+	return os.Getenv("SERVER_MODE") == "grpc"
+}
+
+// runGRPCServer serves the CallPipeline service (pipelinepb/pipeline.proto) so internal Go services
+// can process calls directly and follow their progress. Every RPC needs the server's API key.
+func runGRPCServer() error {
+	addr := os.Getenv("GRPC_ADDR")
+	if addr == "" {
+		addr = ":9090"
+	}
+	apiKey, err := serverAPIKey()
+	if err != nil {
+		return err
+	}
+
+	defer closeSharedRepositories()
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("gRPC server error: %v", err)
+	}
+
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := grpcAuthenticate(ctx, apiKey); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := grpcAuthenticate(stream.Context(), apiKey); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	pipelinepb.RegisterCallPipelineServer(server, &grpcPipelineServer{})
+
+	// Drain in-flight calls on SIGTERM so container restarts don't drop work
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+		<-stop
+		log.Printf("Shutting down gRPC server...")
+		server.GracefulStop()
+	}()
+
+	log.Printf("gRPC server listening on %s", addr)
+	if err := server.Serve(listener); err != nil {
+		return fmt.Errorf("gRPC server error: %v", err)
+	}
+	return nil
+}
+
+// grpcAuthenticate checks the x-api-key metadata of an RPC against the server's API key
+func grpcAuthenticate(ctx context.Context, apiKey string) error {
+	var presented string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("x-api-key"); len(values) > 0 {
+			presented = values[0]
+		}
+	}
+	if !validServerAPIKey(apiKey, presented) {
+		return status.Error(codes.Unauthenticated, "missing or invalid x-api-key")
+	}
+	return nil
+}
+
+// grpcPipelineServer implements the CallPipeline service with a new pipeline per request
+type grpcPipelineServer struct {
+	pipelinepb.UnimplementedCallPipelineServer
+}
+
+// ProcessCall processes a call, streaming each stage as it starts and the analysis once it's done
+func (s *grpcPipelineServer) ProcessCall(request *pipelinepb.ProcessCallRequest, stream pipelinepb.CallPipeline_ProcessCallServer) error {
+	if request.GetCallLogsId() == "" {
+		return status.Error(codes.InvalidArgument, "call_logs_id is required")
+	}
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	pipeline.SetDryRun(request.GetDryRun())
+	pipeline.reprocess = request.GetReprocess()
+
+	// Progress is best-effort: a client that stops reading doesn't stop the call being processed.
+	// The outcome is sent below, as the final update or the RPC's status.
+	start := time.Now()
+	pipeline.SetProgress(func(stage string, _ error) {
+		if stage == StageDone || stage == StageFailed {
+			return
+		}
+		if err := stream.Send(&pipelinepb.ProcessCallUpdate{Stage: stage, ElapsedMs: elapsedMs(start)}); err != nil {
+			log.Printf("Error sending progress for %s: %v", request.GetCallLogsId(), err)
+		}
+	})
+
+	result, err := pipeline.ProcessCall(request.GetCallLogsId())
+	if err != nil {
+		return grpcError(err)
+	}
+
+	// Dry runs return the would-be analysis; otherwise it's read back as stored
+	var analysisJSON []byte
+	if request.GetDryRun() {
+		analysisJSON, err = json.Marshal(result["analysis"])
+	} else {
+		analysisJSON, err = s.storedAnalysis(pipeline, request.GetCallLogsId())
+	}
+	if err != nil {
+		return grpcError(err)
+	}
+
+	analysis, err := analysisMessage(request.GetCallLogsId(), analysisJSON)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	return stream.Send(&pipelinepb.ProcessCallUpdate{Stage: StageDone, ElapsedMs: elapsedMs(start), Analysis: analysis})
+}
+
+// GetAnalysis returns a call's stored analysis
+func (s *grpcPipelineServer) GetAnalysis(ctx context.Context, request *pipelinepb.GetAnalysisRequest) (*pipelinepb.Analysis, error) {
+	if request.GetCallLogsId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "call_logs_id is required")
+	}
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	analysisJSON, err := s.storedAnalysis(pipeline, request.GetCallLogsId())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	analysis, err := analysisMessage(request.GetCallLogsId(), analysisJSON)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return analysis, nil
+}
+
+// storedAnalysis reads a call's stored callAnalysis, returning ErrCallNotFound while it hasn't been processed
+func (s *grpcPipelineServer) storedAnalysis(pipeline *TranscriptionPipeline, callLogsID string) ([]byte, error) {
+	if err := pipeline.ConnectToDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer pipeline.CloseDatabase()
+
+	analysis, err := pipeline.GetCallAnalysis(callLogsID)
+	if err != nil {
+		return nil, err
+	}
+	if analysis == nil {
+		return nil, fmt.Errorf("no analysis found for call_logsId %s: %w", callLogsID, ErrCallNotFound)
+	}
+	return analysis, nil
+}
+
+// analysisMessage maps a stored callAnalysis to the Analysis message, keeping the full JSON alongside
+func analysisMessage(callLogsID string, analysisJSON []byte) (*pipelinepb.Analysis, error) {
+	var analysisData CallAnalysisData
+	if err := json.Unmarshal(analysisJSON, &analysisData); err != nil {
+		return nil, fmt.Errorf("error parsing call analysis: %v", err)
+	}
+
+	return &pipelinepb.Analysis{
+		CallLogsId:      callLogsID,
+		Transcription:   analysisData.Transcription,
+		Answers:         analysisData.Answers,
+		Provider:        analysisData.Provider,
+		CallDisposition: analysisData.CallDisposition,
+		SkipReason:      analysisData.SkipReason,
+		PromptVariant:   analysisData.PromptVariant,
+		ProcessedAt:     analysisData.ProcessedAt,
+		AnalysisJson:    analysisJSON,
+	}, nil
+}
+
+// grpcError converts a pipeline error to a gRPC status with the code matching its HTTP status code
+func grpcError(err error) error {
+	code := codes.Internal
+	switch errorStatusCode(err) {
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.AlreadyExists
+	case http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge:
+		code = codes.FailedPrecondition
+	case http.StatusFailedDependency, http.StatusBadGateway, http.StatusServiceUnavailable:
+		code = codes.Unavailable
+	case http.StatusTooManyRequests, http.StatusPaymentRequired:
+		code = codes.ResourceExhausted
+	}
+	return status.Error(code, err.Error())
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxConnsPerHost = 16
+	defaultDownloadTimeout = 2 * time.Minute
+	defaultProviderTimeout = 2 * time.Minute
+	defaultAPITimeout      = 30 * time.Second
+)
+
+// Outbound requests share a transport per destination so connections, TLS sessions and HTTP/2
+// streams are reused across requests and warm invocations. Clients are cheap and are created per
+// stage with that stage's timeout.
+var (
+	downloadTransport sharedTransport // recording downloads
+	geminiTransport   sharedTransport // Gemini generateContent and embeddings
+	apiTransport      sharedTransport // transcription providers, AWS, CRMs and webhooks
+)
+
+// sharedTransport creates its transport on first use, after the environment has been loaded
+type sharedTransport struct {
+	once      sync.Once
+	transport *http.Transport
+}
+
+func (s *sharedTransport) get() *http.Transport {
+	s.once.Do(func() { s.transport = newHTTPTransport() })
+	return s.transport
+}
+
+// newHTTPTransport returns a keep-alive transport that negotiates HTTP/2 where the server supports
+// it, with up to HTTP_MAX_CONNS_PER_HOST connections per host
+func newHTTPTransport() *http.Transport {
+	perHost := envLimit("HTTP_MAX_CONNS_PER_HOST", defaultMaxConnsPerHost)
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   perHost,
+		MaxConnsPerHost:       perHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// stageTimeout reads a stage's timeout in seconds from the environment
+func stageTimeout(name string, fallback time.Duration) time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv(name)); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+// downloadClient downloads recordings (HTTP_DOWNLOAD_TIMEOUT_SECONDS, default 120)
+func downloadClient() *http.Client {
+	return &http.Client{Transport: downloadTransport.get(), Timeout: stageTimeout("HTTP_DOWNLOAD_TIMEOUT_SECONDS", defaultDownloadTimeout)}
+}
+
+// geminiClient sends Gemini requests with the request's own timeout, unless GEMINI_TIMEOUT_SECONDS
+// sets one for every Gemini request
+func geminiClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: geminiTransport.get(), Timeout: stageTimeout("GEMINI_TIMEOUT_SECONDS", timeout)}
+}
+
+// providerClient sends recordings to the OpenAI and Deepgram transcription APIs
+// (TRANSCRIPTION_PROVIDER_TIMEOUT_SECONDS, default 120)
+func providerClient() *http.Client {
+	return &http.Client{Transport: apiTransport.get(), Timeout: stageTimeout("TRANSCRIPTION_PROVIDER_TIMEOUT_SECONDS", defaultProviderTimeout)}
+}
+
+// apiClient calls AWS, CRMs, webhooks and recording URL refresh endpoints (HTTP_TIMEOUT_SECONDS, default 30)
+func apiClient() *http.Client {
+	return &http.Client{Transport: apiTransport.get(), Timeout: stageTimeout("HTTP_TIMEOUT_SECONDS", defaultAPITimeout)}
+}
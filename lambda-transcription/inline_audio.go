@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAudio rejects inline audio that can't be decoded or read
+var ErrInvalidAudio = errors.New("invalid inline audio")
+
+// InlineAudio is a recording carried by the request instead of a call_logs row: base64 data (a
+// data: URI prefix is accepted) or an S3 object. Its campaign's questions are answered, or the
+// questions sent with it.
+type InlineAudio struct {
+	Data  string `json:"data,omitempty"`
+	S3URI string `json:"s3Uri,omitempty"`
+	// CampaignID selects the questions and campaign settings; without one the audio is only transcribed
+	CampaignID string `json:"campaignId,omitempty"`
+	// Questions are answered instead of the campaign's. Each is in the question table's details
+	// format; they are identified as q1, q2, ... in the answers and in showIf conditions.
+	Questions []map[string]interface{} `json:"questions,omitempty"`
+	// Reference is echoed in the response so callers can match it to their media
+	Reference string `json:"reference,omitempty"`
+}
+
+// audioContent returns the recording's bytes from the request or S3
+func (a *InlineAudio) audioContent() ([]byte, error) {
+	switch {
+	case a.Data != "" && a.S3URI != "":
+		return nil, fmt.Errorf("%w: give either data or s3Uri, not both", ErrInvalidAudio)
+	case a.Data != "":
+		data := a.Data
+		if i := strings.Index(data, "base64,"); strings.HasPrefix(data, "data:") && i >= 0 {
+			data = data[i+len("base64,"):]
+		}
+		if err := checkRecordingSize(int64(base64.StdEncoding.DecodedLen(len(data)))); err != nil {
+			return nil, err
+		}
+		audioContent, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: data isn't base64: %v", ErrInvalidAudio, err)
+		}
+		return audioContent, nil
+	case a.S3URI != "":
+		bucket, key, ok := strings.Cut(strings.TrimPrefix(a.S3URI, "s3://"), "/")
+		if !strings.HasPrefix(a.S3URI, "s3://") || !ok || bucket == "" || key == "" {
+			return nil, fmt.Errorf("%w: s3Uri must look like s3://bucket/key", ErrInvalidAudio)
+		}
+		audioContent, err := s3GetObject(bucket, key)
+		if err != nil {
+			return nil, fmt.Errorf("%w: error reading %s: %v", ErrDownloadFailed, a.S3URI, err)
+		}
+		if err := checkRecordingSize(int64(len(audioContent))); err != nil {
+			return nil, err
+		}
+		return audioContent, nil
+	}
+	return nil, fmt.Errorf("%w: data or s3Uri is required", ErrInvalidAudio)
+}
+
+// ProcessInlineAudio transcribes audio carried by the request and answers its campaign's questions
+// with the same transcription, enum validation and conditional question handling as calls, for
+// integrations whose media has no call_logs row yet. Nothing is saved, cached or published.
+func (tp *TranscriptionPipeline) ProcessInlineAudio(audio InlineAudio) (_ map[string]interface{}, err error) {
+	tp.startProcessingMetadata()
+	tp.errorContext = errorContext{CampaignID: audio.CampaignID}
+	defer func() { tp.reportError(err) }()
+	defer func() { tp.reportFinished(err) }()
+
+	// Inline audio has no URL to key the cache on, and no row whose analysis would be kept
+	tp.cacheEnabled = false
+	// Nor a call whose learned profile or details would apply
+	tp.profile, tp.promptCall = nil, nil
+
+	var questions []Question
+	provider := tp.transcriptionProvider
+	if audio.CampaignID != "" {
+		tp.reportStage(StageFetching)
+		dbFetchStart := time.Now()
+		if err := tp.ConnectToDatabase(); err != nil {
+			return nil, fmt.Errorf("failed to connect to database: %v", err)
+		}
+		defer tp.CloseDatabase()
+
+		settings, err := tp.GetCampaignSettings(audio.CampaignID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get campaign settings: %v", err)
+		}
+		if err := tp.useGeminiTenant(settings.GeminiTenant); err != nil {
+			return nil, err
+		}
+		tp.fallbackModels = settings.GeminiFallbackModels
+		tp.debugResponses = settings.DebugResponses
+		if err := tp.applyCampaignBudget(audio.CampaignID, settings.Budget); err != nil {
+			return nil, err
+		}
+		if settings.TranscriptionProvider != "" {
+			provider = settings.TranscriptionProvider
+		}
+		tp.outputLanguage = settings.OutputLanguage
+		tp.useTwoPhase(settings.TwoPhase)
+
+		if len(audio.Questions) == 0 {
+			questions, err = tp.questionsForCampaign(audio.CampaignID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get questions for campaign: %v", err)
+			}
+		}
+		tp.metadata.Stages.DBFetch = elapsedMs(dbFetchStart)
+	} else {
+		questions = tp.adHocQuestions
+	}
+	if len(audio.Questions) > 0 {
+		if questions, err = adHocQuestions(audio.Questions); err != nil {
+			return nil, err
+		}
+	}
+
+	tp.reportStage(StageDownloading)
+	downloadStart := time.Now()
+	audioContent, err := audio.audioContent()
+	tp.metadata.Stages.Download += elapsedMs(downloadStart)
+	if err != nil {
+		return nil, err
+	}
+	if len(audioContent) == 0 {
+		return nil, fmt.Errorf("%w: the audio is empty", ErrInvalidAudio)
+	}
+	tp.metadata.AudioBytes = len(audioContent)
+
+	provider = transcriptionProviderName(provider)
+	tp.requestBudget = nil
+	transcriptionResult, err := tp.transcribeAudio(audioContent, questions, provider)
+	if err != nil {
+		return nil, &ProviderError{Err: err}
+	}
+
+	answers, enumAnswers := validateEnumAnswers(questions, markNotDiscussed(transcriptionResult.Answers))
+	answers, skippedQuestions := applyQuestionConditions(questions, answers)
+	if answers == nil {
+		answers = map[string]string{}
+	}
+
+	usage := tp.usage
+	analysisData := CallAnalysisData{
+		Transcription:     transcriptionResult.Transcription,
+		Answers:           answers,
+		CallDisposition:   transcriptionResult.Disposition,
+		DispositionReason: transcriptionResult.DispositionReason,
+		SkippedQuestions:  skippedQuestions,
+		EnumAnswers:       enumAnswers,
+		Words:             transcriptionResult.Words,
+		Provider:          transcriptionResult.Provider,
+		Usage:             &usage,
+		RequestBudget:     transcriptionResult.RequestBudget,
+		AudioQuality:      transcriptionResult.AudioQuality,
+		SkipReason:        transcriptionResult.SkipReason,
+		ProcessedAt:       time.Now().Format(time.RFC3339),
+	}
+	analysisData.ProcessingMetadata = tp.processingMetadata()
+
+	result := map[string]interface{}{
+		"transcription":       analysisData.Transcription,
+		"answers":             analysisData.Answers,
+		"skipped_questions":   skippedQuestions,
+		"enum_answers":        enumAnswers,
+		"provider":            analysisData.Provider,
+		"processing_metadata": analysisData.ProcessingMetadata,
+		"processed_at":        analysisData.ProcessedAt,
+	}
+	if audio.CampaignID != "" {
+		result["campaignId"] = audio.CampaignID
+	}
+	if audio.Reference != "" {
+		result["reference"] = audio.Reference
+	}
+	if analysisData.CallDisposition != "" && analysisData.CallDisposition != DispositionConversation {
+		result["call_disposition"] = analysisData.CallDisposition
+	}
+	if analysisData.AudioQuality != nil {
+		result["audio_quality"] = analysisData.AudioQuality
+	}
+	if analysisData.SkipReason != "" {
+		result["skipped"] = true
+		result["skip_reason"] = analysisData.SkipReason
+	}
+
+	return tp.shapeResponse(result, &analysisData), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// IntentLabel is one label of a campaign's intent taxonomy, e.g. "callback"
+type IntentLabel struct {
+	Label string `json:"label"`
+	// Description tells the model when the label applies
+	Description string `json:"description,omitempty"`
+}
+
+// IntentClassification represents the intent section of the call analysis
+type IntentClassification struct {
+	Label     string `json:"label,omitempty"`
+	Rationale string `json:"rationale,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ClassifyIntent asks the model which label of the campaign's taxonomy best describes the call's
+// outcome. A classification failure, including a label outside the taxonomy, is recorded in the result.
+func (tp *TranscriptionPipeline) ClassifyIntent(transcription string, labels []IntentLabel) *IntentClassification {
+	var labelsText strings.Builder
+	for _, l := range labels {
+		if l.Description != "" {
+			fmt.Fprintf(&labelsText, "- %s: %s\n", l.Label, l.Description)
+		} else {
+			fmt.Fprintf(&labelsText, "- %s\n", l.Label)
+		}
+	}
+
+	prompt := fmt.Sprintf(`
+Classify the outcome of the following call by choosing exactly one label that best describes the customer's intent at the end of the call.
+
+LABELS:
+%s
+TRANSCRIPTION:
+%s
+
+The label must be copied exactly from the list.
+%s
+Respond with JSON only, in this format:
+{"label": "one of the labels", "rationale": "one sentence explaining the choice"}
+`, labelsText.String(), transcription, rationaleLanguage(tp.outputLanguage))
+
+	classification := &IntentClassification{}
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		classification.Error = fmt.Sprintf("error classifying intent: %v", err)
+		return classification
+	}
+
+	var modelClassification struct {
+		Label     string `json:"label"`
+		Rationale string `json:"rationale"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &modelClassification); err != nil {
+		classification.Error = fmt.Sprintf("error parsing intent: %v", err)
+		return classification
+	}
+
+	classification.Rationale = modelClassification.Rationale
+	for _, l := range labels {
+		if strings.EqualFold(strings.TrimSpace(modelClassification.Label), l.Label) {
+			classification.Label = l.Label
+			return classification
+		}
+	}
+	classification.Error = fmt.Sprintf("intent %q is not in the campaign's taxonomy", modelClassification.Label)
+	return classification
+}
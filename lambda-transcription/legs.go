@@ -0,0 +1,145 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// multiLegRecordingsEnabled reports whether calls' recording legs are read from call_recordings
+// (MULTI_LEG_RECORDINGS=true)
+func multiLegRecordingsEnabled() bool {
+	return os.Getenv("MULTI_LEG_RECORDINGS") == "true"
+}
+
+// RecordingLeg is one recording segment of a call, e.g. the leg after a transfer or a conference leg
+type RecordingLeg struct {
+	URL       string    `json:"recording_url"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// GetRecordingLegs reads the call's recording legs from call_recordings in start order. Legs
+// without a start time sort last.
+func (tp *TranscriptionPipeline) GetRecordingLegs(callLogsID string) ([]RecordingLeg, error) {
+	c := func(name string) string { return tp.schema.Column("call_recordings", name) }
+	query := fmt.Sprintf(`
+		SELECT %s, %s
+		FROM %s
+		WHERE %s = $1 AND %s IS NOT NULL AND %s <> ''
+		ORDER BY %s NULLS LAST
+	`, c("recording_url"), c("started_at"), tp.schema.Table("call_recordings"),
+		c("call_logsId"), c("recording_url"), c("recording_url"), c("started_at"))
+
+	rows, err := tp.repo.Query(query, callLogsID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching recording legs: %v", err)
+	}
+	defer rows.Close()
+
+	var legs []RecordingLeg
+	for rows.Next() {
+		var leg RecordingLeg
+		var startedAt sql.NullTime
+		if err := rows.Scan(&leg.URL, &startedAt); err != nil {
+			return nil, fmt.Errorf("error scanning recording leg: %v", err)
+		}
+		leg.URL = strings.TrimSpace(leg.URL)
+		leg.StartedAt = startedAt.Time
+		legs = append(legs, leg)
+	}
+	return legs, rows.Err()
+}
+
+// TranscribeRecordingLegs transcribes each leg of a multi-leg call on its own, places its turns on
+// the call's timeline by the leg's start time and answers the questions from the merged
+// transcription. A leg without a start time follows the previous leg. Legs aren't cached or
+// checked for voicemail, and their fallback URLs aren't tried.
+func (tp *TranscriptionPipeline) TranscribeRecordingLegs(legs []RecordingLeg, questions []Question, provider string) (*TranscriptionResult, error) {
+	provider = strings.ToLower(provider)
+	if provider == "" {
+		provider = ProviderGemini
+	}
+	tp.requestBudget = nil
+	tp.fallbackRecordingURLs = nil
+	tp.metadata.RecordingLegs = len(legs)
+
+	transcriber, err := NewTranscriber(provider, tp)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []TranscriptSegment
+	var words []TranscriptWord
+	var timelineEnd float64
+	for i, leg := range legs {
+		tp.reportStage(StageDownloading)
+		downloadStart := time.Now()
+		audioContent, err := tp.DownloadAudio(leg.URL)
+		tp.metadata.Stages.Download += elapsedMs(downloadStart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download leg %d of %d: %w", i+1, len(legs), err)
+		}
+		if len(audioContent) == 0 {
+			return nil, fmt.Errorf("downloaded audio of leg %d of %d is empty", i+1, len(legs))
+		}
+		tp.metadata.AudioBytes += len(audioContent)
+
+		tp.reportStage(StageTranscribing)
+		transcriptionStart := time.Now()
+		audioContent = tp.preprocessAudio(tp.extractVideoAudio(audioContent))
+		transcript, err := transcriber.Transcribe(audioContent)
+		tp.metadata.Stages.Transcription += elapsedMs(transcriptionStart)
+		if err != nil {
+			return nil, fmt.Errorf("failed to transcribe leg %d of %d with %s: %w", i+1, len(legs), transcriber.Name(), err)
+		}
+		tp.metadata.TranscriptionModel = transcript.Model
+
+		legSegments := transcript.Segments
+		if len(legSegments) == 0 {
+			legSegments = parseDiarizedTranscript(transcript.Text)
+		}
+		if len(legSegments) == 0 && strings.TrimSpace(transcript.Text) != "" {
+			// Without timestamps the leg's text is kept as a single turn
+			legSegments = []TranscriptSegment{{Speaker: SpeakerUnknown, Text: strings.TrimSpace(transcript.Text), End: transcript.Duration}}
+		}
+
+		offset := timelineEnd
+		if !leg.StartedAt.IsZero() && !legs[0].StartedAt.IsZero() {
+			offset = leg.StartedAt.Sub(legs[0].StartedAt).Seconds()
+		}
+		for _, segment := range legSegments {
+			segment.Start += offset
+			segment.End += offset
+			segments = append(segments, segment)
+			timelineEnd = max(timelineEnd, segment.End)
+		}
+		for _, word := range transcript.Words {
+			word.Start += offset
+			word.End += offset
+			words = append(words, word)
+		}
+		timelineEnd = max(timelineEnd, offset+transcript.Duration)
+	}
+
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no speech found in any of the %d recording legs", len(legs))
+	}
+
+	// Conference legs overlap, so their turns are interleaved by start time
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	sort.SliceStable(words, func(i, j int) bool { return words[i].Start < words[j].Start })
+	transcription := formatTranscriptSegments(segments)
+
+	answers := make(map[string]string)
+	if len(questions) > 0 {
+		answers, err = tp.AnswerQuestionsFromTranscript(transcription, questions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to answer questions: %w", err)
+		}
+	}
+
+	return &TranscriptionResult{Transcription: transcription, Answers: answers, Words: words, Provider: provider}, nil
+}
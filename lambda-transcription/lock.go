@@ -0,0 +1,92 @@
+package main
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultProcessingLockTTL outlives the longest Lambda invocation, so a crashed run's lock expires
+// before a retry would be rejected for long
+const defaultProcessingLockTTL = 15 * time.Minute
+
+// ErrCallLocked means another invocation is processing the call right now
+var ErrCallLocked = errors.New("call is being processed by another invocation")
+
+// ErrDuplicateInProgress means another invocation is processing a different call_logs row of the
+// same call; a retry once it has saved its analysis links to it
+var ErrDuplicateInProgress = errors.New("another row of the call is being processed by another invocation")
+
+// processingLockEnabled reports whether ProcessCall takes the per-call lock (PROCESSING_LOCK=false disables it)
+func processingLockEnabled() bool {
+	return os.Getenv("PROCESSING_LOCK") != "false"
+}
+
+// processingLockTTL reads how long a lock is held before it expires (PROCESSING_LOCK_TTL_SECONDS)
+func processingLockTTL() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("PROCESSING_LOCK_TTL_SECONDS")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultProcessingLockTTL
+}
+
+// ProcessingLock is a lease on a call in call_processing_locks, held while the call is processed
+type ProcessingLock struct {
+	callLogsID string
+	owner      string
+}
+
+// AcquireProcessingLock takes the call's lock with a conditional insert that only replaces an
+// expired lock, returning ErrCallLocked when another invocation holds it. The lock lives in a table
+// rather than a session advisory lock so it survives the pool recycling the connection.
+func (tp *TranscriptionPipeline) AcquireProcessingLock(callLogsID string) (*ProcessingLock, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("error generating lock owner: %v", err)
+	}
+	lock := &ProcessingLock{callLogsID: callLogsID, owner: hex.EncodeToString(token)}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS held ("call_logsId", owner, "acquiredAt", "expiresAt")
+		VALUES ($1, $2, now(), now() + $3::interval)
+		ON CONFLICT ("call_logsId")
+		DO UPDATE SET owner = EXCLUDED.owner, "acquiredAt" = EXCLUDED."acquiredAt", "expiresAt" = EXCLUDED."expiresAt"
+		WHERE held."expiresAt" < now()
+		RETURNING owner
+	`, tp.schema.Table("call_processing_locks"))
+
+	ttl := fmt.Sprintf("%d seconds", int(processingLockTTL().Seconds()))
+	var owner string
+	err := tp.repo.QueryRow(query, lock.callLogsID, lock.owner, ttl).Scan(&owner)
+	if err == sql.ErrNoRows {
+		return nil, ErrCallLocked
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring processing lock: %v", err)
+	}
+	return lock, nil
+}
+
+// ReleaseProcessingLock releases the lock unless it expired and another invocation took it over.
+// A failed release is only logged: the lock expires on its own.
+func (tp *TranscriptionPipeline) ReleaseProcessingLock(lock *ProcessingLock) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1 AND owner = $2`, tp.schema.Table("call_processing_locks"))
+	if _, err := tp.repo.Exec(query, lock.callLogsID, lock.owner); err != nil {
+		log.Printf("Error releasing processing lock for %s: %v", lock.callLogsID, err)
+	}
+}
+
+// duplicateKeyLockID is the call_processing_locks key of a campaign's call_id or canonical recording
+// URL: a UUID derived from them, since the table is keyed by call_logsId
+func duplicateKeyLockID(campaignID, rule, key string) string {
+	sum := md5.Sum([]byte(campaignID + "\n" + rule + "\n" + key))
+	id := hex.EncodeToString(sum[:])
+	return id[0:8] + "-" + id[8:12] + "-" + id[12:16] + "-" + id[16:20] + "-" + id[20:32]
+}
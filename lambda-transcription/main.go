@@ -1,11 +1,10 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,11 +16,43 @@ import (
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
+	"golang.org/x/sync/errgroup"
 )
 
 // LambdaRequest represents the incoming Lambda event
 type LambdaRequest struct {
 	CallLogsID string `json:"call_logsId"`
+	// Action selects a mode other than call processing ("migrate", "digest", "evaluate", "retention", "warmup",
+	// "backfill", "step")
+	Action string `json:"action,omitempty"`
+	// Date is the day the "digest" action reports on (YYYY-MM-DD, default yesterday)
+	Date string `json:"date,omitempty"`
+	// Evaluation configures the "evaluate" action
+	Evaluation *EvaluationConfig `json:"evaluation,omitempty"`
+	// Backfill configures the "backfill" action
+	Backfill *BackfillRequest `json:"backfill,omitempty"`
+	// Step is the workflow step the "step" action runs, with the Step Functions state in Workflow
+	Step     string         `json:"step,omitempty"`
+	Workflow *WorkflowState `json:"workflow,omitempty"`
+	// DryRun processes the call without saving anything and returns the would-be analysis; with the
+	// "retention" action it lists the expired calls without changing them
+	DryRun bool `json:"dry_run,omitempty"`
+	// Reprocess processes a call that already has an analysis instead of returning 409
+	Reprocess bool `json:"reprocess,omitempty"`
+	// Verbosity trims the processing response: "full" (default), "answers" or "summary"
+	Verbosity string `json:"verbosity,omitempty"`
+	// JobID keys the call's progress updates for WebSocket subscribers (default call_logsId)
+	JobID string `json:"job_id,omitempty"`
+	// MessageID processes a voice note from the messages table instead of a call
+	MessageID string `json:"messageId,omitempty"`
+	// Audio processes a recording carried by the request instead of a call, without saving anything
+	Audio *InlineAudio `json:"audio,omitempty"`
+	// Questions are answered instead of the campaign's questions, or besides them with QuestionsMode
+	// "append", each in the question table's details format. Nothing is saved, as with DryRun.
+	Questions     []map[string]interface{} `json:"questions,omitempty"`
+	QuestionsMode string                   `json:"questions_mode,omitempty"`
+	// Records are S3 notifications of recordings uploaded through the API's POST /uploads
+	Records []S3EventRecord `json:"Records,omitempty"`
 }
 
 // LambdaResponse represents the Lambda response
@@ -29,6 +60,10 @@ type LambdaResponse struct {
 	StatusCode int         `json:"statusCode"`
 	Body       interface{} `json:"body"`
 	Error      string      `json:"error,omitempty"`
+	// ErrorCategory classifies failures callers may handle differently (e.g. "gemini_blocked")
+	ErrorCategory string `json:"errorCategory,omitempty"`
+	// Retryable is set on failures: whether the same request may succeed if retried
+	Retryable *bool `json:"retryable,omitempty"`
 }
 
 // CallData represents call information from the database
@@ -44,6 +79,15 @@ type CallData struct {
 	AgentName       string    `json:"agent_name"`
 	CampaignName    string    `json:"campaign_name"`
 	CampaignID      string    `json:"campaignId"`
+	// FallbackRecordingURLs come from the RECORDING_FALLBACK_URL_COLUMNS columns, in order
+	FallbackRecordingURLs []string `json:"fallback_recording_urls,omitempty"`
+	// Analyzed is set when the call already has a callAnalysis
+	Analyzed bool `json:"-"`
+	// AnalysisVersion is the call's latest call_analysis_versions version when it was read (0 for none);
+	// saving checks it hasn't changed since
+	AnalysisVersion int `json:"-"`
+	// RecordingLegs are the call's recordings from call_recordings when MULTI_LEG_RECORDINGS is set
+	RecordingLegs []RecordingLeg `json:"recording_legs,omitempty"`
 }
 
 // Question represents a question from the database
@@ -57,18 +101,61 @@ type Question struct {
 	Instructions string                 `json:"instructions"`
 	Answer       string                 `json:"answer,omitempty"`
 	AnsweredAt   string                 `json:"answered_at,omitempty"`
+	// Group, Conditions and Options come from the "group", "showIf" and "options" keys of details
+	Group      string              `json:"group,omitempty"`
+	Conditions []QuestionCondition `json:"show_if,omitempty"`
+	Options    []string            `json:"options,omitempty"`
+	// Examples are the campaign's few-shot examples of the question, added to the prompt
+	Examples []QuestionExample `json:"examples,omitempty"`
 }
 
 // CallAnalysisData represents the data to be saved in callAnalysis column
 type CallAnalysisData struct {
-	Transcription string            `json:"transcription"`
-	Answers       map[string]string `json:"answers"`
-	ProcessedAt   string            `json:"processed_at"`
+	Transcription           string                `json:"transcription"`
+	TranslatedTranscription string                `json:"translated_transcription,omitempty"`
+	TranslationError        string                `json:"translation_error,omitempty"`
+	Answers                 map[string]string     `json:"answers"`
+	CallDisposition         string                `json:"call_disposition,omitempty"`
+	DispositionReason       string                `json:"disposition_reason,omitempty"`
+	SkipReason              string                `json:"skip_reason,omitempty"`
+	SkippedQuestions        map[string]string     `json:"skipped_questions,omitempty"`
+	EnumAnswers             map[string]EnumAnswer `json:"enum_answers,omitempty"`
+	OutcomeErrors           map[string]string     `json:"outcome_errors,omitempty"`
+	EmbeddingError          string                `json:"embedding_error,omitempty"`
+	CRMSync                 *CRMSyncResult        `json:"crm_sync,omitempty"`
+	Metrics                 *CallMetrics          `json:"metrics,omitempty"`
+	Compliance              *ComplianceResult     `json:"compliance,omitempty"`
+	AbuseCheck              *AbuseCheck           `json:"abuse_check,omitempty"`
+	Intent                  *IntentClassification `json:"intent,omitempty"`
+	Entities                *EntityExtraction     `json:"entities,omitempty"`
+	Grounding               *AnswerGrounding      `json:"grounding,omitempty"`
+	FollowUps               *FollowUps            `json:"follow_ups,omitempty"`
+	QAScorecard             *QAScorecard          `json:"qa_scorecard,omitempty"`
+	Words                   []TranscriptWord      `json:"words,omitempty"`
+	Provider                string                `json:"provider,omitempty"`
+	CacheHit                bool                  `json:"cache_hit,omitempty"`
+	PromptVariant           string                `json:"prompt_variant,omitempty"`
+	Usage                   *TokenUsage           `json:"usage,omitempty"`
+	RequestBudget           *RequestBudget        `json:"request_budget,omitempty"`
+	ProcessingMetadata      *ProcessingMetadata   `json:"processing_metadata,omitempty"`
+	AudioQuality            *AudioQuality         `json:"audio_quality,omitempty"`
+	PhoneNumbers            *CallPhoneNumbers     `json:"phone_numbers,omitempty"`
+	// DuplicateOf links a row of an already processed call to the row whose analysis it copies
+	DuplicateOf *DuplicateLink `json:"duplicate_of,omitempty"`
+	ProcessedAt string         `json:"processed_at"`
 }
 
 // GeminiRequest represents the request to Gemini API
 type GeminiRequest struct {
-	Contents []Content `json:"contents"`
+	Contents         []Content         `json:"contents"`
+	GenerationConfig *GenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings   []SafetySetting   `json:"safetySettings,omitempty"`
+}
+
+// GenerationConfig controls the output format and length of the Gemini response
+type GenerationConfig struct {
+	ResponseMimeType string `json:"responseMimeType,omitempty"`
+	MaxOutputTokens  int    `json:"maxOutputTokens,omitempty"`
 }
 
 type Content struct {
@@ -83,22 +170,122 @@ type Part struct {
 type InlineData struct {
 	MimeType string `json:"mime_type"`
 	Data     string `json:"data"`
+	// audio is encoded into Data as the request is sent; see audioInlineData
+	audio []byte
 }
 
 // GeminiResponse represents the response from Gemini API
 type GeminiResponse struct {
-	Candidates []Candidate `json:"candidates"`
+	Candidates     []Candidate     `json:"candidates"`
+	PromptFeedback *PromptFeedback `json:"promptFeedback,omitempty"`
+	UsageMetadata  *UsageMetadata  `json:"usageMetadata,omitempty"`
 }
 
 type Candidate struct {
-	Content Content `json:"content"`
+	Content       Content        `json:"content"`
+	FinishReason  string         `json:"finishReason,omitempty"`
+	SafetyRatings []SafetyRating `json:"safetyRatings,omitempty"`
 }
 
 // TranscriptionPipeline handles the transcription process
 type TranscriptionPipeline struct {
 	dbConnectionString string
 	geminiAPIKey       string
-	db                 *sql.DB
+	repo               *Repository
+	cacheEnabled       bool
+
+	// transcriptionProvider selects the transcription backend ("gemini" by default)
+	transcriptionProvider string
+	openAIAPIKey          string
+	deepgramAPIKey        string
+
+	// artifactsBucket enables S3 archival of raw and derived artifacts
+	artifactsBucket string
+	artifactsPrefix string
+	geminiExchanges []GeminiExchange
+	// debugResponses archives the current call's raw Gemini responses (see archiveProviderResponse)
+	debugResponses bool
+	// replay is the archived response ReplayCall parses instead of transcribing the recording
+	replay *replaySource
+
+	// schema maps table and column names onto the tenant's database
+	schema SchemaConfig
+
+	// embeddingsEnabled stores transcript embeddings for semantic search
+	embeddingsEnabled bool
+
+	// audioPreprocessing downsamples recordings to 16kHz mono before transcription
+	audioPreprocessing bool
+	stripSilence       bool
+
+	// splitChannels transcribes the agent and customer channels of stereo recordings separately
+	splitChannels bool
+
+	// dispositionDetection classifies recordings before transcription, skipping voicemails, IVRs and dead air
+	dispositionDetection bool
+	// audioQualityCheck scores recordings before transcription (AUDIO_QUALITY_CHECK)
+	audioQualityCheck bool
+	// translateTranscripts adds an English translation of the transcription to the analysis
+	translateTranscripts bool
+	// abuseDetection flags profanity and abuse by either party
+	abuseDetection bool
+	// entityExtraction extracts products, amounts, dates, locations and order IDs from the transcription
+	entityExtraction bool
+	// answerGrounding rejects answers the model can't cite transcript evidence for (ANSWER_GROUNDING)
+	answerGrounding bool
+	// followUpTasks generates follow-up tasks from the commitments made on the call
+	followUpTasks bool
+	// geminiTenant is the current call's Gemini API key, quota and breaker (nil for GEMINI_API_KEY)
+	geminiTenant *geminiTenant
+	// fallbackModels is the current campaign's Gemini fallback ladder (nil for GEMINI_FALLBACK_MODELS)
+	fallbackModels []string
+	// servedModel is the model that answered the last Gemini request, which may be a fallback
+	servedModel string
+	// modelOverride pins the Gemini model of the next requests, e.g. a cheaper model for classification
+	modelOverride string
+	// budgetModel replaces the configured model of an over-budget campaign's calls
+	budgetModel string
+	// twoPhase splits the current call's transcription and answering into separate requests (see TwoPhaseSettings)
+	twoPhase *TwoPhaseSettings
+	// profile is the current call's learned profile (see ProfileLearning); nil for voice notes and inline audio
+	profile *ProfileChoice
+	// promptCall is the call whose details are added to the question prompts (see callDetailsPrompt)
+	promptCall *CallData
+	// usageCampaignID is the campaign the current call's Gemini requests count towards
+	usageCampaignID string
+	// errorContext tags the current call's reported errors
+	errorContext errorContext
+
+	// eligibility holds the pre-flight rules that skip ineligible calls
+	eligibility EligibilityRules
+
+	// dryRun skips every write and side effect of ProcessCall
+	dryRun bool
+	// responseVerbosity trims what ProcessCall returns ("" for everything)
+	responseVerbosity string
+	// reprocess lets ProcessCall process calls that already have an analysis
+	reprocess bool
+	// adHocQuestions are answered instead of or besides the campaign's questions, per adHocQuestionsMode
+	adHocQuestions     []Question
+	adHocQuestionsMode string
+
+	// variant is the prompt/model variant of the current call (nil for the default prompt)
+	variant *PromptVariant
+	usage   TokenUsage
+	// requestBudget records how the current call's Gemini requests were fitted within the model's limits
+	requestBudget *RequestBudget
+	// outputLanguage is the language the current call's answers are written in ("" for the default)
+	outputLanguage string
+	// fallbackRecordingURLs are the current call's alternate recording URLs, tried when downloads fail
+	fallbackRecordingURLs []string
+	// prefetch is the current call's recording download started by fetchCall, if any
+	prefetch *audioPrefetch
+	// metadata times the current call's processing stages
+	metadata ProcessingMetadata
+	// progress is told as each stage of processing a call starts (nil when nobody is listening)
+	progress ProgressFunc
+	// resultStores are where analyses are saved (nil for the callAnalysis column)
+	resultStores []ResultStore
 }
 
 // NewTranscriptionPipeline creates a new pipeline instance
@@ -106,81 +293,148 @@ func NewTranscriptionPipeline(dbConnectionString, geminiAPIKey string) *Transcri
 	return &TranscriptionPipeline{
 		dbConnectionString: dbConnectionString,
 		geminiAPIKey:       geminiAPIKey,
+		schema:             DefaultSchemaConfig(),
 	}
 }
 
-// ConnectToDatabase establishes connection to PostgreSQL
+// ConnectToDatabase attaches the pipeline to the shared connection pool, opening it on first use.
+// The pool outlives the pipeline so warm invocations reuse its connections and prepared statements.
 func (tp *TranscriptionPipeline) ConnectToDatabase() error {
-	db, err := sql.Open("postgres", tp.dbConnectionString)
-	if err != nil {
-		return fmt.Errorf("failed to open database connection: %v", err)
+	// Fail fast while the database is known to be unreachable
+	if err := databaseBreaker.Allow(); err != nil {
+		return err
 	}
 
-	// Set connection timeouts
-	db.SetConnMaxLifetime(30 * time.Second)
-	db.SetMaxOpenConns(1)
-	db.SetMaxIdleConns(1)
+	// Every outcome after Allow is recorded, so a half-open breaker's trial is never left in flight
+	repo, err := sharedRepository(tp.dbConnectionString)
+	if err != nil {
+		databaseBreaker.RecordFailure()
+		return err
+	}
 
-	if err := db.Ping(); err != nil {
+	if err := repo.Ping(); err != nil {
+		databaseBreaker.RecordFailure()
 		return fmt.Errorf("failed to ping database: %v", err)
 	}
+	databaseBreaker.RecordSuccess()
 
-	tp.db = db
+	tp.repo = repo
 	return nil
 }
 
-// CloseDatabase closes the database connection
-func (tp *TranscriptionPipeline) CloseDatabase() {
-	if tp.db != nil {
-		tp.db.Close()
-	}
-}
+// CloseDatabase releases the pipeline's database access. The shared pool stays open for the next
+// invocation and is closed on shutdown (see closeSharedRepositories).
+func (tp *TranscriptionPipeline) CloseDatabase() {}
 
 // GetCallData retrieves call data from the database
 func (tp *TranscriptionPipeline) GetCallData(callLogsID string) (*CallData, error) {
-	query := `
-		SELECT id, recording_url, call_id, caller_id_number, call_to_number, 
-		       start_date, start_time, duration, agent_name, campaign_name, "campaignId"
-		FROM "smartFlo".call_logs 
-		WHERE id = $1
-	`
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
 
+	// Fallback recording URL columns are optional and configured per deployment
+	fallbackColumns := recordingFallbackColumns()
+	fallbackSelect := ""
+	for _, column := range fallbackColumns {
+		fallbackSelect += ", " + c(column)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s, %s, %s, %s, %s, 
+		       %s, %s, %s, %s, %s, %s, %s IS NOT NULL,
+		       (SELECT COALESCE(MAX(version), 0) FROM %s WHERE "call_logsId" = %s)%s
+		FROM %s 
+		WHERE %s = $1
+	`, c("id"), c("recording_url"), c("call_id"), c("caller_id_number"), c("call_to_number"),
+		c("start_date"), c("start_time"), c("duration"), c("agent_name"), c("campaign_name"), c("campaignId"),
+		c("callAnalysis"), tp.schema.Table("call_analysis_versions"), tp.schema.Table("call_logs")+"."+c("id"), fallbackSelect,
+		tp.schema.Table("call_logs"), c("id"))
+
+	// Everything but the ID is nullable in call_logs; NULLs are read as zero values
 	var callData CallData
-	err := tp.db.QueryRow(query, callLogsID).Scan(
+	var recordingURL, callID, callerIDNumber, callToNumber, startDate, startTime sql.NullString
+	var agentName, campaignName, campaignID sql.NullString
+	var duration sql.NullInt64
+	fallbackURLs := make([]sql.NullString, len(fallbackColumns))
+	dest := []interface{}{
 		&callData.ID,
-		&callData.RecordingURL,
-		&callData.CallID,
-		&callData.CallerIDNumber,
-		&callData.CallToNumber,
-		&callData.StartDate,
-		&callData.StartTime,
-		&callData.Duration,
-		&callData.AgentName,
-		&callData.CampaignName,
-		&callData.CampaignID,
-	)
+		&recordingURL,
+		&callID,
+		&callerIDNumber,
+		&callToNumber,
+		&startDate,
+		&startTime,
+		&duration,
+		&agentName,
+		&campaignName,
+		&campaignID,
+		&callData.Analyzed,
+		&callData.AnalysisVersion,
+	}
+	for i := range fallbackURLs {
+		dest = append(dest, &fallbackURLs[i])
+	}
+	err := tp.repo.QueryRow(query, callLogsID).Scan(dest...)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("no call found with ID: %s", callLogsID)
+			return nil, fmt.Errorf("%w with ID: %s", ErrCallNotFound, callLogsID)
 		}
 		return nil, fmt.Errorf("error fetching call data: %v", err)
 	}
 
+	callData.RecordingURL = recordingURL.String
+	callData.CallID = callID.String
+	callData.CallerIDNumber = callerIDNumber.String
+	callData.CallToNumber = callToNumber.String
+	callData.StartDate = startDate.String
+	callData.StartTime = startTime.String
+	callData.Duration = int(duration.Int64)
+	callData.AgentName = agentName.String
+	callData.CampaignName = campaignName.String
+	callData.CampaignID = campaignID.String
+	for _, fallbackURL := range fallbackURLs {
+		if fallbackURL.String != "" {
+			callData.FallbackRecordingURLs = append(callData.FallbackRecordingURLs, fallbackURL.String)
+		}
+	}
+
+	// A call whose primary recording URL is missing can still be processed from a fallback
+	if callData.RecordingURL == "" && len(callData.FallbackRecordingURLs) > 0 {
+		callData.RecordingURL = callData.FallbackRecordingURLs[0]
+		callData.FallbackRecordingURLs = callData.FallbackRecordingURLs[1:]
+	}
+
+	// Transferred and conference calls list every recording leg in a child table
+	if multiLegRecordingsEnabled() {
+		legs, err := tp.GetRecordingLegs(callLogsID)
+		if err != nil {
+			return nil, err
+		}
+		callData.RecordingLegs = legs
+		if callData.RecordingURL == "" && len(legs) > 0 {
+			callData.RecordingURL = legs[0].URL
+		}
+	}
+
 	return &callData, nil
 }
 
 // GetQuestionsForCampaign retrieves questions specific to the campaign
 func (tp *TranscriptionPipeline) GetQuestionsForCampaign(campaignID string) ([]Question, error) {
-	query := `
-		SELECT q.id, q.label, q."isActive", q.details
-		FROM "smartFlo".question q
-		INNER JOIN "smartFlo".campaign_question cq ON q.id = cq."questionId"
-		WHERE q."isActive" = true AND cq."campaignId" = $1
-		ORDER BY q.id
-	`
-
-	rows, err := tp.db.Query(query, campaignID)
+	q := func(name string) string { return "q." + tp.schema.Column("question", name) }
+	cq := func(name string) string { return "cq." + tp.schema.Column("campaign_question", name) }
+	query := fmt.Sprintf(`
+		SELECT %s, %s, %s, %s
+		FROM %s q
+		INNER JOIN %s cq ON %s = %s
+		WHERE %s = true AND %s = $1
+		ORDER BY %s
+	`, q("id"), q("label"), q("isActive"), q("details"),
+		tp.schema.Table("question"),
+		tp.schema.Table("campaign_question"), q("id"), cq("questionId"),
+		q("isActive"), cq("campaignId"),
+		q("id"))
+
+	rows, err := tp.repo.Query(query, campaignID)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching questions for campaign: %v", err)
 	}
@@ -189,67 +443,226 @@ func (tp *TranscriptionPipeline) GetQuestionsForCampaign(campaignID string) ([]Q
 	var questions []Question
 	for rows.Next() {
 		var q Question
+		var label sql.NullString
 		var detailsJSON []byte
 
-		err := rows.Scan(&q.ID, &q.Label, &q.IsActive, &detailsJSON)
+		err := rows.Scan(&q.ID, &label, &q.IsActive, &detailsJSON)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning question row: %v", err)
 		}
+		q.Label = label.String
 
-		// Parse details JSON
-		if err := json.Unmarshal(detailsJSON, &q.Details); err != nil {
-			return nil, fmt.Errorf("error parsing question details: %v", err)
-		}
-
-		// Extract question text and other fields from details
-		if questionText, ok := q.Details["questionText"].(string); ok {
-			q.QuestionText = questionText
-		}
-		if answerType, ok := q.Details["answerType"].(string); ok {
-			q.AnswerType = answerType
-		} else {
-			q.AnswerType = "text"
+		// Parse details JSON; a NULL details column leaves the defaults below
+		if len(detailsJSON) > 0 {
+			if err := json.Unmarshal(detailsJSON, &q.Details); err != nil {
+				return nil, fmt.Errorf("error parsing question details: %v", err)
+			}
 		}
-		if instructions, ok := q.Details["instructions"].(string); ok {
-			q.Instructions = instructions
+		if err := applyQuestionDetails(&q); err != nil {
+			return nil, err
 		}
 
 		questions = append(questions, q)
 	}
 
-	return questions, nil
+	examples, err := tp.GetQuestionExamplesForCampaign(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range questions {
+		questions[i].Examples = examples[questions[i].ID]
+	}
+
+	return groupQuestions(questions), nil
 }
 
-// DownloadAudio downloads audio file from URL
+// applyQuestionDetails extracts the question text and other fields from the question's details
+func applyQuestionDetails(q *Question) error {
+	if questionText, ok := q.Details["questionText"].(string); ok {
+		q.QuestionText = questionText
+	}
+	if answerType, ok := q.Details["answerType"].(string); ok {
+		q.AnswerType = answerType
+	} else {
+		q.AnswerType = "text"
+	}
+	if instructions, ok := q.Details["instructions"].(string); ok {
+		q.Instructions = instructions
+	}
+	if group, ok := q.Details["group"].(string); ok {
+		q.Group = group
+	}
+	q.Options = parseQuestionOptions(q.Details)
+
+	var err error
+	q.Conditions, err = parseQuestionConditions(q.Details)
+	if err != nil {
+		return fmt.Errorf("error parsing conditions of question %s: %v", q.ID, err)
+	}
+	return nil
+}
+
+// DownloadAudio downloads the recording, trying its mirrors and the call's fallback recording URLs
+// in order when a download fails and retrying them with exponential backoff (see downloadFromCandidates)
 func (tp *TranscriptionPipeline) DownloadAudio(recordingURL string) ([]byte, error) {
-	resp, err := http.Get(recordingURL)
+	return downloadAudio(context.Background(), recordingURL, tp.fallbackRecordingURLs)
+}
+
+// downloadAudio downloads a recording, trying the fallback URLs and mirrors after it, until ctx is done
+func downloadAudio(ctx context.Context, recordingURL string, fallbackRecordingURLs []string) ([]byte, error) {
+	// Recording providers that protect recordings get their credentials from Secrets Manager
+	providers, err := loadRecordingAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	mirrors, err := recordingMirrors()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := recordingURLCandidates(recordingURL, fallbackRecordingURLs, mirrors)
+	return downloadFromCandidates(ctx, candidates, func(candidate string) ([]byte, int, error) {
+		return downloadRecordingWithRefresh(ctx, providers, candidate)
+	})
+}
+
+// audioPrefetch is a recording download started while the call's campaign is read
+type audioPrefetch struct {
+	recordingURL string
+	cancel       context.CancelFunc
+	done         chan struct{}
+	audio        []byte
+	err          error
+}
+
+// prefetchAudio starts downloading the recording in the background, for awaitAudio to pick up. The
+// download gets its own copy of the fallback URLs, since the next call replaces the pipeline's.
+func (tp *TranscriptionPipeline) prefetchAudio(recordingURL string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	prefetch := &audioPrefetch{recordingURL: recordingURL, cancel: cancel, done: make(chan struct{})}
+	fallbackRecordingURLs := append([]string(nil), tp.fallbackRecordingURLs...)
+	go func() {
+		defer close(prefetch.done)
+		prefetch.audio, prefetch.err = downloadAudio(ctx, recordingURL, fallbackRecordingURLs)
+	}()
+	tp.prefetch = prefetch
+}
+
+// cancelPrefetch stops the prefetched download, if any, when the call won't use it
+func (tp *TranscriptionPipeline) cancelPrefetch() {
+	if tp.prefetch != nil {
+		tp.prefetch.cancel()
+		tp.prefetch = nil
+	}
+}
+
+// awaitAudio returns the recording, waiting for its prefetched download if one was started and
+// downloading it otherwise
+func (tp *TranscriptionPipeline) awaitAudio(recordingURL string) ([]byte, error) {
+	prefetch := tp.prefetch
+	tp.prefetch = nil
+	if prefetch == nil || prefetch.recordingURL != recordingURL {
+		return tp.DownloadAudio(recordingURL)
+	}
+	<-prefetch.done
+	prefetch.cancel()
+	return prefetch.audio, prefetch.err
+}
+
+// downloadRecordingWithRefresh downloads a recording URL, refreshing an expired presigned link once
+func downloadRecordingWithRefresh(ctx context.Context, providers map[string]RecordingAuth, recordingURL string) ([]byte, int, error) {
+	audioData, statusCode, err := downloadRecording(ctx, providers, recordingURL)
+	if err == nil {
+		return audioData, statusCode, nil
+	}
+
+	// Presigned links expire; ask the provider for a fresh link and retry once
+	_, auth := recordingAuthForURL(providers, recordingURL)
+	refreshEndpoint := recordingRefreshEndpoint(auth)
+	if refreshEndpoint == "" || !recordingLinkExpired(statusCode) {
+		return nil, statusCode, err
+	}
+
+	freshURL, refreshErr := refreshRecordingURL(refreshEndpoint, recordingURL, auth)
+	if refreshErr != nil {
+		return nil, statusCode, fmt.Errorf("%v; refreshing the recording URL failed: %v", err, refreshErr)
+	}
+
+	audioData, statusCode, err = downloadRecording(ctx, providers, freshURL)
+	if err != nil {
+		return nil, statusCode, fmt.Errorf("%v (after refreshing the recording URL)", err)
+	}
+	return audioData, statusCode, nil
+}
+
+// downloadRecording performs a single download with the credentials configured for the URL's host,
+// returning the HTTP status code alongside any error. A body cut off part way is resumed (see resumeDownload).
+func downloadRecording(ctx context.Context, providers map[string]RecordingAuth, recordingURL string) ([]byte, int, error) {
+	providerName, auth := recordingAuthForURL(providers, recordingURL)
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", recordingURL, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating download request: %v", err)
+		}
+		if auth != nil {
+			if err := auth.apply(req); err != nil {
+				return nil, fmt.Errorf("error applying %s credentials: %v", providerName, err)
+			}
+		}
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resp, err := downloadClient().Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("error downloading audio: %v", err)
+		return nil, 0, fmt.Errorf("error downloading audio: %v", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		if auth == nil {
+			return nil, resp.StatusCode, fmt.Errorf("error downloading audio: status %d (no recording credentials configured for %s)", resp.StatusCode, req.URL.Hostname())
+		}
+		return nil, resp.StatusCode, fmt.Errorf("error downloading audio: status %d (%s credentials rejected)", resp.StatusCode, providerName)
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("error downloading audio: status %d", resp.StatusCode)
+		return nil, resp.StatusCode, fmt.Errorf("error downloading audio: status %d", resp.StatusCode)
+	}
+
+	// Refuse a recording too large for the function's memory before reading it
+	if err := checkRecordingSize(resp.ContentLength); err != nil {
+		return nil, http.StatusRequestEntityTooLarge, err
 	}
 
-	audioData, err := io.ReadAll(resp.Body)
+	audioData, err := readRecording(resp.Body)
+	if errors.Is(err, ErrRecordingTooLarge) {
+		return nil, http.StatusRequestEntityTooLarge, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error reading audio data: %v", err)
+		audioData, err = resumeDownload(newRequest, audioData, err)
+		if errors.Is(err, ErrRecordingTooLarge) {
+			return nil, http.StatusRequestEntityTooLarge, err
+		}
+		if err != nil {
+			// A connection lost part way is worth retrying, so it reports no status
+			return nil, 0, err
+		}
 	}
 
-	return audioData, nil
+	return audioData, resp.StatusCode, nil
 }
 
 // TranscribeAudioOnly transcribes audio without answering questions
 func (tp *TranscriptionPipeline) TranscribeAudioOnly(audioContent []byte) (string, error) {
-	// Encode audio to base64
-	audioBase64 := base64.StdEncoding.EncodeToString(audioContent)
-
-	prompt := "Please transcribe the following audio file."
+	prompt := fmt.Sprintf("Please transcribe the following audio file.\n\n%s", diarizationInstructions)
 
 	// Prepare the request
-	geminiURL := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:generateContent"
-	
 	requestData := GeminiRequest{
 		Contents: []Content{
 			{
@@ -258,124 +671,218 @@ func (tp *TranscriptionPipeline) TranscribeAudioOnly(audioContent []byte) (strin
 						Text: prompt,
 					},
 					{
-						InlineData: &InlineData{
-							MimeType: "audio/mpeg",
-							Data:     audioBase64,
-						},
+						InlineData: audioInlineData(audioContent),
+					},
+				},
+			},
+		},
+		GenerationConfig: &GenerationConfig{MaxOutputTokens: geminiMaxOutputTokens()},
+	}
+
+	transcription, err := tp.generateContent("transcribe_audio", requestData, 30*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if transcription == "" {
+		return "", fmt.Errorf("%w from Gemini API: no transcription", ErrEmptyResponse)
+	}
+
+	return transcription, nil
+}
+
+// GenerateText sends a text-only prompt to Gemini and returns the response text.
+// When jsonOutput is set the model is asked to respond with a JSON document.
+func (tp *TranscriptionPipeline) GenerateText(prompt string, jsonOutput bool) (string, error) {
+	if tokens := estimateTokens(len(prompt)); tokens > geminiMaxInputTokens() {
+		return "", fmt.Errorf("prompt of about %d tokens exceeds the Gemini input limit of %d tokens", tokens, geminiMaxInputTokens())
+	}
+
+	requestData := GeminiRequest{
+		Contents: []Content{
+			{
+				Parts: []Part{
+					{
+						Text: prompt,
 					},
 				},
 			},
 		},
 	}
+	if jsonOutput {
+		requestData.GenerationConfig = &GenerationConfig{ResponseMimeType: "application/json"}
+	}
+
+	responseText, err := tp.generateContent("generate_text", requestData, 45*time.Second)
+	if err != nil {
+		return "", err
+	}
+	if responseText == "" {
+		return "", fmt.Errorf("%w from Gemini API", ErrEmptyResponse)
+	}
 
-	jsonData, err := json.Marshal(requestData)
+	return responseText, nil
+}
+
+// geminiModelURL is the AI Studio endpoint of a model's method (e.g. generateContent), formatted with both
+const geminiModelURL = "https://generativelanguage.googleapis.com/v1beta/models/%s:%s"
+
+// generateContent sends a request to Gemini and returns the response text, falling back to cheaper
+// models when the model is unavailable. A request blocked by the safety filters is retried once with
+// relaxed safety settings; blocks are returned as *GeminiBlockedError.
+func (tp *TranscriptionPipeline) generateContent(name string, requestData GeminiRequest, timeout time.Duration) (string, error) {
+	text, err := tp.generateContentWithFallback(name, requestData, timeout)
+
+	var blocked *GeminiBlockedError
+	if errors.As(err, &blocked) && blocked.SafetyBlock() && requestData.SafetySettings == nil && geminiSafetyRetryEnabled() {
+		requestData.SafetySettings = relaxedSafetySettings()
+		return tp.generateContentWithFallback(name+"_relaxed_safety", requestData, timeout)
+	}
+
+	return text, err
+}
+
+// sendGenerateContent performs a single generateContent request to the model, recording the exchange for archival
+func (tp *TranscriptionPipeline) sendGenerateContent(name, model string, requestData GeminiRequest, timeout time.Duration) (string, error) {
+	body, err := encodeGeminiRequest(requestData)
 	if err != nil {
 		return "", fmt.Errorf("error marshaling request: %v", err)
 	}
+	// Gemini rejects oversized requests with a bare 400; fail with the reason instead
+	if body.Len() > geminiMaxRequestBytes() {
+		return "", fmt.Errorf("request of %d bytes exceeds the Gemini request limit of %d bytes", body.Len(), geminiMaxRequestBytes())
+	}
+
+	// Stay within the Gemini quota shared by every invocation
+	if err := tp.waitForGeminiQuota(model); err != nil {
+		return "", err
+	}
 
-	req, err := http.NewRequest("POST", geminiURL, bytes.NewBuffer(jsonData))
+	req, err := tp.newGeminiRequest(model, "generateContent", body)
 	if err != nil {
 		return "", fmt.Errorf("error creating request: %v", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	
-	// Add API key as query parameter
-	q := req.URL.Query()
-	q.Add("key", tp.geminiAPIKey)
-	req.URL.RawQuery = q.Encode()
-
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := sendGeminiRequest(tp.currentGeminiBreaker(model), req, timeout)
 	if err != nil {
-		return "", fmt.Errorf("error making request: %v", err)
+		return "", fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("gemini API error: status %d, body: %s", resp.StatusCode, string(body))
+		tp.archiveProviderResponse(name, model, resp.StatusCode, body)
+		return "", &GeminiAPIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("error reading response: %v", err)
 	}
+	tp.recordGeminiExchange(name, requestData, respBody)
+	tp.archiveProviderResponse(name, model, resp.StatusCode, respBody)
 
 	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", fmt.Errorf("error decoding response: %v", err)
+	if err := json.Unmarshal(respBody, &geminiResp); err != nil {
+		return "", fmt.Errorf("%w: error decoding Gemini response: %v", ErrParseFailure, err)
 	}
+	tp.recordUsage(geminiResp.UsageMetadata)
+	tp.recordCampaignUsage(model, geminiResp.UsageMetadata)
+
+	return geminiResponseText(geminiResp)
+}
 
-	if len(geminiResp.Candidates) == 0 {
-		return "", fmt.Errorf("no response generated from Gemini API")
+// sendGeminiRequest sends a request to the Gemini API through the Gemini circuit breaker.
+// Connection errors, 429s and 5xx responses count as failures.
+func sendGeminiRequest(breaker *CircuitBreaker, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if err := breaker.Allow(); err != nil {
+		return nil, err
 	}
 
-	if len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", fmt.Errorf("no content parts in Gemini response")
+	resp, err := geminiClient(timeout).Do(req)
+	if err != nil {
+		breaker.RecordFailure()
+		return nil, err
 	}
 
-	transcription := geminiResp.Candidates[0].Content.Parts[0].Text
-	if transcription == "" {
-		return "", fmt.Errorf("empty transcription received from Gemini API")
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		breaker.RecordFailure()
+	} else {
+		breaker.RecordSuccess()
 	}
 
-	return transcription, nil
+	return resp, nil
 }
 
-// ProcessAudioWithGemini transcribes audio and answers questions in a single call
-func (tp *TranscriptionPipeline) ProcessAudioWithGemini(audioContent []byte, questions []Question) (string, map[string]string, error) {
-	// Encode audio to base64
-	audioBase64 := base64.StdEncoding.EncodeToString(audioContent)
+// extractJSON strips markdown code fences the model sometimes wraps around JSON output
+func extractJSON(responseText string) string {
+	text := strings.TrimSpace(responseText)
+	text = strings.TrimPrefix(text, "```json")
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimSuffix(text, "```")
+	return strings.TrimSpace(text)
+}
 
-	// Prepare questions text for Gemini using details from database
-	questionsText := ""
-	var answerConstraints []string
+// buildQuestionsPrompt renders the numbered questions and their answer constraints for the prompt,
+// asking for free-text answers in outputLanguage when it is set. The returned question IDs are in
+// prompt order so "Answer N" can be mapped back to a question.
+func buildQuestionsPrompt(questions []Question, outputLanguage string) (string, string, []string) {
+	promptQuestions := make([]promptQuestion, len(questions))
 	questionIDs := make([]string, len(questions))
-
 	for i, q := range questions {
 		questionIDs[i] = q.ID
-		questionsText += fmt.Sprintf("%d. %s\n", i+1, q.QuestionText)
-
-		// Use instructions from details column instead of hardcoded constraints
-		if q.Instructions != "" {
-			answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: %s", i+1, q.Instructions))
-		} else {
-			// Fallback to basic constraints if no instructions in details
-			switch q.AnswerType {
-			case "boolean":
-				answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be ONLY 'true' or 'false'", i+1))
-			case "integer":
-				answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be ONLY a number (no units, no text)", i+1))
-			case "description":
-				answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer must be a descriptive summary", i+1))
-			default:
-				answerConstraints = append(answerConstraints, fmt.Sprintf("Question %d: Answer should be clear and concise", i+1))
-			}
+		promptQuestions[i] = promptQuestion{
+			Group:        q.Group,
+			QuestionText: q.QuestionText,
+			AnswerType:   q.AnswerType,
+			Instructions: q.Instructions,
+			Options:      q.Options,
 		}
 	}
 
-	constraintsText := strings.Join(answerConstraints, "\n")
-
-	prompt := fmt.Sprintf(`
-Please transcribe the following audio file and then answer the questions based on the transcription.
+	questionsText, constraintsText := renderQuestionsPrompt(promptQuestions, outputLanguage)
+	return questionsText, constraintsText, questionIDs
+}
 
-QUESTIONS TO ANSWER:
-%s
+// AnswerQuestionsFromTranscript answers the questions from an existing transcription with a text-only request,
+// to the campaign's answering model when it uses two-phase processing.
+// A transcription too long for the model's input limit is truncated in the middle.
+func (tp *TranscriptionPipeline) AnswerQuestionsFromTranscript(transcription string, questions []Question) (map[string]string, error) {
+	tp.reportStage(StageAnswering)
+	answeringStart := time.Now()
+	defer func() { tp.metadata.Stages.Answering += elapsedMs(answeringStart) }()
+
+	questionsText, constraintsText, questionIDs := buildQuestionsPrompt(questions, tp.outputLanguage)
+
+	instructions := tp.variantInstructions() + tp.examplesPrompt(questions) + tp.callDetailsPrompt()
+	otherLength := len(fmt.Sprintf(transcriptPromptTemplate, "", questionsText, constraintsText, instructions))
+	transcription, omitted := fitTranscriptionToPrompt(transcription, otherLength)
+	if omitted > 0 {
+		tp.recordTruncation(omitted)
+	}
+	prompt := fmt.Sprintf(transcriptPromptTemplate, transcription, questionsText, constraintsText, instructions)
 
-ANSWER CONSTRAINTS:
-%s
+	if tp.twoPhase != nil {
+		tp.modelOverride = tp.twoPhase.answeringModel()
+	}
+	responseText, err := tp.GenerateText(prompt, false)
+	tp.modelOverride = ""
+	if err != nil {
+		return nil, err
+	}
+	tp.metadata.AnsweringModel = tp.servedModel
 
-IMPORTANT: Follow the answer constraints exactly as specified for each question.
+	_, answers := tp.parseTranscriptionAndAnswers(responseText, questionIDs)
+	return answers, nil
+}
 
-Please provide your response in the following format:
-TRANSCRIPTION:
-[transcribed text here]
+// ProcessAudioWithGemini transcribes audio and answers questions in a single call
+func (tp *TranscriptionPipeline) ProcessAudioWithGemini(audioContent []byte, questions []Question) (string, map[string]string, error) {
+	// Prepare questions text for Gemini using details from database
+	questionsText, constraintsText, questionIDs := buildQuestionsPrompt(questions, tp.outputLanguage)
 
-ANSWERS:
-Answer 1: [your answer]
-Answer 2: [your answer]
-etc.
-`, questionsText, constraintsText)
+	prompt := fmt.Sprintf(audioPromptTemplate, diarizationInstructions, questionsText, constraintsText, tp.variantInstructions()+tp.examplesPrompt(questions)+tp.callDetailsPrompt())
 
 	// Prepare the request
-	geminiURL := "https://generativelanguage.googleapis.com/v1beta/models/gemini-2.5-pro:generateContent"
-	
 	requestData := GeminiRequest{
 		Contents: []Content{
 			{
@@ -384,61 +891,20 @@ etc.
 						Text: prompt,
 					},
 					{
-						InlineData: &InlineData{
-							MimeType: "audio/mpeg",
-							Data:     audioBase64,
-						},
+						InlineData: audioInlineData(audioContent),
 					},
 				},
 			},
 		},
+		GenerationConfig: &GenerationConfig{MaxOutputTokens: geminiMaxOutputTokens()},
 	}
 
-	jsonData, err := json.Marshal(requestData)
-	if err != nil {
-		return "", nil, fmt.Errorf("error marshaling request: %v", err)
-	}
-
-	req, err := http.NewRequest("POST", geminiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", nil, fmt.Errorf("error creating request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	
-	// Add API key as query parameter
-	q := req.URL.Query()
-	q.Add("key", tp.geminiAPIKey)
-	req.URL.RawQuery = q.Encode()
-
-	client := &http.Client{Timeout: 45 * time.Second} // Reduced timeout for faster failure
-	resp, err := client.Do(req)
+	responseText, err := tp.generateContent("process_audio", requestData, 45*time.Second) // Reduced timeout for faster failure
 	if err != nil {
-		return "", nil, fmt.Errorf("error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", nil, fmt.Errorf("gemini API error: status %d, body: %s", resp.StatusCode, string(body))
-	}
-
-	var geminiResp GeminiResponse
-	if err := json.NewDecoder(resp.Body).Decode(&geminiResp); err != nil {
-		return "", nil, fmt.Errorf("error decoding response: %v", err)
-	}
-
-	if len(geminiResp.Candidates) == 0 {
-		return "", nil, fmt.Errorf("no response generated from Gemini API")
-	}
-
-	if len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return "", nil, fmt.Errorf("no content parts in Gemini response")
+		return "", nil, err
 	}
-
-	responseText := geminiResp.Candidates[0].Content.Parts[0].Text
 	if responseText == "" {
-		return "", nil, fmt.Errorf("empty response received from Gemini API")
+		return "", nil, fmt.Errorf("%w from Gemini API", ErrEmptyResponse)
 	}
 	
 	// Parse transcription and answers
@@ -503,112 +969,845 @@ func (tp *TranscriptionPipeline) parseTranscriptionAndAnswers(responseText strin
 	return transcription, answers
 }
 
-// SaveCallAnalysis saves the analysis data to the callAnalysis column
-func (tp *TranscriptionPipeline) SaveCallAnalysis(callLogsID string, transcription string, answers map[string]string) error {
-	// Prepare the analysis data
-	analysisData := CallAnalysisData{
-		Transcription: transcription,
-		Answers:       answers,
-		ProcessedAt:   time.Now().Format(time.RFC3339),
+// TranscriptionResult represents the outcome of transcribing a recording and answering its questions
+type TranscriptionResult struct {
+	Transcription string
+	Answers       map[string]string
+	Words         []TranscriptWord
+	Provider      string
+	CacheHit      bool
+	// Disposition is set when the recording was classified; only conversations are transcribed
+	Disposition       string
+	DispositionReason string
+	// RequestBudget records how the Gemini requests were fitted within the model's limits
+	RequestBudget *RequestBudget
+	// AudioQuality is set when the quality check scored the recording; SkipReason is set when it
+	// was too poor to transcribe
+	AudioQuality *AudioQuality
+	SkipReason   string
+	// ModelFallback is set when a fallback model answered instead of the configured one
+	ModelFallback bool
+}
+
+// transcriptionProviderName normalizes a configured provider name; empty is Gemini
+func transcriptionProviderName(provider string) string {
+	if provider = strings.ToLower(provider); provider == "" {
+		return ProviderGemini
+	}
+	return provider
+}
+
+// cacheQuestionsHash returns the part of a cached transcription's key that isn't the recording:
+// the questions and whatever else changes the result for them
+func (tp *TranscriptionPipeline) cacheQuestionsHash(questions []Question, provider string) string {
+	questionsHash := questionsFingerprint(questions)
+	if provider != ProviderGemini {
+		// Keep results from different providers apart so they can be benchmarked against each other
+		questionsHash = sha256Hex([]byte(provider + questionsHash))
+	}
+	if tp.splitChannels {
+		// Split-channel transcriptions are attributed differently from mixed ones
+		questionsHash = sha256Hex([]byte("split-channels" + questionsHash))
+	}
+	if tp.outputLanguage != "" {
+		// Answers in another language can't be reused
+		questionsHash = sha256Hex([]byte("language:" + strings.ToLower(tp.outputLanguage) + questionsHash))
+	}
+	if tp.profile != nil && tp.profile.Model != "" {
+		// A learned profile's model answers differently from the default
+		questionsHash = sha256Hex([]byte("model:" + tp.profile.Model + questionsHash))
+	}
+	if tp.twoPhase != nil {
+		// Answers by the answering model aren't reused for single-request calls, nor the other way round
+		questionsHash = sha256Hex([]byte("two-phase:" + tp.twoPhase.fingerprint() + questionsHash))
+	}
+	return questionsHash
+}
+
+// useTranscriptionCache reports whether transcriptions are looked up and saved in the cache.
+// Experiment calls skip the cache so each variant's answers and token usage are its own.
+func (tp *TranscriptionPipeline) useTranscriptionCache() bool {
+	return tp.cacheEnabled && tp.variant == nil
+}
+
+// cacheable reports whether the result is a transcription by the requested provider and model,
+// which can be reused for duplicate recordings
+func (r *TranscriptionResult) cacheable(provider string) bool {
+	return r.SkipReason == "" && (r.Disposition == "" || r.Disposition == DispositionConversation) && r.Provider == provider && !r.ModelFallback
+}
+
+// TranscribeRecording downloads the recording and transcribes it with the given provider, answering the questions if any.
+// With the default Gemini provider both happen in a single call; other providers transcribe first
+// and the questions are answered from the transcription in a text-only Gemini request.
+// When the transcription cache is enabled, duplicate recordings (same URL or same audio bytes)
+// reuse the cached result instead of calling the provider again.
+func (tp *TranscriptionPipeline) TranscribeRecording(recordingURL string, questions []Question, provider string) (*TranscriptionResult, error) {
+	provider = transcriptionProviderName(provider)
+	tp.requestBudget = nil
+
+	urlHash := sha256Hex([]byte(recordingURL))
+	questionsHash := tp.cacheQuestionsHash(questions, provider)
+	useCache := tp.useTranscriptionCache()
+
+	// Cache lookups are best-effort; a failed lookup is treated as a miss
+	if useCache {
+		if cached, err := tp.GetCachedTranscriptionByURL(urlHash, questionsHash); err == nil && cached != nil {
+			tp.cancelPrefetch()
+			return &TranscriptionResult{Transcription: cached.Transcription, Answers: cached.Answers, Words: cached.Words, Provider: provider, CacheHit: true}, nil
+		}
 	}
 
-	// Convert to JSON
-	analysisJSON, err := json.Marshal(analysisData)
+	// Download audio
+	tp.reportStage(StageDownloading)
+	downloadStart := time.Now()
+	audioContent, err := tp.awaitAudio(recordingURL)
+	tp.metadata.Stages.Download += elapsedMs(downloadStart)
 	if err != nil {
-		return fmt.Errorf("error marshaling analysis data: %v", err)
+		return nil, fmt.Errorf("failed to download audio: %w", err)
 	}
+	tp.metadata.AudioBytes = len(audioContent)
+	logRecordingMemory(len(audioContent))
 
-	// Update only the callAnalysis column for the specific ID
-	updateQuery := `
-		UPDATE "smartFlo".call_logs 
-		SET "callAnalysis" = $1
-		WHERE id = $2
-	`
+	// Check if audio content is empty
+	if len(audioContent) == 0 {
+		return nil, fmt.Errorf("downloaded audio file is empty")
+	}
 
-	_, err = tp.db.Exec(updateQuery, string(analysisJSON), callLogsID)
+	contentHash := sha256Hex(audioContent)
+	if useCache {
+		if cached, err := tp.GetCachedTranscriptionByContent(contentHash, questionsHash); err == nil && cached != nil {
+			return &TranscriptionResult{Transcription: cached.Transcription, Answers: cached.Answers, Words: cached.Words, Provider: provider, CacheHit: true}, nil
+		}
+	}
+
+	result, err := tp.transcribeAudio(audioContent, questions, provider)
 	if err != nil {
-		return fmt.Errorf("error updating callAnalysis: %v", err)
+		return nil, err
+	}
+
+	// Failing to populate the cache doesn't fail the call. Skipped recordings, non-conversations
+	// and fallback transcriptions aren't cached.
+	if useCache && result.cacheable(provider) {
+		_ = tp.SaveTranscriptionCache(urlHash, contentHash, questionsHash, result)
 	}
 
+	return result, nil
+}
+
+// transcribeAudio transcribes a downloaded recording with the given provider, answering the
+// questions if any: video recordings are reduced to their audio track, the audio is scored,
+// preprocessed or split into channels, and classified before it is transcribed.
+func (tp *TranscriptionPipeline) transcribeAudio(audioContent []byte, questions []Question, provider string) (*TranscriptionResult, error) {
+	tp.errorContext.Provider = transcriptionProviderName(provider)
+	var transcription string
+	var answers map[string]string
+	var words []TranscriptWord
+	var modelFallback bool
+	var err error
+
+	// Answering done from the transcription is timed separately and taken out of the transcription stage
+	tp.reportStage(StageTranscribing)
+	transcriptionStart, answeringBefore := time.Now(), tp.metadata.Stages.Answering
+	defer func() {
+		tp.metadata.Stages.Transcription += elapsedMs(transcriptionStart) - (tp.metadata.Stages.Answering - answeringBefore)
+	}()
+
+	// Video recordings are transcribed from their audio track
+	audioContent = tp.extractVideoAudio(audioContent)
+
+	// Score the recording; unusable audio can be skipped rather than transcribed into a hallucination
+	quality := tp.assessAudioQuality(audioContent)
+	if quality != nil && quality.BelowThreshold && audioQualityAction() == AudioQualityActionSkip {
+		return &TranscriptionResult{Answers: map[string]string{}, Provider: provider, AudioQuality: quality, SkipReason: audioQualitySkipReason(quality)}, nil
+	}
+
+	// Split-channel stereo recordings get each speaker's turns from their own channel
+	var agentAudio, customerAudio []byte
+	split := false
+	if tp.splitChannels {
+		agentAudio, customerAudio, split = splitStereoChannels(audioContent)
+	} else {
+		// Cache keys use the original recording; only the provider sees the preprocessed audio
+		audioContent = tp.preprocessAudio(audioContent)
+	}
+
+	// Voicemails, IVRs and dead air aren't transcribed or cached
+	disposition, dispositionReason := tp.detectDisposition(audioContent)
+	if disposition != "" && disposition != DispositionConversation {
+		return &TranscriptionResult{Answers: map[string]string{}, Provider: provider, Disposition: disposition, DispositionReason: dispositionReason, AudioQuality: quality}, nil
+	}
+
+	if split {
+		transcription, answers, words, err = tp.transcribeSplitChannels(provider, agentAudio, customerAudio, questions)
+		if err != nil {
+			return nil, err
+		}
+	} else if provider != ProviderGemini {
+		transcription, answers, words, err = tp.transcribeWithProvider(provider, audioContent, questions)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		fallbacks := tp.metadata.ModelFallbacks
+		// Two-phase campaigns only ask the questions that need the audio in the audio request
+		audioQuestions, textQuestions := tp.phaseQuestions(questions)
+		budget := tp.planAudioRequest(audioContent, audioQuestions)
+		tp.requestBudget = budget
+		if budget.Strategy == RequestStrategyChunked {
+			// Too large for one request: transcribe in chunks and answer from the merged transcription
+			transcription, answers, err = tp.transcribeInChunks(audioContent, questions, budget)
+			textQuestions = nil
+		} else if len(audioQuestions) == 0 {
+			// No questions linked to campaign, or all answered from the transcription - only transcribe audio
+			transcription, err = tp.TranscribeAudioOnly(audioContent)
+			answers = make(map[string]string)
+			// The model that answered the request, which may be a fallback
+			tp.metadata.TranscriptionModel = tp.servedModel
+		} else {
+			// Process audio and answer questions in a single call
+			transcription, answers, err = tp.ProcessAudioWithGemini(audioContent, audioQuestions)
+			tp.metadata.TranscriptionModel = tp.servedModel
+			tp.metadata.AnsweringModel = tp.servedModel
+		}
+
+		var blocked *GeminiBlockedError
+		if errors.As(err, &blocked) && geminiBlockFallbackProvider() != "" {
+			// Gemini refused the recording; use the fallback provider. Its result isn't cached under Gemini's key.
+			fallback := geminiBlockFallbackProvider()
+			transcription, answers, words, err = tp.transcribeWithProvider(fallback, audioContent, questions)
+			if err != nil {
+				return nil, fmt.Errorf("%w; fallback failed: %v", blocked, err)
+			}
+			return &TranscriptionResult{Transcription: transcription, Answers: answers, Words: words, Provider: fallback, RequestBudget: tp.requestBudget, AudioQuality: quality}, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to process audio: %w", err)
+		}
+
+		// The second phase answers the rest of the questions from the transcription
+		if len(textQuestions) > 0 {
+			textAnswers, err := tp.AnswerQuestionsFromTranscript(transcription, textQuestions)
+			if err != nil {
+				return nil, fmt.Errorf("failed to answer questions: %w", err)
+			}
+			for id, answer := range textAnswers {
+				answers[id] = answer
+			}
+		}
+
+		// Answers by an over-budget campaign's downgrade model aren't kept for other calls either
+		modelFallback = tp.metadata.ModelFallbacks > fallbacks || tp.budgetModel != ""
+	}
+
+	return &TranscriptionResult{Transcription: transcription, Answers: answers, Words: words, Provider: provider, Disposition: disposition, RequestBudget: tp.requestBudget, AudioQuality: quality, ModelFallback: modelFallback}, nil
+}
+
+// transcribeWithProvider transcribes the audio with a non-Gemini provider and answers the questions
+// from the transcription in a text-only Gemini request
+func (tp *TranscriptionPipeline) transcribeWithProvider(provider string, audioContent []byte, questions []Question) (string, map[string]string, []TranscriptWord, error) {
+	transcriber, err := NewTranscriber(provider, tp)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	transcript, err := transcriber.Transcribe(audioContent)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to transcribe audio with %s: %v", transcriber.Name(), err)
+	}
+	tp.metadata.TranscriptionModel = transcript.Model
+
+	answers := make(map[string]string)
+	if len(questions) > 0 {
+		answers, err = tp.AnswerQuestionsFromTranscript(transcript.Text, questions)
+		if err != nil {
+			return "", nil, nil, fmt.Errorf("failed to answer questions: %w", err)
+		}
+	}
+
+	return transcript.Text, answers, transcript.Words, nil
+}
+
+// SaveCallAnalysis saves the analysis to each configured result store in turn (RESULT_STORES,
+// default the callAnalysis column), then runs the related writes, all in one transaction: the first
+// failure rolls back every database write. Uploads to the s3 store happen only once the transaction
+// has committed, and an upload failure doesn't fail the save. It fails with ErrAnalysisConflict when the call's analysis was saved by
+// someone else since callData was read.
+func (tp *TranscriptionPipeline) SaveCallAnalysis(callData *CallData, analysisData CallAnalysisData, related ...func(tx *Tx) error) error {
+	if analysisData.ProcessedAt == "" {
+		analysisData.ProcessedAt = time.Now().Format(time.RFC3339)
+	}
+	// The numbers come from the call record, so every analysis saved for the call carries them
+	analysisData.PhoneNumbers = callPhoneNumbers(callData)
+
+	tx, err := tp.repo.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting analysis transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := tp.checkAnalysisVersion(tx, callData); err != nil {
+		return err
+	}
+
+	stores := tp.resultStores
+	if len(stores) == 0 {
+		stores = []ResultStore{&PostgresResultStore{pipeline: tp}}
+	}
+	for _, store := range stores {
+		if err := store.Save(tx, callData.ID, analysisData); err != nil {
+			return fmt.Errorf("error saving analysis to the %s result store: %w", store.Name(), err)
+		}
+	}
+
+	for _, write := range related {
+		if err := write(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing analysis: %v", err)
+	}
 	return nil
 }
 
 // ProcessCall processes a call: transcribe audio and answer questions
-func (tp *TranscriptionPipeline) ProcessCall(callLogsID string) (map[string]interface{}, error) {
+func (tp *TranscriptionPipeline) ProcessCall(callLogsID string) (_ map[string]interface{}, err error) {
+	tp.startProcessingMetadata()
+	tp.errorContext = errorContext{CallLogsID: callLogsID}
+	defer func() { tp.reportError(err) }()
+
 	// Connect to database
 	if err := tp.ConnectToDatabase(); err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %v", err)
 	}
 	defer tp.CloseDatabase()
+	defer func() { tp.reportFinished(err) }()
+
+	// Record the outcome for the daily digest and publish it to subscribers
+	var campaignID string
+	var completed *CallAnalysisData
+	defer func() {
+		// Duplicate invocations aren't processing attempts
+		if tp.dryRun || errors.Is(err, ErrAlreadyProcessed) || errors.Is(err, ErrCallLocked) || errors.Is(err, ErrDuplicateInProgress) || errors.Is(err, ErrAnalysisConflict) {
+			return
+		}
+		tp.RecordProcessingRun(callLogsID, completed, err)
+		tp.PublishAnalysisEvent(callLogsID, campaignID, completed, err)
+	}()
+
+	// Hold the call's lock so a duplicate delivery running concurrently doesn't call Gemini and
+	// save the analysis a second time; dry runs write nothing and don't need it
+	if processingLockEnabled() && !tp.dryRun {
+		lock, err := tp.AcquireProcessingLock(callLogsID)
+		if err != nil {
+			return nil, err
+		}
+		defer tp.ReleaseProcessingLock(lock)
+	}
+
+	// Get the call, its campaign's settings and everything its analysis needs
+	call, err := tp.fetchCall(callLogsID, tp.replay == nil, tp.replay == nil)
+	if call != nil {
+		campaignID = call.callData.CampaignID
+		defer tp.releaseLocks(call.keyLocks)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if call.duplicate != nil {
+		result, analysis, err := tp.linkDuplicateCall(call.callData, call.duplicate)
+		if err != nil {
+			return nil, err
+		}
+		completed = analysis
+		return tp.shapeResponse(result, analysis), nil
+	}
+	if call.skipReason != "" {
+		result, analysis, err := tp.skipCall(call.callData, call.skipReason, nil)
+		if err != nil {
+			return nil, err
+		}
+		completed = analysis
+		return tp.shapeResponse(result, analysis), nil
+	}
+
+	// Transcribe the recording and answer questions, reusing cached results for duplicate recordings.
+	// Calls with several recording legs are merged into one timeline instead.
+	var transcriptionResult *TranscriptionResult
+	if tp.replay != nil {
+		// Replays parse the archived response instead (see ReplayCall)
+		if transcriptionResult, err = tp.replayTranscription(call.questions); err != nil {
+			return nil, err
+		}
+	} else {
+		if len(call.callData.RecordingLegs) > 1 {
+			transcriptionResult, err = tp.TranscribeRecordingLegs(call.callData.RecordingLegs, call.questions, call.provider)
+		} else {
+			transcriptionResult, err = tp.TranscribeRecording(call.callData.RecordingURL, call.questions, call.provider)
+		}
+		if err != nil {
+			return nil, &ProviderError{Err: err}
+		}
+	}
+
+	result, analysis, err := tp.completeCall(call, transcriptionResult)
+	if err != nil {
+		return nil, err
+	}
+	completed = analysis
+
+	return tp.shapeResponse(result, analysis), nil
+}
+
+// callContext is what processing a call needs besides its recording: the call, its campaign's
+// settings, questions, compliance rules and rubric, and the provider and prompt variant to use
+type callContext struct {
+	callData        *CallData
+	settings        *CampaignSettings
+	questions       []Question
+	complianceRules []ComplianceRule
+	rubric          []RubricCriterion
+	provider        string
+	variant         *PromptVariant
+	variantName     string
+	// skipReason is set when the call fails the pre-flight rules; nothing else is read then
+	skipReason string
+	// duplicate is set when another row of the call was already processed; nothing else is read then
+	duplicate *DuplicateLink
+	// keyLocks lease the call's call_id and recording URL while it is processed (see lockDuplicateKeys)
+	keyLocks []*ProcessingLock
+}
+
+// fetchCall reads the call and everything its analysis needs, and configures the pipeline's prompt
+// variant, output language and fallback recording URLs for it. The campaign's settings, questions,
+// compliance rules and rubric are read concurrently and, with prefetchAudio, the recording is
+// downloaded meanwhile. With checkDuplicates, rows duplicating an analysed row are found first. The
+// context is returned with the call data as soon as the call has been read, even when a later
+// check fails. A prefetched download is cancelled when the call fails or is skipped.
+func (tp *TranscriptionPipeline) fetchCall(callLogsID string, prefetchAudio, checkDuplicates bool) (call *callContext, err error) {
+	// A download left over from an earlier call is never awaited
+	tp.cancelPrefetch()
 
 	// Get call data
+	tp.reportStage(StageFetching)
+	dbFetchStart := time.Now()
 	callData, err := tp.GetCallData(callLogsID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get call data: %v", err)
+		return nil, fmt.Errorf("failed to get call data: %w", err)
+	}
+	call = &callContext{callData: callData}
+	tp.promptCall = callData
+
+	if callData.Analyzed && !tp.reprocess && !tp.dryRun {
+		return call, ErrAlreadyProcessed
 	}
 
 	if callData.RecordingURL == "" {
-		return nil, fmt.Errorf("no recording URL found for this call")
+		return call, ErrNoRecordingURL
 	}
 
 	if callData.CampaignID == "" {
-		return nil, fmt.Errorf("no campaign ID found for this call")
+		return call, fmt.Errorf("no campaign ID found for this call")
+	}
+	tp.errorContext.CampaignID = callData.CampaignID
+
+	// Provider retries create rows for calls that were already processed; they get the call's analysis
+	if checkDuplicates && duplicateDetectionEnabled() {
+		if call.duplicate, err = tp.findDuplicateCall(callData); err != nil {
+			return call, err
+		}
+		// Lease the call's keys, then look again: a row of the same call may have been saved meanwhile
+		if call.duplicate == nil && processingLockEnabled() && !tp.dryRun {
+			if call.keyLocks, err = tp.lockDuplicateKeys(callData); err != nil {
+				return call, err
+			}
+			if call.duplicate, err = tp.findDuplicateCall(callData); err != nil {
+				return call, err
+			}
+		}
+		if call.duplicate != nil {
+			tp.metadata.Stages.DBFetch = elapsedMs(dbFetchStart)
+			return call, nil
+		}
+	}
+
+	// Start downloading the recording while the campaign is read, unless the pipeline-wide pre-flight
+	// rules skip the call; the campaign's own rules are checked before it is used
+	tp.fallbackRecordingURLs = callData.FallbackRecordingURLs
+	if prefetchAudio && len(callData.RecordingLegs) <= 1 && tp.eligibility.skipReason(callData) == "" {
+		tp.prefetchAudio(callData.RecordingURL)
+		defer func() {
+			if err != nil || call.skipReason != "" {
+				tp.cancelPrefetch()
+			}
+		}()
+	}
+
+	// The campaign's settings, questions, compliance rules and rubric don't depend on each other
+	var g errgroup.Group
+	g.Go(func() error {
+		// Per-campaign settings; the campaign's provider takes precedence over TRANSCRIPTION_PROVIDER
+		settings, err := tp.GetCampaignSettings(callData.CampaignID)
+		if err != nil {
+			return fmt.Errorf("failed to get campaign settings: %v", err)
+		}
+		call.settings = settings
+		return nil
+	})
+	g.Go(func() error {
+		// Questions specific to the campaign, with any ad-hoc questions of the request
+		questions, err := tp.questionsForCampaign(callData.CampaignID)
+		if err != nil {
+			return fmt.Errorf("failed to get questions for campaign: %v", err)
+		}
+		call.questions = questions
+		return nil
+	})
+	g.Go(func() error {
+		// Keyword/regex compliance rules for the campaign
+		rules, err := tp.GetComplianceRulesForCampaign(callData.CampaignID)
+		if err != nil {
+			return fmt.Errorf("failed to get compliance rules for campaign: %v", err)
+		}
+		call.complianceRules = rules
+		return nil
+	})
+	g.Go(func() error {
+		// QA scoring rubric for the campaign
+		rubric, err := tp.GetRubricForCampaign(callData.CampaignID)
+		if err != nil {
+			return fmt.Errorf("failed to get rubric for campaign: %v", err)
+		}
+		call.rubric = rubric
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		return call, err
+	}
+
+	if err := tp.useGeminiTenant(call.settings.GeminiTenant); err != nil {
+		return call, err
+	}
+	tp.fallbackModels = call.settings.GeminiFallbackModels
+	tp.debugResponses = call.settings.DebugResponses
+	if err := tp.applyCampaignBudget(callData.CampaignID, call.settings.Budget); err != nil {
+		return call, err
 	}
+	tp.profile = tp.selectCampaignProfile(callData.CampaignID, callLogsID, call.settings.ProfileLearning)
+	tp.metadata.Profile = tp.profile
 
-	// Get questions specific to the campaign
-	questions, err := tp.GetQuestionsForCampaign(callData.CampaignID)
+	// Calls that fail the pre-flight rules (e.g. abandoned two-second calls) are skipped instead of analysed
+	rules, err := tp.eligibility.withCampaignRules(call.settings.Eligibility)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get questions for campaign: %v", err)
+		return call, err
+	}
+	if call.skipReason = rules.skipReason(callData); call.skipReason != "" {
+		return call, nil
 	}
 
-	// Download audio
-	audioContent, err := tp.DownloadAudio(callData.RecordingURL)
+	call.provider = call.settings.TranscriptionProvider
+	if call.provider == "" {
+		call.provider = tp.transcriptionProvider
+	}
+
+	// Assign the call to a prompt variant when the campaign runs a prompt experiment
+	if call.settings.PromptExperiment != nil {
+		call.variantName = call.settings.PromptExperiment.assign(callLogsID)
+	}
+	// A learned profile's variant and output language take precedence over the campaign's
+	if tp.profile != nil && tp.profile.Variant != "" {
+		call.variantName = tp.profile.Variant
+	}
+	if call.variantName != "" {
+		call.variant, err = tp.GetPromptVariant(call.variantName)
+		if err != nil {
+			return call, fmt.Errorf("failed to get prompt variant: %v", err)
+		}
+	}
+	tp.usePromptVariant(call.variant)
+	tp.outputLanguage = call.settings.OutputLanguage
+	if tp.profile != nil && tp.profile.OutputLanguage != "" {
+		tp.outputLanguage = tp.profile.OutputLanguage
+	}
+	tp.useTwoPhase(call.settings.TwoPhase)
+	tp.metadata.Stages.DBFetch = elapsedMs(dbFetchStart)
+
+	return call, nil
+}
+
+// completeCall enriches a transcribed call's analysis and saves it with everything stored
+// alongside. Recordings skipped for their audio quality and non-conversations are saved with just
+// their skip reason or disposition.
+func (tp *TranscriptionPipeline) completeCall(call *callContext, transcriptionResult *TranscriptionResult) (map[string]interface{}, *CallAnalysisData, error) {
+	callData, settings, questions := call.callData, call.settings, call.questions
+	complianceRules, rubric, variant, variantName, provider := call.complianceRules, call.rubric, call.variant, call.variantName, call.provider
+	callLogsID := callData.ID
+
+	// Recordings too poor to transcribe only get their quality score saved
+	if transcriptionResult.SkipReason != "" {
+		return tp.skipCall(callData, transcriptionResult.SkipReason, transcriptionResult.AudioQuality)
+	}
+
+	// Voicemails, IVRs and dead air only get their disposition saved
+	if d := transcriptionResult.Disposition; d != "" && d != DispositionConversation {
+		return tp.completeWithDisposition(callData, transcriptionResult, variantName)
+	}
+
+	transcription := transcriptionResult.Transcription
+	answerUsage := tp.usage
+	tp.reportStage(StageEnriching)
+	enrichmentStart := time.Now()
+
+	// Questions the call didn't address are answered NOT_DISCUSSED rather than left empty
+	answers := markNotDiscussed(transcriptionResult.Answers)
+
+	// Enum answers are reduced to the chosen option; answers outside the allowed options are rejected
+	answers, enumAnswers := validateEnumAnswers(questions, answers)
+
+	// Every question is asked; answers to conditional questions whose conditions aren't met are dropped
+	answers, skippedQuestions := applyQuestionConditions(questions, answers)
+
+	// Parse the diarized transcription into timed segments for grounding, metrics and the enrichments
+	segments := parseDiarizedTranscript(transcription)
+
+	// Answers the transcription gives no evidence for are marked "not found in call"; a verification
+	// failure keeps the answers
+	var grounding *AnswerGrounding
+	if tp.answerGrounding && transcription != "" {
+		grounding = tp.GroundAnswers(transcription, questions, answers, enumAnswers, segments)
+	}
+
+	// Map answers into typed outcome fields configured for the campaign
+	outcomes, outcomeErrors := buildCallOutcomes(settings.OutcomeFields, answers)
+
+	// Compute talk-time and silence metrics from the diarized transcription
+	metrics := computeCallMetrics(segments, callData.Duration)
+
+	// Check mandatory disclosures, including when timed ones were said, and prohibited phrases
+	compliance := checkCompliance(transcription, segments, complianceRules)
+
+	// Score the agent against the campaign rubric; a scoring failure doesn't fail the call
+	scorecard, err := tp.ScoreCallWithRubric(transcription, rubric)
 	if err != nil {
-		return nil, fmt.Errorf("failed to download audio: %v", err)
+		scorecard = &QAScorecard{Criteria: []CriterionScore{}, Error: err.Error()}
 	}
 
-	// Check if audio content is empty
-	if len(audioContent) == 0 {
-		return nil, fmt.Errorf("downloaded audio file is empty")
+	// Classify the call's outcome into the campaign's intent taxonomy; a failure doesn't fail the call
+	var intent *IntentClassification
+	if len(settings.Intents) > 0 && transcription != "" {
+		intent = tp.ClassifyIntent(transcription, settings.Intents)
 	}
 
-	var transcription string
-	var answers map[string]string
+	// Extract structured entities for downstream order matching; an extraction failure doesn't fail the call
+	var entities *EntityExtraction
+	if tp.entityExtraction && transcription != "" {
+		entities = tp.ExtractEntities(transcription, callData.StartDate, segments)
+	}
 
-	if len(questions) == 0 {
-		// No questions linked to campaign - only transcribe audio
-		transcription, err = tp.TranscribeAudioOnly(audioContent)
+	// Turn the commitments made on the call into follow-up tasks; a generation failure doesn't fail the call
+	var followUps *FollowUps
+	if tp.followUpTasks && transcription != "" {
+		followUps = tp.GenerateFollowUps(transcription, callData, segments)
+	}
+
+	// Flag profanity and abuse by either party; a detection failure doesn't fail the call
+	var abuseCheck *AbuseCheck
+	if tp.abuseDetection && transcription != "" {
+		abuseCheck = tp.DetectAbuse(transcription, segments)
+	}
+
+	// Translate the transcript for English-only reviewers; a translation failure doesn't fail the call
+	translation, translationError := "", ""
+	if tp.translateTranscripts && transcription != "" {
+		translation, err = tp.TranslateTranscription(transcription)
 		if err != nil {
-			return nil, fmt.Errorf("failed to transcribe audio: %v", err)
+			translationError = err.Error()
 		}
-		answers = make(map[string]string)
-	} else {
-		// Process audio and answer questions in a single call
-		transcription, answers, err = tp.ProcessAudioWithGemini(audioContent, questions)
-		if err != nil {
-			return nil, fmt.Errorf("failed to process audio: %v", err)
+	}
+
+	// Embed the transcript for semantic search; an embedding failure doesn't fail the call
+	embeddingError := ""
+	if tp.embeddingsEnabled && transcription != "" && !tp.dryRun {
+		if err := tp.SaveCallEmbedding(callLogsID, callData.CampaignID, transcription); err != nil {
+			embeddingError = err.Error()
 		}
 	}
 
-	// Save analysis data to callAnalysis column
-	if err := tp.SaveCallAnalysis(callLogsID, transcription, answers); err != nil {
-		return nil, fmt.Errorf("failed to save call analysis: %v", err)
+	usage := tp.usage
+	analysisData := CallAnalysisData{
+		Transcription:           transcription,
+		TranslatedTranscription: translation,
+		TranslationError:        translationError,
+		Answers:                 answers,
+		CallDisposition:         transcriptionResult.Disposition,
+		SkippedQuestions:        skippedQuestions,
+		EnumAnswers:             enumAnswers,
+		OutcomeErrors:           outcomeErrors,
+		EmbeddingError:          embeddingError,
+		Metrics:                 metrics,
+		Compliance:              compliance,
+		AbuseCheck:              abuseCheck,
+		Intent:                  intent,
+		Entities:                entities,
+		Grounding:               grounding,
+		FollowUps:               followUps,
+		QAScorecard:             scorecard,
+		Words:                   transcriptionResult.Words,
+		Provider:                transcriptionResult.Provider,
+		CacheHit:                transcriptionResult.CacheHit,
+		PromptVariant:           variantName,
+		Usage:                   &usage,
+		RequestBudget:           transcriptionResult.RequestBudget,
+		AudioQuality:            transcriptionResult.AudioQuality,
+		ProcessedAt:             time.Now().Format(time.RFC3339),
+	}
+
+	// Push the results to the campaign's CRM; a failed push is recorded in the analysis and doesn't fail the call
+	if settings.CRM != nil && !tp.dryRun {
+		analysisData.CRMSync = tp.PushToCRM(settings.CRM, callData, &analysisData, outcomes)
 	}
 
+	// Alert immediately on abusive agents rather than waiting for review
+	if abuseCheck != nil && abuseCheck.AgentAbusive && !tp.dryRun {
+		abuseCheck.AlertSent = tp.SendAgentAbuseAlert(callData, abuseCheck)
+	}
+
+	tp.metadata.Stages.Enrichment = elapsedMs(enrichmentStart)
+	analysisData.ProcessingMetadata = tp.processingMetadata()
+
 	// Create minimal response with only essential data
 	result := map[string]interface{}{
-		"call_logsId":  callLogsID,
-		"campaignId":   callData.CampaignID,
-		"transcription": transcription,
-		"answers":       answers,
-		"processed_at":  time.Now().Format(time.RFC3339),
+		"call_logsId":         callLogsID,
+		"campaignId":          callData.CampaignID,
+		"transcription":       transcription,
+		"answers":             answers,
+		"skipped_questions":   skippedQuestions,
+		"enum_answers":        enumAnswers,
+		"metrics":             metrics,
+		"compliance":          compliance,
+		"abuse_check":         abuseCheck,
+		"intent":              intent,
+		"entities":            entities,
+		"grounding":           grounding,
+		"follow_ups":          followUps,
+		"qa_scorecard":        scorecard,
+		"provider":            transcriptionResult.Provider,
+		"cache_hit":           transcriptionResult.CacheHit,
+		"audio_quality":       transcriptionResult.AudioQuality,
+		"processing_metadata": analysisData.ProcessingMetadata,
+		"processed_at":        analysisData.ProcessedAt,
 	}
 
-	return result, nil
+	// A dry run returns the would-be analysis without saving it, for prompt tuning and regression comparisons
+	if tp.dryRun {
+		result["dry_run"] = true
+		result["analysis"] = analysisData
+		return result, nil, nil
+	}
+
+	// The writes that belong to the analysis are saved in its transaction, so an overlapping run
+	// can't leave a mix of its rows and ours
+	var related []func(tx *Tx) error
+
+	// Save the variant's answers and usage for the experiment comparison
+	if variant != nil {
+		related = append(related, func(tx *Tx) error {
+			if err := tp.SavePromptVariantResult(tx, callLogsID, callData.CampaignID, variant, answers, answerUsage, false); err != nil {
+				return fmt.Errorf("failed to save prompt variant result: %v", err)
+			}
+			return nil
+		})
+	}
+
+	// Count the answers' validation towards the learned profile; cached results and budget downgrades
+	// weren't produced by it
+	if tp.profile != nil && !transcriptionResult.CacheHit && tp.budgetModel == "" {
+		profile := tp.profile.CampaignProfile
+		checked, valid := answerValidation(questions, &analysisData)
+		related = append(related, func(tx *Tx) error {
+			if err := tp.RecordProfileStats(tx, callData.CampaignID, profile, checked, valid); err != nil {
+				return fmt.Errorf("failed to save profile statistics: %v", err)
+			}
+			return nil
+		})
+	}
+
+	// Queue the analysis for human review when it trips the campaign's review rules
+	if settings.Review != nil {
+		if reasons := reviewReasons(settings.Review, callLogsID, questions, &analysisData); len(reasons) > 0 {
+			related = append(related, func(tx *Tx) error {
+				if err := tp.FlagForReview(tx, callLogsID, callData.CampaignID, reasons); err != nil {
+					return fmt.Errorf("failed to flag call for review: %v", err)
+				}
+				return nil
+			})
+		}
+	}
+
+	// Save typed outcomes for reporting
+	if len(settings.OutcomeFields) > 0 {
+		related = append(related, func(tx *Tx) error {
+			if err := tp.SaveCallOutcomes(tx, callLogsID, callData.CampaignID, outcomes); err != nil {
+				return fmt.Errorf("failed to save call outcomes: %v", err)
+			}
+			return nil
+		})
+	}
+
+	// Save the follow-up tasks
+	saveFollowUps := followUps != nil && followUps.Error == ""
+	if saveFollowUps {
+		related = append(related, func(tx *Tx) error {
+			if err := tp.SaveCallFollowUps(tx, callLogsID, callData.CampaignID, followUps.Tasks); err != nil {
+				return fmt.Errorf("failed to save follow-up tasks: %v", err)
+			}
+			return nil
+		})
+	}
+
+	// Save SRT/WebVTT subtitles for the playback UI
+	if len(segments) > 0 {
+		related = append(related, func(tx *Tx) error {
+			if err := tp.SaveSubtitles(tx, callLogsID, segments); err != nil {
+				return fmt.Errorf("failed to save subtitles: %v", err)
+			}
+			return nil
+		})
+	}
+
+	// Save analysis data to callAnalysis column
+	tp.reportStage(StageSaving)
+	saveStart := time.Now()
+	if err := tp.SaveCallAnalysis(callData, analysisData, related...); err != nil {
+		return nil, nil, fmt.Errorf("failed to save call analysis: %w", err)
+	}
+
+	// Stream the analysis to the data lake alongside the database write
+	tp.StreamAnalysis(callData, analysisData)
+
+	// Hand the follow-up tasks to the task system
+	if saveFollowUps {
+		tp.PushFollowUps(callData, followUps.Tasks)
+	}
+
+	// Archive raw Gemini payloads and derived artifacts to S3 for audits. The analysis is already
+	// saved, so a failure is logged rather than failing the call.
+	if tp.artifactsBucket != "" {
+		tp.archiveCall(callData, analysisData)
+	}
+	tp.RecordSaveDuration(callLogsID, saveStart)
+
+	// Run the campaign's shadow variants; they don't affect the stored analysis
+	if settings.PromptExperiment != nil && len(settings.PromptExperiment.ShadowVariants) > 0 {
+		tp.runShadowVariants(settings.PromptExperiment, variantName, callData, questions, provider)
+	}
+
+	return result, &analysisData, nil
 }
 
-// LambdaHandler handles Lambda events
-func LambdaHandler(ctx context.Context, request LambdaRequest) (LambdaResponse, error) {
+// NewTranscriptionPipelineFromEnv creates a pipeline configured from environment variables (and .env, if present)
+func NewTranscriptionPipelineFromEnv() (*TranscriptionPipeline, error) {
 	// Load environment variables
 	if err := godotenv.Load(); err != nil {
 		// If .env file doesn't exist, continue with environment variables
@@ -627,9 +1826,57 @@ func LambdaHandler(ctx context.Context, request LambdaRequest) (LambdaResponse,
 
 	// Create pipeline
 	pipeline := NewTranscriptionPipeline(dbConnectionString, geminiAPIKey)
+	pipeline.cacheEnabled = os.Getenv("TRANSCRIPTION_CACHE_ENABLED") == "true"
+	pipeline.transcriptionProvider = os.Getenv("TRANSCRIPTION_PROVIDER")
+	pipeline.openAIAPIKey = os.Getenv("OPENAI_API_KEY")
+	pipeline.deepgramAPIKey = os.Getenv("DEEPGRAM_API_KEY")
+	pipeline.artifactsBucket = os.Getenv("ARTIFACTS_S3_BUCKET")
+	pipeline.artifactsPrefix = os.Getenv("ARTIFACTS_S3_PREFIX")
+	pipeline.embeddingsEnabled = os.Getenv("EMBEDDINGS_ENABLED") == "true"
+	pipeline.audioPreprocessing = os.Getenv("AUDIO_PREPROCESSING") == "true"
+	pipeline.stripSilence = os.Getenv("AUDIO_STRIP_SILENCE") == "true"
+	pipeline.splitChannels = os.Getenv("SPLIT_CHANNEL_RECORDINGS") == "true"
+	pipeline.dispositionDetection = os.Getenv("DISPOSITION_DETECTION") == "true"
+	pipeline.audioQualityCheck = os.Getenv("AUDIO_QUALITY_CHECK") == "true"
+	pipeline.translateTranscripts = os.Getenv("TRANSCRIPT_TRANSLATION") == "true"
+	pipeline.abuseDetection = os.Getenv("ABUSE_DETECTION") == "true"
+	pipeline.entityExtraction = os.Getenv("ENTITY_EXTRACTION") == "true"
+	pipeline.answerGrounding = os.Getenv("ANSWER_GROUNDING") == "true"
+	pipeline.followUpTasks = os.Getenv("FOLLOWUP_TASKS") == "true"
+
+	schema, err := LoadSchemaConfig()
+	if err != nil {
+		return nil, err
+	}
+	pipeline.schema = schema
 
-	// Process the call
-	result, err := pipeline.ProcessCall(request.CallLogsID)
+	eligibility, err := LoadEligibilityRules()
+	if err != nil {
+		return nil, err
+	}
+	pipeline.eligibility = eligibility
+
+	resultStores, err := NewResultStores(os.Getenv("RESULT_STORES"), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	pipeline.resultStores = resultStores
+
+	return pipeline, nil
+}
+
+// SetDryRun makes ProcessCall skip every database write and side effect (archival, events, CRM, streaming).
+// The transcription cache is bypassed too, so prompt changes take effect.
+func (tp *TranscriptionPipeline) SetDryRun(dryRun bool) {
+	tp.dryRun = dryRun
+	if dryRun {
+		tp.cacheEnabled = false
+	}
+}
+
+// LambdaHandler handles Lambda events
+func LambdaHandler(ctx context.Context, request LambdaRequest) (LambdaResponse, error) {
+	pipeline, err := NewTranscriptionPipelineFromEnv()
 	if err != nil {
 		return LambdaResponse{
 			StatusCode: 500,
@@ -637,13 +1884,146 @@ func LambdaHandler(ctx context.Context, request LambdaRequest) (LambdaResponse,
 		}, nil
 	}
 
+	pipeline.SetDryRun(request.DryRun)
+	pipeline.reprocess = request.Reprocess
+	if !validResponseVerbosity(request.Verbosity) {
+		return LambdaResponse{StatusCode: 400, Error: fmt.Sprintf("unknown verbosity %q; use full, answers or summary", request.Verbosity)}, nil
+	}
+	pipeline.SetResponseVerbosity(request.Verbosity)
+
+	if request.Action == "migrate" {
+		return pipeline.HandleMigrate(), nil
+	}
+
+	if request.Action == "digest" {
+		return pipeline.HandleDigest(request.Date), nil
+	}
+
+	if request.Action == "retention" {
+		return pipeline.HandleRetention(), nil
+	}
+
+	if request.Action == "replay" {
+		if request.CallLogsID == "" {
+			return LambdaResponse{StatusCode: 400, Error: "call_logsId is required to replay"}, nil
+		}
+		result, err := pipeline.ReplayCall(request.CallLogsID)
+		if err != nil {
+			return errorResponse(err), nil
+		}
+		return LambdaResponse{StatusCode: 200, Body: result}, nil
+	}
+
+	if request.Action == "warmup" {
+		return HandleWarmUp(), nil
+	}
+
+	if request.Action == "backfill" {
+		var backfill BackfillRequest
+		if request.Backfill != nil {
+			backfill = *request.Backfill
+		}
+		return pipeline.HandleBackfill(ctx, backfill), nil
+	}
+
+	if request.Action == "step" {
+		// Errors fail the task, so Step Functions retries it
+		return pipeline.HandleWorkflowStep(request.Step, request.Workflow)
+	}
+
+	if len(request.Records) > 0 {
+		return pipeline.HandleUploadEvents(request.Records), nil
+	}
+
+	if request.Action == "evaluate" {
+		var config EvaluationConfig
+		if request.Evaluation != nil {
+			config = *request.Evaluation
+		}
+		return pipeline.HandleEvaluate(config), nil
+	}
+
+	// Push stage transitions to the job's WebSocket subscribers
+	if websocketProgressEnabled() {
+		jobID := request.JobID
+		if jobID == "" {
+			jobID = request.CallLogsID
+		}
+		if jobID == "" {
+			jobID = request.MessageID
+		}
+		if jobID == "" && request.Audio != nil {
+			jobID = request.Audio.Reference
+		}
+		pipeline.SetProgress(pipeline.websocketProgress(jobID, request.CallLogsID))
+	}
+
+	if err := pipeline.SetAdHocQuestions(request.Questions, request.QuestionsMode); err != nil {
+		return errorResponse(err), nil
+	}
+
+	// Process the inline audio, the voice note or the call
+	var result map[string]interface{}
+	if request.Audio != nil {
+		result, err = pipeline.ProcessInlineAudio(*request.Audio)
+	} else if request.MessageID != "" {
+		result, err = pipeline.ProcessVoiceNote(request.MessageID)
+	} else {
+		result, err = pipeline.ProcessCall(request.CallLogsID)
+	}
+	if err != nil {
+		return errorResponse(err), nil
+	}
+
 	return LambdaResponse{
 		StatusCode: 200,
 		Body:       result,
 	}, nil
 }
 
+// HandleMigrate applies pending database migrations
+func (tp *TranscriptionPipeline) HandleMigrate() LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	applied, err := tp.RunMigrations()
+	if err != nil {
+		return LambdaResponse{StatusCode: 500, Body: map[string]interface{}{"applied": applied}, Error: err.Error()}
+	}
+
+	return LambdaResponse{StatusCode: 200, Body: map[string]interface{}{"applied": applied}}
+}
+
 func main() {
-	lambda.Start(LambdaHandler)
+	configureMemoryLimit()
+	initErrorReporting()
+
+	// Run as a plain HTTP server for non-Lambda deployments
+	if serverModeEnabled() {
+		if err := runHTTPServer(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Serve the pipeline to internal services over gRPC
+	if grpcModeEnabled() {
+		if err := runGRPCServer(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Run as a CLI when invoked with arguments outside the Lambda runtime
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") == "" && len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
+	// Close the database pool when Lambda shuts the instance down
+	lambda.StartWithOptions(recoverPanics(LambdaHandler), lambda.WithEnableSIGTERM(closeSharedRepositories))
 }
 
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+)
+
+// ErrRecordingTooLarge means the recording can't be processed within the function's memory
+var ErrRecordingTooLarge = errors.New("recording too large for the function's memory")
+
+const (
+	// memoryReserveBytes is kept for the runtime, the database pool, prompts and responses, on top
+	// of what the recording itself takes
+	memoryReserveBytes = 96 << 20
+	// recordingMemoryFactor is how many copies of a recording can be alive at once: the download,
+	// the preprocessed or extracted audio, and the chunk or channel being sent
+	recordingMemoryFactor = 3
+	// chunkingBytesPerSecond is the memory splitting a recording into chunks takes per second of
+	// audio: the 16-bit WAV ffmpeg converts it to plus the decoded, mono and downsampled float64 samples
+	chunkingBytesPerSecond = preprocessedSampleRate * (2 + 3*8)
+	// memoryLimitPercent is the share of the function's memory the Go heap is limited to unless
+	// GOMEMLIMIT is set, so the collector works harder before the instance is killed
+	memoryLimitPercent = 90
+)
+
+// lambdaMemoryBytes is the function's configured memory (AWS_LAMBDA_FUNCTION_MEMORY_SIZE), or 0
+// outside Lambda
+func lambdaMemoryBytes() int64 {
+	mb, err := strconv.ParseInt(os.Getenv("AWS_LAMBDA_FUNCTION_MEMORY_SIZE"), 10, 64)
+	if err != nil || mb <= 0 {
+		return 0
+	}
+	return mb << 20
+}
+
+// maxRecordingBytes is the largest recording the function accepts: AUDIO_MAX_BYTES when set,
+// otherwise what fits the function's memory. 0 means no limit, as outside Lambda.
+func maxRecordingBytes() int {
+	if limit := envLimit("AUDIO_MAX_BYTES", 0); limit > 0 {
+		return limit
+	}
+	memory := lambdaMemoryBytes()
+	if memory == 0 {
+		return 0
+	}
+	return int(max(memory-memoryReserveBytes, 0) / recordingMemoryFactor)
+}
+
+// memoryNeededMB is the function memory in MB that would hold the given bytes on top of the reserve
+func memoryNeededMB(bytes int64) int64 {
+	return (bytes+memoryReserveBytes)>>20 + 1
+}
+
+// recordingTooLarge returns the error for a recording of size bytes over the limit, naming the
+// memory that would fit it
+func recordingTooLarge(size int64) error {
+	limit := maxRecordingBytes()
+	if memory := lambdaMemoryBytes(); memory > 0 && envLimit("AUDIO_MAX_BYTES", 0) == 0 {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d bytes for %d MB; raise the function's memory to at least %d MB",
+			ErrRecordingTooLarge, size, limit, memory>>20, memoryNeededMB(size*recordingMemoryFactor))
+	}
+	return fmt.Errorf("%w: %d bytes exceeds AUDIO_MAX_BYTES (%d)", ErrRecordingTooLarge, size, limit)
+}
+
+// checkRecordingSize fails for recordings over maxRecordingBytes; negative sizes are unknown and pass
+func checkRecordingSize(size int64) error {
+	if limit := maxRecordingBytes(); limit > 0 && size > int64(limit) {
+		return recordingTooLarge(size)
+	}
+	return nil
+}
+
+// readRecording reads a recording download, stopping once it exceeds maxRecordingBytes rather than
+// reading it all into memory. A read error returns what was read so far, for resuming.
+func readRecording(body io.Reader) ([]byte, error) {
+	limit := maxRecordingBytes()
+	if limit <= 0 {
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, int64(limit)+1))
+	if err == nil && len(data) > limit {
+		return nil, recordingTooLarge(int64(len(data)))
+	}
+	return data, err
+}
+
+// checkChunkingMemory fails when splitting a recording of the given length into chunks wouldn't fit
+// the function's memory alongside the recording itself, rather than have the instance killed
+func checkChunkingMemory(audioBytes int, seconds float64) error {
+	memory := lambdaMemoryBytes()
+	if memory == 0 {
+		return nil
+	}
+	needed := int64(seconds*chunkingBytesPerSecond) + int64(audioBytes)*recordingMemoryFactor
+	if needed+memoryReserveBytes <= memory {
+		return nil
+	}
+	return fmt.Errorf("%w: splitting %.0f seconds of audio into chunks takes about %d MB, more than %d MB allows; raise the function's memory to at least %d MB",
+		ErrRecordingTooLarge, seconds, needed>>20, memory>>20, memoryNeededMB(needed))
+}
+
+// configureMemoryLimit limits the Go heap to memoryLimitPercent of the function's memory unless
+// GOMEMLIMIT is set, so garbage builds up less before an instance runs out
+func configureMemoryLimit() {
+	memory := lambdaMemoryBytes()
+	if memory == 0 || os.Getenv("GOMEMLIMIT") != "" {
+		return
+	}
+	debug.SetMemoryLimit(memory / 100 * memoryLimitPercent)
+}
+
+// memoryUsedMB is the memory the runtime has taken from the OS so far. Go seldom returns memory, so
+// it is close to the instance's peak.
+func memoryUsedMB() int {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return int(stats.Sys >> 20)
+}
+
+// logRecordingMemory logs the recording's size against the function's memory before it is
+// processed, so an instance killed for running out of memory leaves a trace of why
+func logRecordingMemory(audioBytes int) {
+	memory := lambdaMemoryBytes()
+	if memory == 0 {
+		return
+	}
+	log.Printf("Processing a recording of %d bytes with %d MB of memory (%d MB in use, recording limit %d bytes)",
+		audioBytes, memory>>20, memoryUsedMB(), maxRecordingBytes())
+}
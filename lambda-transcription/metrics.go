@@ -0,0 +1,179 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Speaker labels used in the diarized transcription
+const (
+	SpeakerAgent    = "Agent"
+	SpeakerCustomer = "Customer"
+	SpeakerUnknown  = "Unknown"
+)
+
+// segmentLinePattern matches diarized lines like "[00:05 - 00:12] Agent: Hello"
+var segmentLinePattern = regexp.MustCompile(`^\[(\d{1,2}:\d{2}(?::\d{2})?)\s*-\s*(\d{1,2}:\d{2}(?::\d{2})?)\]\s*([^:]+):\s*(.*)$`)
+
+// TranscriptSegment represents a single speaker turn in the diarized transcription
+type TranscriptSegment struct {
+	Speaker string  `json:"speaker"`
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+}
+
+// CallMetrics represents talk-time and silence KPIs computed from the diarized transcription
+type CallMetrics struct {
+	AgentTalkSeconds        float64 `json:"agent_talk_seconds"`
+	CustomerTalkSeconds     float64 `json:"customer_talk_seconds"`
+	AgentTalkRatio          float64 `json:"agent_talk_ratio"`
+	CustomerTalkRatio       float64 `json:"customer_talk_ratio"`
+	LongestMonologueSeconds float64 `json:"longest_monologue_seconds"`
+	LongestMonologueSpeaker string  `json:"longest_monologue_speaker"`
+	SilencePercentage       float64 `json:"silence_percentage"`
+	InterruptionCount       int     `json:"interruption_count"`
+}
+
+// parseDiarizedTranscript splits the transcription into timestamped speaker segments.
+// Lines that don't follow the diarized format are ignored.
+func parseDiarizedTranscript(transcription string) []TranscriptSegment {
+	var segments []TranscriptSegment
+
+	for _, line := range strings.Split(transcription, "\n") {
+		match := segmentLinePattern.FindStringSubmatch(strings.TrimSpace(line))
+		if match == nil {
+			continue
+		}
+
+		start, ok := parseTimestamp(match[1])
+		if !ok {
+			continue
+		}
+		end, ok := parseTimestamp(match[2])
+		if !ok || end < start {
+			continue
+		}
+
+		segments = append(segments, TranscriptSegment{
+			Speaker: normalizeSpeaker(match[3]),
+			Start:   start,
+			End:     end,
+			Text:    strings.TrimSpace(match[4]),
+		})
+	}
+
+	return segments
+}
+
+// parseTimestamp converts "MM:SS" or "HH:MM:SS" into seconds
+func parseTimestamp(value string) (float64, bool) {
+	seconds := 0
+	for _, part := range strings.Split(value, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		seconds = seconds*60 + n
+	}
+	return float64(seconds), true
+}
+
+// normalizeSpeaker maps the model's speaker label onto Agent/Customer
+func normalizeSpeaker(label string) string {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "agent":
+		return SpeakerAgent
+	case "customer", "caller":
+		return SpeakerCustomer
+	default:
+		return SpeakerUnknown
+	}
+}
+
+// computeCallMetrics derives talk ratio, longest monologue, silence and interruptions from the segments.
+// callDuration is the recorded call length in seconds and is used as the silence baseline when known.
+func computeCallMetrics(segments []TranscriptSegment, callDuration int) *CallMetrics {
+	if len(segments) == 0 {
+		return nil
+	}
+
+	sorted := make([]TranscriptSegment, len(segments))
+	copy(sorted, segments)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+
+	metrics := &CallMetrics{}
+
+	// Talk time per speaker
+	for _, s := range sorted {
+		switch s.Speaker {
+		case SpeakerAgent:
+			metrics.AgentTalkSeconds += s.End - s.Start
+		case SpeakerCustomer:
+			metrics.CustomerTalkSeconds += s.End - s.Start
+		}
+	}
+	if total := metrics.AgentTalkSeconds + metrics.CustomerTalkSeconds; total > 0 {
+		metrics.AgentTalkRatio = roundTo(metrics.AgentTalkSeconds/total, 3)
+		metrics.CustomerTalkRatio = roundTo(metrics.CustomerTalkSeconds/total, 3)
+	}
+
+	// Longest monologue: consecutive turns by the same speaker are merged
+	runSpeaker, runStart, runEnd := sorted[0].Speaker, sorted[0].Start, sorted[0].End
+	for i := 1; i <= len(sorted); i++ {
+		if i < len(sorted) && sorted[i].Speaker == runSpeaker {
+			if sorted[i].End > runEnd {
+				runEnd = sorted[i].End
+			}
+			continue
+		}
+		if runEnd-runStart > metrics.LongestMonologueSeconds {
+			metrics.LongestMonologueSeconds = runEnd - runStart
+			metrics.LongestMonologueSpeaker = runSpeaker
+		}
+		if i < len(sorted) {
+			runSpeaker, runStart, runEnd = sorted[i].Speaker, sorted[i].Start, sorted[i].End
+		}
+	}
+
+	// Interruptions: a speaker change that starts before the previous turn finished
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Speaker != sorted[i-1].Speaker && sorted[i].Start < sorted[i-1].End {
+			metrics.InterruptionCount++
+		}
+	}
+
+	// Silence: call length not covered by any speech interval
+	speech := 0.0
+	coveredUntil := 0.0
+	for _, s := range sorted {
+		start := s.Start
+		if start < coveredUntil {
+			start = coveredUntil
+		}
+		if s.End > start {
+			speech += s.End - start
+		}
+		if s.End > coveredUntil {
+			coveredUntil = s.End
+		}
+	}
+	totalDuration := float64(callDuration)
+	if totalDuration < coveredUntil {
+		totalDuration = coveredUntil
+	}
+	if totalDuration > 0 {
+		metrics.SilencePercentage = roundTo((totalDuration-speech)/totalDuration*100, 2)
+	}
+
+	return metrics
+}
+
+// roundTo rounds a value to the given number of decimal places
+func roundTo(value float64, places int) float64 {
+	factor := math.Pow10(places)
+	return math.Round(value*factor) / factor
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/lib/pq"
+)
+
+// migrationsFS holds the SQL migrations, named "NNNN_description.sql" and applied in version order.
+// Migrations reference tables as {{table "name"}} so they follow the configured schema and table names.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID serializes concurrent migration runs via a Postgres advisory lock
+const migrationLockID = 7305214
+
+// Migration represents a single embedded SQL migration
+type Migration struct {
+	Version int64
+	Name    string
+	SQL     string
+}
+
+// loadMigrations parses the embedded migrations, rendering table names for the given schema
+func loadMigrations(schema SchemaConfig) ([]Migration, error) {
+	entries, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %v", err)
+	}
+
+	funcs := template.FuncMap{"table": schema.Table, "column": schema.Column}
+
+	var migrations []Migration
+	seen := make(map[int64]string)
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		versionText, _, ok := strings.Cut(name, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s must be named NNNN_description.sql", entry.Name())
+		}
+		version, err := strconv.ParseInt(versionText, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has an invalid version: %v", entry.Name(), err)
+		}
+		if other, exists := seen[version]; exists {
+			return nil, fmt.Errorf("migrations %s and %s share version %d", other, entry.Name(), version)
+		}
+		seen[version] = entry.Name()
+
+		content, err := migrationsFS.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %s: %v", entry.Name(), err)
+		}
+
+		tmpl, err := template.New(entry.Name()).Funcs(funcs).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing migration %s: %v", entry.Name(), err)
+		}
+		var rendered strings.Builder
+		if err := tmpl.Execute(&rendered, nil); err != nil {
+			return nil, fmt.Errorf("error rendering migration %s: %v", entry.Name(), err)
+		}
+
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: rendered.String()})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// RunMigrations applies every embedded migration that hasn't been applied yet and returns the names
+// of the migrations it applied. Each migration runs in its own transaction and is recorded in the
+// schema_migrations table; concurrent runners wait on an advisory lock.
+func (tp *TranscriptionPipeline) RunMigrations() ([]string, error) {
+	migrations, err := loadMigrations(tp.schema)
+	if err != nil {
+		return nil, err
+	}
+
+	setup := fmt.Sprintf(`
+		CREATE SCHEMA IF NOT EXISTS %s;
+		CREATE TABLE IF NOT EXISTS %s (
+			version     bigint PRIMARY KEY,
+			name        text NOT NULL,
+			"appliedAt" timestamptz NOT NULL DEFAULT now()
+		);
+	`, pq.QuoteIdentifier(tp.schema.Schema), tp.schema.Table("schema_migrations"))
+	if _, err := tp.repo.db.Exec(setup); err != nil {
+		return nil, fmt.Errorf("error creating schema_migrations table: %v", err)
+	}
+
+	var applied []string
+	for _, migration := range migrations {
+		ran, err := tp.applyMigration(migration)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			log.Printf("Applied migration %s", migration.Name)
+			applied = append(applied, migration.Name)
+		}
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a single migration unless it has already been applied. Migrations bypass
+// the repository: their multi-statement scripts can't be prepared and may outlast the query timeout.
+func (tp *TranscriptionPipeline) applyMigration(migration Migration) (bool, error) {
+	tx, err := tp.repo.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error starting migration %s: %v", migration.Name, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, fmt.Errorf("error locking migrations: %v", err)
+	}
+
+	var exists bool
+	checkQuery := fmt.Sprintf(`SELECT EXISTS (SELECT 1 FROM %s WHERE version = $1)`, tp.schema.Table("schema_migrations"))
+	if err := tx.QueryRow(checkQuery, migration.Version).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking migration %s: %v", migration.Name, err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(migration.SQL); err != nil {
+		return false, fmt.Errorf("error applying migration %s: %v", migration.Name, err)
+	}
+
+	recordQuery := fmt.Sprintf(`INSERT INTO %s (version, name, "appliedAt") VALUES ($1, $2, now())`, tp.schema.Table("schema_migrations"))
+	if _, err := tx.Exec(recordQuery, migration.Version, migration.Name); err != nil {
+		return false, fmt.Errorf("error recording migration %s: %v", migration.Name, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error committing migration %s: %v", migration.Name, err)
+	}
+
+	return true, nil
+}
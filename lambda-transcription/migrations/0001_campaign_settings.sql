@@ -0,0 +1,5 @@
+-- Per-campaign pipeline settings (e.g. transcriptionProvider)
+CREATE TABLE IF NOT EXISTS {{table "campaign_settings"}} (
+    "campaignId" uuid PRIMARY KEY,
+    settings     jsonb NOT NULL DEFAULT '{}'
+);
@@ -0,0 +1,12 @@
+-- Transcriptions reused for duplicate recordings
+CREATE TABLE IF NOT EXISTS {{table "transcription_cache"}} (
+    "urlHash"       text NOT NULL,
+    "contentHash"   text NOT NULL,
+    "questionsHash" text NOT NULL,
+    transcription   text NOT NULL,
+    answers         jsonb NOT NULL DEFAULT '{}',
+    words           jsonb,
+    "createdAt"     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("contentHash", "questionsHash")
+);
+CREATE INDEX IF NOT EXISTS transcription_cache_url_idx ON {{table "transcription_cache"}} ("urlHash", "questionsHash");
@@ -0,0 +1,10 @@
+-- Mandatory and prohibited phrases checked against each transcript
+CREATE TABLE IF NOT EXISTS {{table "campaign_compliance_rule"}} (
+    id           uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "campaignId" uuid NOT NULL,
+    label        text NOT NULL,
+    pattern      text NOT NULL,
+    "ruleType"   text NOT NULL CHECK ("ruleType" IN ('mandatory', 'prohibited')),
+    "isRegex"    boolean NOT NULL DEFAULT false,
+    "isActive"   boolean NOT NULL DEFAULT true
+);
@@ -0,0 +1,11 @@
+-- Weighted criteria for agent QA scorecards
+CREATE TABLE IF NOT EXISTS {{table "campaign_rubric_criterion"}} (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "campaignId"  uuid NOT NULL,
+    name          text NOT NULL,
+    description   text NOT NULL DEFAULT '',
+    weight        numeric NOT NULL DEFAULT 1,
+    "maxScore"    integer NOT NULL DEFAULT 10,
+    "sortOrder"   integer NOT NULL DEFAULT 0,
+    "isActive"    boolean NOT NULL DEFAULT true
+);
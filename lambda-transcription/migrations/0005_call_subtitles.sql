@@ -0,0 +1,7 @@
+-- SRT and WebVTT renditions of each call's transcript
+CREATE TABLE IF NOT EXISTS {{table "call_subtitles"}} (
+    "call_logsId" uuid PRIMARY KEY,
+    srt           text NOT NULL,
+    vtt           text NOT NULL,
+    "updatedAt"   timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- S3 keys of archived raw and derived artifacts
+CREATE TABLE IF NOT EXISTS {{table "call_artifacts"}} (
+    id             bigserial PRIMARY KEY,
+    "call_logsId"  uuid NOT NULL,
+    "artifactType" text NOT NULL,
+    bucket         text NOT NULL,
+    "s3Key"        text NOT NULL,
+    "createdAt"    timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS call_artifacts_call_idx ON {{table "call_artifacts"}} ("call_logsId");
@@ -0,0 +1,6 @@
+-- Token buckets used by the API Gateway Lambda's rate limiter
+CREATE TABLE IF NOT EXISTS {{table "api_rate_limit_bucket"}} (
+    "bucketKey" text PRIMARY KEY,
+    tokens      double precision NOT NULL,
+    "updatedAt" timestamptz NOT NULL DEFAULT now()
+);
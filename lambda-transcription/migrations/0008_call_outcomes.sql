@@ -0,0 +1,16 @@
+-- Typed per-call outcomes mapped from question answers by the campaign's outcomeFields setting
+CREATE TABLE IF NOT EXISTS {{table "call_outcomes"}} (
+    "call_logsId"  uuid NOT NULL,
+    "campaignId"   uuid NOT NULL,
+    field          text NOT NULL,
+    "questionId"   text NOT NULL,
+    "valueType"    text NOT NULL CHECK ("valueType" IN ('boolean', 'numeric', 'integer', 'text', 'date')),
+    "booleanValue" boolean,
+    "numericValue" numeric,
+    "textValue"    text,
+    "dateValue"    date,
+    "rawAnswer"    text NOT NULL,
+    "createdAt"    timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", field)
+);
+CREATE INDEX IF NOT EXISTS call_outcomes_campaign_field_idx ON {{table "call_outcomes"}} ("campaignId", field);
@@ -0,0 +1,13 @@
+-- Transcript embeddings for semantic search (requires the pgvector extension)
+CREATE EXTENSION IF NOT EXISTS vector;
+
+CREATE TABLE IF NOT EXISTS {{table "call_embeddings"}} (
+    "call_logsId" uuid PRIMARY KEY,
+    "campaignId"  uuid NOT NULL,
+    model         text NOT NULL,
+    content       text NOT NULL,
+    embedding     vector(768) NOT NULL,
+    "updatedAt"   timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS call_embeddings_campaign_idx ON {{table "call_embeddings"}} ("campaignId");
+CREATE INDEX IF NOT EXISTS call_embeddings_embedding_idx ON {{table "call_embeddings"}} USING hnsw (embedding vector_cosine_ops);
@@ -0,0 +1,18 @@
+-- Full-text search index over call transcriptions. The 'simple' configuration is used because
+-- transcripts mix Hindi and English, which language-specific stemmers would mangle.
+CREATE TABLE IF NOT EXISTS {{table "call_transcript_search"}} (
+    "call_logsId"  uuid PRIMARY KEY,
+    "campaignId"   uuid,
+    transcription  text NOT NULL,
+    search         tsvector GENERATED ALWAYS AS (to_tsvector('simple', transcription)) STORED,
+    "updatedAt"    timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS call_transcript_search_search_idx ON {{table "call_transcript_search"}} USING gin (search);
+CREATE INDEX IF NOT EXISTS call_transcript_search_campaign_idx ON {{table "call_transcript_search"}} ("campaignId");
+
+-- Index calls analysed before this migration
+INSERT INTO {{table "call_transcript_search"}} ("call_logsId", "campaignId", transcription)
+SELECT {{column "call_logs" "id"}}, {{column "call_logs" "campaignId"}}, {{column "call_logs" "callAnalysis"}}->>'transcription'
+FROM {{table "call_logs"}}
+WHERE COALESCE({{column "call_logs" "callAnalysis"}}->>'transcription', '') <> ''
+ON CONFLICT ("call_logsId") DO NOTHING;
@@ -0,0 +1,12 @@
+-- One row per processing attempt, so failures are visible without the Lambda logs (read by the daily digest)
+CREATE TABLE IF NOT EXISTS {{table "call_processing_runs"}} (
+    id              bigserial PRIMARY KEY,
+    "call_logsId"   uuid NOT NULL,
+    "campaignId"    uuid,
+    status          text NOT NULL CHECK (status IN ('succeeded', 'failed')),
+    "errorCategory" text,
+    error           text,
+    "createdAt"     timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS call_processing_runs_created_idx ON {{table "call_processing_runs"}} ("createdAt");
+CREATE INDEX IF NOT EXISTS call_processing_runs_call_idx ON {{table "call_processing_runs"}} ("call_logsId", "createdAt");
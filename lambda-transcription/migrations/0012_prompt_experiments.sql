@@ -0,0 +1,25 @@
+-- Named prompt/model variants for A/B testing prompt changes. Costs are USD per million tokens.
+CREATE TABLE IF NOT EXISTS {{table "prompt_variants"}} (
+    name                   text PRIMARY KEY,
+    model                  text NOT NULL DEFAULT 'gemini-2.5-pro',
+    instructions           text NOT NULL DEFAULT '',
+    "inputCostPerMillion"  numeric NOT NULL DEFAULT 0,
+    "outputCostPerMillion" numeric NOT NULL DEFAULT 0,
+    "createdAt"            timestamptz NOT NULL DEFAULT now()
+);
+
+-- Answers and token usage of each variant a call was processed with; shadow rows don't feed callAnalysis
+CREATE TABLE IF NOT EXISTS {{table "prompt_variant_results"}} (
+    "call_logsId"  uuid NOT NULL,
+    "campaignId"   uuid NOT NULL,
+    variant        text NOT NULL,
+    model          text NOT NULL,
+    shadow         boolean NOT NULL DEFAULT false,
+    answers        jsonb NOT NULL,
+    "promptTokens" integer NOT NULL,
+    "outputTokens" integer NOT NULL,
+    "costUsd"      numeric NOT NULL,
+    "createdAt"    timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", variant)
+);
+CREATE INDEX IF NOT EXISTS prompt_variant_results_campaign_idx ON {{table "prompt_variant_results"}} ("campaignId", "createdAt");
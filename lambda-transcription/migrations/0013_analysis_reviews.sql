@@ -0,0 +1,26 @@
+-- Human review queue: analyses flagged by the campaign's review settings
+CREATE TABLE IF NOT EXISTS {{table "analysis_reviews"}} (
+    "call_logsId" uuid PRIMARY KEY,
+    "campaignId"  uuid NOT NULL,
+    status        text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'claimed', 'reviewed')),
+    reasons       jsonb NOT NULL DEFAULT '[]',
+    "claimedBy"   text,
+    "claimedAt"   timestamptz,
+    "reviewedBy"  text,
+    "reviewedAt"  timestamptz,
+    notes         text,
+    "createdAt"   timestamptz NOT NULL DEFAULT now(),
+    "updatedAt"   timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS analysis_reviews_status_idx ON {{table "analysis_reviews"}} (status, "campaignId", "createdAt");
+
+-- Reviewer corrections, kept apart from the model output for evaluation and fine-tuning
+CREATE TABLE IF NOT EXISTS {{table "analysis_review_corrections"}} (
+    "call_logsId"     uuid NOT NULL,
+    "questionId"      text NOT NULL,
+    "modelAnswer"     text,
+    "correctedAnswer" text NOT NULL,
+    reviewer          text NOT NULL,
+    "createdAt"       timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", "questionId")
+);
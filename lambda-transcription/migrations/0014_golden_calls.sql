@@ -0,0 +1,18 @@
+-- Calls with human-verified transcripts and answers, used to evaluate providers, models and prompt variants
+CREATE TABLE IF NOT EXISTS {{table "golden_calls"}} (
+    "call_logsId" uuid PRIMARY KEY,
+    "campaignId"  uuid NOT NULL,
+    transcript    text NOT NULL DEFAULT '',
+    answers       jsonb NOT NULL DEFAULT '{}',
+    notes         text,
+    "createdAt"   timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS golden_calls_campaign_idx ON {{table "golden_calls"}} ("campaignId");
+
+-- Evaluation reports, kept so quality can be compared across runs
+CREATE TABLE IF NOT EXISTS {{table "evaluation_runs"}} (
+    id           bigserial PRIMARY KEY,
+    "campaignId" uuid,
+    report       jsonb NOT NULL,
+    "createdAt"  timestamptz NOT NULL DEFAULT now()
+);
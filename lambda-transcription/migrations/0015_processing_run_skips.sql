@@ -0,0 +1,19 @@
+-- Calls skipped by the pre-flight eligibility rules are recorded as 'skipped' with the reason
+ALTER TABLE {{table "call_processing_runs"}} ADD COLUMN IF NOT EXISTS "skipReason" text;
+
+DO $$
+DECLARE
+    constraint_name text;
+BEGIN
+    SELECT conname INTO constraint_name
+    FROM pg_constraint
+    WHERE conrelid = '{{table "call_processing_runs"}}'::regclass
+      AND contype = 'c'
+      AND pg_get_constraintdef(oid) LIKE '%status%';
+    IF constraint_name IS NOT NULL THEN
+        EXECUTE format('ALTER TABLE {{table "call_processing_runs"}} DROP CONSTRAINT %I', constraint_name);
+    END IF;
+END $$;
+
+ALTER TABLE {{table "call_processing_runs"}}
+    ADD CONSTRAINT call_processing_runs_status_check CHECK (status IN ('succeeded', 'failed', 'skipped'));
@@ -0,0 +1,25 @@
+-- Bumped on every change to questions or campaign links, so warm Lambdas know to reload cached questions
+CREATE TABLE IF NOT EXISTS {{table "question_cache_version"}} (
+    id          boolean PRIMARY KEY DEFAULT true CHECK (id),
+    version     bigint NOT NULL DEFAULT 0,
+    "updatedAt" timestamptz NOT NULL DEFAULT now()
+);
+INSERT INTO {{table "question_cache_version"}} (id, version) VALUES (true, 0) ON CONFLICT DO NOTHING;
+
+-- The function lives next to the tables, so it is named like one
+CREATE OR REPLACE FUNCTION {{table "bump_question_cache_version"}}() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE {{table "question_cache_version"}} SET version = version + 1, "updatedAt" = now();
+    RETURN NULL;
+END
+$$;
+
+DROP TRIGGER IF EXISTS question_cache_version_bump ON {{table "question"}};
+CREATE TRIGGER question_cache_version_bump
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON {{table "question"}}
+    FOR EACH STATEMENT EXECUTE PROCEDURE {{table "bump_question_cache_version"}}();
+
+DROP TRIGGER IF EXISTS question_cache_version_bump ON {{table "campaign_question"}};
+CREATE TRIGGER question_cache_version_bump
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON {{table "campaign_question"}}
+    FOR EACH STATEMENT EXECUTE PROCEDURE {{table "bump_question_cache_version"}}();
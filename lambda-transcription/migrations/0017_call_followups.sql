@@ -0,0 +1,16 @@
+-- Follow-up tasks committed to on calls. A call's tasks are replaced whenever it is reprocessed.
+CREATE TABLE IF NOT EXISTS {{table "call_followups"}} (
+    "call_logsId" uuid NOT NULL,
+    position      integer NOT NULL,
+    "campaignId"  uuid NOT NULL,
+    owner         text NOT NULL CHECK (owner IN ('agent', 'customer')),
+    assignee      text NOT NULL DEFAULT '',
+    task          text NOT NULL,
+    "dueDate"     date,
+    "dueTime"     time,
+    quote         text NOT NULL DEFAULT '',
+    status        text NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'done', 'cancelled')),
+    "createdAt"   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", position)
+);
+CREATE INDEX IF NOT EXISTS call_followups_campaign_due_idx ON {{table "call_followups"}} ("campaignId", status, "dueDate");
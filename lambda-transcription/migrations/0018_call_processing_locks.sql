@@ -0,0 +1,8 @@
+-- Per-call leases that stop concurrent invocations for the same call from processing it twice.
+-- Rows are deleted when processing finishes; expired rows are left by crashed runs and taken over.
+CREATE TABLE IF NOT EXISTS {{table "call_processing_locks"}} (
+    "call_logsId" uuid PRIMARY KEY,
+    owner         text NOT NULL,
+    "acquiredAt"  timestamptz NOT NULL DEFAULT now(),
+    "expiresAt"   timestamptz NOT NULL
+);
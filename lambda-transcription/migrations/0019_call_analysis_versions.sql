@@ -0,0 +1,9 @@
+-- Every callAnalysis saved for a call, numbered from 1, so reprocessed calls can be compared with earlier runs
+CREATE TABLE IF NOT EXISTS {{table "call_analysis_versions"}} (
+    "call_logsId"   uuid NOT NULL,
+    version         integer NOT NULL,
+    analysis        jsonb NOT NULL,
+    "promptVariant" text,
+    "createdAt"     timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", version)
+);
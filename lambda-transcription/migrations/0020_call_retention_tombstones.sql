@@ -0,0 +1,12 @@
+-- What the retention job removed from each call and when. Tombstones hold no transcript content.
+CREATE TABLE IF NOT EXISTS {{table "call_retention_tombstones"}} (
+    id              bigserial PRIMARY KEY,
+    "call_logsId"   uuid NOT NULL,
+    "campaignId"    uuid,
+    mode            text NOT NULL CHECK (mode IN ('anonymize', 'purge')),
+    "retentionDays" integer NOT NULL,
+    removed         jsonb NOT NULL DEFAULT '{}',
+    "deletedAt"     timestamptz NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS call_retention_tombstones_call_idx ON {{table "call_retention_tombstones"}} ("call_logsId");
+CREATE INDEX IF NOT EXISTS call_retention_tombstones_deleted_idx ON {{table "call_retention_tombstones"}} ("campaignId", "deletedAt");
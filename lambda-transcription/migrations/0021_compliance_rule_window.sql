@@ -0,0 +1,3 @@
+-- Mandatory rules with a window must be said within the first "withinSeconds" of the call, e.g. recording notices
+ALTER TABLE {{table "campaign_compliance_rule"}}
+    ADD COLUMN IF NOT EXISTS "withinSeconds" integer CHECK ("withinSeconds" > 0);
@@ -0,0 +1,73 @@
+-- Per-campaign answer aggregates, kept up to date as analyses are saved so dashboards don't scan
+-- callAnalysis. call_answer_facts holds each call's counted answers so a reprocessed call's old
+-- answers can be taken out of the aggregates.
+CREATE TABLE IF NOT EXISTS {{table "call_answer_facts"}} (
+    "call_logsId"  uuid NOT NULL,
+    "questionId"   text NOT NULL,
+    "campaignId"   uuid NOT NULL,
+    kind           text NOT NULL CHECK (kind IN ('boolean', 'option', 'numeric')),
+    answer         text NOT NULL,
+    "numericValue" numeric,
+    PRIMARY KEY ("call_logsId", "questionId")
+);
+
+-- Calls per boolean or option answer
+CREATE TABLE IF NOT EXISTS {{table "campaign_answer_counts"}} (
+    "campaignId" uuid NOT NULL,
+    "questionId" text NOT NULL,
+    answer       text NOT NULL,
+    calls        integer NOT NULL,
+    "updatedAt"  timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("campaignId", "questionId", answer)
+);
+
+-- Count and sum of numeric answers; see campaign_answer_averages
+CREATE TABLE IF NOT EXISTS {{table "campaign_answer_numeric"}} (
+    "campaignId" uuid NOT NULL,
+    "questionId" text NOT NULL,
+    calls        integer NOT NULL,
+    total        numeric NOT NULL,
+    "updatedAt"  timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("campaignId", "questionId")
+);
+
+CREATE OR REPLACE VIEW {{table "campaign_answer_averages"}} AS
+SELECT "campaignId", "questionId", calls, total / NULLIF(calls, 0) AS average, "updatedAt"
+FROM {{table "campaign_answer_numeric"}};
+
+-- Count calls analysed before this migration, classifying answers as the pipeline does
+INSERT INTO {{table "call_answer_facts"}} ("call_logsId", "questionId", "campaignId", kind, answer, "numericValue")
+SELECT id, "questionId", "campaignId", kind, answer, CASE WHEN kind = 'numeric' THEN answer::numeric END
+FROM (
+    SELECT {{column "call_logs" "id"}} AS id, a.key AS "questionId", {{column "call_logs" "campaignId"}} AS "campaignId",
+           CASE
+               WHEN COALESCE(({{column "call_logs" "callAnalysis"}}->'enum_answers'->a.key->>'valid')::boolean, false) THEN 'option'
+               WHEN lower(btrim(a.value)) IN ('true', 'false', 'yes', 'no') THEN 'boolean'
+               WHEN btrim(a.value) ~ '^-?[0-9]+(\.[0-9]+)?$' THEN 'numeric'
+           END AS kind,
+           CASE
+               WHEN lower(btrim(a.value)) IN ('true', 'yes') THEN 'true'
+               WHEN lower(btrim(a.value)) IN ('false', 'no') THEN 'false'
+               ELSE btrim(a.value)
+           END AS answer
+    FROM {{table "call_logs"}},
+         jsonb_each_text(CASE WHEN jsonb_typeof({{column "call_logs" "callAnalysis"}}->'answers') = 'object'
+                              THEN {{column "call_logs" "callAnalysis"}}->'answers' ELSE '{}'::jsonb END) a
+    WHERE {{column "call_logs" "campaignId"}} IS NOT NULL
+) classified
+WHERE kind IS NOT NULL
+ON CONFLICT DO NOTHING;
+
+INSERT INTO {{table "campaign_answer_counts"}} ("campaignId", "questionId", answer, calls)
+SELECT "campaignId", "questionId", answer, count(*)
+FROM {{table "call_answer_facts"}}
+WHERE kind <> 'numeric'
+GROUP BY "campaignId", "questionId", answer
+ON CONFLICT DO NOTHING;
+
+INSERT INTO {{table "campaign_answer_numeric"}} ("campaignId", "questionId", calls, total)
+SELECT "campaignId", "questionId", count(*), sum("numericValue")
+FROM {{table "call_answer_facts"}}
+WHERE kind = 'numeric'
+GROUP BY "campaignId", "questionId"
+ON CONFLICT DO NOTHING;
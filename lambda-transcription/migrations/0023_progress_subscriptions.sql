@@ -0,0 +1,11 @@
+-- WebSocket connections waiting for a job's progress, added on $connect and removed on $disconnect
+-- by the API's WebSocket mode; the pipeline pushes each stage to the job's connections
+CREATE TABLE IF NOT EXISTS {{table "progress_subscriptions"}} (
+    "connectionId" text PRIMARY KEY,
+    "jobId"        text NOT NULL,
+    "clientId"     text,
+    "callbackUrl"  text NOT NULL,
+    "connectedAt"  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS progress_subscriptions_job_idx ON {{table "progress_subscriptions"}} ("jobId");
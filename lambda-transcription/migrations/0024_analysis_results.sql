@@ -0,0 +1,29 @@
+-- Normalized analyses written by the relational result store (RESULT_STORES=relational): one row per
+-- call and one per answered or skipped question
+CREATE TABLE IF NOT EXISTS {{table "analysis_results"}} (
+    "call_logsId"       uuid PRIMARY KEY,
+    transcription       text NOT NULL DEFAULT '',
+    provider            text,
+    "callDisposition"   text,
+    "dispositionReason" text,
+    "skipReason"        text,
+    "promptVariant"     text,
+    "cacheHit"          boolean NOT NULL DEFAULT false,
+    "processedAt"       timestamptz NOT NULL,
+    "updatedAt"         timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS {{table "analysis_result_answers"}} (
+    "call_logsId" uuid NOT NULL REFERENCES {{table "analysis_results"}} ("call_logsId") ON DELETE CASCADE,
+    "questionId"  text NOT NULL,
+    -- The answer, or for skipped questions the reason they were skipped
+    answer        text NOT NULL,
+    skipped       boolean NOT NULL DEFAULT false,
+    PRIMARY KEY ("call_logsId", "questionId")
+);
+
+CREATE INDEX IF NOT EXISTS analysis_result_answers_question_idx ON {{table "analysis_result_answers"}} ("questionId");
+
+-- Set once an s3 result store object is uploaded; the store records the key before uploading, so a
+-- NULL marks an upload that failed after the analysis was saved
+ALTER TABLE {{table "call_artifacts"}} ADD COLUMN IF NOT EXISTS "uploadedAt" timestamptz;
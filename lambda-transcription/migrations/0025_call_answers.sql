@@ -0,0 +1,25 @@
+-- One row per answered question, written with callAnalysis so answers can be joined against
+-- questions without unpacking JSON. answerType is the question's details.answerType when the
+-- answer was saved; confidence is NULL unless the answering model reports one.
+CREATE TABLE IF NOT EXISTS {{table "call_answers"}} (
+    "call_logsId" uuid NOT NULL,
+    "questionId"  text NOT NULL,
+    answer        text NOT NULL,
+    "answerType"  text NOT NULL DEFAULT 'text',
+    confidence    real,
+    "createdAt"   timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("call_logsId", "questionId")
+);
+
+CREATE INDEX IF NOT EXISTS call_answers_question_idx ON {{table "call_answers"}} ("questionId");
+
+-- Answers of calls analysed before this migration
+INSERT INTO {{table "call_answers"}} ("call_logsId", "questionId", answer, "answerType")
+SELECT {{column "call_logs" "id"}}, a.key, a.value,
+       COALESCE((SELECT q.{{column "question" "details"}}->>'answerType'
+                 FROM {{table "question"}} q
+                 WHERE q.{{column "question" "id"}}::text = a.key), 'text')
+FROM {{table "call_logs"}},
+     jsonb_each_text(CASE WHEN jsonb_typeof({{column "call_logs" "callAnalysis"}}->'answers') = 'object'
+                          THEN {{column "call_logs" "callAnalysis"}}->'answers' ELSE '{}'::jsonb END) a
+ON CONFLICT DO NOTHING;
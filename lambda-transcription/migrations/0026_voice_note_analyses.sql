@@ -0,0 +1,11 @@
+-- Analyses of voice notes from the messages table, keyed by message ID. Messages belong to the
+-- messaging product, so their analyses are kept here rather than in a column on messages.
+CREATE TABLE IF NOT EXISTS {{table "voice_note_analyses"}} (
+    "messageId"  text PRIMARY KEY,
+    "campaignId" uuid,
+    analysis     jsonb NOT NULL,
+    "createdAt"  timestamptz NOT NULL DEFAULT now(),
+    "updatedAt"  timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS voice_note_analyses_campaign_idx ON {{table "voice_note_analyses"}} ("campaignId", "createdAt");
@@ -0,0 +1,26 @@
+-- Checkpointed backfills started by the "backfill" Lambda action. The cursor is the last call whose
+-- processing is finished, as the (start_date, start_time, id) key the calls are paged by; the lease
+-- keeps two invocations from running the same backfill at once.
+CREATE TABLE IF NOT EXISTS {{table "backfill_jobs"}} (
+    id             bigserial PRIMARY KEY,
+    "campaignId"   uuid NOT NULL,
+    since          date NOT NULL,
+    until          date,
+    reprocess      boolean NOT NULL DEFAULT false,
+    status         text NOT NULL DEFAULT 'running' CHECK (status IN ('running', 'completed')),
+    "cursorDate"   date,
+    "cursorTime"   text,
+    "cursorId"     text,
+    processed      integer NOT NULL DEFAULT 0,
+    skipped        integer NOT NULL DEFAULT 0,
+    failed         integer NOT NULL DEFAULT 0,
+    "lastError"    text,
+    invocations    integer NOT NULL DEFAULT 0,
+    "leaseOwner"   text,
+    "leaseUntil"   timestamptz,
+    "createdAt"    timestamptz NOT NULL DEFAULT now(),
+    "updatedAt"    timestamptz NOT NULL DEFAULT now(),
+    "completedAt"  timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS backfill_jobs_campaign_idx ON {{table "backfill_jobs"}} ("campaignId", "createdAt");
@@ -0,0 +1,18 @@
+-- Ad-hoc recordings uploaded through the API's POST /uploads. The API creates the job with the
+-- questions to answer and a presigned URL for objectKey; the S3 notification for the upload runs
+-- the pipeline, which stores the result here. Nothing is written to call_logs or callAnalysis.
+CREATE TABLE IF NOT EXISTS {{table "audio_uploads"}} (
+    id             uuid PRIMARY KEY,
+    "objectKey"    text NOT NULL UNIQUE,
+    "campaignId"   uuid,
+    questions      jsonb NOT NULL DEFAULT '[]',
+    status         text NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'processing', 'completed', 'failed')),
+    result         jsonb,
+    error          text,
+    "createdBy"    text,
+    "createdAt"    timestamptz NOT NULL DEFAULT now(),
+    "updatedAt"    timestamptz NOT NULL DEFAULT now(),
+    "completedAt"  timestamptz
+);
+
+CREATE INDEX IF NOT EXISTS audio_uploads_created_idx ON {{table "audio_uploads"}} ("createdAt");
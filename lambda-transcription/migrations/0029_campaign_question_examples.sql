@@ -0,0 +1,22 @@
+-- Few-shot examples for nuanced questions: an excerpt of another call and the answer it should
+-- get, added to the prompt of the campaign's calls within FEW_SHOT_MAX_BYTES. Higher priority
+-- examples are added first.
+CREATE TABLE IF NOT EXISTS {{table "campaign_question_example"}} (
+    id            uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    "campaignId"  uuid NOT NULL,
+    "questionId"  uuid NOT NULL,
+    excerpt       text NOT NULL,
+    answer        text NOT NULL,
+    explanation   text,
+    priority      integer NOT NULL DEFAULT 0,
+    "isActive"    boolean NOT NULL DEFAULT true,
+    "createdAt"   timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS campaign_question_example_campaign_idx ON {{table "campaign_question_example"}} ("campaignId");
+
+-- Examples are cached with the campaign's questions, so changes reload them like question changes
+DROP TRIGGER IF EXISTS question_cache_version_bump ON {{table "campaign_question_example"}};
+CREATE TRIGGER question_cache_version_bump
+    AFTER INSERT OR UPDATE OR DELETE OR TRUNCATE ON {{table "campaign_question_example"}}
+    FOR EACH STATEMENT EXECUTE PROCEDURE {{table "bump_question_cache_version"}}();
@@ -0,0 +1,12 @@
+-- Gemini tokens and estimated cost per campaign and UTC day, added to on every request and checked
+-- against the campaign's budget setting before its calls are processed
+CREATE TABLE IF NOT EXISTS {{table "campaign_daily_usage"}} (
+    "campaignId"   uuid NOT NULL,
+    day            date NOT NULL,
+    requests       integer NOT NULL DEFAULT 0,
+    "promptTokens" bigint NOT NULL DEFAULT 0,
+    "outputTokens" bigint NOT NULL DEFAULT 0,
+    "costUsd"      numeric(12, 6) NOT NULL DEFAULT 0,
+    "updatedAt"    timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("campaignId", day)
+);
@@ -0,0 +1,13 @@
+-- Answer validation counts per campaign and profile (output language, model and prompt variant),
+-- added to as the calls of campaigns with profileLearning are saved and read to pick their profile
+CREATE TABLE IF NOT EXISTS {{table "campaign_profile_stats"}} (
+    "campaignId"     uuid NOT NULL,
+    "outputLanguage" text NOT NULL DEFAULT '',
+    model            text NOT NULL DEFAULT '',
+    variant          text NOT NULL DEFAULT '',
+    calls            integer NOT NULL DEFAULT 0,
+    "checkedAnswers" integer NOT NULL DEFAULT 0,
+    "validAnswers"   integer NOT NULL DEFAULT 0,
+    "updatedAt"      timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY ("campaignId", "outputLanguage", model, variant)
+);
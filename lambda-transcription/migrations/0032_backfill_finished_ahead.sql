@@ -0,0 +1,4 @@
+-- IDs of calls past the cursor that finished before a halted backfill stopped; they're already
+-- counted, so resumed runs leave them out instead of processing them again
+ALTER TABLE {{table "backfill_jobs"}}
+    ADD COLUMN IF NOT EXISTS "finishedAhead" jsonb NOT NULL DEFAULT '[]';
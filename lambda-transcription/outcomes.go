@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Outcome value types
+const (
+	OutcomeTypeBoolean = "boolean"
+	OutcomeTypeNumeric = "numeric"
+	OutcomeTypeInteger = "integer"
+	OutcomeTypeText    = "text"
+	OutcomeTypeDate    = "date"
+)
+
+// OutcomeField maps a question's answer to a typed field in call_outcomes
+type OutcomeField struct {
+	QuestionID string `json:"questionId"`
+	Field      string `json:"field"`
+	Type       string `json:"type"`
+}
+
+// CallOutcome represents a typed value extracted from an answer
+type CallOutcome struct {
+	Field      string
+	QuestionID string
+	Type       string
+	Value      interface{}
+	RawAnswer  string
+}
+
+// buildCallOutcomes converts the mapped answers to typed outcomes. Answers that can't be converted
+// are returned as errors keyed by field; unanswered and NOT_DISCUSSED questions and answers rejected
+// as "not found in call" produce no outcome.
+func buildCallOutcomes(fields []OutcomeField, answers map[string]string) ([]CallOutcome, map[string]string) {
+	var outcomes []CallOutcome
+	outcomeErrors := make(map[string]string)
+
+	for _, field := range fields {
+		answer, ok := answers[field.QuestionID]
+		if !ok || strings.TrimSpace(answer) == "" || answer == AnswerNotFoundInCall || answer == AnswerNotDiscussed {
+			continue
+		}
+
+		value, err := convertOutcomeValue(answer, field.Type)
+		if err != nil {
+			outcomeErrors[field.Field] = err.Error()
+			continue
+		}
+
+		outcomes = append(outcomes, CallOutcome{
+			Field:      field.Field,
+			QuestionID: field.QuestionID,
+			Type:       strings.ToLower(field.Type),
+			Value:      value,
+			RawAnswer:  answer,
+		})
+	}
+
+	if len(outcomeErrors) == 0 {
+		outcomeErrors = nil
+	}
+	return outcomes, outcomeErrors
+}
+
+// convertOutcomeValue parses an answer as the given outcome type
+func convertOutcomeValue(answer, valueType string) (interface{}, error) {
+	value := strings.TrimSpace(answer)
+
+	switch strings.ToLower(valueType) {
+	case OutcomeTypeBoolean:
+		switch strings.ToLower(strings.TrimSuffix(value, ".")) {
+		case "true", "yes", "y", "1":
+			return true, nil
+		case "false", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not a boolean", answer)
+	case OutcomeTypeNumeric, OutcomeTypeInteger:
+		// Tolerate thousands separators and currency symbols, e.g. "₹1,250.50"
+		cleaned := strings.Map(func(r rune) rune {
+			if r == ',' || r == ' ' || strings.ContainsRune("₹$€£", r) {
+				return -1
+			}
+			return r
+		}, value)
+		number, err := strconv.ParseFloat(cleaned, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a number", answer)
+		}
+		if strings.ToLower(valueType) == OutcomeTypeInteger {
+			if number != float64(int64(number)) {
+				return nil, fmt.Errorf("%q is not an integer", answer)
+			}
+			return int64(number), nil
+		}
+		return number, nil
+	case OutcomeTypeDate:
+		date, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a YYYY-MM-DD date", answer)
+		}
+		return date.Format("2006-01-02"), nil
+	case OutcomeTypeText, "":
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("unknown outcome type: %s", valueType)
+}
+
+// SaveCallOutcomes replaces the call's rows in call_outcomes with the given outcomes, in the analysis
+// transaction
+func (tp *TranscriptionPipeline) SaveCallOutcomes(tx *Tx, callLogsID, campaignID string, outcomes []CallOutcome) error {
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, tp.schema.Table("call_outcomes"))
+	if _, err := tx.Exec(deleteQuery, callLogsID); err != nil {
+		return fmt.Errorf("error clearing call outcomes: %v", err)
+	}
+
+	insertQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", field, "questionId", "valueType",
+		                "booleanValue", "numericValue", "textValue", "dateValue", "rawAnswer", "createdAt")
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, now())
+	`, tp.schema.Table("call_outcomes"))
+
+	for _, outcome := range outcomes {
+		var booleanValue, numericValue, textValue, dateValue interface{}
+		switch outcome.Type {
+		case OutcomeTypeBoolean:
+			booleanValue = outcome.Value
+		case OutcomeTypeNumeric, OutcomeTypeInteger:
+			numericValue = outcome.Value
+		case OutcomeTypeDate:
+			dateValue = outcome.Value
+		default:
+			textValue = outcome.Value
+		}
+
+		valueType := outcome.Type
+		if valueType == "" {
+			valueType = OutcomeTypeText
+		}
+
+		if _, err := tx.Exec(insertQuery, callLogsID, campaignID, outcome.Field, outcome.QuestionID, valueType,
+			booleanValue, numericValue, textValue, dateValue, outcome.RawAnswer); err != nil {
+			return fmt.Errorf("error saving outcome %s: %v", outcome.Field, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+// This file is duplicated byte for byte in lambda-api-gateway and lambda-transcription. The two are
+// separate modules, each built and deployed from its own directory, so they can't share a package.
+// Change both copies together and check they still match with
+// "cmp lambda-api-gateway/phone_numbers.go lambda-transcription/phone_numbers.go".
+
+package main
+
+import "strings"
+
+// Types of phone number
+const (
+	PhoneTypeMobile   = "mobile"
+	PhoneTypeLandline = "landline"
+	PhoneTypeTollFree = "toll_free"
+)
+
+// PhoneNumber is a call_logs phone number normalized for reporting. Carriers aren't included: with
+// mobile number portability the number no longer tells which network it is on.
+type PhoneNumber struct {
+	// E164 is the number in E.164 format, e.g. +919876543210
+	E164 string `json:"e164"`
+	// Type is mobile, landline or toll_free for Indian numbers and empty for international ones
+	Type string `json:"type,omitempty"`
+	// RegionCode is the ISO 3166-2 state of Indian landlines with a known trunk code (IN-MH), else
+	// the ISO 3166-1 country (IN, AE); empty for calling codes shared by several countries
+	RegionCode string `json:"region_code,omitempty"`
+	// Region describes the region for people, e.g. "India (Mumbai)" or "United Arab Emirates"
+	Region string `json:"region,omitempty"`
+}
+
+// trunkArea is the city and state of an Indian trunk (STD) code
+type trunkArea struct {
+	City  string
+	State string
+}
+
+// indianSTDCodes are the trunk codes of the larger Indian cities, for the region of landline numbers
+var indianSTDCodes = map[string]trunkArea{
+	"11": {"Delhi", "IN-DL"}, "20": {"Pune", "IN-MH"}, "22": {"Mumbai", "IN-MH"},
+	"33": {"Kolkata", "IN-WB"}, "40": {"Hyderabad", "IN-TG"}, "44": {"Chennai", "IN-TN"},
+	"79": {"Ahmedabad", "IN-GJ"}, "80": {"Bengaluru", "IN-KA"}, "120": {"Noida", "IN-UP"},
+	"124": {"Gurugram", "IN-HR"}, "141": {"Jaipur", "IN-RJ"}, "172": {"Chandigarh", "IN-CH"},
+	"361": {"Guwahati", "IN-AS"}, "422": {"Coimbatore", "IN-TN"}, "471": {"Thiruvananthapuram", "IN-KL"},
+	"484": {"Kochi", "IN-KL"}, "522": {"Lucknow", "IN-UP"}, "612": {"Patna", "IN-BR"},
+	"674": {"Bhubaneswar", "IN-OD"}, "712": {"Nagpur", "IN-MH"}, "731": {"Indore", "IN-MP"},
+	"755": {"Bhopal", "IN-MP"},
+}
+
+// callingCountry is the country of an international calling code; Code is empty for codes
+// several countries share
+type callingCountry struct {
+	Name string
+	Code string
+}
+
+// countryCallingCodes are the countries of international numbers seen on calls
+var countryCallingCodes = map[string]callingCountry{
+	"1": {"USA/Canada", ""}, "27": {"South Africa", "ZA"}, "33": {"France", "FR"},
+	"44": {"United Kingdom", "GB"}, "49": {"Germany", "DE"}, "60": {"Malaysia", "MY"},
+	"61": {"Australia", "AU"}, "64": {"New Zealand", "NZ"}, "65": {"Singapore", "SG"},
+	"81": {"Japan", "JP"}, "86": {"China", "CN"}, "92": {"Pakistan", "PK"}, "94": {"Sri Lanka", "LK"},
+	"880": {"Bangladesh", "BD"}, "965": {"Kuwait", "KW"}, "966": {"Saudi Arabia", "SA"},
+	"968": {"Oman", "OM"}, "971": {"United Arab Emirates", "AE"}, "973": {"Bahrain", "BH"},
+	"974": {"Qatar", "QA"}, "977": {"Nepal", "NP"},
+}
+
+// longestPrefix returns the value of the longest key of codes that digits starts with
+func longestPrefix[V any](digits string, codes map[string]V) (V, bool) {
+	for length := min(len(digits), 3); length > 0; length-- {
+		if value, ok := codes[digits[:length]]; ok {
+			return value, true
+		}
+	}
+	var zero V
+	return zero, false
+}
+
+// parsePhoneNumber normalizes a number as stored in call_logs, in whatever format the telephony
+// provider sent it: with or without +91, 0091 or a trunk 0, and with spaces or dashes. Numbers
+// without a country code are taken as Indian. Numbers that can't be made sense of return nil.
+func parsePhoneNumber(number string) *PhoneNumber {
+	international := strings.HasPrefix(strings.TrimSpace(number), "+")
+	var b strings.Builder
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	digits := b.String()
+	if strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+
+	// Indian toll-free numbers are 1800 followed by 6 or 7 digits
+	tollFree := func(national string) bool {
+		return strings.HasPrefix(national, "1800") && (len(national) == 10 || len(national) == 11)
+	}
+	switch {
+	case strings.HasPrefix(digits, "91") && (len(digits) == 12 || tollFree(digits[2:])):
+		digits = digits[2:]
+	case international:
+		return parseInternationalNumber(digits)
+	case len(digits) == 11 && strings.HasPrefix(digits, "0"):
+		digits = digits[1:]
+	case len(digits) != 10 && !tollFree(digits):
+		return nil
+	}
+	return parseIndianNumber(digits)
+}
+
+// parseIndianNumber classifies an Indian number without its country code or trunk 0
+func parseIndianNumber(national string) *PhoneNumber {
+	number := &PhoneNumber{E164: "+91" + national, RegionCode: "IN", Region: "India"}
+	switch {
+	case strings.HasPrefix(national, "1800"):
+		number.Type, number.Region = PhoneTypeTollFree, "India (toll-free)"
+	case strings.IndexByte("6789", national[0]) >= 0:
+		number.Type, number.Region = PhoneTypeMobile, "India (mobile)"
+	default:
+		number.Type = PhoneTypeLandline
+		if area, ok := longestPrefix(national, indianSTDCodes); ok {
+			number.RegionCode, number.Region = area.State, "India ("+area.City+")"
+		}
+	}
+	return number
+}
+
+// parseInternationalNumber resolves the country of a number's calling code. E.164 numbers have at
+// most 15 digits; anything shorter than 8 isn't a full number.
+func parseInternationalNumber(digits string) *PhoneNumber {
+	if len(digits) < 8 || len(digits) > 15 {
+		return nil
+	}
+	number := &PhoneNumber{E164: "+" + digits}
+	if country, ok := longestPrefix(digits, countryCallingCodes); ok {
+		number.RegionCode, number.Region = country.Code, country.Name
+	}
+	return number
+}
+
+// callerRegion describes where a phone number is from, as in PhoneNumber.Region: the city of
+// Indian landlines with a known trunk code, mobile or toll-free for other Indian numbers, and the
+// country of international ones. Unknown numbers return "".
+func callerRegion(number string) string {
+	if parsed := parsePhoneNumber(number); parsed != nil {
+		return parsed.Region
+	}
+	return ""
+}
@@ -0,0 +1,505 @@
+// Internal gRPC interface to the transcription pipeline (SERVER_MODE=grpc).
+//
+// Regenerate the Go code after changing this file, from lambda-transcription/:
+//
+//	protoc --go_out=. --go_opt=module=lambda-transcription \
+//	       --go-grpc_out=. --go-grpc_opt=module=lambda-transcription \
+//	       pipelinepb/pipeline.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.34.2
+// 	protoc        v25.3.0
+// source: pipelinepb/pipeline.proto
+
+package pipelinepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ProcessCallRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CallLogsId string `protobuf:"bytes,1,opt,name=call_logs_id,json=callLogsId,proto3" json:"call_logs_id,omitempty"`
+	// dry_run processes the call without saving anything; the final update carries the would-be analysis
+	DryRun bool `protobuf:"varint,2,opt,name=dry_run,json=dryRun,proto3" json:"dry_run,omitempty"`
+	// reprocess processes a call that already has an analysis instead of failing with ALREADY_EXISTS
+	Reprocess bool `protobuf:"varint,3,opt,name=reprocess,proto3" json:"reprocess,omitempty"`
+}
+
+func (x *ProcessCallRequest) Reset() {
+	*x = ProcessCallRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pipelinepb_pipeline_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProcessCallRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessCallRequest) ProtoMessage() {}
+
+func (x *ProcessCallRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pipelinepb_pipeline_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessCallRequest.ProtoReflect.Descriptor instead.
+func (*ProcessCallRequest) Descriptor() ([]byte, []int) {
+	return file_pipelinepb_pipeline_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ProcessCallRequest) GetCallLogsId() string {
+	if x != nil {
+		return x.CallLogsId
+	}
+	return ""
+}
+
+func (x *ProcessCallRequest) GetDryRun() bool {
+	if x != nil {
+		return x.DryRun
+	}
+	return false
+}
+
+func (x *ProcessCallRequest) GetReprocess() bool {
+	if x != nil {
+		return x.Reprocess
+	}
+	return false
+}
+
+type ProcessCallUpdate struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// stage is the stage that just started ("fetching", "downloading", "transcribing", "answering",
+	// "enriching", "saving") or "done" on the final update
+	Stage string `protobuf:"bytes,1,opt,name=stage,proto3" json:"stage,omitempty"`
+	// elapsed_ms is the time since processing started
+	ElapsedMs int64 `protobuf:"varint,2,opt,name=elapsed_ms,json=elapsedMs,proto3" json:"elapsed_ms,omitempty"`
+	// analysis is set on the final update
+	Analysis *Analysis `protobuf:"bytes,3,opt,name=analysis,proto3" json:"analysis,omitempty"`
+}
+
+func (x *ProcessCallUpdate) Reset() {
+	*x = ProcessCallUpdate{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pipelinepb_pipeline_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ProcessCallUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProcessCallUpdate) ProtoMessage() {}
+
+func (x *ProcessCallUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pipelinepb_pipeline_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProcessCallUpdate.ProtoReflect.Descriptor instead.
+func (*ProcessCallUpdate) Descriptor() ([]byte, []int) {
+	return file_pipelinepb_pipeline_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ProcessCallUpdate) GetStage() string {
+	if x != nil {
+		return x.Stage
+	}
+	return ""
+}
+
+func (x *ProcessCallUpdate) GetElapsedMs() int64 {
+	if x != nil {
+		return x.ElapsedMs
+	}
+	return 0
+}
+
+func (x *ProcessCallUpdate) GetAnalysis() *Analysis {
+	if x != nil {
+		return x.Analysis
+	}
+	return nil
+}
+
+type GetAnalysisRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CallLogsId string `protobuf:"bytes,1,opt,name=call_logs_id,json=callLogsId,proto3" json:"call_logs_id,omitempty"`
+}
+
+func (x *GetAnalysisRequest) Reset() {
+	*x = GetAnalysisRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pipelinepb_pipeline_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetAnalysisRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetAnalysisRequest) ProtoMessage() {}
+
+func (x *GetAnalysisRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pipelinepb_pipeline_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetAnalysisRequest.ProtoReflect.Descriptor instead.
+func (*GetAnalysisRequest) Descriptor() ([]byte, []int) {
+	return file_pipelinepb_pipeline_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GetAnalysisRequest) GetCallLogsId() string {
+	if x != nil {
+		return x.CallLogsId
+	}
+	return ""
+}
+
+type Analysis struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	CallLogsId    string `protobuf:"bytes,1,opt,name=call_logs_id,json=callLogsId,proto3" json:"call_logs_id,omitempty"`
+	Transcription string `protobuf:"bytes,2,opt,name=transcription,proto3" json:"transcription,omitempty"`
+	// answers maps question IDs to answers
+	Answers  map[string]string `protobuf:"bytes,3,rep,name=answers,proto3" json:"answers,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Provider string            `protobuf:"bytes,4,opt,name=provider,proto3" json:"provider,omitempty"`
+	// call_disposition is "conversation", "voicemail", "ivr_only", "no_answer" or "dead_air"
+	CallDisposition string `protobuf:"bytes,5,opt,name=call_disposition,json=callDisposition,proto3" json:"call_disposition,omitempty"`
+	// skip_reason is set when the call was skipped without being transcribed
+	SkipReason    string `protobuf:"bytes,6,opt,name=skip_reason,json=skipReason,proto3" json:"skip_reason,omitempty"`
+	PromptVariant string `protobuf:"bytes,7,opt,name=prompt_variant,json=promptVariant,proto3" json:"prompt_variant,omitempty"`
+	// processed_at is an RFC 3339 timestamp
+	ProcessedAt string `protobuf:"bytes,8,opt,name=processed_at,json=processedAt,proto3" json:"processed_at,omitempty"`
+	// analysis_json is the complete analysis as stored in callAnalysis, for fields not mapped above
+	AnalysisJson []byte `protobuf:"bytes,9,opt,name=analysis_json,json=analysisJson,proto3" json:"analysis_json,omitempty"`
+}
+
+func (x *Analysis) Reset() {
+	*x = Analysis{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_pipelinepb_pipeline_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Analysis) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Analysis) ProtoMessage() {}
+
+func (x *Analysis) ProtoReflect() protoreflect.Message {
+	mi := &file_pipelinepb_pipeline_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Analysis.ProtoReflect.Descriptor instead.
+func (*Analysis) Descriptor() ([]byte, []int) {
+	return file_pipelinepb_pipeline_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Analysis) GetCallLogsId() string {
+	if x != nil {
+		return x.CallLogsId
+	}
+	return ""
+}
+
+func (x *Analysis) GetTranscription() string {
+	if x != nil {
+		return x.Transcription
+	}
+	return ""
+}
+
+func (x *Analysis) GetAnswers() map[string]string {
+	if x != nil {
+		return x.Answers
+	}
+	return nil
+}
+
+func (x *Analysis) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *Analysis) GetCallDisposition() string {
+	if x != nil {
+		return x.CallDisposition
+	}
+	return ""
+}
+
+func (x *Analysis) GetSkipReason() string {
+	if x != nil {
+		return x.SkipReason
+	}
+	return ""
+}
+
+func (x *Analysis) GetPromptVariant() string {
+	if x != nil {
+		return x.PromptVariant
+	}
+	return ""
+}
+
+func (x *Analysis) GetProcessedAt() string {
+	if x != nil {
+		return x.ProcessedAt
+	}
+	return ""
+}
+
+func (x *Analysis) GetAnalysisJson() []byte {
+	if x != nil {
+		return x.AnalysisJson
+	}
+	return nil
+}
+
+var File_pipelinepb_pipeline_proto protoreflect.FileDescriptor
+
+var file_pipelinepb_pipeline_proto_rawDesc = []byte{
+	0x0a, 0x19, 0x70, 0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e, 0x65, 0x70, 0x62, 0x2f, 0x70, 0x69, 0x70,
+	0x65, 0x6c, 0x69, 0x6e, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x19, 0x73, 0x6d, 0x61,
+	0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x22, 0x6d, 0x0a, 0x12, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x43, 0x61, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0c,
+	0x63, 0x61, 0x6c, 0x6c, 0x5f, 0x6c, 0x6f, 0x67, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0a, 0x63, 0x61, 0x6c, 0x6c, 0x4c, 0x6f, 0x67, 0x73, 0x49, 0x64, 0x12, 0x17,
+	0x0a, 0x07, 0x64, 0x72, 0x79, 0x5f, 0x72, 0x75, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52,
+	0x06, 0x64, 0x72, 0x79, 0x52, 0x75, 0x6e, 0x12, 0x1c, 0x0a, 0x09, 0x72, 0x65, 0x70, 0x72, 0x6f,
+	0x63, 0x65, 0x73, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x72, 0x65, 0x70, 0x72,
+	0x6f, 0x63, 0x65, 0x73, 0x73, 0x22, 0x89, 0x01, 0x0a, 0x11, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x43, 0x61, 0x6c, 0x6c, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x73,
+	0x74, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x73, 0x74, 0x61, 0x67,
+	0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x5f, 0x6d, 0x73, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x65, 0x6c, 0x61, 0x70, 0x73, 0x65, 0x64, 0x4d, 0x73,
+	0x12, 0x3f, 0x0a, 0x08, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x23, 0x2e, 0x73, 0x6d, 0x61, 0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72,
+	0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x41,
+	0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x52, 0x08, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69,
+	0x73, 0x22, 0x36, 0x0a, 0x12, 0x47, 0x65, 0x74, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73,
+	0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x20, 0x0a, 0x0c, 0x63, 0x61, 0x6c, 0x6c, 0x5f,
+	0x6c, 0x6f, 0x67, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63,
+	0x61, 0x6c, 0x6c, 0x4c, 0x6f, 0x67, 0x73, 0x49, 0x64, 0x22, 0xb1, 0x03, 0x0a, 0x08, 0x41, 0x6e,
+	0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x12, 0x20, 0x0a, 0x0c, 0x63, 0x61, 0x6c, 0x6c, 0x5f, 0x6c,
+	0x6f, 0x67, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x63, 0x61,
+	0x6c, 0x6c, 0x4c, 0x6f, 0x67, 0x73, 0x49, 0x64, 0x12, 0x24, 0x0a, 0x0d, 0x74, 0x72, 0x61, 0x6e,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0d, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x4a,
+	0x0a, 0x07, 0x61, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x30, 0x2e, 0x73, 0x6d, 0x61, 0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73,
+	0x63, 0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c,
+	0x79, 0x73, 0x69, 0x73, 0x2e, 0x41, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x52, 0x07, 0x61, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x63, 0x61, 0x6c, 0x6c, 0x5f, 0x64,
+	0x69, 0x73, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0f, 0x63, 0x61, 0x6c, 0x6c, 0x44, 0x69, 0x73, 0x70, 0x6f, 0x73, 0x69, 0x74, 0x69, 0x6f,
+	0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x6b, 0x69, 0x70, 0x5f, 0x72, 0x65, 0x61, 0x73, 0x6f, 0x6e,
+	0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x73, 0x6b, 0x69, 0x70, 0x52, 0x65, 0x61, 0x73,
+	0x6f, 0x6e, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x72, 0x6f, 0x6d, 0x70, 0x74, 0x5f, 0x76, 0x61, 0x72,
+	0x69, 0x61, 0x6e, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0d, 0x70, 0x72, 0x6f, 0x6d,
+	0x70, 0x74, 0x56, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x70, 0x72, 0x6f,
+	0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x70, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x65, 0x64, 0x41, 0x74, 0x12, 0x23, 0x0a, 0x0d,
+	0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x5f, 0x6a, 0x73, 0x6f, 0x6e, 0x18, 0x09, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x0c, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x4a, 0x73, 0x6f,
+	0x6e, 0x1a, 0x3a, 0x0a, 0x0c, 0x41, 0x6e, 0x73, 0x77, 0x65, 0x72, 0x73, 0x45, 0x6e, 0x74, 0x72,
+	0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03,
+	0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x32, 0xdf, 0x01,
+	0x0a, 0x0c, 0x43, 0x61, 0x6c, 0x6c, 0x50, 0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e, 0x65, 0x12, 0x6c,
+	0x0a, 0x0b, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73, 0x43, 0x61, 0x6c, 0x6c, 0x12, 0x2d, 0x2e,
+	0x73, 0x6d, 0x61, 0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72,
+	0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73,
+	0x73, 0x43, 0x61, 0x6c, 0x6c, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x2c, 0x2e, 0x73,
+	0x6d, 0x61, 0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69,
+	0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x50, 0x72, 0x6f, 0x63, 0x65, 0x73, 0x73,
+	0x43, 0x61, 0x6c, 0x6c, 0x55, 0x70, 0x64, 0x61, 0x74, 0x65, 0x30, 0x01, 0x12, 0x61, 0x0a, 0x0b,
+	0x47, 0x65, 0x74, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x12, 0x2d, 0x2e, 0x73, 0x6d,
+	0x61, 0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x47, 0x65, 0x74, 0x41, 0x6e, 0x61, 0x6c, 0x79,
+	0x73, 0x69, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x23, 0x2e, 0x73, 0x6d, 0x61,
+	0x72, 0x74, 0x66, 0x6c, 0x6f, 0x2e, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63, 0x72, 0x69, 0x70, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x42,
+	0x21, 0x5a, 0x1f, 0x6c, 0x61, 0x6d, 0x62, 0x64, 0x61, 0x2d, 0x74, 0x72, 0x61, 0x6e, 0x73, 0x63,
+	0x72, 0x69, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x70, 0x69, 0x70, 0x65, 0x6c, 0x69, 0x6e, 0x65,
+	0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_pipelinepb_pipeline_proto_rawDescOnce sync.Once
+	file_pipelinepb_pipeline_proto_rawDescData = file_pipelinepb_pipeline_proto_rawDesc
+)
+
+func file_pipelinepb_pipeline_proto_rawDescGZIP() []byte {
+	file_pipelinepb_pipeline_proto_rawDescOnce.Do(func() {
+		file_pipelinepb_pipeline_proto_rawDescData = protoimpl.X.CompressGZIP(file_pipelinepb_pipeline_proto_rawDescData)
+	})
+	return file_pipelinepb_pipeline_proto_rawDescData
+}
+
+var file_pipelinepb_pipeline_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_pipelinepb_pipeline_proto_goTypes = []any{
+	(*ProcessCallRequest)(nil), // 0: smartflo.transcription.v1.ProcessCallRequest
+	(*ProcessCallUpdate)(nil),  // 1: smartflo.transcription.v1.ProcessCallUpdate
+	(*GetAnalysisRequest)(nil), // 2: smartflo.transcription.v1.GetAnalysisRequest
+	(*Analysis)(nil),           // 3: smartflo.transcription.v1.Analysis
+	nil,                        // 4: smartflo.transcription.v1.Analysis.AnswersEntry
+}
+var file_pipelinepb_pipeline_proto_depIdxs = []int32{
+	3, // 0: smartflo.transcription.v1.ProcessCallUpdate.analysis:type_name -> smartflo.transcription.v1.Analysis
+	4, // 1: smartflo.transcription.v1.Analysis.answers:type_name -> smartflo.transcription.v1.Analysis.AnswersEntry
+	0, // 2: smartflo.transcription.v1.CallPipeline.ProcessCall:input_type -> smartflo.transcription.v1.ProcessCallRequest
+	2, // 3: smartflo.transcription.v1.CallPipeline.GetAnalysis:input_type -> smartflo.transcription.v1.GetAnalysisRequest
+	1, // 4: smartflo.transcription.v1.CallPipeline.ProcessCall:output_type -> smartflo.transcription.v1.ProcessCallUpdate
+	3, // 5: smartflo.transcription.v1.CallPipeline.GetAnalysis:output_type -> smartflo.transcription.v1.Analysis
+	4, // [4:6] is the sub-list for method output_type
+	2, // [2:4] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_pipelinepb_pipeline_proto_init() }
+func file_pipelinepb_pipeline_proto_init() {
+	if File_pipelinepb_pipeline_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_pipelinepb_pipeline_proto_msgTypes[0].Exporter = func(v any, i int) any {
+			switch v := v.(*ProcessCallRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pipelinepb_pipeline_proto_msgTypes[1].Exporter = func(v any, i int) any {
+			switch v := v.(*ProcessCallUpdate); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pipelinepb_pipeline_proto_msgTypes[2].Exporter = func(v any, i int) any {
+			switch v := v.(*GetAnalysisRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_pipelinepb_pipeline_proto_msgTypes[3].Exporter = func(v any, i int) any {
+			switch v := v.(*Analysis); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_pipelinepb_pipeline_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pipelinepb_pipeline_proto_goTypes,
+		DependencyIndexes: file_pipelinepb_pipeline_proto_depIdxs,
+		MessageInfos:      file_pipelinepb_pipeline_proto_msgTypes,
+	}.Build()
+	File_pipelinepb_pipeline_proto = out.File
+	file_pipelinepb_pipeline_proto_rawDesc = nil
+	file_pipelinepb_pipeline_proto_goTypes = nil
+	file_pipelinepb_pipeline_proto_depIdxs = nil
+}
@@ -0,0 +1,190 @@
+// Internal gRPC interface to the transcription pipeline (SERVER_MODE=grpc).
+//
+// Regenerate the Go code after changing this file, from lambda-transcription/:
+//
+//	protoc --go_out=. --go_opt=module=lambda-transcription \
+//	       --go-grpc_out=. --go-grpc_opt=module=lambda-transcription \
+//	       pipelinepb/pipeline.proto
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v25.3.0
+// source: pipelinepb/pipeline.proto
+
+package pipelinepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	CallPipeline_ProcessCall_FullMethodName = "/smartflo.transcription.v1.CallPipeline/ProcessCall"
+	CallPipeline_GetAnalysis_FullMethodName = "/smartflo.transcription.v1.CallPipeline/GetAnalysis"
+)
+
+// CallPipelineClient is the client API for CallPipeline service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type CallPipelineClient interface {
+	// ProcessCall processes a call, streaming an update as each stage starts and a final update with
+	// the analysis. Errors use the status code matching the Lambda response's statusCode (NOT_FOUND,
+	// ALREADY_EXISTS for calls that were already processed or are being processed, and so on).
+	ProcessCall(ctx context.Context, in *ProcessCallRequest, opts ...grpc.CallOption) (CallPipeline_ProcessCallClient, error)
+	// GetAnalysis returns a call's stored analysis, or NOT_FOUND while it hasn't been processed
+	GetAnalysis(ctx context.Context, in *GetAnalysisRequest, opts ...grpc.CallOption) (*Analysis, error)
+}
+
+type callPipelineClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCallPipelineClient(cc grpc.ClientConnInterface) CallPipelineClient {
+	return &callPipelineClient{cc}
+}
+
+func (c *callPipelineClient) ProcessCall(ctx context.Context, in *ProcessCallRequest, opts ...grpc.CallOption) (CallPipeline_ProcessCallClient, error) {
+	stream, err := c.cc.NewStream(ctx, &CallPipeline_ServiceDesc.Streams[0], CallPipeline_ProcessCall_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &callPipelineProcessCallClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type CallPipeline_ProcessCallClient interface {
+	Recv() (*ProcessCallUpdate, error)
+	grpc.ClientStream
+}
+
+type callPipelineProcessCallClient struct {
+	grpc.ClientStream
+}
+
+func (x *callPipelineProcessCallClient) Recv() (*ProcessCallUpdate, error) {
+	m := new(ProcessCallUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *callPipelineClient) GetAnalysis(ctx context.Context, in *GetAnalysisRequest, opts ...grpc.CallOption) (*Analysis, error) {
+	out := new(Analysis)
+	err := c.cc.Invoke(ctx, CallPipeline_GetAnalysis_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CallPipelineServer is the server API for CallPipeline service.
+// All implementations must embed UnimplementedCallPipelineServer
+// for forward compatibility
+type CallPipelineServer interface {
+	// ProcessCall processes a call, streaming an update as each stage starts and a final update with
+	// the analysis. Errors use the status code matching the Lambda response's statusCode (NOT_FOUND,
+	// ALREADY_EXISTS for calls that were already processed or are being processed, and so on).
+	ProcessCall(*ProcessCallRequest, CallPipeline_ProcessCallServer) error
+	// GetAnalysis returns a call's stored analysis, or NOT_FOUND while it hasn't been processed
+	GetAnalysis(context.Context, *GetAnalysisRequest) (*Analysis, error)
+	mustEmbedUnimplementedCallPipelineServer()
+}
+
+// UnimplementedCallPipelineServer must be embedded to have forward compatible implementations.
+type UnimplementedCallPipelineServer struct {
+}
+
+func (UnimplementedCallPipelineServer) ProcessCall(*ProcessCallRequest, CallPipeline_ProcessCallServer) error {
+	return status.Errorf(codes.Unimplemented, "method ProcessCall not implemented")
+}
+func (UnimplementedCallPipelineServer) GetAnalysis(context.Context, *GetAnalysisRequest) (*Analysis, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetAnalysis not implemented")
+}
+func (UnimplementedCallPipelineServer) mustEmbedUnimplementedCallPipelineServer() {}
+
+// UnsafeCallPipelineServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CallPipelineServer will
+// result in compilation errors.
+type UnsafeCallPipelineServer interface {
+	mustEmbedUnimplementedCallPipelineServer()
+}
+
+func RegisterCallPipelineServer(s grpc.ServiceRegistrar, srv CallPipelineServer) {
+	s.RegisterService(&CallPipeline_ServiceDesc, srv)
+}
+
+func _CallPipeline_ProcessCall_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ProcessCallRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CallPipelineServer).ProcessCall(m, &callPipelineProcessCallServer{stream})
+}
+
+type CallPipeline_ProcessCallServer interface {
+	Send(*ProcessCallUpdate) error
+	grpc.ServerStream
+}
+
+type callPipelineProcessCallServer struct {
+	grpc.ServerStream
+}
+
+func (x *callPipelineProcessCallServer) Send(m *ProcessCallUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _CallPipeline_GetAnalysis_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetAnalysisRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CallPipelineServer).GetAnalysis(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CallPipeline_GetAnalysis_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CallPipelineServer).GetAnalysis(ctx, req.(*GetAnalysisRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CallPipeline_ServiceDesc is the grpc.ServiceDesc for CallPipeline service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CallPipeline_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "smartflo.transcription.v1.CallPipeline",
+	HandlerType: (*CallPipelineServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetAnalysis",
+			Handler:    _CallPipeline_GetAnalysis_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ProcessCall",
+			Handler:       _CallPipeline_ProcessCall_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "pipelinepb/pipeline.proto",
+}
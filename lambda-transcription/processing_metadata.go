@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ProcessingMetadata records where a call's processing time went, for capacity planning and
+// debugging slow calls
+type ProcessingMetadata struct {
+	Stages StageDurations `json:"stages_ms"`
+	// TotalMs is the time from the start of processing until the analysis was saved
+	TotalMs    int64 `json:"total_ms"`
+	AudioBytes int   `json:"audio_bytes,omitempty"`
+	// SourceFormat is the MIME type of video recordings, whose audio track is what gets transcribed
+	SourceFormat string `json:"source_format,omitempty"`
+	// RecordingLegs is the number of recordings merged for multi-leg calls; AudioBytes is their total
+	RecordingLegs int `json:"recording_legs,omitempty"`
+	// TranscriptionModel and AnsweringModel are empty for cache hits
+	TranscriptionModel string `json:"transcription_model,omitempty"`
+	AnsweringModel     string `json:"answering_model,omitempty"`
+	// ModelFallbacks counts the Gemini requests a fallback model answered
+	ModelFallbacks int `json:"model_fallbacks,omitempty"`
+	// GeminiTenant is the tenant whose Gemini API key the call was processed with
+	GeminiTenant string `json:"gemini_tenant,omitempty"`
+	// FewShotExamples counts the few-shot examples in the prompt, and FewShotExamplesOmitted those
+	// left out to stay within FEW_SHOT_MAX_BYTES
+	FewShotExamples        int `json:"few_shot_examples,omitempty"`
+	FewShotExamplesOmitted int `json:"few_shot_examples_omitted,omitempty"`
+	// BudgetDowngrade is the model the call was downgraded to because its campaign was over budget
+	BudgetDowngrade string `json:"budget_downgrade,omitempty"`
+	// MemoryLimitMB is the function's memory and MemoryUsedMB what the instance had taken from the OS
+	// by the end of the call; both are left out outside Lambda
+	MemoryLimitMB int `json:"memory_limit_mb,omitempty"`
+	MemoryUsedMB  int `json:"memory_used_mb,omitempty"`
+	// ProviderResponses are the raw Gemini responses archived for the campaign's debugResponses flag
+	ProviderResponses []ArtifactRef `json:"provider_responses,omitempty"`
+	// ReplayedFrom is the archived response a replayed call was parsed from instead of transcribing it
+	ReplayedFrom string `json:"replayed_from,omitempty"`
+	// Profile is the learned profile of a campaign with profileLearning
+	Profile *ProfileChoice `json:"profile,omitempty"`
+	// ArchiveError is why archiving the call's artifacts to S3 failed after the analysis was saved
+	ArchiveError string `json:"archive_error,omitempty"`
+
+	started time.Time
+}
+
+// StageDurations are the milliseconds spent in each stage of processing a call. With the default
+// Gemini provider the questions are answered in the transcription request, so answering stays 0
+// unless the campaign uses two-phase processing.
+type StageDurations struct {
+	// DBFetch covers the call, campaign settings, questions, compliance rules and rubric
+	DBFetch  int64 `json:"db_fetch"`
+	Download int64 `json:"download"`
+	// Transcription includes audio extraction from videos, preprocessing and disposition detection
+	Transcription int64 `json:"transcription"`
+	Answering     int64 `json:"answering"`
+	// Enrichment covers QA scoring, classification, extraction, translation, embedding and the CRM push
+	Enrichment int64 `json:"enrichment"`
+	// Save covers the analysis and everything stored alongside it (outcomes, tasks, artifacts, subtitles)
+	Save int64 `json:"save"`
+}
+
+// elapsedMs returns the milliseconds since start
+func elapsedMs(start time.Time) int64 {
+	return time.Since(start).Milliseconds()
+}
+
+// startProcessingMetadata resets the metadata for a new call
+func (tp *TranscriptionPipeline) startProcessingMetadata() {
+	tp.metadata = ProcessingMetadata{started: time.Now()}
+}
+
+// processingMetadata returns a copy of the current call's metadata with its total so far
+func (tp *TranscriptionPipeline) processingMetadata() *ProcessingMetadata {
+	metadata := tp.metadata
+	if !metadata.started.IsZero() {
+		metadata.TotalMs = elapsedMs(metadata.started)
+	}
+	if memory := lambdaMemoryBytes(); memory > 0 {
+		metadata.MemoryLimitMB, metadata.MemoryUsedMB = int(memory>>20), memoryUsedMB()
+	}
+	return &metadata
+}
+
+// RecordSaveDuration adds the save stage, timed from start, to the stored analysis's
+// processing_metadata along with the final total. The analysis is saved before the stage ends, so
+// this is a separate best-effort update; a failure is logged and doesn't affect the call.
+func (tp *TranscriptionPipeline) RecordSaveDuration(callLogsID string, start time.Time) {
+	tp.metadata.Stages.Save = elapsedMs(start)
+	total := tp.processingMetadata().TotalMs
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET %s = jsonb_set(jsonb_set(%s, '{processing_metadata,stages_ms,save}', to_jsonb($1::bigint)),
+		                   '{processing_metadata,total_ms}', to_jsonb($2::bigint))
+		WHERE %s = $3 AND %s ? 'processing_metadata'
+	`, tp.schema.Table("call_logs"), c("callAnalysis"), c("callAnalysis"), c("id"), c("callAnalysis"))
+
+	if _, err := tp.repo.Exec(query, tp.metadata.Stages.Save, total, callLogsID); err != nil {
+		log.Printf("Error recording save duration for %s: %v", callLogsID, err)
+	}
+}
+
+// RecordArchiveError adds why archiving failed to the stored analysis's processing_metadata. Like
+// RecordSaveDuration it is a best-effort update made after the save; a failure is logged.
+func (tp *TranscriptionPipeline) RecordArchiveError(callLogsID string, archiveErr error) {
+	tp.metadata.ArchiveError = archiveErr.Error()
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	query := fmt.Sprintf(`
+		UPDATE %s
+		SET %s = jsonb_set(%s, '{processing_metadata,archive_error}', to_jsonb($1::text))
+		WHERE %s = $2 AND %s ? 'processing_metadata'
+	`, tp.schema.Table("call_logs"), c("callAnalysis"), c("callAnalysis"), c("id"), c("callAnalysis"))
+
+	if _, err := tp.repo.Exec(query, tp.metadata.ArchiveError, callLogsID); err != nil {
+		log.Printf("Error recording archive failure for %s: %v", callLogsID, err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+// Stages reported while a call is processed, in the order they start. Stages that don't apply to a
+// call are skipped: cache hits aren't downloaded or transcribed, and with the default Gemini provider
+// the questions are answered in the transcription request.
+const (
+	StageFetching     = "fetching"
+	StageDownloading  = "downloading"
+	StageTranscribing = "transcribing"
+	StageAnswering    = "answering"
+	StageEnriching    = "enriching"
+	StageSaving       = "saving"
+	// StageDone and StageFailed end every call that gets as far as connecting to the database
+	StageDone   = "done"
+	StageFailed = "failed"
+)
+
+// ProgressFunc is called as each stage of processing a call starts; err is set for StageFailed
+type ProgressFunc func(stage string, err error)
+
+// SetProgress sets the function called as each stage of processing a call starts
+func (tp *TranscriptionPipeline) SetProgress(progress ProgressFunc) {
+	tp.progress = progress
+}
+
+// reportStage reports that a stage of processing the current call has started
+func (tp *TranscriptionPipeline) reportStage(stage string) {
+	tp.errorContext.Stage = stage
+	if tp.progress != nil {
+		tp.progress(stage, nil)
+	}
+}
+
+// reportFinished reports that processing the current call succeeded or failed with err
+func (tp *TranscriptionPipeline) reportFinished(err error) {
+	if tp.progress == nil {
+		return
+	}
+	if err != nil {
+		tp.progress(StageFailed, err)
+		return
+	}
+	tp.progress(StageDone, nil)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// websocketProgressEnabled reports whether stage transitions are pushed to WebSocket subscribers
+// (PROGRESS_WEBSOCKET_ENABLED=true). Subscriptions are made through the API's WebSocket mode.
+func websocketProgressEnabled() bool {
+	return os.Getenv("PROGRESS_WEBSOCKET_ENABLED") == "true"
+}
+
+// ProgressMessage is pushed to a job's WebSocket subscribers as each stage starts
+type ProgressMessage struct {
+	JobID      string `json:"jobId"`
+	CallLogsID string `json:"call_logsId"`
+	Stage      string `json:"stage"`
+	// ElapsedMs is the time since processing started
+	ElapsedMs int64  `json:"elapsedMs"`
+	Error     string `json:"error,omitempty"`
+}
+
+// progressSubscriber is a WebSocket connection waiting for a job's progress
+type progressSubscriber struct {
+	ConnectionID string
+	// CallbackURL is the API Gateway management endpoint the connection is reached through
+	CallbackURL string
+}
+
+// websocketProgress returns a ProgressFunc pushing each stage to the connections subscribed to the
+// job. Subscribers are looked up per stage so a UI that connects after submitting the call still
+// gets the later stages. Pushes are best-effort and never fail the call.
+func (tp *TranscriptionPipeline) websocketProgress(jobID, callLogsID string) ProgressFunc {
+	start := time.Now()
+	return func(stage string, stageErr error) {
+		message := ProgressMessage{JobID: jobID, CallLogsID: callLogsID, Stage: stage, ElapsedMs: elapsedMs(start)}
+		if stageErr != nil {
+			message.Error = stageErr.Error()
+		}
+		body, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Error encoding progress for job %s: %v", jobID, err)
+			return
+		}
+
+		subscribers, err := tp.progressSubscribers(jobID)
+		if err != nil {
+			log.Printf("Error listing progress subscribers for job %s: %v", jobID, err)
+			return
+		}
+		for _, subscriber := range subscribers {
+			err := postToConnection(subscriber, body)
+			var apiErr *AWSAPIError
+			switch {
+			case err == nil:
+			case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusGone:
+				// The client went away without API Gateway delivering $disconnect
+				tp.removeProgressSubscriber(subscriber.ConnectionID)
+			default:
+				log.Printf("Error pushing progress for job %s to %s: %v", jobID, subscriber.ConnectionID, err)
+			}
+		}
+	}
+}
+
+// progressSubscribers returns the connections subscribed to a job
+func (tp *TranscriptionPipeline) progressSubscribers(jobID string) ([]progressSubscriber, error) {
+	if tp.repo == nil {
+		return nil, fmt.Errorf("database is not connected")
+	}
+
+	query := fmt.Sprintf(`SELECT "connectionId", "callbackUrl" FROM %s WHERE "jobId" = $1`, tp.schema.Table("progress_subscriptions"))
+	rows, err := tp.repo.Query(query, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subscribers []progressSubscriber
+	for rows.Next() {
+		var subscriber progressSubscriber
+		if err := rows.Scan(&subscriber.ConnectionID, &subscriber.CallbackURL); err != nil {
+			return nil, err
+		}
+		subscribers = append(subscribers, subscriber)
+	}
+	return subscribers, rows.Err()
+}
+
+// removeProgressSubscriber drops a connection that no longer exists; dry runs leave it for the next push
+func (tp *TranscriptionPipeline) removeProgressSubscriber(connectionID string) {
+	if tp.dryRun {
+		return
+	}
+	query := fmt.Sprintf(`DELETE FROM %s WHERE "connectionId" = $1`, tp.schema.Table("progress_subscriptions"))
+	if _, err := tp.repo.Exec(query, connectionID); err != nil {
+		log.Printf("Error removing progress subscriber %s: %v", connectionID, err)
+	}
+}
+
+// postToConnection sends a message to a WebSocket client through the API Gateway management API.
+// Connection IDs are sent unescaped (they may end in "=") so the signed path matches the one API
+// Gateway canonicalizes.
+func postToConnection(subscriber progressSubscriber, body []byte) error {
+	endpoint := strings.TrimRight(subscriber.CallbackURL, "/") + "/@connections/" + subscriber.ConnectionID
+	_, err := doAWSRequest("POST", endpoint, "execute-api", map[string]string{"Content-Type": "application/json"}, body)
+	return err
+}
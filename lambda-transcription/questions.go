@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// QuestionCondition makes a question depend on another question's answer. Exactly one of
+// Equals, NotEquals or In is expected; values are compared case-insensitively.
+//
+//	"showIf": {"questionId": "<id>", "equals": "true"}
+//	"showIf": [{"questionId": "<id>", "in": ["yes", "maybe"]}, {"questionId": "<id>", "notEquals": "0"}]
+type QuestionCondition struct {
+	QuestionID string        `json:"questionId"`
+	Equals     interface{}   `json:"equals,omitempty"`
+	NotEquals  interface{}   `json:"notEquals,omitempty"`
+	In         []interface{} `json:"in,omitempty"`
+}
+
+// parseQuestionConditions reads the "showIf" rule from a question's details; a single condition
+// or a list of conditions (all of which must hold) are accepted
+func parseQuestionConditions(details map[string]interface{}) ([]QuestionCondition, error) {
+	raw, ok := details["showIf"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("error reading showIf: %v", err)
+	}
+
+	var conditions []QuestionCondition
+	if _, isList := raw.([]interface{}); isList {
+		err = json.Unmarshal(data, &conditions)
+	} else {
+		var condition QuestionCondition
+		err = json.Unmarshal(data, &condition)
+		conditions = []QuestionCondition{condition}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing showIf: %v", err)
+	}
+
+	for _, c := range conditions {
+		if c.QuestionID == "" {
+			return nil, fmt.Errorf("showIf condition is missing questionId")
+		}
+	}
+	return conditions, nil
+}
+
+// markNotDiscussed returns the answers with empty answers and the model's variants of
+// NOT_DISCUSSED ("not discussed", "[NOT_DISCUSSED] | ...") replaced by AnswerNotDiscussed.
+// Questions without an answer line are left out, since a missing line means the response couldn't
+// be parsed rather than that the topic didn't come up.
+func markNotDiscussed(answers map[string]string) map[string]string {
+	marked := make(map[string]string, len(answers))
+	for id, answer := range answers {
+		if isNotDiscussed(answer) {
+			answer = AnswerNotDiscussed
+		}
+		marked[id] = answer
+	}
+	return marked
+}
+
+// isNotDiscussed reports whether an answer is empty or says the question wasn't discussed
+func isNotDiscussed(answer string) bool {
+	choice, _, _ := strings.Cut(answer, enumJustificationSeparator)
+	choice = strings.ToLower(strings.Trim(strings.TrimSpace(choice), "[]\"'."))
+	choice = strings.NewReplacer("_", " ", "-", " ").Replace(choice)
+	return choice == "" || choice == "not discussed"
+}
+
+// normalizeAnswer lowercases and trims an answer for comparison
+func normalizeAnswer(value interface{}) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(fmt.Sprint(value))), ".")
+}
+
+// met reports whether the dependency's answer satisfies the condition
+func (c QuestionCondition) met(answer string) bool {
+	answer = normalizeAnswer(answer)
+	switch {
+	case c.Equals != nil:
+		return answer == normalizeAnswer(c.Equals)
+	case c.NotEquals != nil:
+		return answer != normalizeAnswer(c.NotEquals)
+	case len(c.In) > 0:
+		for _, value := range c.In {
+			if answer == normalizeAnswer(value) {
+				return true
+			}
+		}
+		return false
+	}
+	// A condition without a comparison only requires the question to have been answered
+	return answer != "" && answer != normalizeAnswer(AnswerNotDiscussed)
+}
+
+// describe explains the condition for skipped-question records
+func (c QuestionCondition) describe() string {
+	switch {
+	case c.Equals != nil:
+		return fmt.Sprintf("%s = %q", c.QuestionID, fmt.Sprint(c.Equals))
+	case c.NotEquals != nil:
+		return fmt.Sprintf("%s != %q", c.QuestionID, fmt.Sprint(c.NotEquals))
+	case len(c.In) > 0:
+		values := make([]string, len(c.In))
+		for i, value := range c.In {
+			values[i] = fmt.Sprintf("%q", fmt.Sprint(value))
+		}
+		return fmt.Sprintf("%s in [%s]", c.QuestionID, strings.Join(values, ", "))
+	}
+	return c.QuestionID + " answered"
+}
+
+// applyQuestionConditions removes the answers of questions whose showIf conditions aren't met and
+// returns them as skipped questions with the reason. A question that depends on a skipped question is
+// skipped too.
+func applyQuestionConditions(questions []Question, answers map[string]string) (map[string]string, map[string]string) {
+	byID := make(map[string]Question, len(questions))
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	skipped := make(map[string]string)
+	resolved := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var resolve func(id string) bool
+	resolve = func(id string) bool {
+		if shown, done := resolved[id]; done {
+			return shown
+		}
+		if visiting[id] {
+			skipped[id] = "circular showIf condition"
+			return false
+		}
+		visiting[id] = true
+		defer delete(visiting, id)
+
+		shown := true
+		for _, condition := range byID[id].Conditions {
+			if _, known := byID[condition.QuestionID]; known && !resolve(condition.QuestionID) {
+				shown = false
+				skipped[id] = fmt.Sprintf("depends on skipped question %s", condition.QuestionID)
+				break
+			}
+			if !condition.met(answers[condition.QuestionID]) {
+				shown = false
+				skipped[id] = fmt.Sprintf("condition not met: %s (answered %q)", condition.describe(), answers[condition.QuestionID])
+				break
+			}
+		}
+
+		resolved[id] = shown
+		return shown
+	}
+
+	filtered := make(map[string]string, len(answers))
+	for _, q := range questions {
+		if resolve(q.ID) {
+			if answer, ok := answers[q.ID]; ok {
+				filtered[q.ID] = answer
+			}
+		}
+	}
+
+	return filtered, skipped
+}
+
+// groupQuestions orders questions so each group's questions are asked together, keeping groups in
+// order of first appearance and questions in their original order within a group
+func groupQuestions(questions []Question) []Question {
+	var groups []string
+	byGroup := make(map[string][]Question)
+	for _, q := range questions {
+		if _, seen := byGroup[q.Group]; !seen {
+			groups = append(groups, q.Group)
+		}
+		byGroup[q.Group] = append(byGroup[q.Group], q)
+	}
+
+	grouped := make([]Question, 0, len(questions))
+	for _, group := range groups {
+		grouped = append(grouped, byGroup[group]...)
+	}
+	return grouped
+}
+
+// AnswerTypeEnum is the answer type of multiple-choice questions whose details list the allowed "options"
+const AnswerTypeEnum = "enum"
+
+// EnumAnswer represents the validated answer to an enum question
+type EnumAnswer struct {
+	Option        string `json:"option,omitempty"`
+	Justification string `json:"justification,omitempty"`
+	Raw           string `json:"raw"`
+	Valid         bool   `json:"valid"`
+	Error         string `json:"error,omitempty"`
+}
+
+// parseQuestionOptions reads the "options" list from a question's details
+func parseQuestionOptions(details map[string]interface{}) []string {
+	raw, ok := details["options"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	options := make([]string, 0, len(raw))
+	for _, value := range raw {
+		if option := strings.TrimSpace(fmt.Sprint(value)); option != "" {
+			options = append(options, option)
+		}
+	}
+	return options
+}
+
+// validateEnumAnswers replaces each enum question's answer with the chosen option and records the
+// option and justification. Answers that don't match an allowed option are removed from the answers
+// and recorded as invalid; NOT_DISCUSSED answers are kept as they are.
+func validateEnumAnswers(questions []Question, answers map[string]string) (map[string]string, map[string]EnumAnswer) {
+	validated := make(map[string]string, len(answers))
+	for id, answer := range answers {
+		validated[id] = answer
+	}
+
+	enumAnswers := make(map[string]EnumAnswer)
+	for _, q := range questions {
+		if q.AnswerType != AnswerTypeEnum {
+			continue
+		}
+		raw, ok := answers[q.ID]
+		if !ok || raw == AnswerNotDiscussed {
+			continue
+		}
+
+		enumAnswer := parseEnumAnswer(raw, q.Options)
+		if enumAnswer.Valid {
+			validated[q.ID] = enumAnswer.Option
+		} else {
+			delete(validated, q.ID)
+		}
+		enumAnswers[q.ID] = enumAnswer
+	}
+
+	return validated, enumAnswers
+}
+
+// parseEnumAnswer splits "Option | justification" and matches the option case-insensitively against the allowed options
+func parseEnumAnswer(raw string, options []string) EnumAnswer {
+	choice, justification, _ := strings.Cut(raw, enumJustificationSeparator)
+	choice = strings.Trim(strings.TrimSpace(choice), `'"[].`)
+
+	enumAnswer := EnumAnswer{Raw: raw, Justification: strings.TrimSpace(justification)}
+	if len(options) == 0 {
+		enumAnswer.Error = "question has no options configured"
+		return enumAnswer
+	}
+
+	for _, option := range options {
+		if strings.EqualFold(choice, option) {
+			enumAnswer.Option = option
+			enumAnswer.Valid = true
+			return enumAnswer
+		}
+	}
+
+	enumAnswer.Error = fmt.Sprintf("%q is not one of the allowed options", choice)
+	return enumAnswer
+}
+
+// ErrInvalidQuestions rejects ad-hoc questions sent with a request that can't be asked
+var ErrInvalidQuestions = errors.New("invalid ad-hoc questions")
+
+// How ad-hoc questions combine with the campaign's
+const (
+	QuestionsModeReplace = "replace"
+	QuestionsModeAppend  = "append"
+)
+
+// adHocQuestions builds questions sent with a request, each in the question table's details
+// format. They are identified as q1, q2, ... in the answers and in showIf conditions.
+func adHocQuestions(details []map[string]interface{}) ([]Question, error) {
+	questions := make([]Question, 0, len(details))
+	for i, d := range details {
+		q := Question{ID: fmt.Sprintf("q%d", i+1), IsActive: true, Details: d}
+		if err := applyQuestionDetails(&q); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuestions, err)
+		}
+		if q.QuestionText == "" {
+			return nil, fmt.Errorf("%w: question %s has no questionText", ErrInvalidQuestions, q.ID)
+		}
+		questions = append(questions, q)
+	}
+	return groupQuestions(questions), nil
+}
+
+// SetAdHocQuestions answers the given questions instead of ("replace", the default) or besides
+// ("append") the campaign's. The analysis of hypothetical questions mustn't replace a call's stored
+// one, so the run becomes a dry run.
+func (tp *TranscriptionPipeline) SetAdHocQuestions(details []map[string]interface{}, mode string) error {
+	switch mode {
+	case "":
+		mode = QuestionsModeReplace
+	case QuestionsModeReplace, QuestionsModeAppend:
+	default:
+		return fmt.Errorf("%w: unknown questions_mode %q; use replace or append", ErrInvalidQuestions, mode)
+	}
+	if len(details) == 0 {
+		return nil
+	}
+
+	questions, err := adHocQuestions(details)
+	if err != nil {
+		return err
+	}
+	tp.adHocQuestions = questions
+	tp.adHocQuestionsMode = mode
+	tp.SetDryRun(true)
+	return nil
+}
+
+// questionsForCampaign returns the questions to answer for a campaign: its own (cached across warm
+// invocations), replaced or supplemented by the ad-hoc questions
+func (tp *TranscriptionPipeline) questionsForCampaign(campaignID string) ([]Question, error) {
+	if len(tp.adHocQuestions) > 0 && tp.adHocQuestionsMode == QuestionsModeReplace {
+		return tp.adHocQuestions, nil
+	}
+	questions, err := tp.GetCachedQuestionsForCampaign(campaignID)
+	if err != nil || len(tp.adHocQuestions) == 0 {
+		return questions, err
+	}
+	// The cached slice is shared, so combine into a new one
+	combined := make([]Question, 0, len(questions)+len(tp.adHocQuestions))
+	combined = append(append(combined, questions...), tp.adHocQuestions...)
+	return groupQuestions(combined), nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultQuestionsCacheTTL is how long cached campaign questions are used before their version is checked
+const defaultQuestionsCacheTTL = time.Minute
+
+// questionsCacheEntry is a campaign's question set as of a question_cache_version
+type questionsCacheEntry struct {
+	questions []Question
+	version   int64
+	checkedAt time.Time
+}
+
+// The questions cache is shared by every pipeline in the process, so it persists across warm
+// invocations and is safe for the concurrent calls of the HTTP server mode
+var (
+	questionsCacheMu sync.Mutex
+	questionsCache   = make(map[string]*questionsCacheEntry)
+)
+
+// questionsCacheTTL reads the questions cache TTL (QUESTIONS_CACHE_TTL_SECONDS; 0 disables the cache)
+func questionsCacheTTL() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("QUESTIONS_CACHE_TTL_SECONDS")); err == nil && value >= 0 {
+		return time.Duration(value) * time.Second
+	}
+	return defaultQuestionsCacheTTL
+}
+
+// GetCachedQuestionsForCampaign returns the campaign's questions from the in-memory cache. Entries
+// are served without any query until the TTL expires; after that one cheap lookup of the
+// question_cache_version row decides whether the questions are reloaded or kept for another TTL.
+func (tp *TranscriptionPipeline) GetCachedQuestionsForCampaign(campaignID string) ([]Question, error) {
+	ttl := questionsCacheTTL()
+	if ttl == 0 {
+		return tp.GetQuestionsForCampaign(campaignID)
+	}
+
+	// Keyed by table as well, in case pipelines for different schemas share the process
+	key := tp.schema.Table("question") + "/" + campaignID
+
+	questionsCacheMu.Lock()
+	entry := questionsCache[key]
+	questionsCacheMu.Unlock()
+
+	if entry != nil && time.Since(entry.checkedAt) < ttl {
+		return entry.questions, nil
+	}
+
+	// Without the version table (migration not applied yet) the cache falls back to a plain TTL
+	version, versionErr := tp.questionsVersion()
+	if entry != nil && versionErr == nil && entry.version == version {
+		questionsCacheMu.Lock()
+		questionsCache[key] = &questionsCacheEntry{questions: entry.questions, version: version, checkedAt: time.Now()}
+		questionsCacheMu.Unlock()
+		return entry.questions, nil
+	}
+
+	questions, err := tp.GetQuestionsForCampaign(campaignID)
+	if err != nil {
+		return nil, err
+	}
+	if versionErr != nil {
+		version = -1
+	}
+
+	questionsCacheMu.Lock()
+	questionsCache[key] = &questionsCacheEntry{questions: questions, version: version, checkedAt: time.Now()}
+	questionsCacheMu.Unlock()
+	return questions, nil
+}
+
+// questionsVersion reads the current question_cache_version
+func (tp *TranscriptionPipeline) questionsVersion() (int64, error) {
+	var version int64
+	query := fmt.Sprintf(`SELECT version FROM %s`, tp.schema.Table("question_cache_version"))
+	if err := tp.repo.QueryRow(query).Scan(&version); err != nil {
+		return 0, fmt.Errorf("error fetching question cache version: %v", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// defaultGeminiQuotaMaxWait is how long a request waits for quota when GEMINI_QUOTA_MAX_WAIT_SECONDS is not set
+const defaultGeminiQuotaMaxWait = 20 * time.Second
+
+// ErrGeminiQuotaExhausted means the shared Gemini quota had no room for a request within the wait limit
+var ErrGeminiQuotaExhausted = errors.New("gemini quota exhausted")
+
+// GeminiQuota is a token bucket shared by every invocation: Burst requests may be made at once,
+// refilled at PerMinute per minute
+type GeminiQuota struct {
+	PerMinute float64
+	Burst     float64
+	MaxWait   time.Duration
+}
+
+// Enabled reports whether the quota is configured (a zero rate disables it)
+func (q GeminiQuota) Enabled() bool {
+	return q.PerMinute > 0
+}
+
+// geminiQuotaFromEnv reads GEMINI_QUOTA_PER_MINUTE, GEMINI_QUOTA_BURST (default the per-minute rate)
+// and GEMINI_QUOTA_MAX_WAIT_SECONDS
+func geminiQuotaFromEnv() GeminiQuota {
+	var quota GeminiQuota
+	if value, err := strconv.ParseFloat(os.Getenv("GEMINI_QUOTA_PER_MINUTE"), 64); err == nil && value >= 0 {
+		quota.PerMinute = value
+	}
+	quota.Burst = quota.PerMinute
+	if value, err := strconv.ParseFloat(os.Getenv("GEMINI_QUOTA_BURST"), 64); err == nil && value >= 1 {
+		quota.Burst = value
+	}
+	quota.MaxWait = defaultGeminiQuotaMaxWait
+	if seconds, err := strconv.Atoi(os.Getenv("GEMINI_QUOTA_MAX_WAIT_SECONDS")); err == nil && seconds >= 0 {
+		quota.MaxWait = time.Duration(seconds) * time.Second
+	}
+	return quota
+}
+
+// waitForGeminiQuota takes a token from the model's quota bucket (the tenant's own for campaigns with
+// a Gemini tenant), waiting up to the quota's MaxWait for one to be refilled. It returns
+// ErrGeminiQuotaExhausted when none arrives in time, so the call is deferred to a retry rather than
+// adding to a burst of 429s. Limiter failures are logged and the request is let through.
+func (tp *TranscriptionPipeline) waitForGeminiQuota(model string) error {
+	bucketKey, quota := tp.geminiQuotaBucket(model)
+	if !quota.Enabled() || tp.repo == nil {
+		return nil
+	}
+
+	deadline := time.Now().Add(quota.MaxWait)
+	for {
+		allowed, wait, err := tp.takeGeminiQuotaToken(bucketKey, quota)
+		if err != nil {
+			log.Printf("Gemini quota limiter unavailable: %v", err)
+			return nil
+		}
+		if allowed {
+			return nil
+		}
+		if time.Now().Add(wait).After(deadline) {
+			return fmt.Errorf("%w: no request budget for %s within %s", ErrGeminiQuotaExhausted, model, quota.MaxWait)
+		}
+		log.Printf("Gemini quota for %s exhausted, waiting %s", model, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+	}
+}
+
+// takeGeminiQuotaToken atomically refills the bucket and takes one token from it, returning whether
+// the request may go ahead and, if not, how long until a token is available.
+//
+// Buckets live in the api_rate_limit_bucket table shared with the API Gateway Lambda's rate
+// limiter, so the quota holds across concurrent Lambda instances. The two modules can't share code,
+// so both queries copy takeRateLimitToken's in lambda-api-gateway/ratelimit.go; any change to the
+// refill arithmetic or the table must be made to both.
+func (tp *TranscriptionPipeline) takeGeminiQuotaToken(bucketKey string, quota GeminiQuota) (bool, time.Duration, error) {
+	refillPerSecond := quota.PerMinute / 60
+
+	// The WHERE clause leaves the row untouched (and returns nothing) when the bucket is empty
+	query := fmt.Sprintf(`
+		INSERT INTO %s AS bucket ("bucketKey", tokens, "updatedAt")
+		VALUES ($1, $2 - 1, now())
+		ON CONFLICT ("bucketKey")
+		DO UPDATE SET
+			tokens = LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM (now() - bucket."updatedAt")) * $3) - 1,
+			"updatedAt" = now()
+		WHERE LEAST($2, bucket.tokens + EXTRACT(EPOCH FROM (now() - bucket."updatedAt")) * $3) >= 1
+		RETURNING tokens
+	`, tp.schema.Table("api_rate_limit_bucket"))
+
+	var remaining float64
+	err := tp.repo.QueryRow(query, bucketKey, quota.Burst, refillPerSecond).Scan(&remaining)
+	if err == nil {
+		return true, 0, nil
+	}
+	if err != sql.ErrNoRows {
+		return false, 0, fmt.Errorf("error updating quota bucket: %v", err)
+	}
+
+	// Bucket is empty: work out when the next token arrives
+	var tokens float64
+	balanceQuery := fmt.Sprintf(`
+		SELECT LEAST($2, tokens + EXTRACT(EPOCH FROM (now() - "updatedAt")) * $3)
+		FROM %s
+		WHERE "bucketKey" = $1
+	`, tp.schema.Table("api_rate_limit_bucket"))
+	if err := tp.repo.QueryRow(balanceQuery, bucketKey, quota.Burst, refillPerSecond).Scan(&tokens); err != nil {
+		return false, 0, fmt.Errorf("error reading quota bucket: %v", err)
+	}
+
+	wait := time.Duration((1 - tokens) / refillPerSecond * float64(time.Second))
+	if wait < 100*time.Millisecond {
+		wait = 100 * time.Millisecond
+	}
+	return false, wait, nil
+}
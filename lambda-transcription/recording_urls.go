@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Download retry defaults, overridable via AUDIO_DOWNLOAD_RETRIES, AUDIO_DOWNLOAD_RETRY_BASE_MS and
+// AUDIO_DOWNLOAD_RESUMES
+const (
+	defaultDownloadRetries   = 2
+	defaultDownloadRetryBase = 500 * time.Millisecond
+	defaultDownloadResumes   = 3
+)
+
+// downloadRetries reads how many times the candidate recording URLs are retried after the first round fails
+func downloadRetries() int {
+	if retries, err := strconv.Atoi(os.Getenv("AUDIO_DOWNLOAD_RETRIES")); err == nil && retries >= 0 {
+		return retries
+	}
+	return defaultDownloadRetries
+}
+
+// downloadRetryDelay is the backoff before retry round n (0-based): the base delay doubled each round
+func downloadRetryDelay(n int) time.Duration {
+	base := defaultDownloadRetryBase
+	if ms, err := strconv.Atoi(os.Getenv("AUDIO_DOWNLOAD_RETRY_BASE_MS")); err == nil && ms >= 0 {
+		base = time.Duration(ms) * time.Millisecond
+	}
+	return base << uint(n)
+}
+
+// downloadResumes reads how many times a download cut off part way is resumed
+func downloadResumes() int {
+	if resumes, err := strconv.Atoi(os.Getenv("AUDIO_DOWNLOAD_RESUMES")); err == nil && resumes >= 0 {
+		return resumes
+	}
+	return defaultDownloadResumes
+}
+
+// resumeDownload continues a download whose body was cut off after the bytes in partial, asking for
+// the rest with range requests. Each attempt gets the full download timeout. Servers that ignore
+// the range send the whole recording again, which replaces the partial one.
+func resumeDownload(newRequest func() (*http.Request, error), partial []byte, readErr error) ([]byte, error) {
+	for attempt := 1; attempt <= downloadResumes(); attempt++ {
+		log.Printf("Recording download cut off after %d bytes (%v), resuming (attempt %d)", len(partial), readErr, attempt)
+
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		if len(partial) > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(partial)))
+		}
+
+		resp, err := downloadClient().Do(req)
+		if err != nil {
+			readErr = err
+			continue
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusPartialContent && contentRangeStart(resp.Header.Get("Content-Range")) == len(partial):
+			var rest []byte
+			rest, readErr = readRecording(resp.Body)
+			partial = append(partial, rest...)
+			if err := checkRecordingSize(int64(len(partial))); err != nil {
+				readErr = err
+			}
+		case resp.StatusCode == http.StatusOK:
+			partial, readErr = readRecording(resp.Body)
+		default:
+			resp.Body.Close()
+			return nil, fmt.Errorf("error resuming audio download after %d bytes: status %d", len(partial), resp.StatusCode)
+		}
+		resp.Body.Close()
+
+		if readErr == nil {
+			return partial, nil
+		}
+		if errors.Is(readErr, ErrRecordingTooLarge) {
+			return nil, readErr
+		}
+	}
+	return nil, fmt.Errorf("error reading audio data: %v", readErr)
+}
+
+// contentRangeStart returns the first byte of a "bytes first-last/length" Content-Range, or -1
+func contentRangeStart(contentRange string) int {
+	rangeSpec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(rangeSpec, "-")
+	if !ok {
+		return -1
+	}
+	start, err := strconv.Atoi(first)
+	if err != nil {
+		return -1
+	}
+	return start
+}
+
+// recordingFallbackColumns returns the call_logs columns holding fallback recording URLs, from the
+// comma-separated RECORDING_FALLBACK_URL_COLUMNS
+func recordingFallbackColumns() []string {
+	var columns []string
+	for _, column := range strings.Split(os.Getenv("RECORDING_FALLBACK_URL_COLUMNS"), ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+// recordingMirrors reads RECORDING_URL_MIRRORS, a JSON object mapping a recording host to the hosts
+// serving the same paths, e.g. {"cdn.provider.com": ["mirror.provider.com"]}
+func recordingMirrors() (map[string][]string, error) {
+	raw := os.Getenv("RECORDING_URL_MIRRORS")
+	if raw == "" {
+		return nil, nil
+	}
+	var mirrors map[string][]string
+	if err := json.Unmarshal([]byte(raw), &mirrors); err != nil {
+		return nil, fmt.Errorf("error parsing RECORDING_URL_MIRRORS: %v", err)
+	}
+	return mirrors, nil
+}
+
+// recordingURLCandidates lists the URLs to download a recording from, in order: the recording URL,
+// its mirrors, then each fallback URL followed by its mirrors. Duplicates are dropped.
+func recordingURLCandidates(recordingURL string, fallbacks []string, mirrors map[string][]string) []string {
+	var candidates []string
+	seen := map[string]bool{}
+	add := func(candidate string) {
+		if candidate != "" && !seen[candidate] {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	for _, primary := range append([]string{recordingURL}, fallbacks...) {
+		add(primary)
+		parsed, err := url.Parse(primary)
+		if err != nil {
+			continue
+		}
+		for _, host := range mirrors[strings.ToLower(parsed.Host)] {
+			mirrored := *parsed
+			mirrored.Host = host
+			add(mirrored.String())
+		}
+	}
+	return candidates
+}
+
+// retryableDownloadStatus reports whether a failed download may succeed on a later attempt:
+// connection errors (status 0), timeouts, rate limits, server errors and the intermittent 404s some
+// CDNs return before a recording has propagated
+func retryableDownloadStatus(statusCode int) bool {
+	switch statusCode {
+	case 0, http.StatusNotFound, http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return statusCode >= 500
+}
+
+// downloadFromCandidates tries each candidate URL in order, repeating the round with exponential
+// backoff while any candidate failed in a way a retry could fix. It stops as soon as ctx is done.
+func downloadFromCandidates(ctx context.Context, candidates []string, download func(string) ([]byte, int, error)) ([]byte, error) {
+	retries := downloadRetries()
+	var failures []string
+	for round := 0; ; round++ {
+		retryable := false
+		for i, candidate := range candidates {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			audioData, statusCode, err := download(candidate)
+			if err == nil {
+				if i > 0 || round > 0 {
+					log.Printf("Downloaded recording from candidate %d of %d on attempt %d", i+1, len(candidates), round+1)
+				}
+				return audioData, nil
+			}
+			// Every candidate is the same recording, so none of them would fit either
+			if errors.Is(err, ErrRecordingTooLarge) {
+				return nil, err
+			}
+			failures = append(failures, err.Error())
+			if retryableDownloadStatus(statusCode) {
+				retryable = true
+			}
+		}
+
+		if !retryable || round >= retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(downloadRetryDelay(round)):
+		}
+	}
+
+	if len(candidates) == 1 && len(failures) == 1 {
+		return nil, fmt.Errorf("%w: %s", ErrDownloadFailed, failures[0])
+	}
+	return nil, fmt.Errorf("%w: all %d download attempts from %d recording URLs failed: %s",
+		ErrDownloadFailed, len(failures), len(candidates), strings.Join(failures, "; "))
+}
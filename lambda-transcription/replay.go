@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNoArchivedResponse means a call has no archived Gemini response to replay
+var ErrNoArchivedResponse = errors.New("no archived process_audio response to replay")
+
+// processAudioResponse reports whether an archived object is a successful response to the request
+// that transcribes the recording and answers the questions: a "-response.json" of full archival, or
+// a "-200.json" of debug response archival
+func processAudioResponse(key string) bool {
+	return strings.Contains(key, "-process_audio") && (strings.HasSuffix(key, "-response.json") || strings.HasSuffix(key, "-200.json"))
+}
+
+// archivedProcessAudioResponse finds the call's latest archived process_audio response: among the
+// debug responses referenced by its stored analysis, then among its call_artifacts
+func (tp *TranscriptionPipeline) archivedProcessAudioResponse(callLogsID string, stored *CallAnalysisData) (*ArtifactRef, error) {
+	if stored != nil && stored.ProcessingMetadata != nil {
+		responses := stored.ProcessingMetadata.ProviderResponses
+		for i := len(responses) - 1; i >= 0; i-- {
+			if processAudioResponse(responses[i].Key) {
+				return &responses[i], nil
+			}
+		}
+	}
+
+	query := fmt.Sprintf(`
+		SELECT bucket, "s3Key"
+		FROM %s
+		WHERE "call_logsId" = $1 AND "artifactType" = $2
+		ORDER BY "createdAt" DESC
+	`, tp.schema.Table("call_artifacts"))
+
+	rows, err := tp.repo.Query(query, callLogsID, ArtifactGeminiResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching archived responses: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ref := ArtifactRef{Type: ArtifactGeminiResponse}
+		if err := rows.Scan(&ref.Bucket, &ref.Key); err != nil {
+			return nil, fmt.Errorf("error scanning archived response row: %v", err)
+		}
+		if processAudioResponse(ref.Key) {
+			return &ref, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating archived responses: %v", err)
+	}
+	return nil, nil
+}
+
+// ReplayCall regenerates a call's analysis from its archived process_audio response instead of
+// downloading and transcribing the recording again, e.g. after a parser fix. The response is parsed
+// with the campaign's current questions, and the rest of processing (validation, enrichment, saving
+// and publishing) runs as for ProcessCall; the transcription cache is left alone.
+func (tp *TranscriptionPipeline) ReplayCall(callLogsID string) (map[string]interface{}, error) {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	storedJSON, err := tp.GetCallAnalysis(callLogsID)
+	var stored *CallAnalysisData
+	if err == nil && storedJSON != nil {
+		stored = &CallAnalysisData{}
+		if jsonErr := json.Unmarshal(storedJSON, stored); jsonErr != nil {
+			stored = nil
+		}
+	}
+	var ref *ArtifactRef
+	if err == nil {
+		ref, err = tp.archivedProcessAudioResponse(callLogsID, stored)
+	}
+	tp.CloseDatabase()
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil {
+		return nil, fmt.Errorf("%w: call %s", ErrNoArchivedResponse, callLogsID)
+	}
+
+	tp.replay = &replaySource{ref: *ref}
+	if stored != nil {
+		tp.replay.storedAnswers = stored.Answers
+	}
+	tp.reprocess = true
+	return tp.ProcessCall(callLogsID)
+}
+
+// replaySource is the archived response a replayed call is parsed from
+type replaySource struct {
+	ref ArtifactRef
+	// storedAnswers are the answers of the analysis being replaced, to check the questions still match
+	storedAnswers map[string]string
+}
+
+// replayTranscription parses the archived response in place of transcribing the recording. The
+// questions are numbered in the prompt, so a response can only be parsed with the questions it
+// answered; stored answers to questions the campaign no longer has mean they have changed. For
+// two-phase campaigns the response holds the audio questions, and the rest are answered from its
+// transcription again.
+func (tp *TranscriptionPipeline) replayTranscription(questions []Question) (*TranscriptionResult, error) {
+	questionIDs := make(map[string]bool, len(questions))
+	for _, q := range questions {
+		questionIDs[q.ID] = true
+	}
+	for id := range tp.replay.storedAnswers {
+		if !questionIDs[id] {
+			return nil, fmt.Errorf("the campaign's questions have changed since the call was processed (question %s is gone); reprocess it instead", id)
+		}
+	}
+
+	body, err := s3GetObject(tp.replay.ref.Bucket, tp.replay.ref.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading s3://%s/%s: %v", tp.replay.ref.Bucket, tp.replay.ref.Key, err)
+	}
+	var geminiResp GeminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return nil, fmt.Errorf("%w: error decoding archived Gemini response: %v", ErrParseFailure, err)
+	}
+	responseText, err := geminiResponseText(geminiResp)
+	if err != nil {
+		return nil, err
+	}
+
+	audioQuestions, textQuestions := tp.phaseQuestions(questions)
+	_, _, ids := buildQuestionsPrompt(audioQuestions, tp.outputLanguage)
+	transcription, answers := tp.parseTranscriptionAndAnswers(responseText, ids)
+	if transcription == "" {
+		return nil, fmt.Errorf("%w: no transcription in the archived response", ErrEmptyResponse)
+	}
+	if len(textQuestions) > 0 {
+		textAnswers, err := tp.AnswerQuestionsFromTranscript(transcription, textQuestions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to answer questions: %w", err)
+		}
+		for id, answer := range textAnswers {
+			answers[id] = answer
+		}
+	}
+
+	tp.metadata.ReplayedFrom = fmt.Sprintf("s3://%s/%s", tp.replay.ref.Bucket, tp.replay.ref.Key)
+	return &TranscriptionResult{Transcription: transcription, Answers: answers, Provider: ProviderGemini}, nil
+}
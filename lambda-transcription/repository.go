@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultQueryTimeout bounds every query unless DB_QUERY_TIMEOUT_SECONDS overrides it
+const defaultQueryTimeout = 30 * time.Second
+
+// pingTimeout bounds the readiness check before each use of the pool, well inside Lambda's 10-second init phase
+const pingTimeout = 5 * time.Second
+
+// queryTimeout reads the per-query timeout (DB_QUERY_TIMEOUT_SECONDS)
+func queryTimeout() time.Duration {
+	if seconds, err := strconv.Atoi(os.Getenv("DB_QUERY_TIMEOUT_SECONDS")); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultQueryTimeout
+}
+
+// preparedStatementsEnabled reports whether queries are prepared and cached (DB_PREPARED_STATEMENTS=false
+// disables it). RDS Proxy pins a client connection to its database connection once it prepares a
+// statement, so with the proxy queries should run unprepared.
+func preparedStatementsEnabled() bool {
+	return os.Getenv("DB_PREPARED_STATEMENTS") != "false"
+}
+
+// Repository is the pipeline's database access layer. Queries are prepared once per connection
+// and reused across warm invocations, unless prepared statements are disabled, and every query
+// runs under a timeout.
+type Repository struct {
+	db       *sql.DB
+	timeout  time.Duration
+	prepared bool
+
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// NewRepository wraps an open database
+func NewRepository(db *sql.DB, timeout time.Duration) *Repository {
+	return &Repository{
+		db:       db,
+		timeout:  timeout,
+		prepared: preparedStatementsEnabled(),
+		stmts:    make(map[string]*sql.Stmt),
+	}
+}
+
+// Ping checks that a connection can be used, opening one if the pool has none
+func (r *Repository) Ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	return r.db.PingContext(ctx)
+}
+
+// Close closes the prepared statements and the database
+func (r *Repository) Close() error {
+	r.mu.Lock()
+	for query, stmt := range r.stmts {
+		stmt.Close()
+		delete(r.stmts, query)
+	}
+	r.mu.Unlock()
+	return r.db.Close()
+}
+
+// prepare returns the prepared statement for the query, preparing it on first use
+func (r *Repository) prepare(ctx context.Context, query string) (*sql.Stmt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stmt, ok := r.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := r.db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	r.stmts[query] = stmt
+	return stmt, nil
+}
+
+// Row is the result of QueryRow; the query's timeout is released once it is scanned
+type Row struct {
+	row    *sql.Row
+	err    error
+	cancel context.CancelFunc
+}
+
+// Scan copies the row's columns into dest, returning sql.ErrNoRows when there was no row
+func (r *Row) Scan(dest ...interface{}) error {
+	defer r.cancel()
+	if r.err != nil {
+		return r.err
+	}
+	return r.row.Scan(dest...)
+}
+
+// Rows is the result of Query; the query's timeout is released when it is closed
+type Rows struct {
+	*sql.Rows
+	cancel context.CancelFunc
+}
+
+// Close closes the rows and releases the query's timeout
+func (r *Rows) Close() error {
+	defer r.cancel()
+	return r.Rows.Close()
+}
+
+// QueryRow runs a query expected to return at most one row
+func (r *Repository) QueryRow(query string, args ...interface{}) *Row {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	if !r.prepared {
+		return &Row{row: r.db.QueryRowContext(ctx, query, args...), cancel: cancel}
+	}
+	stmt, err := r.prepare(ctx, query)
+	if err != nil {
+		return &Row{err: err, cancel: cancel}
+	}
+	return &Row{row: stmt.QueryRowContext(ctx, args...), cancel: cancel}
+}
+
+// Query runs a query returning rows, which the caller must close
+func (r *Repository) Query(query string, args ...interface{}) (*Rows, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	var rows *sql.Rows
+	var err error
+	if r.prepared {
+		var stmt *sql.Stmt
+		if stmt, err = r.prepare(ctx, query); err != nil {
+			cancel()
+			return nil, err
+		}
+		rows, err = stmt.QueryContext(ctx, args...)
+	} else {
+		rows, err = r.db.QueryContext(ctx, query, args...)
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Rows{Rows: rows, cancel: cancel}, nil
+}
+
+// Exec runs a statement that returns no rows
+func (r *Repository) Exec(query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+
+	if !r.prepared {
+		return r.db.ExecContext(ctx, query, args...)
+	}
+	stmt, err := r.prepare(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(ctx, args...)
+}
+
+// Execer runs statements on the pool or inside a transaction, for writes made either way
+type Execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Tx is a transaction whose statements share a single timeout, released on commit or rollback
+type Tx struct {
+	repo   *Repository
+	tx     *sql.Tx
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	afterCommit []func()
+}
+
+// Begin starts a transaction
+func (r *Repository) Begin() (*Tx, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &Tx{repo: r, tx: tx, ctx: ctx, cancel: cancel}, nil
+}
+
+// stmt returns the query's statement bound to the transaction. A query the repository hasn't
+// prepared yet is prepared on the transaction itself: preparing it on the pool would wait for
+// the connection the transaction holds.
+func (t *Tx) stmt(query string) (*sql.Stmt, error) {
+	t.repo.mu.Lock()
+	stmt, ok := t.repo.stmts[query]
+	t.repo.mu.Unlock()
+	if ok {
+		return t.tx.StmtContext(t.ctx, stmt), nil
+	}
+
+	stmt, err := t.tx.PrepareContext(t.ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error preparing query: %w", err)
+	}
+	return stmt, nil
+}
+
+// Exec runs a statement in the transaction
+func (t *Tx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if !t.repo.prepared {
+		return t.tx.ExecContext(t.ctx, query, args...)
+	}
+	stmt, err := t.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.ExecContext(t.ctx, args...)
+}
+
+// QueryRow runs a single-row query in the transaction
+func (t *Tx) QueryRow(query string, args ...interface{}) *Row {
+	// The timeout belongs to the transaction, not the row
+	noop := func() {}
+	if !t.repo.prepared {
+		return &Row{row: t.tx.QueryRowContext(t.ctx, query, args...), cancel: noop}
+	}
+	stmt, err := t.stmt(query)
+	if err != nil {
+		return &Row{err: err, cancel: noop}
+	}
+	return &Row{row: stmt.QueryRowContext(t.ctx, args...), cancel: noop}
+}
+
+// Query runs a query returning rows in the transaction, which the caller must close before running
+// another statement in it
+func (t *Tx) Query(query string, args ...interface{}) (*Rows, error) {
+	if !t.repo.prepared {
+		rows, err := t.tx.QueryContext(t.ctx, query, args...)
+		if err != nil {
+			return nil, err
+		}
+		return &Rows{Rows: rows, cancel: func() {}}, nil
+	}
+	stmt, err := t.stmt(query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(t.ctx, args...)
+	if err != nil {
+		return nil, err
+	}
+	// The timeout belongs to the transaction, not the rows
+	return &Rows{Rows: rows, cancel: func() {}}, nil
+}
+
+// AfterCommit registers fn to run once the transaction has committed, for side effects outside the
+// database that mustn't happen if it rolls back
+func (t *Tx) AfterCommit(fn func()) {
+	t.afterCommit = append(t.afterCommit, fn)
+}
+
+// Commit commits the transaction, then runs the functions registered with AfterCommit in order
+func (t *Tx) Commit() error {
+	err := t.tx.Commit()
+	t.cancel()
+	if err != nil {
+		return err
+	}
+	for _, fn := range t.afterCommit {
+		fn()
+	}
+	return nil
+}
+
+// Rollback aborts the transaction; it is a no-op after Commit
+func (t *Tx) Rollback() error {
+	defer t.cancel()
+	return t.tx.Rollback()
+}
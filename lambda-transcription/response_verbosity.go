@@ -0,0 +1,65 @@
+package main
+
+// Response verbosities, selected by the request's verbosity
+const (
+	// ResponseVerbosityFull returns everything, including the transcription (the default)
+	ResponseVerbosityFull = "full"
+	// ResponseVerbosityAnswers returns the answers without the transcription or enrichments
+	ResponseVerbosityAnswers = "answers"
+	// ResponseVerbositySummary returns only the summary published with analysis events
+	ResponseVerbositySummary = "summary"
+)
+
+// responseOutcomeFields identify the call and its outcome, and are kept at every verbosity
+var responseOutcomeFields = []string{
+	"call_logsId", "messageId", "reference", "campaignId", "processed_at", "skipped", "skip_reason", "call_disposition", "dry_run",
+}
+
+// responseAnswerFields are also kept by the answers verbosity
+var responseAnswerFields = []string{
+	"answers", "enum_answers", "skipped_questions", "provider", "cache_hit",
+}
+
+// validResponseVerbosity reports whether the verbosity is known; empty is full
+func validResponseVerbosity(verbosity string) bool {
+	switch verbosity {
+	case "", ResponseVerbosityFull, ResponseVerbosityAnswers, ResponseVerbositySummary:
+		return true
+	}
+	return false
+}
+
+// SetResponseVerbosity sets how much of the analysis ProcessCall and ProcessVoiceNote return. The
+// saved analysis is always complete.
+func (tp *TranscriptionPipeline) SetResponseVerbosity(verbosity string) {
+	tp.responseVerbosity = verbosity
+}
+
+// shapeResponse trims the processing response to the pipeline's verbosity. analysis is nil for dry
+// runs, whose would-be analysis is summarized instead.
+func (tp *TranscriptionPipeline) shapeResponse(result map[string]interface{}, analysis *CallAnalysisData) map[string]interface{} {
+	if tp.responseVerbosity == "" || tp.responseVerbosity == ResponseVerbosityFull {
+		return result
+	}
+
+	fields := responseOutcomeFields
+	if tp.responseVerbosity == ResponseVerbosityAnswers {
+		fields = append(append([]string(nil), fields...), responseAnswerFields...)
+	}
+	shaped := make(map[string]interface{}, len(fields)+1)
+	for _, field := range fields {
+		if value, ok := result[field]; ok {
+			shaped[field] = value
+		}
+	}
+
+	if tp.responseVerbosity == ResponseVerbositySummary {
+		if dryRunAnalysis, ok := result["analysis"].(CallAnalysisData); ok && analysis == nil {
+			analysis = &dryRunAnalysis
+		}
+		if analysis != nil && analysis.SkipReason == "" {
+			shaped["summary"] = summarizeAnalysis(analysis)
+		}
+	}
+	return shaped
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Result stores selectable via RESULT_STORES
+const (
+	ResultStorePostgres   = "postgres"
+	ResultStoreRelational = "relational"
+	ResultStoreS3         = "s3"
+)
+
+// ArtifactResult is the artifact type of analyses written by the S3 result store
+const ArtifactResult = "result"
+
+// ResultStore persists a call's analysis. Save runs inside the analysis transaction; database
+// writes go through tx so they commit or roll back with the rest of the save.
+type ResultStore interface {
+	Name() string
+	Save(tx *Tx, callLogsID string, analysisData CallAnalysisData) error
+}
+
+// NewResultStores returns the result stores named in a comma-separated list, in order; an empty list
+// is the Postgres JSON column
+func NewResultStores(names string, tp *TranscriptionPipeline) ([]ResultStore, error) {
+	if strings.TrimSpace(names) == "" {
+		names = ResultStorePostgres
+	}
+
+	var stores []ResultStore
+	for _, name := range strings.Split(names, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case ResultStorePostgres:
+			stores = append(stores, &PostgresResultStore{pipeline: tp})
+		case ResultStoreRelational:
+			stores = append(stores, &RelationalResultStore{pipeline: tp})
+		case ResultStoreS3:
+			store, err := NewS3ResultStore(tp)
+			if err != nil {
+				return nil, err
+			}
+			stores = append(stores, store)
+		default:
+			return nil, fmt.Errorf("unknown result store: %s", name)
+		}
+	}
+	return stores, nil
+}
+
+// PostgresResultStore stores the analysis as JSON in call_logs.callAnalysis, which everything that
+// reads analyses back relies on: duplicate detection, GET /analysis, diffs, search and aggregates
+type PostgresResultStore struct {
+	pipeline *TranscriptionPipeline
+}
+
+// Name returns the store name
+func (s *PostgresResultStore) Name() string {
+	return ResultStorePostgres
+}
+
+// Save writes the callAnalysis column and records it as the call's next version, together with the
+// search index, call_answers and answer aggregates
+func (s *PostgresResultStore) Save(tx *Tx, callLogsID string, analysisData CallAnalysisData) error {
+	tp := s.pipeline
+
+	// Convert to JSON
+	analysisJSON, err := json.Marshal(analysisData)
+	if err != nil {
+		return fmt.Errorf("error marshaling analysis data: %v", err)
+	}
+
+	// Update only the callAnalysis column for the specific ID
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET %s = $1
+		WHERE %s = $2
+	`, tp.schema.Table("call_logs"), tp.schema.Column("call_logs", "callAnalysis"), tp.schema.Column("call_logs", "id"))
+
+	_, err = tx.Exec(updateQuery, string(analysisJSON), callLogsID)
+	if err != nil {
+		return fmt.Errorf("error updating callAnalysis: %v", err)
+	}
+
+	// Keep the full-text search index in step with the stored transcription
+	if err := tp.indexTranscription(tx, callLogsID, analysisData.Transcription); err != nil {
+		return err
+	}
+
+	// Keep the relational copy of the answers in step with the stored answers
+	if err := tp.replaceCallAnswers(tx, callLogsID, analysisData.Answers); err != nil {
+		return err
+	}
+
+	// Keep the campaign answer aggregates in step with the stored answers
+	if err := tp.replaceAnswerFacts(tx, callLogsID, answerFacts(analysisData)); err != nil {
+		return err
+	}
+
+	// Keep every version so reprocessing with new prompts can be compared with earlier runs
+	versionQuery := fmt.Sprintf(`
+		INSERT INTO %[1]s ("call_logsId", version, analysis, "promptVariant", "createdAt")
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2::jsonb, NULLIF($3::text, ''), now()
+		FROM %[1]s
+		WHERE "call_logsId" = $1
+	`, tp.schema.Table("call_analysis_versions"))
+	if _, err := tx.Exec(versionQuery, callLogsID, string(analysisJSON), analysisData.PromptVariant); err != nil {
+		return fmt.Errorf("error saving analysis version: %v", err)
+	}
+
+	return nil
+}
+
+// RelationalResultStore stores the analysis in analysis_results, one row per call, and its answers
+// in analysis_result_answers, one row per question. Nested sections such as compliance and QA
+// scoring are only kept by the JSON stores.
+type RelationalResultStore struct {
+	pipeline *TranscriptionPipeline
+}
+
+// Name returns the store name
+func (s *RelationalResultStore) Name() string {
+	return ResultStoreRelational
+}
+
+// Save replaces the call's result row and answers
+func (s *RelationalResultStore) Save(tx *Tx, callLogsID string, analysisData CallAnalysisData) error {
+	tp := s.pipeline
+	resultsTable := tp.schema.Table("analysis_results")
+	answersTable := tp.schema.Table("analysis_result_answers")
+
+	resultQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", transcription, provider, "callDisposition", "skipReason",
+		                "dispositionReason", "promptVariant", "cacheHit", "processedAt", "updatedAt")
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), NULLIF($7, ''), $8, $9::timestamptz, now())
+		ON CONFLICT ("call_logsId") DO UPDATE SET
+			transcription = EXCLUDED.transcription, provider = EXCLUDED.provider,
+			"callDisposition" = EXCLUDED."callDisposition", "skipReason" = EXCLUDED."skipReason",
+			"dispositionReason" = EXCLUDED."dispositionReason", "promptVariant" = EXCLUDED."promptVariant",
+			"cacheHit" = EXCLUDED."cacheHit", "processedAt" = EXCLUDED."processedAt", "updatedAt" = now()
+	`, resultsTable)
+	if _, err := tx.Exec(resultQuery, callLogsID, analysisData.Transcription, analysisData.Provider,
+		analysisData.CallDisposition, analysisData.SkipReason, analysisData.DispositionReason,
+		analysisData.PromptVariant, analysisData.CacheHit, analysisData.ProcessedAt); err != nil {
+		return fmt.Errorf("error saving analysis result: %v", err)
+	}
+
+	if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, answersTable), callLogsID); err != nil {
+		return fmt.Errorf("error clearing result answers: %v", err)
+	}
+	answerQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "questionId", answer, skipped)
+		VALUES ($1, $2, $3, $4)
+	`, answersTable)
+	for questionID, answer := range analysisData.Answers {
+		if _, err := tx.Exec(answerQuery, callLogsID, questionID, answer, false); err != nil {
+			return fmt.Errorf("error saving result answer: %v", err)
+		}
+	}
+	for questionID, reason := range analysisData.SkippedQuestions {
+		if _, ok := analysisData.Answers[questionID]; ok {
+			continue
+		}
+		if _, err := tx.Exec(answerQuery, callLogsID, questionID, reason, true); err != nil {
+			return fmt.Errorf("error saving result answer: %v", err)
+		}
+	}
+	return nil
+}
+
+// S3ResultStore writes each analysis to S3 as a single-line JSON object, partitioned by processing
+// date for Athena:
+//
+//	{prefix}/dt=YYYY-MM-DD/{call_logsId}-{timestamp}.json
+type S3ResultStore struct {
+	pipeline *TranscriptionPipeline
+	bucket   string
+	prefix   string
+}
+
+// s3ResultRecord is the object written for each analysis
+type s3ResultRecord struct {
+	CallLogsID string `json:"call_logsId"`
+	CallAnalysisData
+}
+
+// NewS3ResultStore configures the S3 store from RESULT_STORE_S3_BUCKET and RESULT_STORE_S3_PREFIX
+func NewS3ResultStore(tp *TranscriptionPipeline) (*S3ResultStore, error) {
+	bucket := os.Getenv("RESULT_STORE_S3_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("RESULT_STORE_S3_BUCKET is required for the s3 result store")
+	}
+	prefix := strings.Trim(os.Getenv("RESULT_STORE_S3_PREFIX"), "/")
+	if prefix == "" {
+		prefix = "analysis-results"
+	}
+	return &S3ResultStore{pipeline: tp, bucket: bucket, prefix: prefix}, nil
+}
+
+// Name returns the store name
+func (s *S3ResultStore) Name() string {
+	return ResultStoreS3
+}
+
+// Save records the object's key in call_artifacts as pending, so data retention deletes it with the
+// call's other S3 objects, and uploads it once the transaction commits: Athena never sees an
+// analysis that was rolled back. Each save is a new object; queries pick the latest processed_at
+// per call.
+func (s *S3ResultStore) Save(tx *Tx, callLogsID string, analysisData CallAnalysisData) error {
+	tp := s.pipeline
+
+	body, err := json.Marshal(s3ResultRecord{CallLogsID: callLogsID, CallAnalysisData: analysisData})
+	if err != nil {
+		return fmt.Errorf("error marshaling analysis data: %v", err)
+	}
+	body = append(body, '\n')
+
+	now := time.Now().UTC()
+	key := fmt.Sprintf("%s/dt=%s/%s-%s.json", s.prefix, now.Format("2006-01-02"), callLogsID, now.Format("20060102T150405Z"))
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "artifactType", bucket, "s3Key", "createdAt")
+		VALUES ($1, $2, $3, $4, now())
+		RETURNING id
+	`, tp.schema.Table("call_artifacts"))
+	var refID int64
+	if err := tx.QueryRow(query, callLogsID, ArtifactResult, s.bucket, key).Scan(&refID); err != nil {
+		return fmt.Errorf("error saving artifact key: %v", err)
+	}
+
+	tx.AfterCommit(func() { s.upload(callLogsID, refID, key, body) })
+	return nil
+}
+
+// upload writes a committed analysis to S3 and marks its call_artifacts row uploaded. The analysis
+// is already saved, so a failure is logged and reported rather than failing the call; the row is
+// left with a NULL "uploadedAt" to find the objects that are missing.
+func (s *S3ResultStore) upload(callLogsID string, refID int64, key string, body []byte) {
+	tp := s.pipeline
+
+	if err := s3PutObject(s.bucket, key, "application/json", body); err != nil {
+		err = fmt.Errorf("error uploading %s for %s to the s3 result store: %v", key, callLogsID, err)
+		log.Print(err)
+		tp.reportError(err)
+		return
+	}
+
+	query := fmt.Sprintf(`UPDATE %s SET "uploadedAt" = now() WHERE id = $1`, tp.schema.Table("call_artifacts"))
+	if _, err := tp.repo.Exec(query, refID); err != nil {
+		log.Printf("Error marking %s uploaded: %v", key, err)
+	}
+}
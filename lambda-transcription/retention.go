@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// Retention modes
+const (
+	// RetentionAnonymize removes the call's transcript text, quotes and artifacts but keeps its
+	// answers, scores and metrics
+	RetentionAnonymize = "anonymize"
+	// RetentionPurge removes everything derived from the call, leaving a stub analysis so the call
+	// isn't picked up for processing again
+	RetentionPurge = "purge"
+)
+
+// defaultRetentionBatchSize is how many calls a retention run handles when RETENTION_BATCH_SIZE is not set
+const defaultRetentionBatchSize = 500
+
+// anonymizedAnalysisKeys are the callAnalysis keys holding transcript text or quotes from it.
+// Sections that add transcript-derived fields must be added here or to anonymizedAnalysisFields.
+var anonymizedAnalysisKeys = []string{"translated_transcription", "words", "entities", "follow_ups", "abuse_check"}
+
+// anonymizedAnalysisFields are the quotes from the transcript and the caller's numbers inside
+// callAnalysis sections that are otherwise kept, as paths from the top-level key. Arrays on the way
+// are walked element by element, and "*" stands for every entry of an object.
+var anonymizedAnalysisFields = [][]string{
+	{"compliance", "timed_checks", "quote"},
+	{"compliance", "prohibited_hits", "matches"},
+	{"qa_scorecard", "criteria", "evidence"},
+	{"grounding", "evidence", "*", "quote"},
+	{"phone_numbers", "caller", "e164"},
+	{"phone_numbers", "call_to", "e164"},
+}
+
+// anonymizeAnalysis blanks the transcription of a stored analysis and removes the
+// anonymizedAnalysisKeys and anonymizedAnalysisFields, keeping answers, scores and metrics
+func anonymizeAnalysis(analysisJSON []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(analysisJSON))
+	decoder.UseNumber()
+	var analysis map[string]interface{}
+	if err := decoder.Decode(&analysis); err != nil {
+		return nil, fmt.Errorf("error parsing analysis: %v", err)
+	}
+
+	for _, key := range anonymizedAnalysisKeys {
+		delete(analysis, key)
+	}
+	analysis["transcription"] = ""
+	for _, path := range anonymizedAnalysisFields {
+		removeAnalysisField(analysis[path[0]], path[1:])
+	}
+	return json.Marshal(analysis)
+}
+
+// removeAnalysisField deletes the field at path inside value
+func removeAnalysisField(value interface{}, path []string) {
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			removeAnalysisField(item, path)
+		}
+	case map[string]interface{}:
+		switch {
+		case len(path) == 1:
+			delete(v, path[0])
+		case path[0] == "*":
+			for _, item := range v {
+				removeAnalysisField(item, path[1:])
+			}
+		default:
+			removeAnalysisField(v[path[0]], path[1:])
+		}
+	}
+}
+
+// anonymizeAnalysisVersions anonymizes every stored version of the call's analysis
+func (tp *TranscriptionPipeline) anonymizeAnalysisVersions(tx *Tx, callLogsID string) (int64, error) {
+	versionsTable := tp.schema.Table("call_analysis_versions")
+	rows, err := tx.Query(fmt.Sprintf(`SELECT version, analysis::text FROM %s WHERE "call_logsId" = $1 FOR UPDATE`, versionsTable), callLogsID)
+	if err != nil {
+		return 0, fmt.Errorf("error reading analysis versions: %v", err)
+	}
+	analyses := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var analysis string
+		if err := rows.Scan(&version, &analysis); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning analysis version: %v", err)
+		}
+		analyses[version] = analysis
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading analysis versions: %v", err)
+	}
+
+	updateQuery := fmt.Sprintf(`UPDATE %s SET analysis = $3::jsonb WHERE "call_logsId" = $1 AND version = $2`, versionsTable)
+	for version, analysis := range analyses {
+		anonymized, err := anonymizeAnalysis([]byte(analysis))
+		if err != nil {
+			return 0, fmt.Errorf("version %d: %v", version, err)
+		}
+		if _, err := tx.Exec(updateQuery, callLogsID, version, string(anonymized)); err != nil {
+			return 0, fmt.Errorf("error anonymizing analysis version %d: %v", version, err)
+		}
+	}
+	return int64(len(analyses)), nil
+}
+
+// RetentionPolicy is how long a campaign's transcripts are kept, from the call's start date
+type RetentionPolicy struct {
+	// Days calls are kept; 0 keeps them forever
+	Days int `json:"days"`
+	// Mode is "anonymize" (default) or "purge"
+	Mode string `json:"mode,omitempty"`
+}
+
+// RetentionReport summarizes a retention run
+type RetentionReport struct {
+	DryRun     bool                    `json:"dryRun,omitempty"`
+	Anonymized int                     `json:"anonymized"`
+	Purged     int                     `json:"purged"`
+	Failed     int                     `json:"failed"`
+	Policies   []RetentionPolicyReport `json:"policies"`
+}
+
+// RetentionPolicyReport is the outcome of one policy in a retention run; the default policy has no campaignId
+type RetentionPolicyReport struct {
+	CampaignID string   `json:"campaignId,omitempty"`
+	Days       int      `json:"days"`
+	Mode       string   `json:"mode"`
+	Calls      []string `json:"calls"`
+	Failed     int      `json:"failed,omitempty"`
+}
+
+// retentionCall is a call whose transcripts have expired
+type retentionCall struct {
+	ID           string
+	CampaignID   string
+	RecordingURL string
+}
+
+// defaultRetentionPolicy reads the policy for campaigns without a retention setting (RETENTION_DAYS,
+// RETENTION_MODE); Days is 0 when RETENTION_DAYS is not set
+func defaultRetentionPolicy() RetentionPolicy {
+	policy := RetentionPolicy{Mode: os.Getenv("RETENTION_MODE")}
+	if days, err := strconv.Atoi(os.Getenv("RETENTION_DAYS")); err == nil && days > 0 {
+		policy.Days = days
+	}
+	return policy
+}
+
+// retentionBatchSize reads how many calls a retention run handles (RETENTION_BATCH_SIZE)
+func retentionBatchSize() int {
+	if v, err := strconv.Atoi(os.Getenv("RETENTION_BATCH_SIZE")); err == nil && v > 0 {
+		return v
+	}
+	return defaultRetentionBatchSize
+}
+
+// normalize fills in the default mode and checks it
+func (p *RetentionPolicy) normalize() error {
+	if p.Mode == "" {
+		p.Mode = RetentionAnonymize
+	}
+	if p.Mode != RetentionAnonymize && p.Mode != RetentionPurge {
+		return fmt.Errorf("invalid retention mode %q (expected %q or %q)", p.Mode, RetentionAnonymize, RetentionPurge)
+	}
+	return nil
+}
+
+// listRetentionPolicies returns the retention settings of the campaigns that have one, keyed by campaign ID
+func (tp *TranscriptionPipeline) listRetentionPolicies() (map[string]RetentionPolicy, error) {
+	query := fmt.Sprintf(`
+		SELECT "campaignId"::text, settings->'retention'
+		FROM %s
+		WHERE settings->'retention' IS NOT NULL
+		ORDER BY "campaignId"
+	`, tp.schema.Table("campaign_settings"))
+
+	rows, err := tp.repo.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error querying retention settings: %v", err)
+	}
+	defer rows.Close()
+
+	policies := make(map[string]RetentionPolicy)
+	for rows.Next() {
+		var campaignID string
+		var policyJSON []byte
+		if err := rows.Scan(&campaignID, &policyJSON); err != nil {
+			return nil, fmt.Errorf("error scanning retention settings: %v", err)
+		}
+		var policy RetentionPolicy
+		if err := json.Unmarshal(policyJSON, &policy); err != nil {
+			return nil, fmt.Errorf("error parsing retention settings of campaign %s: %v", campaignID, err)
+		}
+		if err := policy.normalize(); err != nil {
+			return nil, fmt.Errorf("campaign %s: %v", campaignID, err)
+		}
+		policies[campaignID] = policy
+	}
+
+	return policies, rows.Err()
+}
+
+// listExpiredCalls returns up to limit analysed calls older than the policy's days that the policy
+// hasn't been applied to yet, oldest first. An empty campaignID lists the calls of campaigns without
+// a retention setting.
+func (tp *TranscriptionPipeline) listExpiredCalls(policy RetentionPolicy, campaignID string, limit int) ([]retentionCall, error) {
+	c := func(name string) string { return "cl." + tp.schema.Column("call_logs", name) }
+
+	campaignFilter := fmt.Sprintf(`%s::text = $3`, c("campaignId"))
+	if campaignID == "" {
+		campaignFilter = fmt.Sprintf(`$3 = '' AND NOT EXISTS (
+			SELECT 1 FROM %s s
+			WHERE s."campaignId"::text = %s::text AND s.settings->'retention' IS NOT NULL
+		)`, tp.schema.Table("campaign_settings"), c("campaignId"))
+	}
+
+	// A purged call is never anonymized afterwards
+	query := fmt.Sprintf(`
+		SELECT %s, COALESCE(%s::text, ''), COALESCE(%s, '')
+		FROM %s cl
+		WHERE %s IS NOT NULL
+		  AND %s::date < current_date - $1::int
+		  AND COALESCE(%s->'retention'->>'mode', '') NOT IN ($2, '%s')
+		  AND %s
+		ORDER BY %s
+		LIMIT %d
+	`, c("id"), c("campaignId"), c("recording_url"), tp.schema.Table("call_logs"),
+		c("callAnalysis"), c("start_date"), c("callAnalysis"), RetentionPurge, campaignFilter,
+		c("start_date"), limit)
+
+	rows, err := tp.repo.Query(query, policy.Days, policy.Mode, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing expired calls: %v", err)
+	}
+	defer rows.Close()
+
+	var calls []retentionCall
+	for rows.Next() {
+		var call retentionCall
+		if err := rows.Scan(&call.ID, &call.CampaignID, &call.RecordingURL); err != nil {
+			return nil, fmt.Errorf("error scanning expired call: %v", err)
+		}
+		calls = append(calls, call)
+	}
+
+	return calls, rows.Err()
+}
+
+// deleteCallArtifacts deletes the call's archived S3 artifacts and their rows in call_artifacts,
+// returning how many objects were deleted
+func (tp *TranscriptionPipeline) deleteCallArtifacts(callLogsID string) (int, error) {
+	query := fmt.Sprintf(`SELECT id, bucket, "s3Key" FROM %s WHERE "call_logsId" = $1`, tp.schema.Table("call_artifacts"))
+	rows, err := tp.repo.Query(query, callLogsID)
+	if err != nil {
+		return 0, fmt.Errorf("error listing artifacts: %v", err)
+	}
+
+	type artifact struct {
+		id          int64
+		bucket, key string
+	}
+	var artifacts []artifact
+	for rows.Next() {
+		var a artifact
+		if err := rows.Scan(&a.id, &a.bucket, &a.key); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("error scanning artifact: %v", err)
+		}
+		artifacts = append(artifacts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error reading artifacts: %v", err)
+	}
+
+	// Rows are removed one by one so a failed deletion leaves the remaining keys for the next run
+	deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE id = $1`, tp.schema.Table("call_artifacts"))
+	for i, a := range artifacts {
+		if err := s3DeleteObject(a.bucket, a.key); err != nil {
+			return i, fmt.Errorf("error deleting %s: %v", a.key, err)
+		}
+		if _, err := tp.repo.Exec(deleteQuery, a.id); err != nil {
+			return i + 1, fmt.Errorf("error removing artifact key: %v", err)
+		}
+	}
+
+	return len(artifacts), nil
+}
+
+// applyRetention anonymizes or purges one call and records a tombstone of what was removed
+func (tp *TranscriptionPipeline) applyRetention(call retentionCall, policy RetentionPolicy) error {
+	removed := make(map[string]int64)
+
+	objects, err := tp.deleteCallArtifacts(call.ID)
+	if objects > 0 {
+		removed["s3Objects"] = int64(objects)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := tp.repo.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting retention transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	exec := func(name, query string, args ...interface{}) error {
+		result, err := tx.Exec(query, args...)
+		if err != nil {
+			return fmt.Errorf("error applying retention to %s: %v", name, err)
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			removed[name] = n
+		}
+		return nil
+	}
+	deleteRows := func(table string) error {
+		return exec(table, fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, tp.schema.Table(table)), call.ID)
+	}
+
+	// Derived copies of the transcript are removed in either mode
+	for _, table := range []string{"call_subtitles", "call_embeddings", "call_transcript_search"} {
+		if err := deleteRows(table); err != nil {
+			return err
+		}
+	}
+	if call.RecordingURL != "" {
+		cacheQuery := fmt.Sprintf(`DELETE FROM %s WHERE "urlHash" = $1`, tp.schema.Table("transcription_cache"))
+		if err := exec("transcription_cache", cacheQuery, sha256Hex([]byte(call.RecordingURL))); err != nil {
+			return err
+		}
+	}
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	marker := `jsonb_build_object('retention', jsonb_build_object('mode', $2::text, 'appliedAt', now()))`
+
+	if policy.Mode == RetentionPurge {
+		for _, table := range []string{"call_analysis_versions", "call_outcomes", "call_followups",
+			"prompt_variant_results", "analysis_review_corrections", "analysis_reviews",
+			"analysis_result_answers", "analysis_results", "call_answers"} {
+			if err := deleteRows(table); err != nil {
+				return err
+			}
+		}
+		if err := tp.replaceAnswerFacts(tx, call.ID, nil); err != nil {
+			return err
+		}
+		analysisQuery := fmt.Sprintf(`
+			UPDATE %s
+			SET %s = jsonb_build_object('transcription', '', 'answers', '{}'::jsonb, 'processed_at', %s->'processed_at') || %s
+			WHERE %s = $1
+		`, tp.schema.Table("call_logs"), c("callAnalysis"), c("callAnalysis"), marker, c("id"))
+		if err := exec("callAnalysis", analysisQuery, call.ID, policy.Mode); err != nil {
+			return err
+		}
+	} else {
+		versions, err := tp.anonymizeAnalysisVersions(tx, call.ID)
+		if err != nil {
+			return fmt.Errorf("error applying retention to call_analysis_versions: %v", err)
+		}
+		if versions > 0 {
+			removed["call_analysis_versions"] = versions
+		}
+		resultsQuery := fmt.Sprintf(`UPDATE %s SET transcription = '' WHERE "call_logsId" = $1 AND transcription <> ''`, tp.schema.Table("analysis_results"))
+		if err := exec("analysis_results", resultsQuery, call.ID); err != nil {
+			return err
+		}
+		quotesQuery := fmt.Sprintf(`UPDATE %s SET quote = '' WHERE "call_logsId" = $1 AND quote <> ''`, tp.schema.Table("call_followups"))
+		if err := exec("call_followups", quotesQuery, call.ID); err != nil {
+			return err
+		}
+		var analysis []byte
+		analysisRead := fmt.Sprintf(`SELECT %s::text FROM %s WHERE %s = $1 FOR UPDATE`, c("callAnalysis"), tp.schema.Table("call_logs"), c("id"))
+		if err := tx.QueryRow(analysisRead, call.ID).Scan(&analysis); err != nil {
+			return fmt.Errorf("error reading callAnalysis: %v", err)
+		}
+		anonymized, err := anonymizeAnalysis(analysis)
+		if err != nil {
+			return fmt.Errorf("error anonymizing callAnalysis: %v", err)
+		}
+		analysisQuery := fmt.Sprintf(`
+			UPDATE %s
+			SET %s = $3::jsonb || %s
+			WHERE %s = $1
+		`, tp.schema.Table("call_logs"), c("callAnalysis"), marker, c("id"))
+		if err := exec("callAnalysis", analysisQuery, call.ID, policy.Mode, string(anonymized)); err != nil {
+			return err
+		}
+	}
+
+	removedJSON, err := json.Marshal(removed)
+	if err != nil {
+		return fmt.Errorf("error marshaling tombstone: %v", err)
+	}
+	tombstoneQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", mode, "retentionDays", removed, "deletedAt")
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4, $5, now())
+	`, tp.schema.Table("call_retention_tombstones"))
+	if _, err := tx.Exec(tombstoneQuery, call.ID, call.CampaignID, policy.Mode, policy.Days, string(removedJSON)); err != nil {
+		return fmt.Errorf("error saving retention tombstone: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing retention: %v", err)
+	}
+	return nil
+}
+
+// RunRetention applies each campaign's retention setting, and the RETENTION_DAYS default to campaigns
+// without one, to at most RETENTION_BATCH_SIZE calls. With dryRun set it only lists the expired calls.
+func (tp *TranscriptionPipeline) RunRetention(dryRun bool) (*RetentionReport, error) {
+	policies, err := tp.listRetentionPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	type scopedPolicy struct {
+		campaignID string
+		policy     RetentionPolicy
+	}
+	var scoped []scopedPolicy
+	for campaignID, policy := range policies {
+		scoped = append(scoped, scopedPolicy{campaignID, policy})
+	}
+	sort.Slice(scoped, func(i, j int) bool { return scoped[i].campaignID < scoped[j].campaignID })
+	if policy := defaultRetentionPolicy(); policy.Days > 0 {
+		if err := policy.normalize(); err != nil {
+			return nil, fmt.Errorf("RETENTION_MODE: %v", err)
+		}
+		scoped = append(scoped, scopedPolicy{"", policy})
+	}
+
+	report := &RetentionReport{DryRun: dryRun, Policies: []RetentionPolicyReport{}}
+	remaining := retentionBatchSize()
+	for _, s := range scoped {
+		if s.policy.Days <= 0 || remaining == 0 {
+			continue
+		}
+
+		calls, err := tp.listExpiredCalls(s.policy, s.campaignID, remaining)
+		if err != nil {
+			return report, err
+		}
+		remaining -= len(calls)
+
+		policyReport := RetentionPolicyReport{CampaignID: s.campaignID, Days: s.policy.Days, Mode: s.policy.Mode, Calls: []string{}}
+		for _, call := range calls {
+			if !dryRun {
+				if err := tp.applyRetention(call, s.policy); err != nil {
+					log.Printf("Error applying retention to %s: %v", call.ID, err)
+					policyReport.Failed++
+					report.Failed++
+					continue
+				}
+				if s.policy.Mode == RetentionPurge {
+					report.Purged++
+				} else {
+					report.Anonymized++
+				}
+			}
+			policyReport.Calls = append(policyReport.Calls, call.ID)
+		}
+		report.Policies = append(report.Policies, policyReport)
+	}
+
+	return report, nil
+}
+
+// HandleRetention runs the retention job; the pipeline's dry-run setting only lists the expired calls
+func (tp *TranscriptionPipeline) HandleRetention() LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	report, err := tp.RunRetention(tp.dryRun)
+	if err != nil {
+		return LambdaResponse{StatusCode: 500, Body: report, Error: err.Error()}
+	}
+	if report.Failed > 0 {
+		return LambdaResponse{StatusCode: 500, Body: report, Error: fmt.Sprintf("retention failed for %d calls", report.Failed)}
+	}
+
+	return LambdaResponse{StatusCode: 200, Body: report}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// ReviewSettings selects which of a campaign's analyses are flagged for human review
+type ReviewSettings struct {
+	// QAScoreBelow flags calls whose QA composite score is below it (0 disables)
+	QAScoreBelow float64 `json:"qaScoreBelow,omitempty"`
+	// ComplianceFailures flags calls that missed a mandatory disclosure or hit a prohibited phrase
+	ComplianceFailures bool `json:"complianceFailures,omitempty"`
+	// AnswerProblems flags calls with unanswered questions, invalid enum answers or unconvertible outcome values
+	AnswerProblems bool `json:"answerProblems,omitempty"`
+	// SamplePercent flags a stable random sample of the campaign's calls for spot checks
+	SamplePercent int `json:"samplePercent,omitempty"`
+	// PoorAudio flags calls whose recording scored below AUDIO_QUALITY_MIN_SCORE
+	PoorAudio bool `json:"poorAudio,omitempty"`
+}
+
+// reviewReasons returns why an analysis should be reviewed, or nil when it shouldn't
+func reviewReasons(settings *ReviewSettings, callLogsID string, questions []Question, analysis *CallAnalysisData) []string {
+	var reasons []string
+
+	if settings.QAScoreBelow > 0 && analysis.QAScorecard != nil && analysis.QAScorecard.Error == "" &&
+		len(analysis.QAScorecard.Criteria) > 0 && analysis.QAScorecard.CompositeScore < settings.QAScoreBelow {
+		reasons = append(reasons, fmt.Sprintf("qa score %.1f below %.1f", analysis.QAScorecard.CompositeScore, settings.QAScoreBelow))
+	}
+
+	if settings.ComplianceFailures && analysis.Compliance != nil && analysis.Compliance.RulesChecked > 0 && !analysis.Compliance.Passed {
+		reasons = append(reasons, "compliance check failed")
+	}
+
+	if settings.AnswerProblems {
+		for _, q := range questions {
+			if _, skipped := analysis.SkippedQuestions[q.ID]; skipped {
+				continue
+			}
+			if enumAnswer, ok := analysis.EnumAnswers[q.ID]; ok && !enumAnswer.Valid {
+				reasons = append(reasons, fmt.Sprintf("invalid answer to question %s", q.ID))
+			} else if _, answered := analysis.Answers[q.ID]; !answered {
+				reasons = append(reasons, fmt.Sprintf("no answer to question %s", q.ID))
+			}
+		}
+		fields := make([]string, 0, len(analysis.OutcomeErrors))
+		for field := range analysis.OutcomeErrors {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			reasons = append(reasons, fmt.Sprintf("unconvertible outcome %s", field))
+		}
+	}
+
+	if settings.PoorAudio && analysis.AudioQuality != nil && analysis.AudioQuality.BelowThreshold {
+		reasons = append(reasons, fmt.Sprintf("audio quality score %d", analysis.AudioQuality.Score))
+	}
+
+	if settings.SamplePercent > 0 {
+		h := fnv.New32a()
+		h.Write([]byte("review:" + callLogsID))
+		if int(h.Sum32()%100) < settings.SamplePercent {
+			reasons = append(reasons, "random sample")
+		}
+	}
+
+	return reasons
+}
+
+// FlagForReview adds the call to the review queue in the analysis transaction. Reprocessing a call
+// reopens its review.
+func (tp *TranscriptionPipeline) FlagForReview(tx *Tx, callLogsID, campaignID string, reasons []string) error {
+	reasonsJSON, err := json.Marshal(reasons)
+	if err != nil {
+		return fmt.Errorf("error marshaling review reasons: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", status, reasons, "createdAt", "updatedAt")
+		VALUES ($1, $2, 'pending', $3, now(), now())
+		ON CONFLICT ("call_logsId")
+		DO UPDATE SET "campaignId" = EXCLUDED."campaignId", status = 'pending', reasons = EXCLUDED.reasons,
+		              "claimedBy" = NULL, "claimedAt" = NULL, "reviewedBy" = NULL, "reviewedAt" = NULL,
+		              "updatedAt" = now()
+	`, tp.schema.Table("analysis_reviews"))
+
+	if _, err := tx.Exec(query, callLogsID, campaignID, string(reasonsJSON)); err != nil {
+		return fmt.Errorf("error flagging call for review: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// defaultRubricMaxScore is used when a criterion doesn't define its own maximum score
+const defaultRubricMaxScore = 10
+
+// RubricCriterion represents a weighted QA scoring criterion defined for a campaign
+type RubricCriterion struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Weight      float64 `json:"weight"`
+	MaxScore    int     `json:"max_score"`
+}
+
+// CriterionScore represents the model's score for a single rubric criterion. Unscored marks a
+// criterion the model left out of its response, which doesn't count towards the composite score.
+type CriterionScore struct {
+	CriterionID string   `json:"criterion_id"`
+	Name        string   `json:"name"`
+	Weight      float64  `json:"weight"`
+	Score       float64  `json:"score"`
+	MaxScore    int      `json:"max_score"`
+	Evidence    []string `json:"evidence"`
+	Rationale   string   `json:"rationale,omitempty"`
+	Unscored    bool     `json:"unscored,omitempty"`
+}
+
+// QAScorecard represents the agent QA scorecard stored in the call analysis
+type QAScorecard struct {
+	CompositeScore float64          `json:"composite_score"`
+	Criteria       []CriterionScore `json:"criteria"`
+	Error          string           `json:"error,omitempty"`
+}
+
+// rubricModelScore is the per-criterion shape the model is asked to return
+type rubricModelScore struct {
+	Criterion int      `json:"criterion"`
+	Score     float64  `json:"score"`
+	Evidence  []string `json:"evidence"`
+	Rationale string   `json:"rationale"`
+}
+
+// GetRubricForCampaign retrieves the active QA scoring criteria for the campaign
+func (tp *TranscriptionPipeline) GetRubricForCampaign(campaignID string) ([]RubricCriterion, error) {
+	query := fmt.Sprintf(`
+		SELECT id, name, description, weight, "maxScore"
+		FROM %s
+		WHERE "isActive" = true AND "campaignId" = $1
+		ORDER BY "sortOrder", id
+	`, tp.schema.Table("campaign_rubric_criterion"))
+
+	rows, err := tp.repo.Query(query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rubric for campaign: %v", err)
+	}
+	defer rows.Close()
+
+	var criteria []RubricCriterion
+	for rows.Next() {
+		var c RubricCriterion
+		if err := rows.Scan(&c.ID, &c.Name, &c.Description, &c.Weight, &c.MaxScore); err != nil {
+			return nil, fmt.Errorf("error scanning rubric criterion row: %v", err)
+		}
+		if c.MaxScore <= 0 {
+			c.MaxScore = defaultRubricMaxScore
+		}
+		criteria = append(criteria, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rubric criteria: %v", err)
+	}
+
+	return criteria, nil
+}
+
+// ScoreCallWithRubric asks the model to score the agent against each criterion, citing evidence
+// quotes from the transcription, and computes the weighted composite score (0-100).
+func (tp *TranscriptionPipeline) ScoreCallWithRubric(transcription string, criteria []RubricCriterion) (*QAScorecard, error) {
+	if len(criteria) == 0 {
+		return nil, nil
+	}
+
+	var criteriaText strings.Builder
+	for i, c := range criteria {
+		fmt.Fprintf(&criteriaText, "%d. %s (score 0-%d): %s\n", i+1, c.Name, c.MaxScore, c.Description)
+	}
+
+	prompt := fmt.Sprintf(`
+You are a call-center quality analyst. Score the agent's performance in the following call transcription against each criterion.
+
+CRITERIA:
+%s
+TRANSCRIPTION:
+%s
+
+For every criterion give a score within its range, one or more short verbatim quotes from the transcription as evidence, and a one-sentence rationale.
+If there is no evidence for a criterion, score it 0 and leave evidence empty.
+%s
+Respond with a JSON array only, in this format:
+[{"criterion": 1, "score": 8, "evidence": ["quote from the call"], "rationale": "why this score"}]
+`, criteriaText.String(), transcription, rationaleLanguage(tp.outputLanguage))
+
+	responseText, err := tp.GenerateText(prompt, true)
+	if err != nil {
+		return nil, fmt.Errorf("error scoring call: %v", err)
+	}
+
+	var modelScores []rubricModelScore
+	if err := json.Unmarshal([]byte(extractJSON(responseText)), &modelScores); err != nil {
+		return nil, fmt.Errorf("error parsing rubric scores: %v", err)
+	}
+
+	return buildScorecard(criteria, modelScores), nil
+}
+
+// buildScorecard matches the model's scores to the criteria and computes the weighted composite score
+// over the criteria the model scored. Criteria it left out are marked unscored rather than counted as
+// 0; when it scored none, the scorecard records an error.
+func buildScorecard(criteria []RubricCriterion, modelScores []rubricModelScore) *QAScorecard {
+	byNumber := make(map[int]rubricModelScore, len(modelScores))
+	for _, ms := range modelScores {
+		byNumber[ms.Criterion] = ms
+	}
+
+	scorecard := &QAScorecard{Criteria: make([]CriterionScore, 0, len(criteria))}
+	weightedTotal := 0.0
+	totalWeight := 0.0
+
+	for i, c := range criteria {
+		ms, ok := byNumber[i+1]
+		if !ok {
+			scorecard.Criteria = append(scorecard.Criteria, CriterionScore{
+				CriterionID: c.ID,
+				Name:        c.Name,
+				Weight:      c.Weight,
+				MaxScore:    c.MaxScore,
+				Evidence:    []string{},
+				Unscored:    true,
+			})
+			continue
+		}
+
+		// Clamp the score into the criterion's range
+		score := ms.Score
+		if score < 0 {
+			score = 0
+		}
+		if score > float64(c.MaxScore) {
+			score = float64(c.MaxScore)
+		}
+
+		evidence := ms.Evidence
+		if evidence == nil {
+			evidence = []string{}
+		}
+
+		scorecard.Criteria = append(scorecard.Criteria, CriterionScore{
+			CriterionID: c.ID,
+			Name:        c.Name,
+			Weight:      c.Weight,
+			Score:       score,
+			MaxScore:    c.MaxScore,
+			Evidence:    evidence,
+			Rationale:   ms.Rationale,
+		})
+
+		weightedTotal += c.Weight * score / float64(c.MaxScore)
+		totalWeight += c.Weight
+	}
+
+	if scoredNone(scorecard.Criteria) {
+		scorecard.Error = "the model scored none of the criteria"
+	} else if totalWeight > 0 {
+		scorecard.CompositeScore = roundTo(weightedTotal/totalWeight*100, 2)
+	}
+
+	return scorecard
+}
+
+// scoredNone reports whether every criterion is unscored
+func scoredNone(scores []CriterionScore) bool {
+	for _, score := range scores {
+		if !score.Unscored {
+			return false
+		}
+	}
+	return true
+}
+
+// rationaleLanguage asks for the rationales in the campaign's output language; evidence quotes stay verbatim
+func rationaleLanguage(outputLanguage string) string {
+	if outputLanguage == "" {
+		return ""
+	}
+	return fmt.Sprintf("Write the rationales in %s; keep the evidence quotes verbatim in the language spoken.\n", outputLanguage)
+}
@@ -0,0 +1,75 @@
+// This file is duplicated byte for byte in lambda-api-gateway and lambda-transcription. The two are
+// separate modules, each built and deployed from its own directory, so they can't share a package.
+// Change both copies together and check they still match with
+// "cmp lambda-api-gateway/schema.go lambda-transcription/schema.go".
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/lib/pq"
+)
+
+// defaultSchema is the Postgres schema used when DB_SCHEMA is not set
+const defaultSchema = "smartFlo"
+
+// SchemaConfig maps the logical table and column names used in queries onto a tenant's database,
+// so the same binary can serve schemas with different names and layouts.
+type SchemaConfig struct {
+	Schema string
+	// Tables maps logical table names (e.g. "call_logs") to the tenant's table names
+	Tables map[string]string
+	// Columns maps "table.column" logical names (e.g. "call_logs.recording_url") to the tenant's column names
+	Columns map[string]string
+}
+
+// DefaultSchemaConfig returns the configuration for the "smartFlo" schema
+func DefaultSchemaConfig() SchemaConfig {
+	return SchemaConfig{Schema: defaultSchema}
+}
+
+// LoadSchemaConfig reads the schema configuration from the environment:
+//
+//	DB_SCHEMA        schema name (default "smartFlo")
+//	DB_TABLE_NAMES   JSON object of table overrides, e.g. {"call_logs": "calls"}
+//	DB_COLUMN_NAMES  JSON object of column overrides, e.g. {"call_logs.recording_url": "recordingUrl"}
+func LoadSchemaConfig() (SchemaConfig, error) {
+	config := DefaultSchemaConfig()
+
+	if schema := os.Getenv("DB_SCHEMA"); schema != "" {
+		config.Schema = schema
+	}
+
+	if tables := os.Getenv("DB_TABLE_NAMES"); tables != "" {
+		if err := json.Unmarshal([]byte(tables), &config.Tables); err != nil {
+			return config, fmt.Errorf("error parsing DB_TABLE_NAMES: %v", err)
+		}
+	}
+
+	if columns := os.Getenv("DB_COLUMN_NAMES"); columns != "" {
+		if err := json.Unmarshal([]byte(columns), &config.Columns); err != nil {
+			return config, fmt.Errorf("error parsing DB_COLUMN_NAMES: %v", err)
+		}
+	}
+
+	return config, nil
+}
+
+// Table returns the quoted, schema-qualified name of a logical table
+func (s SchemaConfig) Table(name string) string {
+	if mapped, ok := s.Tables[name]; ok && mapped != "" {
+		name = mapped
+	}
+	return pq.QuoteIdentifier(s.Schema) + "." + pq.QuoteIdentifier(name)
+}
+
+// Column returns the quoted name of a logical column in a logical table
+func (s SchemaConfig) Column(table, name string) string {
+	if mapped, ok := s.Columns[table+"."+name]; ok && mapped != "" {
+		name = mapped
+	}
+	return pq.QuoteIdentifier(name)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+)
+
+// indexTranscription upserts the call's transcription into call_transcript_search, whose generated
+// tsvector column and GIN index back full-text search; an empty transcription removes the call from the index
+func (tp *TranscriptionPipeline) indexTranscription(tx *Tx, callLogsID, transcription string) error {
+	if transcription == "" {
+		deleteQuery := fmt.Sprintf(`DELETE FROM %s WHERE "call_logsId" = $1`, tp.schema.Table("call_transcript_search"))
+		if _, err := tx.Exec(deleteQuery, callLogsID); err != nil {
+			return fmt.Errorf("error clearing transcription search index: %v", err)
+		}
+		return nil
+	}
+
+	c := func(name string) string { return tp.schema.Column("call_logs", name) }
+	upsertQuery := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", "campaignId", transcription, "updatedAt")
+		SELECT %s, %s, $2, now()
+		FROM %s
+		WHERE %s = $1
+		ON CONFLICT ("call_logsId")
+		DO UPDATE SET "campaignId" = EXCLUDED."campaignId", transcription = EXCLUDED.transcription,
+		              "updatedAt" = EXCLUDED."updatedAt"
+	`, tp.schema.Table("call_transcript_search"), c("id"), c("campaignId"), tp.schema.Table("call_logs"), c("id"))
+
+	if _, err := tx.Exec(upsertQuery, callLogsID, transcription); err != nil {
+		return fmt.Errorf("error updating transcription search index: %v", err)
+	}
+	return nil
+}
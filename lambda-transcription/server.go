@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// serverModeEnabled reports whether the binary should run as an HTTP server instead of a Lambda (SERVER_MODE=http)
+func serverModeEnabled() bool {
+	return os.Getenv("SERVER_MODE") == "http"
+}
+
+// runHTTPServer serves the pipeline over plain HTTP for non-Lambda deployments (ECS, Kubernetes, docker-compose).
+// Requests other than the health check need the server's API key.
+//
+//	POST /process          same payload and response as the Lambda event for calls, voice notes and audio
+//	GET  /analysis/{id}    the stored analysis for a call, or 404 while it hasn't been processed
+//	GET  /health           liveness check
+func runHTTPServer() error {
+	addr := os.Getenv("HTTP_ADDR")
+	if addr == "" {
+		addr = ":8080"
+	}
+	apiKey, err := serverAPIKey()
+	if err != nil {
+		return err
+	}
+
+	defer closeSharedRepositories()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/process", requireServerAPIKey(apiKey, handleHTTPProcess))
+	mux.HandleFunc("/analysis/", requireServerAPIKey(apiKey, handleHTTPAnalysis))
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		writeHTTPJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	// Long recordings can take several minutes to process
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      15 * time.Minute,
+	}
+
+	// Drain in-flight calls on SIGTERM so container restarts don't drop work
+	shutdownDone := make(chan struct{})
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGTERM, syscall.SIGINT)
+		<-stop
+		log.Printf("Shutting down HTTP server...")
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server shutdown error: %v", err)
+		}
+		close(shutdownDone)
+	}()
+
+	log.Printf("HTTP server listening on %s", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("HTTP server error: %v", err)
+	}
+	<-shutdownDone
+	return nil
+}
+
+// handleHTTPProcess runs the Lambda handler for a POSTed call, voice note or audio event. Actions
+// (migrations, backfills, retention, ...) stay with Lambda and the CLI.
+func handleHTTPProcess(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeHTTPJSON(w, http.StatusMethodNotAllowed, LambdaResponse{StatusCode: http.StatusMethodNotAllowed, Error: "method not allowed"})
+		return
+	}
+
+	var request LambdaRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeHTTPJSON(w, http.StatusBadRequest, LambdaResponse{StatusCode: http.StatusBadRequest, Error: fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+	if request.Action != "" {
+		writeHTTPJSON(w, http.StatusBadRequest, LambdaResponse{StatusCode: http.StatusBadRequest, Error: fmt.Sprintf("action %q isn't served over HTTP; run it with the CLI or Lambda", request.Action)})
+		return
+	}
+	if request.CallLogsID == "" && request.MessageID == "" && request.Audio == nil {
+		writeHTTPJSON(w, http.StatusBadRequest, LambdaResponse{StatusCode: http.StatusBadRequest, Error: "call_logsId, messageId or audio is required"})
+		return
+	}
+
+	response, err := recoverPanics(LambdaHandler)(r.Context(), request)
+	if err != nil {
+		writeHTTPJSON(w, http.StatusInternalServerError, LambdaResponse{StatusCode: http.StatusInternalServerError, Error: err.Error()})
+		return
+	}
+
+	writeHTTPJSON(w, response.StatusCode, response)
+}
+
+// handleHTTPAnalysis returns the stored callAnalysis for GET /analysis/{id}
+func handleHTTPAnalysis(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeHTTPJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	callLogsID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/analysis/"), "/")
+	if callLogsID == "" || strings.Contains(callLogsID, "/") {
+		writeHTTPJSON(w, http.StatusNotFound, map[string]string{"error": "not found"})
+		return
+	}
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		writeHTTPJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := pipeline.ConnectToDatabase(); err != nil {
+		writeHTTPJSON(w, http.StatusServiceUnavailable, map[string]string{"error": fmt.Sprintf("failed to connect to database: %v", err)})
+		return
+	}
+	defer pipeline.CloseDatabase()
+
+	analysis, err := pipeline.GetCallAnalysis(callLogsID)
+	if err != nil {
+		writeHTTPJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	if analysis == nil {
+		writeHTTPJSON(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("no analysis found for call_logsId: %s", callLogsID)})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(analysis)
+}
+
+// GetCallAnalysis returns the stored callAnalysis JSON for a call, or nil when the call hasn't been processed
+func (tp *TranscriptionPipeline) GetCallAnalysis(callLogsID string) (json.RawMessage, error) {
+	query := fmt.Sprintf(`SELECT %s FROM %s WHERE %s = $1`,
+		tp.schema.Column("call_logs", "callAnalysis"), tp.schema.Table("call_logs"), tp.schema.Column("call_logs", "id"))
+
+	var analysis []byte
+	if err := tp.repo.QueryRow(query, callLogsID).Scan(&analysis); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching call analysis: %v", err)
+	}
+	if len(analysis) == 0 {
+		return nil, nil
+	}
+
+	return json.RawMessage(analysis), nil
+}
+
+// writeHTTPJSON writes v as a JSON response with the given status code
+func writeHTTPJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
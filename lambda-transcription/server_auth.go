@@ -0,0 +1,35 @@
+package main
+
+import (
+	"crypto/hmac"
+	"errors"
+	"net/http"
+	"os"
+)
+
+// serverAPIKey is the key clients of the HTTP and gRPC servers present as X-Api-Key. Unlike Lambda,
+// whose invocations IAM guards, the servers accept whatever reaches their port, so they don't start
+// without one.
+func serverAPIKey() (string, error) {
+	key := os.Getenv("SERVER_API_KEY")
+	if key == "" {
+		return "", errors.New("SERVER_API_KEY is required in server mode")
+	}
+	return key, nil
+}
+
+// validServerAPIKey compares a presented key with the configured one in constant time
+func validServerAPIKey(expected, presented string) bool {
+	return presented != "" && hmac.Equal([]byte(presented), []byte(expected))
+}
+
+// requireServerAPIKey rejects HTTP requests without the server's API key
+func requireServerAPIKey(key string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validServerAPIKey(key, r.Header.Get("X-Api-Key")) {
+			writeHTTPJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid X-Api-Key"})
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// buildSRT renders the transcript segments as SubRip (SRT) subtitles
+func buildSRT(segments []TranscriptSegment) string {
+	var b strings.Builder
+	for i, s := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n%s: %s\n\n", i+1, formatSubtitleTime(s.Start, ","), formatSubtitleTime(s.End, ","), s.Speaker, s.Text)
+	}
+	return b.String()
+}
+
+// buildVTT renders the transcript segments as WebVTT captions, using voice tags for the speaker
+func buildVTT(segments []TranscriptSegment) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n\n")
+	for i, s := range segments {
+		fmt.Fprintf(&b, "%d\n%s --> %s\n<v %s>%s\n\n", i+1, formatSubtitleTime(s.Start, "."), formatSubtitleTime(s.End, "."), s.Speaker, s.Text)
+	}
+	return b.String()
+}
+
+// formatSubtitleTime converts seconds into "HH:MM:SS,mmm" (SRT) or "HH:MM:SS.mmm" (WebVTT)
+func formatSubtitleTime(seconds float64, millisSeparator string) string {
+	totalMillis := int64(seconds*1000 + 0.5)
+	hours := totalMillis / 3600000
+	minutes := (totalMillis % 3600000) / 60000
+	secs := (totalMillis % 60000) / 1000
+	millis := totalMillis % 1000
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", hours, minutes, secs, millisSeparator, millis)
+}
+
+// SaveSubtitles stores the SRT and WebVTT renditions of the call's transcript in the analysis transaction
+func (tp *TranscriptionPipeline) SaveSubtitles(tx *Tx, callLogsID string, segments []TranscriptSegment) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("call_logsId", srt, vtt, "updatedAt")
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT ("call_logsId")
+		DO UPDATE SET srt = EXCLUDED.srt, vtt = EXCLUDED.vtt, "updatedAt" = EXCLUDED."updatedAt"
+	`, tp.schema.Table("call_subtitles"))
+
+	if _, err := tx.Exec(query, callLogsID, buildSRT(segments), buildVTT(segments)); err != nil {
+		return fmt.Errorf("error saving subtitles: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Transcription providers selectable via TRANSCRIPTION_PROVIDER
+const (
+	ProviderGemini   = "gemini"
+	ProviderOpenAI   = "openai"
+	ProviderDeepgram = "deepgram"
+)
+
+// Transcript is the provider-independent transcription shared by all transcription backends
+type Transcript struct {
+	Text     string              `json:"text"`
+	Language string              `json:"language,omitempty"`
+	Duration float64             `json:"duration,omitempty"`
+	Segments []TranscriptSegment `json:"segments,omitempty"`
+	Words    []TranscriptWord    `json:"words,omitempty"`
+	Provider string              `json:"provider"`
+	Model    string              `json:"model,omitempty"`
+}
+
+// TranscriptWord represents a single word with its timing, for providers that return word-level timestamps
+type TranscriptWord struct {
+	Word       string  `json:"word"`
+	Start      float64 `json:"start"`
+	End        float64 `json:"end"`
+	Speaker    string  `json:"speaker,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+}
+
+// Transcriber converts call audio into a Transcript
+type Transcriber interface {
+	Name() string
+	Transcribe(audioContent []byte) (*Transcript, error)
+}
+
+// NewTranscriber returns the transcription backend for the given provider name
+func NewTranscriber(provider string, tp *TranscriptionPipeline) (Transcriber, error) {
+	switch strings.ToLower(provider) {
+	case "", ProviderGemini:
+		return &GeminiTranscriber{pipeline: tp}, nil
+	case ProviderOpenAI:
+		return NewWhisperTranscriber(tp.openAIAPIKey)
+	case ProviderDeepgram:
+		return NewDeepgramTranscriber(tp.deepgramAPIKey)
+	default:
+		return nil, fmt.Errorf("unknown transcription provider: %s", provider)
+	}
+}
+
+// GeminiTranscriber transcribes audio with Gemini using the diarized transcription prompt
+type GeminiTranscriber struct {
+	pipeline *TranscriptionPipeline
+}
+
+// Name returns the provider name
+func (g *GeminiTranscriber) Name() string {
+	return ProviderGemini
+}
+
+// Transcribe transcribes the audio and parses the diarized speaker turns
+func (g *GeminiTranscriber) Transcribe(audioContent []byte) (*Transcript, error) {
+	text, err := g.pipeline.TranscribeAudioOnly(audioContent)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transcript{
+		Text:     text,
+		Segments: parseDiarizedTranscript(text),
+		Provider: ProviderGemini,
+		Model:    g.pipeline.servedModel,
+	}, nil
+}
+
+// formatTranscriptSegments renders segments in the diarized line format used by the Gemini prompt,
+// so transcripts from every provider are stored and parsed the same way
+func formatTranscriptSegments(segments []TranscriptSegment) string {
+	lines := make([]string, 0, len(segments))
+	for _, s := range segments {
+		lines = append(lines, fmt.Sprintf("[%s - %s] %s: %s", formatTimestamp(s.Start), formatTimestamp(s.End), s.Speaker, s.Text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatTimestamp converts seconds into "MM:SS", or "HH:MM:SS" for recordings over an hour
+func formatTimestamp(seconds float64) string {
+	total := int(seconds)
+	if total >= 3600 {
+		return fmt.Sprintf("%02d:%02d:%02d", total/3600, (total%3600)/60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
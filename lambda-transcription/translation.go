@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// alreadyEnglish is the model's reply when the transcription needs no translation
+const alreadyEnglish = "ALREADY_ENGLISH"
+
+// TranslateTranscription translates the transcription into English, keeping its timestamps and
+// speaker labels. It returns "" when the call is already entirely in English.
+func (tp *TranscriptionPipeline) TranslateTranscription(transcription string) (string, error) {
+	prompt := fmt.Sprintf(`
+Translate the following call transcription into English for compliance reviewers who only read English.
+
+Rules:
+- Keep every line's timestamp and speaker label (e.g. "[00:12] Agent:") exactly as they are; translate only the spoken text.
+- Translate faithfully, including mixed-language (e.g. Hinglish) and romanized speech. Don't summarize, omit or add anything.
+- Keep names, numbers, amounts and product names as spoken.
+- If the transcription is already entirely in English, reply with exactly %s and nothing else.
+
+TRANSCRIPTION:
+%s
+`, alreadyEnglish, transcription)
+
+	responseText, err := tp.GenerateText(prompt, false)
+	if err != nil {
+		return "", fmt.Errorf("error translating transcription: %v", err)
+	}
+
+	translation := strings.TrimSpace(responseText)
+	if translation == alreadyEnglish {
+		return "", nil
+	}
+	return translation, nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultTwoPhaseAnsweringModel answers two-phase campaigns' questions unless the campaign or
+// TWO_PHASE_ANSWERING_MODEL names another model
+const defaultTwoPhaseAnsweringModel = "gemini-2.5-flash"
+
+// TwoPhaseSettings splits a campaign's Gemini processing in two: the audio request transcribes the
+// call, and the questions are answered from the transcription in a text-only request to a cheaper
+// model. Questions that need the audio itself (tone, hesitation, background noise) can stay in the
+// audio request.
+//
+//	"twoPhase": {"enabled": true, "answeringModel": "gemini-2.5-flash-lite", "audioQuestions": ["<question id>"]}
+type TwoPhaseSettings struct {
+	Enabled bool `json:"enabled"`
+	// AnsweringModel answers the text-only phase; empty uses TWO_PHASE_ANSWERING_MODEL
+	AnsweringModel string `json:"answeringModel,omitempty"`
+	// AudioQuestions are the IDs of the questions answered in the audio request alongside the transcription
+	AudioQuestions []string `json:"audioQuestions,omitempty"`
+}
+
+// useTwoPhase applies the campaign's two-phase settings to the current call; disabled settings are dropped
+func (tp *TranscriptionPipeline) useTwoPhase(settings *TwoPhaseSettings) {
+	tp.twoPhase = nil
+	if settings != nil && settings.Enabled {
+		tp.twoPhase = settings
+	}
+}
+
+// answeringModel is the model of the text-only answering phase: the campaign's, then
+// TWO_PHASE_ANSWERING_MODEL, then defaultTwoPhaseAnsweringModel
+func (s *TwoPhaseSettings) answeringModel() string {
+	if s.AnsweringModel != "" {
+		return s.AnsweringModel
+	}
+	if model := os.Getenv("TWO_PHASE_ANSWERING_MODEL"); model != "" {
+		return model
+	}
+	return defaultTwoPhaseAnsweringModel
+}
+
+// splitQuestions returns the questions answered in the audio request and those answered from the transcription
+func (s *TwoPhaseSettings) splitQuestions(questions []Question) (audioQuestions, textQuestions []Question) {
+	inAudio := make(map[string]bool, len(s.AudioQuestions))
+	for _, id := range s.AudioQuestions {
+		inAudio[id] = true
+	}
+	for _, q := range questions {
+		if inAudio[q.ID] {
+			audioQuestions = append(audioQuestions, q)
+		} else {
+			textQuestions = append(textQuestions, q)
+		}
+	}
+	return audioQuestions, textQuestions
+}
+
+// fingerprint identifies the answering model and phase boundary, for cache keys
+func (s *TwoPhaseSettings) fingerprint() string {
+	audioQuestions := append([]string(nil), s.AudioQuestions...)
+	sort.Strings(audioQuestions)
+	return s.answeringModel() + ":" + strings.Join(audioQuestions, ",")
+}
+
+// phaseQuestions returns the questions of an audio request and those left for a text-only request;
+// without two-phase processing all of them are answered in the audio request
+func (tp *TranscriptionPipeline) phaseQuestions(questions []Question) (audioQuestions, textQuestions []Question) {
+	if tp.twoPhase == nil {
+		return questions, nil
+	}
+	return tp.twoPhase.splitQuestions(questions)
+}
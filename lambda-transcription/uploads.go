@@ -0,0 +1,153 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+)
+
+// Audio upload statuses
+const (
+	UploadPending    = "pending"
+	UploadProcessing = "processing"
+	UploadCompleted  = "completed"
+	UploadFailed     = "failed"
+)
+
+// S3EventRecord is a record of an S3 event notification, as delivered to the function
+type S3EventRecord struct {
+	EventSource string `json:"eventSource"`
+	EventName   string `json:"eventName"`
+	S3          struct {
+		Bucket struct {
+			Name string `json:"name"`
+		} `json:"bucket"`
+		Object struct {
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+		} `json:"object"`
+	} `json:"s3"`
+}
+
+// audioUpload is an audio_uploads job claimed for processing
+type audioUpload struct {
+	ID         string
+	CampaignID string
+	Questions  []map[string]interface{}
+}
+
+// HandleUploadEvents processes the recordings uploaded through the API's POST /uploads, as S3
+// ObjectCreated notifications deliver them. Objects without a pending job are ignored, so a
+// repeated notification doesn't process a recording twice.
+func (tp *TranscriptionPipeline) HandleUploadEvents(records []S3EventRecord) LambdaResponse {
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{StatusCode: 500, Error: err.Error()}
+	}
+	defer tp.CloseDatabase()
+
+	outcomes := map[string]string{}
+	for _, record := range records {
+		if record.EventSource != "aws:s3" {
+			continue
+		}
+		// Keys arrive URL-encoded, with spaces as '+'
+		key, err := url.QueryUnescape(record.S3.Object.Key)
+		if err != nil {
+			key = record.S3.Object.Key
+		}
+
+		upload, err := tp.ClaimAudioUpload(key)
+		if err != nil {
+			return LambdaResponse{StatusCode: 500, Body: outcomes, Error: err.Error()}
+		}
+		if upload == nil {
+			log.Printf("No pending upload for s3://%s/%s, ignoring", record.S3.Bucket.Name, key)
+			continue
+		}
+
+		result, processErr := processAudioUpload(upload, fmt.Sprintf("s3://%s/%s", record.S3.Bucket.Name, key))
+		if err := tp.FinishAudioUpload(upload.ID, result, processErr); err != nil {
+			return LambdaResponse{StatusCode: 500, Body: outcomes, Error: err.Error()}
+		}
+		outcomes[upload.ID] = UploadCompleted
+		if processErr != nil {
+			log.Printf("❌ Upload %s failed: %v", upload.ID, processErr)
+			outcomes[upload.ID] = UploadFailed
+		}
+	}
+
+	return LambdaResponse{StatusCode: 200, Body: outcomes}
+}
+
+// processAudioUpload processes an uploaded recording with a fresh pipeline, so per-upload state
+// doesn't leak between the records of an event
+func processAudioUpload(upload *audioUpload, s3URI string) (map[string]interface{}, error) {
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.ProcessInlineAudio(InlineAudio{
+		S3URI:      s3URI,
+		CampaignID: upload.CampaignID,
+		Questions:  upload.Questions,
+		Reference:  upload.ID,
+	})
+}
+
+// ClaimAudioUpload marks the pending upload of an object as processing and returns it; nil when
+// the object has no pending upload. An upload left processing for longer than the processing lock
+// TTL belongs to an invocation that died before finishing it, so it is claimed again.
+func (tp *TranscriptionPipeline) ClaimAudioUpload(objectKey string) (*audioUpload, error) {
+	query := fmt.Sprintf(`
+		UPDATE %s SET status = $2, "updatedAt" = now()
+		WHERE "objectKey" = $1
+		  AND (status = $3 OR (status = $2 AND "updatedAt" < now() - make_interval(secs => $4)))
+		RETURNING id::text, COALESCE("campaignId"::text, ''), questions
+	`, tp.schema.Table("audio_uploads"))
+
+	var upload audioUpload
+	var questionsJSON []byte
+	err := tp.repo.QueryRow(query, objectKey, UploadProcessing, UploadPending, processingLockTTL().Seconds()).
+		Scan(&upload.ID, &upload.CampaignID, &questionsJSON)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error claiming audio upload: %v", err)
+	}
+	if err := json.Unmarshal(questionsJSON, &upload.Questions); err != nil {
+		parseErr := fmt.Errorf("error parsing questions of upload %s: %v", upload.ID, err)
+		if err := tp.FinishAudioUpload(upload.ID, nil, parseErr); err != nil {
+			return nil, err
+		}
+		return nil, parseErr
+	}
+	return &upload, nil
+}
+
+// FinishAudioUpload stores the upload's result, or its error. A result that can't be marshaled
+// fails the upload, so it never stays processing.
+func (tp *TranscriptionPipeline) FinishAudioUpload(uploadID string, result map[string]interface{}, processErr error) error {
+	status, message := UploadCompleted, ""
+	var resultJSON []byte
+	if processErr == nil {
+		var err error
+		if resultJSON, err = json.Marshal(result); err != nil {
+			processErr = fmt.Errorf("error marshaling upload result: %v", err)
+		}
+	}
+	if processErr != nil {
+		status, message, resultJSON = UploadFailed, processErr.Error(), nil
+	}
+
+	query := fmt.Sprintf(`
+		UPDATE %s SET status = $2, result = NULLIF($3, '')::jsonb, error = NULLIF($4, ''), "updatedAt" = now(), "completedAt" = now()
+		WHERE id::text = $1
+	`, tp.schema.Table("audio_uploads"))
+	if _, err := tp.repo.Exec(query, uploadID, status, string(resultJSON), message); err != nil {
+		return fmt.Errorf("error saving audio upload result: %v", err)
+	}
+	return nil
+}
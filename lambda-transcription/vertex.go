@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Gemini backends, selected by GEMINI_BACKEND
+const (
+	// GeminiBackendAIStudio is the public generativelanguage endpoint, authenticated with API keys
+	GeminiBackendAIStudio = "ai_studio"
+	// GeminiBackendVertex is Vertex AI, authenticated with a Google service account or workload identity federation
+	GeminiBackendVertex = "vertex"
+)
+
+const (
+	// vertexScope is the OAuth scope of Vertex AI access tokens
+	vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+	// vertexTokenRefreshMargin renews access tokens this long before they expire
+	vertexTokenRefreshMargin = 5 * time.Minute
+	// defaultVertexLocation is the region of Vertex AI requests when VERTEX_LOCATION is not set
+	defaultVertexLocation = "us-central1"
+)
+
+// geminiBackend returns the configured Gemini backend (GEMINI_BACKEND, default ai_studio)
+func geminiBackend() string {
+	if strings.EqualFold(os.Getenv("GEMINI_BACKEND"), GeminiBackendVertex) {
+		return GeminiBackendVertex
+	}
+	return GeminiBackendAIStudio
+}
+
+// newGeminiRequest creates a request to the model's method (generateContent, embedContent or
+// predict) on the configured backend, authenticated with the current call's API key on AI Studio or
+// a service account access token on Vertex AI
+func (tp *TranscriptionPipeline) newGeminiRequest(model, method string, body *geminiRequestBody) (*http.Request, error) {
+	if geminiBackend() != GeminiBackendVertex {
+		req, err := newStreamingRequest(fmt.Sprintf(geminiModelURL, model, method), body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		// The key goes in a header: a failed request's error quotes the URL, and errors are reported
+		req.Header.Set("x-goog-api-key", tp.currentGeminiAPIKey())
+		return req, nil
+	}
+
+	// Tenants may bill their requests to a project of their own
+	project := os.Getenv("VERTEX_PROJECT_ID")
+	if tp.geminiTenant != nil && tp.geminiTenant.key.VertexProject != "" {
+		project = tp.geminiTenant.key.VertexProject
+	}
+	if project == "" {
+		return nil, fmt.Errorf("VERTEX_PROJECT_ID is required with GEMINI_BACKEND=vertex")
+	}
+	location := os.Getenv("VERTEX_LOCATION")
+	if location == "" {
+		location = defaultVertexLocation
+	}
+
+	token, err := vertexAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	host := location + "-aiplatform.googleapis.com"
+	if location == "global" {
+		host = "aiplatform.googleapis.com"
+	}
+	endpoint := fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+		host, url.PathEscape(project), url.PathEscape(location), url.PathEscape(model), method)
+	req, err := newStreamingRequest(endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req, nil
+}
+
+// googleCredentials is a Google credentials file: a service account key ("service_account") or a
+// workload identity federation configuration for AWS ("external_account")
+type googleCredentials struct {
+	Type string `json:"type"`
+
+	// Service account keys
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	PrivateKeyID string `json:"private_key_id"`
+	TokenURI     string `json:"token_uri"`
+
+	// Workload identity federation
+	Audience                       string `json:"audience"`
+	SubjectTokenType               string `json:"subject_token_type"`
+	TokenURL                       string `json:"token_url"`
+	ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	CredentialSource               struct {
+		EnvironmentID               string `json:"environment_id"`
+		RegionalCredVerificationURL string `json:"regional_cred_verification_url"`
+	} `json:"credential_source"`
+}
+
+var (
+	vertexTokenMu        sync.Mutex
+	vertexToken          string
+	vertexTokenExpiresAt time.Time
+)
+
+// vertexAccessToken returns a cached Vertex AI access token, minting a new one shortly before it expires
+func vertexAccessToken() (string, error) {
+	vertexTokenMu.Lock()
+	defer vertexTokenMu.Unlock()
+
+	if vertexToken != "" && time.Until(vertexTokenExpiresAt) > vertexTokenRefreshMargin {
+		return vertexToken, nil
+	}
+
+	creds, err := loadGoogleCredentials()
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	var expiresAt time.Time
+	switch creds.Type {
+	case "service_account":
+		token, expiresAt, err = serviceAccountToken(creds)
+	case "external_account":
+		token, expiresAt, err = workloadIdentityToken(creds)
+	default:
+		err = fmt.Errorf("unsupported Google credentials type %q", creds.Type)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error fetching Vertex AI access token: %v", err)
+	}
+
+	vertexToken, vertexTokenExpiresAt = token, expiresAt
+	return token, nil
+}
+
+// loadGoogleCredentials reads the credentials from the Secrets Manager secret named by
+// VERTEX_CREDENTIALS_SECRET_ID, or else the file named by GOOGLE_APPLICATION_CREDENTIALS
+func loadGoogleCredentials() (*googleCredentials, error) {
+	var data []byte
+	if secretID := os.Getenv("VERTEX_CREDENTIALS_SECRET_ID"); secretID != "" {
+		secretString, err := getSecretString(secretID)
+		if err != nil {
+			return nil, fmt.Errorf("error loading Vertex AI credentials: %v", err)
+		}
+		data = []byte(secretString)
+	} else if path := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("error reading Vertex AI credentials: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("VERTEX_CREDENTIALS_SECRET_ID or GOOGLE_APPLICATION_CREDENTIALS is required with GEMINI_BACKEND=vertex")
+	}
+
+	var creds googleCredentials
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, fmt.Errorf("error parsing Vertex AI credentials: %v", err)
+	}
+	return &creds, nil
+}
+
+// serviceAccountToken exchanges a JWT signed with the service account's key for an access token
+func serviceAccountToken(creds *googleCredentials) (string, time.Time, error) {
+	block, _ := pem.Decode([]byte(creds.PrivateKey))
+	if block == nil {
+		return "", time.Time{}, fmt.Errorf("service account private_key is not PEM encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error parsing service account private key: %v", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("service account private key is not an RSA key")
+	}
+
+	tokenURI := creds.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": creds.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"iss":   creds.ClientEmail,
+		"scope": vertexScope,
+		"aud":   tokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error signing service account assertion: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", unsigned+"."+base64.RawURLEncoding.EncodeToString(signature))
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := postGoogleToken(tokenURI, "application/x-www-form-urlencoded", "", []byte(form.Encode()), &token); err != nil {
+		return "", time.Time{}, err
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("no access token in the OAuth response")
+	}
+	return token.AccessToken, now.Add(time.Duration(token.ExpiresIn) * time.Second), nil
+}
+
+// workloadIdentityToken exchanges the Lambda's AWS identity for a Google access token: a signed
+// sts:GetCallerIdentity request is the subject token for Google STS, whose federated token then
+// impersonates the service account when the configuration names one
+func workloadIdentityToken(creds *googleCredentials) (string, time.Time, error) {
+	if creds.CredentialSource.EnvironmentID != "aws1" {
+		return "", time.Time{}, fmt.Errorf("unsupported workload identity credential source %q; only AWS is supported", creds.CredentialSource.EnvironmentID)
+	}
+	subjectToken, err := awsSubjectToken(creds)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	tokenURL := creds.TokenURL
+	if tokenURL == "" {
+		tokenURL = "https://sts.googleapis.com/v1/token"
+	}
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+	form.Set("audience", creds.Audience)
+	form.Set("scope", vertexScope)
+	form.Set("requested_token_type", "urn:ietf:params:oauth:token-type:access_token")
+	form.Set("subject_token_type", creds.SubjectTokenType)
+	form.Set("subject_token", subjectToken)
+
+	now := time.Now()
+	var federated struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := postGoogleToken(tokenURL, "application/x-www-form-urlencoded", "", []byte(form.Encode()), &federated); err != nil {
+		return "", time.Time{}, err
+	}
+	if creds.ServiceAccountImpersonationURL == "" {
+		return federated.AccessToken, now.Add(time.Duration(federated.ExpiresIn) * time.Second), nil
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"scope": []string{vertexScope}})
+	var impersonated struct {
+		AccessToken string    `json:"accessToken"`
+		ExpireTime  time.Time `json:"expireTime"`
+	}
+	if err := postGoogleToken(creds.ServiceAccountImpersonationURL, "application/json", federated.AccessToken, body, &impersonated); err != nil {
+		return "", time.Time{}, err
+	}
+	return impersonated.AccessToken, impersonated.ExpireTime, nil
+}
+
+// awsSubjectToken returns the URL-encoded, SigV4-signed sts:GetCallerIdentity request that Google STS
+// verifies with AWS to establish the Lambda's identity
+func awsSubjectToken(creds *googleCredentials) (string, error) {
+	awsCreds, err := loadAWSCredentials()
+	if err != nil {
+		return "", err
+	}
+	region := awsRegion()
+	if region == "" {
+		return "", fmt.Errorf("AWS_REGION is not configured")
+	}
+
+	verificationURL := creds.CredentialSource.RegionalCredVerificationURL
+	if verificationURL == "" {
+		verificationURL = "https://sts.{region}.amazonaws.com?Action=GetCallerIdentity&Version=2011-06-15"
+	}
+	verificationURL = strings.ReplaceAll(verificationURL, "{region}", region)
+
+	req, err := http.NewRequest("POST", verificationURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("error creating caller identity request: %v", err)
+	}
+	req.Header.Set("X-Goog-Cloud-Target-Resource", creds.Audience)
+	signAWSRequest(req, nil, "sts", region, awsCreds, time.Now())
+
+	type header struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	headers := []header{{Key: "host", Value: req.URL.Host}}
+	for name := range req.Header {
+		headers = append(headers, header{Key: name, Value: req.Header.Get(name)})
+	}
+	token, err := json.Marshal(map[string]interface{}{
+		"url":     verificationURL,
+		"method":  "POST",
+		"headers": headers,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshaling subject token: %v", err)
+	}
+	return url.QueryEscape(string(token)), nil
+}
+
+// postGoogleToken posts a token request, with the bearer token if any, and decodes the response into out
+func postGoogleToken(endpoint, contentType, bearer string, body []byte, out interface{}) error {
+	req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating token request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+
+	resp, err := apiClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("error making token request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading token response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("token request to %s failed: status %d, body: %s", endpoint, resp.StatusCode, string(respBody))
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("error decoding token response: %v", err)
+	}
+	return nil
+}
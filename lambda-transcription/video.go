@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// videoMimeType identifies video recordings from their header: MP4, QuickTime and 3GP files with a
+// video track, and WebM/Matroska. It returns "" for anything else, including audio-only M4A files.
+func videoMimeType(content []byte) string {
+	switch {
+	case len(content) >= 12 && string(content[4:8]) == "ftyp":
+		if !hasMP4VideoTrack(content) {
+			return ""
+		}
+		brand := string(content[8:12])
+		switch {
+		case brand == "qt  ":
+			return "video/quicktime"
+		case strings.HasPrefix(brand, "3g"):
+			return "video/3gpp"
+		}
+		return "video/mp4"
+	case len(content) >= 4 && bytes.Equal(content[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return "video/webm"
+	}
+	return ""
+}
+
+// hasMP4VideoTrack reports whether an MP4/QuickTime file has a track whose handler ("hdlr" box) is
+// "vide". The moov box may come after the media data, so the whole file is scanned.
+func hasMP4VideoTrack(content []byte) bool {
+	for offset := 0; ; {
+		i := bytes.Index(content[offset:], []byte("hdlr"))
+		if i < 0 {
+			return false
+		}
+		// hdlr: version and flags, pre_defined, then the handler type
+		handler := offset + i + 12
+		if handler+4 <= len(content) && string(content[handler:handler+4]) == "vide" {
+			return true
+		}
+		offset += i + 4
+	}
+}
+
+// extractVideoAudio replaces a video recording with its audio track, a 16kHz mono MP3, so it's
+// transcribed like any other call. Without ffmpeg, or when extraction fails, the video is kept and
+// sent with its video MIME type, which Gemini accepts; other providers may reject it.
+func (tp *TranscriptionPipeline) extractVideoAudio(content []byte) []byte {
+	mimeType := videoMimeType(content)
+	if mimeType == "" {
+		return content
+	}
+	tp.metadata.SourceFormat = mimeType
+
+	ffmpeg := ffmpegPath()
+	if ffmpeg == "" {
+		log.Printf("Sending %s recording of %d bytes as is: ffmpeg isn't available to extract its audio", mimeType, len(content))
+		return content
+	}
+
+	audio, err := extractAudioTrack(ffmpeg, content)
+	if err != nil {
+		log.Printf("Error extracting audio from %s recording, sending the video: %v", mimeType, err)
+		return content
+	}
+
+	log.Printf("Extracted %d bytes of audio from %s recording of %d bytes", len(audio), mimeType, len(content))
+	return audio
+}
+
+// extractAudioTrack converts the video's first audio track to a 16kHz mono MP3. The video is read
+// from a temporary file rather than a pipe because MP4s often keep their index at the end.
+func extractAudioTrack(ffmpeg string, content []byte) ([]byte, error) {
+	input, err := os.CreateTemp("", "recording-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary file: %v", err)
+	}
+	defer os.Remove(input.Name())
+	if _, err := input.Write(content); err != nil {
+		input.Close()
+		return nil, fmt.Errorf("error writing temporary file: %v", err)
+	}
+	if err := input.Close(); err != nil {
+		return nil, fmt.Errorf("error writing temporary file: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpegTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error", "-i", input.Name(),
+		"-map", "0:a:0", "-vn", "-ac", "1", "-ar", strconv.Itoa(preprocessedSampleRate),
+		"-c:a", "libmp3lame", "-b:a", ffmpegBitrate, "-f", "mp3", "pipe:1")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg failed: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("video has no audio track")
+	}
+	return stdout.Bytes(), nil
+}
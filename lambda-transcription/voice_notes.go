@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Permanent ProcessVoiceNote failures
+var (
+	ErrMessageNotFound         = errors.New("message not found")
+	ErrNoMediaURL              = errors.New("no media URL found for this message")
+	ErrMessageAlreadyProcessed = errors.New("message already has an analysis")
+)
+
+// VoiceNote is a voice message from the messages table, e.g. a WhatsApp voice note (OGG/Opus)
+type VoiceNote struct {
+	ID         string `json:"messageId"`
+	MediaURL   string `json:"media_url"`
+	CampaignID string `json:"campaignId"`
+	// Analyzed is set when the message already has a row in voice_note_analyses
+	Analyzed bool `json:"-"`
+}
+
+// GetVoiceNote reads a message's media URL and campaign. Messages without a campaign use
+// VOICE_NOTE_CAMPAIGN_ID, whose questions then apply to every voice note.
+func (tp *TranscriptionPipeline) GetVoiceNote(messageID string) (*VoiceNote, error) {
+	c := func(name string) string { return "m." + tp.schema.Column("messages", name) }
+	query := fmt.Sprintf(`
+		SELECT %s::text, %s, %s::text,
+		       EXISTS (SELECT 1 FROM %s a WHERE a."messageId" = %s::text)
+		FROM %s m
+		WHERE %s::text = $1
+	`, c("id"), c("media_url"), c("campaignId"),
+		tp.schema.Table("voice_note_analyses"), c("id"),
+		tp.schema.Table("messages"), c("id"))
+
+	var note VoiceNote
+	var mediaURL, campaignID sql.NullString
+	err := tp.repo.QueryRow(query, messageID).Scan(&note.ID, &mediaURL, &campaignID, &note.Analyzed)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w with ID: %s", ErrMessageNotFound, messageID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching message: %v", err)
+	}
+
+	note.MediaURL = strings.TrimSpace(mediaURL.String)
+	note.CampaignID = campaignID.String
+	if note.CampaignID == "" {
+		note.CampaignID = os.Getenv("VOICE_NOTE_CAMPAIGN_ID")
+	}
+	return &note, nil
+}
+
+// ProcessVoiceNote transcribes a voice note and answers its campaign's questions with the same
+// transcription, caching, enum validation and conditional question handling as calls. Voice notes
+// have one speaker and no call metadata, so the call enrichments (metrics, compliance, QA scoring,
+// follow-ups, CRM push) don't run, and the analysis is saved to voice_note_analyses.
+func (tp *TranscriptionPipeline) ProcessVoiceNote(messageID string) (_ map[string]interface{}, err error) {
+	tp.startProcessingMetadata()
+	tp.errorContext = errorContext{MessageID: messageID}
+	defer func() { tp.reportError(err) }()
+
+	if err := tp.ConnectToDatabase(); err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer tp.CloseDatabase()
+	defer func() { tp.reportFinished(err) }()
+
+	tp.reportStage(StageFetching)
+	dbFetchStart := time.Now()
+	note, err := tp.GetVoiceNote(messageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if note.Analyzed && !tp.reprocess && !tp.dryRun {
+		return nil, ErrMessageAlreadyProcessed
+	}
+	if note.MediaURL == "" {
+		return nil, ErrNoMediaURL
+	}
+	if note.CampaignID == "" {
+		return nil, fmt.Errorf("no campaign ID found for this message; set VOICE_NOTE_CAMPAIGN_ID")
+	}
+	tp.errorContext.CampaignID = note.CampaignID
+
+	settings, err := tp.GetCampaignSettings(note.CampaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign settings: %v", err)
+	}
+	if err := tp.useGeminiTenant(settings.GeminiTenant); err != nil {
+		return nil, err
+	}
+	tp.fallbackModels = settings.GeminiFallbackModels
+	tp.debugResponses = settings.DebugResponses
+	if err := tp.applyCampaignBudget(note.CampaignID, settings.Budget); err != nil {
+		return nil, err
+	}
+	questions, err := tp.questionsForCampaign(note.CampaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get questions for campaign: %v", err)
+	}
+
+	provider := settings.TranscriptionProvider
+	if provider == "" {
+		provider = tp.transcriptionProvider
+	}
+	tp.outputLanguage = settings.OutputLanguage
+	tp.useTwoPhase(settings.TwoPhase)
+	tp.profile = nil
+	tp.promptCall = nil
+	tp.metadata.Stages.DBFetch = elapsedMs(dbFetchStart)
+
+	transcriptionResult, err := tp.TranscribeRecording(note.MediaURL, questions, provider)
+	if err != nil {
+		return nil, &ProviderError{Err: err}
+	}
+
+	answers, enumAnswers := validateEnumAnswers(questions, markNotDiscussed(transcriptionResult.Answers))
+	answers, skippedQuestions := applyQuestionConditions(questions, answers)
+
+	usage := tp.usage
+	analysisData := CallAnalysisData{
+		Transcription:     transcriptionResult.Transcription,
+		Answers:           answers,
+		CallDisposition:   transcriptionResult.Disposition,
+		DispositionReason: transcriptionResult.DispositionReason,
+		SkippedQuestions:  skippedQuestions,
+		EnumAnswers:       enumAnswers,
+		Words:             transcriptionResult.Words,
+		Provider:          transcriptionResult.Provider,
+		CacheHit:          transcriptionResult.CacheHit,
+		Usage:             &usage,
+		RequestBudget:     transcriptionResult.RequestBudget,
+		AudioQuality:      transcriptionResult.AudioQuality,
+		SkipReason:        transcriptionResult.SkipReason,
+		ProcessedAt:       time.Now().Format(time.RFC3339),
+	}
+	if analysisData.Answers == nil {
+		analysisData.Answers = map[string]string{}
+	}
+	analysisData.ProcessingMetadata = tp.processingMetadata()
+
+	result := map[string]interface{}{
+		"messageId":           note.ID,
+		"campaignId":          note.CampaignID,
+		"transcription":       analysisData.Transcription,
+		"answers":             analysisData.Answers,
+		"skipped_questions":   skippedQuestions,
+		"enum_answers":        enumAnswers,
+		"provider":            analysisData.Provider,
+		"cache_hit":           analysisData.CacheHit,
+		"processing_metadata": analysisData.ProcessingMetadata,
+		"processed_at":        analysisData.ProcessedAt,
+	}
+	if analysisData.CallDisposition != "" && analysisData.CallDisposition != DispositionConversation {
+		result["call_disposition"] = analysisData.CallDisposition
+	}
+	if analysisData.AudioQuality != nil {
+		result["audio_quality"] = analysisData.AudioQuality
+	}
+	if analysisData.SkipReason != "" {
+		result["skipped"] = true
+		result["skip_reason"] = analysisData.SkipReason
+	}
+
+	if tp.dryRun {
+		result["dry_run"] = true
+		result["analysis"] = analysisData
+		return tp.shapeResponse(result, nil), nil
+	}
+
+	tp.reportStage(StageSaving)
+	if err := tp.SaveVoiceNoteAnalysis(note, analysisData); err != nil {
+		return nil, fmt.Errorf("failed to save voice note analysis: %v", err)
+	}
+
+	return tp.shapeResponse(result, &analysisData), nil
+}
+
+// SaveVoiceNoteAnalysis stores the voice note's analysis, replacing an earlier one
+func (tp *TranscriptionPipeline) SaveVoiceNoteAnalysis(note *VoiceNote, analysisData CallAnalysisData) error {
+	analysisJSON, err := json.Marshal(analysisData)
+	if err != nil {
+		return fmt.Errorf("error marshaling analysis data: %v", err)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s ("messageId", "campaignId", analysis, "createdAt", "updatedAt")
+		VALUES ($1, $2, $3, now(), now())
+		ON CONFLICT ("messageId")
+		DO UPDATE SET "campaignId" = EXCLUDED."campaignId", analysis = EXCLUDED.analysis, "updatedAt" = now()
+	`, tp.schema.Table("voice_note_analyses"))
+	if _, err := tp.repo.Exec(query, note.ID, note.CampaignID, string(analysisJSON)); err != nil {
+		return fmt.Errorf("error saving voice note analysis: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+const (
+	// defaultLambdaMaxOpenConns is the pool size under Lambda, which handles one event at a time but
+	// reads a call's campaign configuration with four concurrent queries
+	defaultLambdaMaxOpenConns = 4
+	// defaultServerMaxOpenConns is the pool size for the CLI and the HTTP and gRPC servers
+	defaultServerMaxOpenConns = 10
+	// connMaxLifetime is long enough for connections to span warm invocations. RDS IAM tokens are
+	// minted per connection, so it doesn't need to track their 15-minute expiry.
+	connMaxLifetime = 5 * time.Minute
+)
+
+// sharedRepositories are the connection pools the pipelines use, keyed by connection string.
+// They are opened in init under Lambda, or on first use, and closed on shutdown.
+var (
+	sharedRepositoriesMu sync.Mutex
+	sharedRepositories   = map[string]*Repository{}
+)
+
+// runningInLambda reports whether the binary runs inside the Lambda runtime
+func runningInLambda() bool {
+	return os.Getenv("AWS_LAMBDA_RUNTIME_API") != ""
+}
+
+// init prepares the Lambda during its init phase, which provisioned concurrency runs ahead of the
+// first invocation, so invocations don't pay for the connection, secrets and TLS setup
+func init() {
+	if !runningInLambda() {
+		return
+	}
+	start := time.Now()
+	readiness := warmUp()
+	log.Printf("Warm-up finished in %dms: %+v", elapsedMs(start), readiness)
+}
+
+// maxOpenConns is the connection pool size (DB_MAX_OPEN_CONNS)
+func maxOpenConns() int {
+	if n, err := strconv.Atoi(os.Getenv("DB_MAX_OPEN_CONNS")); err == nil && n > 0 {
+		return n
+	}
+	if runningInLambda() {
+		return defaultLambdaMaxOpenConns
+	}
+	return defaultServerMaxOpenConns
+}
+
+// sharedRepository returns the connection pool for a connection string, opening it on first use.
+// Opening doesn't connect; callers Ping it.
+func sharedRepository(connectionString string) (*Repository, error) {
+	sharedRepositoriesMu.Lock()
+	defer sharedRepositoriesMu.Unlock()
+
+	if repo, ok := sharedRepositories[connectionString]; ok {
+		return repo, nil
+	}
+
+	var db *sql.DB
+	if dbIAMAuthEnabled() {
+		connector, err := newRDSIAMConnector(connectionString)
+		if err != nil {
+			return nil, err
+		}
+		db = sql.OpenDB(connector)
+	} else {
+		var err error
+		db, err = sql.Open("postgres", connectionString)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database connection: %v", err)
+		}
+	}
+
+	db.SetConnMaxLifetime(connMaxLifetime)
+	db.SetMaxOpenConns(maxOpenConns())
+	db.SetMaxIdleConns(maxOpenConns())
+
+	repo := NewRepository(db, queryTimeout())
+	sharedRepositories[connectionString] = repo
+	return repo, nil
+}
+
+// closeSharedRepositories closes every connection pool; called on SIGTERM and when the servers stop
+func closeSharedRepositories() {
+	sharedRepositoriesMu.Lock()
+	defer sharedRepositoriesMu.Unlock()
+
+	for connectionString, repo := range sharedRepositories {
+		if err := repo.Close(); err != nil {
+			log.Printf("Error closing database pool: %v", err)
+		}
+		delete(sharedRepositories, connectionString)
+	}
+}
+
+// Readiness reports which dependencies were prepared by warm-up. A failed check doesn't stop the
+// Lambda; the dependency is retried on first use.
+type Readiness struct {
+	Database string `json:"database"`
+	Secrets  string `json:"secrets"`
+}
+
+// warmUp opens the database pool with one verified connection, loads the Secrets Manager
+// credentials and creates the shared HTTP transports
+func warmUp() Readiness {
+	readiness := Readiness{Database: "ok", Secrets: "ok"}
+
+	// The pipeline normally loads .env itself; the transports read their settings from it
+	_ = godotenv.Load()
+	downloadTransport.get()
+	geminiTransport.get()
+	apiTransport.get()
+
+	pipeline, err := NewTranscriptionPipelineFromEnv()
+	if err == nil {
+		err = pipeline.ConnectToDatabase()
+	}
+	if err != nil {
+		readiness.Database = err.Error()
+	}
+
+	if err := loadSecrets(); err != nil {
+		readiness.Secrets = err.Error()
+	}
+	return readiness
+}
+
+// loadSecrets loads the recording and CRM credentials, Gemini tenant keys and Vertex AI access token
+// that are configured, so they're cached before the first call needs them
+func loadSecrets() error {
+	if _, err := loadRecordingAuth(); err != nil {
+		return err
+	}
+	if geminiBackend() == GeminiBackendVertex {
+		if _, err := vertexAccessToken(); err != nil {
+			return err
+		}
+	}
+	if secretID := os.Getenv("GEMINI_TENANT_KEYS_SECRET_ID"); secretID != "" {
+		geminiTenantKeysMu.Lock()
+		err := refreshGeminiTenantKeys(secretID)
+		geminiTenantKeysMu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	if secretID := os.Getenv("CRM_SECRET_ID"); secretID != "" {
+		crmCredentialsMu.Lock()
+		defer crmCredentialsMu.Unlock()
+		return refreshCRMCredentials(secretID)
+	}
+	return nil
+}
+
+// HandleWarmUp reports readiness for the "warmup" action, e.g. from a scheduled warmer. Once the
+// pool, secrets and transports exist this is little more than a database ping.
+func HandleWarmUp() LambdaResponse {
+	readiness := warmUp()
+	if readiness.Database != "ok" || readiness.Secrets != "ok" {
+		return LambdaResponse{StatusCode: 503, Body: readiness, Error: "not ready"}
+	}
+	return LambdaResponse{StatusCode: 200, Body: readiness}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	openAITranscriptionURL = "https://api.openai.com/v1/audio/transcriptions"
+	defaultWhisperModel    = "whisper-1"
+	// whisperMaxUploadBytes is the OpenAI audio upload limit
+	whisperMaxUploadBytes = 25 * 1024 * 1024
+)
+
+// WhisperTranscriber transcribes audio with OpenAI's audio transcription endpoint
+type WhisperTranscriber struct {
+	apiKey   string
+	model    string
+	language string
+}
+
+// whisperVerboseResponse represents the verbose_json response format
+type whisperVerboseResponse struct {
+	Language string           `json:"language"`
+	Duration float64          `json:"duration"`
+	Text     string           `json:"text"`
+	Segments []whisperSegment `json:"segments"`
+}
+
+type whisperSegment struct {
+	ID    int     `json:"id"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Text  string  `json:"text"`
+}
+
+// NewWhisperTranscriber creates a Whisper backend. OPENAI_TRANSCRIPTION_MODEL and
+// OPENAI_TRANSCRIPTION_LANGUAGE (ISO-639-1, e.g. "hi") optionally override the defaults.
+func NewWhisperTranscriber(apiKey string) (*WhisperTranscriber, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY is required for the openai transcription provider")
+	}
+
+	model := os.Getenv("OPENAI_TRANSCRIPTION_MODEL")
+	if model == "" {
+		model = defaultWhisperModel
+	}
+
+	return &WhisperTranscriber{
+		apiKey:   apiKey,
+		model:    model,
+		language: os.Getenv("OPENAI_TRANSCRIPTION_LANGUAGE"),
+	}, nil
+}
+
+// Name returns the provider name
+func (w *WhisperTranscriber) Name() string {
+	return ProviderOpenAI
+}
+
+// Transcribe uploads the audio as multipart/form-data and maps the verbose_json output into a Transcript
+func (w *WhisperTranscriber) Transcribe(audioContent []byte) (*Transcript, error) {
+	if len(audioContent) > whisperMaxUploadBytes {
+		return nil, fmt.Errorf("audio is %d bytes, exceeding the OpenAI upload limit of %d bytes", len(audioContent), whisperMaxUploadBytes)
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	fileWriter, err := writer.CreateFormFile("file", "recording"+audioFileExtension(audioContent))
+	if err != nil {
+		return nil, fmt.Errorf("error creating multipart file: %v", err)
+	}
+	if _, err := fileWriter.Write(audioContent); err != nil {
+		return nil, fmt.Errorf("error writing audio to multipart body: %v", err)
+	}
+
+	fields := [][2]string{
+		{"model", w.model},
+		{"response_format", "verbose_json"},
+		{"timestamp_granularities[]", "segment"},
+	}
+	if w.language != "" {
+		fields = append(fields, [2]string{"language", w.language})
+	}
+	for _, field := range fields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return nil, fmt.Errorf("error writing multipart field %s: %v", field[0], err)
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error finalizing multipart body: %v", err)
+	}
+
+	req, err := http.NewRequest("POST", openAITranscriptionURL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+w.apiKey)
+
+	resp, err := providerClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, providerAPIError("openai", resp.StatusCode, respBody)
+	}
+
+	var verbose whisperVerboseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&verbose); err != nil {
+		return nil, fmt.Errorf("error decoding response: %v", err)
+	}
+
+	return w.toTranscript(verbose), nil
+}
+
+// toTranscript maps Whisper segments onto the shared Transcript. Whisper doesn't diarize,
+// so every segment is attributed to an unknown speaker.
+func (w *WhisperTranscriber) toTranscript(verbose whisperVerboseResponse) *Transcript {
+	segments := make([]TranscriptSegment, 0, len(verbose.Segments))
+	for _, s := range verbose.Segments {
+		text := strings.TrimSpace(s.Text)
+		if text == "" {
+			continue
+		}
+		segments = append(segments, TranscriptSegment{
+			Speaker: SpeakerUnknown,
+			Start:   s.Start,
+			End:     s.End,
+			Text:    text,
+		})
+	}
+
+	text := strings.TrimSpace(verbose.Text)
+	if len(segments) > 0 {
+		text = formatTranscriptSegments(segments)
+	}
+
+	return &Transcript{
+		Text:     text,
+		Language: verbose.Language,
+		Duration: verbose.Duration,
+		Segments: segments,
+		Provider: ProviderOpenAI,
+		Model:    w.model,
+	}
+}
@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Steps of the Step Functions workflow (statemachine/call_pipeline.asl.json), each run by the
+// "step" action
+const (
+	StepFetch      = "fetch"
+	StepDownload   = "download"
+	StepTranscribe = "transcribe"
+	StepAnswer     = "answer"
+	StepSave       = "save"
+	// StepFail records a call whose task failed after its retries
+	StepFail = "fail"
+)
+
+// WorkflowState is the Step Functions state passed from step to step. The recording and the
+// transcription result are staged in S3 (WORKFLOW_S3_BUCKET), since states are limited to 256 KB.
+// An execution starts with just call_logsId and, optionally, reprocess.
+type WorkflowState struct {
+	CallLogsID string `json:"call_logsId"`
+	Reprocess  bool   `json:"reprocess,omitempty"`
+	// Next is the step to run next; empty once the call is finished
+	Next string `json:"next,omitempty"`
+	// StartedAt is when the fetch step started; TotalMs in the processing metadata counts from it
+	StartedAt time.Time `json:"startedAt"`
+	// AudioKey and ResultKey are the staged recording and TranscriptionResult
+	AudioKey  string `json:"audioKey,omitempty"`
+	ResultKey string `json:"resultKey,omitempty"`
+	// URLHash and ContentHash key the transcription cache entry saved with the analysis
+	URLHash     string             `json:"urlHash,omitempty"`
+	ContentHash string             `json:"contentHash,omitempty"`
+	Usage       TokenUsage         `json:"usage"`
+	Metadata    ProcessingMetadata `json:"metadata"`
+	// Result is the processing response once the call is saved or skipped, without the transcription
+	Result map[string]interface{} `json:"result,omitempty"`
+	// Error, ErrorCategory and StatusCode are set when the call failed permanently (404, 409, 422)
+	Error         string `json:"error,omitempty"`
+	ErrorCategory string `json:"errorCategory,omitempty"`
+	StatusCode    int    `json:"statusCode,omitempty"`
+	// Failure is what Step Functions caught once a task's retries ran out
+	Failure *WorkflowFailure `json:"failure,omitempty"`
+}
+
+// WorkflowFailure is a Step Functions error caught by the state machine
+type WorkflowFailure struct {
+	Error string `json:"Error"`
+	Cause string `json:"Cause"`
+}
+
+// workflowStorage is where the workflow stages recordings and transcription results
+type workflowStorage struct {
+	bucket string
+	prefix string
+}
+
+// workflowStorageFromEnv reads the staging location (WORKFLOW_S3_BUCKET, WORKFLOW_S3_PREFIX)
+func workflowStorageFromEnv() (workflowStorage, error) {
+	bucket := os.Getenv("WORKFLOW_S3_BUCKET")
+	if bucket == "" {
+		return workflowStorage{}, fmt.Errorf("WORKFLOW_S3_BUCKET is required for the step workflow")
+	}
+	prefix := strings.Trim(os.Getenv("WORKFLOW_S3_PREFIX"), "/")
+	if prefix == "" {
+		prefix = "workflow"
+	}
+	return workflowStorage{bucket: bucket, prefix: prefix}, nil
+}
+
+// key returns the key of a staged object, unique to the execution
+func (s workflowStorage) key(state *WorkflowState, name string) string {
+	return fmt.Sprintf("%s/%s/%d/%s", s.prefix, state.CallLogsID, state.StartedAt.UnixNano(), name)
+}
+
+// HandleWorkflowStep runs one step of a call's Step Functions workflow and returns the state for
+// the next. Failures worth retrying (downloads, providers, quota, the database) are returned as
+// errors so the task's Retry policy applies; permanent ones finish the workflow with the error in
+// the state.
+func (tp *TranscriptionPipeline) HandleWorkflowStep(step string, state *WorkflowState) (LambdaResponse, error) {
+	if state == nil || state.CallLogsID == "" {
+		return LambdaResponse{StatusCode: 400, Error: "workflow.call_logsId is required"}, nil
+	}
+	storage, err := workflowStorageFromEnv()
+	if err != nil {
+		return LambdaResponse{}, err
+	}
+
+	tp.reprocess = state.Reprocess
+	tp.startProcessingMetadata()
+	tp.errorContext = errorContext{CallLogsID: state.CallLogsID, Stage: step}
+	if state.StartedAt.IsZero() {
+		state.StartedAt = tp.metadata.started
+	}
+
+	if err := tp.ConnectToDatabase(); err != nil {
+		return LambdaResponse{}, fmt.Errorf("failed to connect to database: %v", err)
+	}
+	defer tp.CloseDatabase()
+
+	if step == StepFail {
+		tp.failWorkflow(state, storage)
+		return LambdaResponse{StatusCode: 500, Body: state, Error: state.Error}, nil
+	}
+
+	// Every step reads the call and its campaign's configuration again, rather than carrying them in the state
+	call, err := tp.fetchCall(state.CallLogsID, false, step == StepFetch)
+	if err == nil && step != StepFetch {
+		// Keep the stages timed by the earlier steps
+		tp.metadata, tp.metadata.started = state.Metadata, state.StartedAt
+		tp.usage = state.Usage
+	}
+	if err == nil {
+		err = tp.runWorkflowStep(step, state, call, storage)
+	}
+	state.Metadata, state.Usage = tp.metadata, tp.usage
+
+	if err != nil {
+		tp.reportError(err)
+		if errorRetryable(err) {
+			return LambdaResponse{}, err
+		}
+		state.Next, state.Error, state.ErrorCategory, state.StatusCode = "", err.Error(), errorCategory(err), errorStatusCode(err)
+		campaignID := ""
+		if call != nil {
+			campaignID = call.callData.CampaignID
+		}
+		tp.finishWorkflow(state, storage, campaignID, nil, err)
+	}
+	return LambdaResponse{StatusCode: 200, Body: state}, nil
+}
+
+// runWorkflowStep runs a step and sets the state's next step
+func (tp *TranscriptionPipeline) runWorkflowStep(step string, state *WorkflowState, call *callContext, storage workflowStorage) error {
+	provider := transcriptionProviderName(call.provider)
+
+	switch step {
+	case StepFetch:
+		// Calls that fail the pre-flight rules are skipped instead of analysed
+		if call.skipReason != "" {
+			result, analysis, err := tp.skipCall(call.callData, call.skipReason, nil)
+			if err != nil {
+				return err
+			}
+			tp.finishWorkflow(state, storage, call.callData.CampaignID, analysis, nil)
+			state.Result = workflowResult(result)
+			return nil
+		}
+		// Rows of an already processed call copy its analysis
+		if call.duplicate != nil {
+			result, analysis, err := tp.linkDuplicateCall(call.callData, call.duplicate)
+			if err != nil {
+				return err
+			}
+			tp.finishWorkflow(state, storage, call.callData.CampaignID, analysis, nil)
+			state.Result = workflowResult(result)
+			return nil
+		}
+		state.Next = StepDownload
+
+	case StepDownload:
+		// Multi-leg calls are downloaded leg by leg when they are transcribed
+		if len(call.callData.RecordingLegs) > 1 {
+			state.Next = StepTranscribe
+			return nil
+		}
+
+		questionsHash := tp.cacheQuestionsHash(call.questions, provider)
+		state.URLHash = sha256Hex([]byte(call.callData.RecordingURL))
+		if tp.useTranscriptionCache() {
+			if cached, err := tp.GetCachedTranscriptionByURL(state.URLHash, questionsHash); err == nil && cached != nil {
+				return tp.stageCachedResult(state, storage, cached, provider)
+			}
+		}
+
+		downloadStart := time.Now()
+		audioContent, err := tp.DownloadAudio(call.callData.RecordingURL)
+		tp.metadata.Stages.Download += elapsedMs(downloadStart)
+		if err != nil {
+			return &ProviderError{Err: fmt.Errorf("failed to download audio: %w", err)}
+		}
+		if len(audioContent) == 0 {
+			return &ProviderError{Err: fmt.Errorf("downloaded audio file is empty")}
+		}
+		tp.metadata.AudioBytes = len(audioContent)
+
+		state.ContentHash = sha256Hex(audioContent)
+		if tp.useTranscriptionCache() {
+			if cached, err := tp.GetCachedTranscriptionByContent(state.ContentHash, questionsHash); err == nil && cached != nil {
+				return tp.stageCachedResult(state, storage, cached, provider)
+			}
+		}
+
+		state.AudioKey = storage.key(state, "audio")
+		if err := s3PutObject(storage.bucket, state.AudioKey, "application/octet-stream", audioContent); err != nil {
+			return fmt.Errorf("error staging recording: %v", err)
+		}
+		state.Next = StepTranscribe
+
+	case StepTranscribe:
+		// Questions are answered in their own step, from the transcription
+		var result *TranscriptionResult
+		var err error
+		if len(call.callData.RecordingLegs) > 1 {
+			result, err = tp.TranscribeRecordingLegs(call.callData.RecordingLegs, nil, provider)
+		} else {
+			var audioContent []byte
+			audioContent, err = s3GetObject(storage.bucket, state.AudioKey)
+			if err != nil {
+				return fmt.Errorf("error reading staged recording: %v", err)
+			}
+			tp.requestBudget = nil
+			result, err = tp.transcribeAudio(audioContent, nil, provider)
+		}
+		if err != nil {
+			return &ProviderError{Err: err}
+		}
+		if err := tp.stageResult(state, storage, result); err != nil {
+			return err
+		}
+
+		state.Next = StepSave
+		if len(call.questions) > 0 && result.Transcription != "" && result.SkipReason == "" &&
+			(result.Disposition == "" || result.Disposition == DispositionConversation) {
+			state.Next = StepAnswer
+		}
+
+	case StepAnswer:
+		result, err := loadStagedResult(state, storage)
+		if err != nil {
+			return err
+		}
+		result.Answers, err = tp.AnswerQuestionsFromTranscript(result.Transcription, call.questions)
+		if err != nil {
+			return &ProviderError{Err: fmt.Errorf("failed to answer questions: %w", err)}
+		}
+		if err := tp.stageResult(state, storage, result); err != nil {
+			return err
+		}
+		state.Next = StepSave
+
+	case StepSave:
+		result, err := loadStagedResult(state, storage)
+		if err != nil {
+			return err
+		}
+		if result.Answers == nil {
+			result.Answers = map[string]string{}
+		}
+
+		// Failing to populate the cache doesn't fail the call
+		if tp.useTranscriptionCache() && state.ContentHash != "" && !result.CacheHit && result.cacheable(provider) {
+			_ = tp.SaveTranscriptionCache(state.URLHash, state.ContentHash, tp.cacheQuestionsHash(call.questions, provider), result)
+		}
+
+		response, analysis, err := tp.completeCall(call, result)
+		if err != nil {
+			return err
+		}
+		tp.finishWorkflow(state, storage, call.callData.CampaignID, analysis, nil)
+		state.Result = workflowResult(response)
+
+	default:
+		return fmt.Errorf("unknown workflow step %q", step)
+	}
+	return nil
+}
+
+// stageResult writes the transcription result for the following steps
+func (tp *TranscriptionPipeline) stageResult(state *WorkflowState, storage workflowStorage, result *TranscriptionResult) error {
+	body, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("error marshaling transcription result: %v", err)
+	}
+	state.ResultKey = storage.key(state, "result.json")
+	if err := s3PutObject(storage.bucket, state.ResultKey, "application/json", body); err != nil {
+		return fmt.Errorf("error staging transcription result: %v", err)
+	}
+	return nil
+}
+
+// stageCachedResult stages a cached transcription and goes straight to saving it
+func (tp *TranscriptionPipeline) stageCachedResult(state *WorkflowState, storage workflowStorage, cached *CachedTranscription, provider string) error {
+	result := &TranscriptionResult{Transcription: cached.Transcription, Answers: cached.Answers, Words: cached.Words, Provider: provider, CacheHit: true}
+	if err := tp.stageResult(state, storage, result); err != nil {
+		return err
+	}
+	state.Next = StepSave
+	return nil
+}
+
+// loadStagedResult reads the transcription result staged by an earlier step
+func loadStagedResult(state *WorkflowState, storage workflowStorage) (*TranscriptionResult, error) {
+	if state.ResultKey == "" {
+		return nil, fmt.Errorf("the workflow has no transcription result")
+	}
+	body, err := s3GetObject(storage.bucket, state.ResultKey)
+	if err != nil {
+		return nil, fmt.Errorf("error reading staged transcription result: %v", err)
+	}
+	var result TranscriptionResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("error parsing staged transcription result: %v", err)
+	}
+	return &result, nil
+}
+
+// failWorkflow records a call whose task failed after its retries
+func (tp *TranscriptionPipeline) failWorkflow(state *WorkflowState, storage workflowStorage) {
+	message := "workflow failed"
+	if state.Failure != nil {
+		// Lambda errors arrive as a JSON cause with the error message; timeouts as plain text
+		var cause struct {
+			ErrorMessage string `json:"errorMessage"`
+		}
+		if json.Unmarshal([]byte(state.Failure.Cause), &cause) == nil && cause.ErrorMessage != "" {
+			message = cause.ErrorMessage
+		} else if state.Failure.Cause != "" {
+			message = state.Failure.Cause
+		} else {
+			message = state.Failure.Error
+		}
+	}
+	state.Next, state.Error, state.StatusCode = "", message, 500
+
+	campaignID := ""
+	if callData, err := tp.GetCallData(state.CallLogsID); err == nil {
+		campaignID = callData.CampaignID
+	}
+	tp.finishWorkflow(state, storage, campaignID, nil, errors.New(message))
+}
+
+// finishWorkflow records the outcome as ProcessCall does, publishes it and removes the staged
+// objects. The bucket should also expire them with a lifecycle rule, for executions that are
+// aborted.
+func (tp *TranscriptionPipeline) finishWorkflow(state *WorkflowState, storage workflowStorage, campaignID string, analysis *CallAnalysisData, processErr error) {
+	state.Next = ""
+
+	// Duplicate executions aren't processing attempts
+	if !errors.Is(processErr, ErrAlreadyProcessed) && !errors.Is(processErr, ErrAnalysisConflict) {
+		tp.RecordProcessingRun(state.CallLogsID, analysis, processErr)
+		tp.PublishAnalysisEvent(state.CallLogsID, campaignID, analysis, processErr)
+	}
+
+	for _, key := range []string{state.AudioKey, state.ResultKey} {
+		if key == "" {
+			continue
+		}
+		if err := s3DeleteObject(storage.bucket, key); err != nil {
+			log.Printf("Error deleting staged object %s: %v", key, err)
+		}
+	}
+	state.AudioKey, state.ResultKey = "", ""
+}
+
+// workflowResult is the processing response kept in the state, without the transcription, which
+// could outgrow the state's size limit
+func workflowResult(result map[string]interface{}) map[string]interface{} {
+	trimmed := make(map[string]interface{}, len(result))
+	for key, value := range result {
+		if key != "transcription" {
+			trimmed[key] = value
+		}
+	}
+	return trimmed
+}